@@ -1,6 +1,7 @@
 package decrypt
 
 import (
+	"bufio"
 	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
@@ -29,81 +30,114 @@ const (
 
 // DecryptDB decrypts a single database file
 func DecryptDB(srcPath, dstPath string, key []byte) error {
+	_, _, err := decryptDB(srcPath, dstPath, key, nil, nil)
+	return err
+}
+
+// decryptDB decrypts srcPath into dstPath page by page and returns the page fingerprints of the source.
+// When base is given, pages whose fingerprint is unchanged since the last run are copied from
+// the previous output instead of being decrypted. Plaintext sources are copied and return no state.
+func decryptDB(srcPath, dstPath string, key []byte, base *baseFile, keys *keyCache) (*pageState, fileStats, error) {
+	var stats fileStats
 	f, err := os.Open(srcPath)
 	if err != nil {
-		return fmt.Errorf("open file failed: %v", err)
+		return nil, stats, fmt.Errorf("open file failed: %v", err)
 	}
 	defer f.Close()
 
 	firstPage := make([]byte, PageSize)
 	n, err := io.ReadFull(f, firstPage)
 	if err != nil {
-		return fmt.Errorf("read first page failed: %v", err)
+		return nil, stats, fmt.Errorf("read first page failed: %v", err)
 	}
 	if n != PageSize {
-		return fmt.Errorf("file too small, expected at least %d bytes", PageSize)
+		return nil, stats, fmt.Errorf("file too small, expected at least %d bytes", PageSize)
 	}
 
 	if IsSQLiteHeader(firstPage) {
-		return copyFile(srcPath, dstPath)
+		return nil, stats, copyFile(srcPath, dstPath)
 	}
 
 	salt := firstPage[:SaltSize]
-	encKey, macKey := deriveKeys(key, salt)
-
-	outF, err := os.Create(dstPath)
-	if err != nil {
-		return fmt.Errorf("create output file failed: %v", err)
-	}
-	defer outF.Close()
-
-	if _, err := outF.Write([]byte(SQLiteHeader)); err != nil {
-		return err
-	}
+	encKey, macKey := keys.get(key, salt)
 
 	fileSize, _ := GetFileSize(srcPath)
 	totalPages := fileSize / PageSize
 	if fileSize%PageSize != 0 {
 		totalPages++
 	}
+	state := newPageState(salt, macKey, totalPages)
 
-	decryptedFirstPage, err := decryptPage(firstPage, encKey, macKey, 1)
-	if err != nil {
-		return fmt.Errorf("decrypt page 1 failed: %v", err)
+	// 上一次的输出只有在盐和密钥都不变时才能复用
+	var prev *os.File
+	if base != nil && base.state.compatible(state) {
+		if prev, err = os.Open(base.path); err == nil {
+			defer prev.Close()
+		} else {
+			prev = nil
+		}
 	}
 
-	// Reset to start to write header + decrypted page 1 body
-	outF.Seek(0, 0)
-	outF.Truncate(0)
-	outF.Write([]byte(SQLiteHeader))
-	if _, err := outF.Write(decryptedFirstPage); err != nil {
-		return err
+	outF, err := os.Create(dstPath)
+	if err != nil {
+		return nil, stats, fmt.Errorf("create output file failed: %v", err)
 	}
-
-	buf := make([]byte, PageSize)
-	for i := int64(1); i < totalPages; i++ {
-		n, err := io.ReadFull(f, buf)
-		if err != nil {
-			if err == io.EOF {
-				break
+	defer outF.Close()
+	w := bufio.NewWriterSize(outF, 64*PageSize)
+
+	buf := firstPage
+	out := make([]byte, PageSize)
+	for i := int64(0); i < totalPages; i++ {
+		if i > 0 {
+			if _, err := io.ReadFull(f, buf); err != nil {
+				if err == io.EOF {
+					break
+				}
+				return nil, stats, err
 			}
-			return err
 		}
+		stats.pages++
 
-		if isAllZero(buf[:n]) {
-			outF.Write(buf[:n])
+		if i > 0 && isAllZero(buf) {
+			state.add(zeroFingerprint)
+			if _, err := w.Write(buf); err != nil {
+				return nil, stats, err
+			}
 			continue
 		}
 
+		fp := pageFingerprint(buf)
+		state.add(fp)
+		if prev != nil && base.state.unchanged(i, fp) {
+			if _, err := prev.ReadAt(out, i*PageSize); err == nil {
+				stats.reused++
+				if _, err := w.Write(out); err != nil {
+					return nil, stats, err
+				}
+				continue
+			}
+			// 上一次的输出不完整时退回到解密
+		}
+
 		decrypted, err := decryptPage(buf, encKey, macKey, i+1)
 		if err != nil {
-			return fmt.Errorf("decrypt page %d failed: %v", i+1, err)
+			return nil, stats, fmt.Errorf("decrypt page %d failed: %v", i+1, err)
+		}
+		stats.decrypted++
+		if i == 0 {
+			if _, err := w.Write([]byte(SQLiteHeader)); err != nil {
+				return nil, stats, err
+			}
+		}
+		if _, err := w.Write(decrypted); err != nil {
+			return nil, stats, err
 		}
-
-		outF.Write(decrypted)
 	}
 
-	return nil
+	if err := w.Flush(); err != nil {
+		return nil, stats, err
+	}
+	return state, stats, nil
 }
 
 func deriveKeys(key, salt []byte) (encKey, macKey []byte) {
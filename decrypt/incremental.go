@@ -0,0 +1,201 @@
+package decrypt
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// StateDirName is the directory under the output dir holding the page fingerprints of each database
+	StateDirName = ".decrypt"
+	// stateExt is the extension of a page fingerprint file
+	stateExt = ".pages"
+	// stateMagic identifies the page fingerprint file format
+	stateMagic = "WTPS\x01"
+
+	fingerprintSize = 16
+	keyIDSize       = 8
+)
+
+// fingerprint is the leading bytes of a page's stored HMAC. SQLCipher writes a fresh IV
+// every time a page is rewritten, so the HMAC changes whenever the page does.
+type fingerprint [fingerprintSize]byte
+
+// zeroFingerprint marks an all-zero (unused) page
+var zeroFingerprint fingerprint
+
+// pageState records the page fingerprints of an encrypted database at the time it was decrypted
+type pageState struct {
+	salt  [SaltSize]byte
+	keyID [keyIDSize]byte // 派生的 HMAC 密钥的摘要，密钥变化时不复用上一次的输出
+	pages []fingerprint
+}
+
+// baseFile is the previous decrypted output of a database and the state it was decrypted from
+type baseFile struct {
+	path  string
+	state *pageState
+}
+
+// fileStats counts the pages of one database
+type fileStats struct {
+	pages     int64
+	decrypted int64
+	reused    int64
+}
+
+// Stats summarizes a decryption run
+type Stats struct {
+	Files          int           `json:"files"`           // 成功处理的文件数
+	Pages          int64         `json:"pages"`           // 加密数据库的总页数
+	DecryptedPages int64         `json:"decrypted_pages"` // 实际解密的页数
+	ReusedPages    int64         `json:"reused_pages"`    // 与上一次相同、直接复用上一次输出的页数
+	KeptFiles      int           `json:"kept_files"`      // 解密失败、沿用上一次结果的文件数
+	BytesDecrypted int64         `json:"bytes_decrypted"`
+	Duration       time.Duration `json:"-"`
+}
+
+func newPageState(salt, macKey []byte, totalPages int64) *pageState {
+	s := &pageState{pages: make([]fingerprint, 0, totalPages)}
+	copy(s.salt[:], salt)
+	sum := sha256.Sum256(macKey)
+	copy(s.keyID[:], sum[:])
+	return s
+}
+
+func (s *pageState) add(fp fingerprint) {
+	s.pages = append(s.pages, fp)
+}
+
+// compatible reports whether pages decrypted under s can be reused for a file with state o
+func (s *pageState) compatible(o *pageState) bool {
+	return s.salt == o.salt && s.keyID == o.keyID
+}
+
+// unchanged reports whether page i (0-based) had fingerprint fp in the previous run
+func (s *pageState) unchanged(i int64, fp fingerprint) bool {
+	return i < int64(len(s.pages)) && s.pages[i] == fp
+}
+
+func pageFingerprint(page []byte) fingerprint {
+	var fp fingerprint
+	dataEnd := PageSize - ReserveSize + IVSize
+	copy(fp[:], page[dataEnd:dataEnd+fingerprintSize])
+	return fp
+}
+
+// statePath returns where the page fingerprints of the database at rel are kept under dir
+func statePath(dir, rel string) string {
+	return filepath.Join(dir, StateDirName, rel+stateExt)
+}
+
+// loadPageState reads a page fingerprint file, returning nil if it is missing or malformed
+func loadPageState(path string) *pageState {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	r := bytes.NewReader(data)
+	magic := make([]byte, len(stateMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != stateMagic {
+		return nil
+	}
+	s := &pageState{}
+	var count uint32
+	if _, err := io.ReadFull(r, s.salt[:]); err != nil {
+		return nil
+	}
+	if _, err := io.ReadFull(r, s.keyID[:]); err != nil {
+		return nil
+	}
+	if err := binary.Read(r, binary.LittleEndian, &count); err != nil || int64(count)*fingerprintSize != int64(r.Len()) {
+		return nil
+	}
+	s.pages = make([]fingerprint, count)
+	for i := range s.pages {
+		_, _ = io.ReadFull(r, s.pages[i][:])
+	}
+	return s
+}
+
+// save writes the page fingerprints to path
+func (s *pageState) save(path string) error {
+	if err := EnsureDir(filepath.Dir(path)); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	buf.Grow(len(stateMagic) + SaltSize + keyIDSize + 4 + len(s.pages)*fingerprintSize)
+	buf.WriteString(stateMagic)
+	buf.Write(s.salt[:])
+	buf.Write(s.keyID[:])
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(len(s.pages)))
+	for _, fp := range s.pages {
+		buf.Write(fp[:])
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("write page state failed: %v", err)
+	}
+	return nil
+}
+
+// keyCache holds the page keys derived during one RunTaskIncremental call. Derivation runs
+// IterCount PBKDF2 rounds per salt; the cache is dropped with the run so derived keys do not
+// stay in memory between syncs.
+type keyCache struct {
+	mu   sync.Mutex
+	keys map[string][2][]byte
+}
+
+func newKeyCache() *keyCache {
+	return &keyCache{keys: make(map[string][2][]byte)}
+}
+
+// get returns the page keys for key and salt, deriving them only on first use.
+// A nil cache derives the keys every time.
+func (c *keyCache) get(key, salt []byte) (encKey, macKey []byte) {
+	if c == nil {
+		return deriveKeys(key, salt)
+	}
+	id := string(key) + string(salt)
+	c.mu.Lock()
+	k, ok := c.keys[id]
+	c.mu.Unlock()
+	if ok {
+		return k[0], k[1]
+	}
+
+	// 派生在锁外进行，不同数据库的首次派生可以并行
+	encKey, macKey = deriveKeys(key, salt)
+	c.mu.Lock()
+	c.keys[id] = [2][]byte{encKey, macKey}
+	c.mu.Unlock()
+	return encKey, macKey
+}
+
+// loadBase returns the previous output of rel under prevDir together with its page fingerprints.
+// It returns nil when either is missing, or when the output was modified after its fingerprints
+// were saved: its pages may then no longer be the plaintext of the recorded source pages.
+func loadBase(prevDir, rel string) *baseFile {
+	path := filepath.Join(prevDir, rel)
+	stPath := statePath(prevDir, rel)
+	out, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+	st, err := os.Stat(stPath)
+	if err != nil || out.ModTime().After(st.ModTime()) {
+		return nil
+	}
+	state := loadPageState(stPath)
+	if state == nil {
+		return nil
+	}
+	return &baseFile{path: path, state: state}
+}
@@ -0,0 +1,211 @@
+package decrypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// encryptPage builds an encrypted page the way WeChat writes it: AES-CBC body, random IV and HMAC-SHA512
+func encryptPage(t *testing.T, plain, salt, encKey, macKey []byte, pageNum int64) []byte {
+	page := make([]byte, PageSize)
+	offset := 0
+	if pageNum == 1 {
+		offset = SaltSize
+		copy(page, salt)
+	}
+	iv := page[PageSize-ReserveSize : PageSize-ReserveSize+IVSize]
+	if _, err := rand.Read(iv); err != nil {
+		t.Fatal(err)
+	}
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(page[offset:PageSize-ReserveSize], plain[offset:PageSize-ReserveSize])
+
+	dataEnd := PageSize - ReserveSize + IVSize
+	mac := hmac.New(sha512.New, macKey)
+	mac.Write(page[offset:dataEnd])
+	pageNo := make([]byte, 4)
+	binary.LittleEndian.PutUint32(pageNo, uint32(pageNum))
+	mac.Write(pageNo)
+	copy(page[dataEnd:], mac.Sum(nil))
+	return page
+}
+
+func TestRunTaskIncremental(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, KeySize)
+	salt := bytes.Repeat([]byte{0x07}, SaltSize)
+	encKey, macKey := deriveKeys(key, salt)
+
+	randomPage := func() []byte {
+		p := make([]byte, PageSize)
+		if _, err := rand.Read(p); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	// 5 页，第 4 页为未使用的全零页
+	var pages [][]byte
+	for i := int64(1); i <= 5; i++ {
+		if i == 4 {
+			pages = append(pages, make([]byte, PageSize))
+			continue
+		}
+		pages = append(pages, encryptPage(t, randomPage(), salt, encKey, macKey, i))
+	}
+
+	srcDir := t.TempDir()
+	dbPath := filepath.Join(srcDir, "db_storage", "message", "message_0.db")
+	writeDB := func() {
+		if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(dbPath, bytes.Join(pages, nil), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeDB()
+	keyStr := hex.EncodeToString(key)
+
+	out1 := filepath.Join(t.TempDir(), "out1")
+	stats, err := RunTaskIncremental(srcDir, keyStr, "", out1)
+	if err != nil {
+		t.Fatalf("RunTaskIncremental failed: %v", err)
+	}
+	if stats.Files != 1 || stats.Pages != 5 || stats.DecryptedPages != 4 || stats.ReusedPages != 0 || stats.BytesDecrypted != 4*PageSize {
+		t.Errorf("unexpected full run stats: %+v", stats)
+	}
+	if _, err := os.Stat(statePath(out1, filepath.Join("message", "message_0.db"))); err != nil {
+		t.Errorf("page state not written: %v", err)
+	}
+
+	// 改写第 3 页并追加第 6 页
+	pages[2] = encryptPage(t, randomPage(), salt, encKey, macKey, 3)
+	pages = append(pages, encryptPage(t, randomPage(), salt, encKey, macKey, 6))
+	writeDB()
+
+	out2 := filepath.Join(t.TempDir(), "out2")
+	stats, err = RunTaskIncremental(srcDir, keyStr, out1, out2)
+	if err != nil {
+		t.Fatalf("incremental run failed: %v", err)
+	}
+	if stats.Pages != 6 || stats.DecryptedPages != 2 || stats.ReusedPages != 3 || stats.BytesDecrypted != 2*PageSize {
+		t.Errorf("unexpected incremental stats: %+v", stats)
+	}
+
+	full := filepath.Join(t.TempDir(), "full.db")
+	if err := DecryptDB(dbPath, full, key); err != nil {
+		t.Fatalf("DecryptDB failed: %v", err)
+	}
+	want, _ := os.ReadFile(full)
+	got, _ := os.ReadFile(filepath.Join(out2, "message", "message_0.db"))
+	if len(want) != 6*PageSize || !bytes.Equal(got, want) {
+		t.Errorf("incremental output differs from full decryption (%d vs %d bytes)", len(got), len(want))
+	}
+	if !IsSQLiteHeader(got) {
+		t.Error("output should start with the SQLite header")
+	}
+
+	// 密钥变化后不复用上一次的输出
+	if state := loadPageState(statePath(out2, filepath.Join("message", "message_0.db"))); state == nil || len(state.pages) != 6 {
+		t.Fatalf("unexpected page state: %+v", state)
+	}
+	other := newPageState(salt, bytes.Repeat([]byte{0x01}, KeySize), 6)
+	if loadPageState(statePath(out2, filepath.Join("message", "message_0.db"))).compatible(other) {
+		t.Error("state derived from another key should not be reusable")
+	}
+
+	// 上一次的输出在保存页指纹之后被改动过，不再从中复用页面
+	out2DB := filepath.Join(out2, "message", "message_0.db")
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(out2DB, later, later); err != nil {
+		t.Fatal(err)
+	}
+	out3 := filepath.Join(t.TempDir(), "out3")
+	stats, err = RunTaskIncremental(srcDir, keyStr, out2, out3)
+	if err != nil {
+		t.Fatalf("run after modified output failed: %v", err)
+	}
+	if stats.ReusedPages != 0 || stats.DecryptedPages != 5 {
+		t.Errorf("modified output should not be reused: %+v", stats)
+	}
+}
+
+func TestRunTaskIncremental_KeepsPreviousOnFailure(t *testing.T) {
+	key := bytes.Repeat([]byte{0x24}, KeySize)
+	salt := bytes.Repeat([]byte{0x09}, SaltSize)
+	encKey, macKey := deriveKeys(key, salt)
+	keyStr := hex.EncodeToString(key)
+
+	srcDir := t.TempDir()
+	write := func(rel string, corrupt bool) {
+		var pages [][]byte
+		for i := int64(1); i <= 2; i++ {
+			plain := make([]byte, PageSize)
+			if _, err := rand.Read(plain); err != nil {
+				t.Fatal(err)
+			}
+			pages = append(pages, encryptPage(t, plain, salt, encKey, macKey, i))
+		}
+		if corrupt {
+			// 半写入的页：密文与 HMAC 不一致
+			pages[1][100] ^= 0xff
+		}
+		path := filepath.Join(srcDir, "db_storage", rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, bytes.Join(pages, nil), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	msgRel := filepath.Join("message", "message_0.db")
+	contactRel := filepath.Join("contact", "contact.db")
+	write(msgRel, false)
+	write(contactRel, false)
+
+	out1 := filepath.Join(t.TempDir(), "out1")
+	if _, err := RunTaskIncremental(srcDir, keyStr, "", out1); err != nil {
+		t.Fatalf("first run failed: %v", err)
+	}
+
+	write(contactRel, true)
+	out2 := filepath.Join(t.TempDir(), "out2")
+	stats, err := RunTaskIncremental(srcDir, keyStr, out1, out2)
+	if err != nil {
+		t.Fatalf("run with a corrupt file should keep the previous copy, got %v", err)
+	}
+	if stats.Files != 1 || stats.KeptFiles != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	want, _ := os.ReadFile(filepath.Join(out1, contactRel))
+	got, err := os.ReadFile(filepath.Join(out2, contactRel))
+	if err != nil || !bytes.Equal(got, want) {
+		t.Errorf("contact.db should be the previous decrypted copy (err %v)", err)
+	}
+	if loadPageState(statePath(out2, contactRel)) == nil {
+		t.Error("page state of the kept file should be carried over")
+	}
+
+	// 没有上一次的结果时，失败的文件不会出现在输出中，其余文件照常解密
+	out3 := filepath.Join(t.TempDir(), "out3")
+	stats, err = RunTaskIncremental(srcDir, keyStr, "", out3)
+	if err != nil || stats.Files != 1 || stats.KeptFiles != 0 {
+		t.Fatalf("unexpected result without previous output: %+v, %v", stats, err)
+	}
+	if fileExists(filepath.Join(out3, contactRel)) {
+		t.Error("failed file should not be written without a previous copy")
+	}
+}
@@ -6,28 +6,47 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 )
 
 // RunTask executes the decryption process with provided parameters.
 func RunTask(srcDir, keyStr string) (int, string, error) {
+	return RunTaskTo(srcDir, keyStr, "data")
+}
+
+// RunTaskTo decrypts all databases under srcDir into outDir, keeping the relative layout.
+func RunTaskTo(srcDir, keyStr, outDir string) (int, string, error) {
+	stats, err := RunTaskIncremental(srcDir, keyStr, "", outDir)
+	if stats == nil {
+		return 0, "", err
+	}
+	return stats.Files, outDir, err
+}
+
+// RunTaskIncremental decrypts all databases under srcDir into outDir like RunTaskTo.
+// prevDir is the output of a previous run (may be empty): pages that have not changed since
+// that run are copied from it instead of being decrypted. Page fingerprints are written under
+// outDir/StateDirName so that the next run can do the same.
+func RunTaskIncremental(srcDir, keyStr, prevDir, outDir string) (*Stats, error) {
+	start := time.Now()
 	if keyStr == "" {
-		return 0, "", fmt.Errorf("数据库密钥未获取,请先获取密钥")
+		return nil, fmt.Errorf("数据库密钥未获取,请先获取密钥")
 	}
 
 	if srcDir == "" {
-		return 0, "", fmt.Errorf("微信存储路径未配置，请先配置存储路径")
+		return nil, fmt.Errorf("微信存储路径未配置，请先配置存储路径")
 	}
 
 	// 检查 srcDir 是否存在且为目录
 	stat, err := os.Stat(srcDir)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return 0, "", fmt.Errorf("指定的微信存储路径不存在: %s", srcDir)
+			return nil, fmt.Errorf("指定的微信存储路径不存在: %s", srcDir)
 		}
-		return 0, "", fmt.Errorf("无法访问微信存储路径: %v", err)
+		return nil, fmt.Errorf("无法访问微信存储路径: %v", err)
 	}
 	if !stat.IsDir() {
-		return 0, "", fmt.Errorf("指定的微信存储路径不是一个目录: %s", srcDir)
+		return nil, fmt.Errorf("指定的微信存储路径不是一个目录: %s", srcDir)
 	}
 
 	// 自动拼接 db_storage 目录进行扫描
@@ -35,12 +54,11 @@ func RunTask(srcDir, keyStr string) (int, string, error) {
 
 	key, err := DecodeHexKey(keyStr)
 	if err != nil {
-		return 0, "", fmt.Errorf("invalid key: %v", err)
+		return nil, fmt.Errorf("invalid key: %v", err)
 	}
 
-	outDir := "data"
 	if err := EnsureDir(outDir); err != nil {
-		return 0, "", fmt.Errorf("create output dir failed: %v", err)
+		return nil, fmt.Errorf("create output dir failed: %v", err)
 	}
 
 	var dbFiles []string
@@ -74,19 +92,21 @@ func RunTask(srcDir, keyStr string) (int, string, error) {
 	}
 
 	if len(dbFiles) == 0 {
-		return 0, "", fmt.Errorf("在指定目录及其子目录下未找到任何微信数据库文件(.db)，请检查路径是否正确")
+		return nil, fmt.Errorf("在指定目录及其子目录下未找到任何微信数据库文件(.db)，请检查路径是否正确")
 	}
 
 	if err != nil {
-		return 0, "", fmt.Errorf("扫描目录失败: %v", err)
+		return nil, fmt.Errorf("扫描目录失败: %v", err)
 	}
 
 	var wg sync.WaitGroup
 	sem := make(chan struct{}, 8)
 
-	successCount := 0
+	stats := &Stats{}
+	keys := newKeyCache()
 	var mu sync.Mutex
 	var firstErr error
+	var lostErr error // 解密失败且无法沿用上一次结果的错误，此时不能切换到新数据
 
 	for _, src := range dbFiles {
 		wg.Add(1)
@@ -115,28 +135,107 @@ func RunTask(srcDir, keyStr string) (int, string, error) {
 				return
 			}
 
-			if err := DecryptDB(src, dst, key); err != nil {
+			var base *baseFile
+			if prevDir != "" {
+				base = loadBase(prevDir, rel)
+			}
+
+			state, fstats, err := decryptDB(src, dst, key, base, keys)
+			if err != nil {
+				// 不保留解密了一半的文件；源文件可能被锁定或正在写入，沿用上一次的结果，避免新快照中缺少该数据库
+				_ = os.Remove(dst)
+				kept, keepErr := keepPrevious(prevDir, outDir, rel)
 				mu.Lock()
 				if firstErr == nil {
 					firstErr = err
 				}
-				fmt.Printf("Failed to decrypt %s: %v\n", rel, err)
-				mu.Unlock()
-			} else {
-				mu.Lock()
-				successCount++
+				switch {
+				case kept:
+					stats.KeptFiles++
+					fmt.Printf("Failed to decrypt %s, keeping previous copy: %v\n", rel, err)
+				case keepErr != nil:
+					if lostErr == nil {
+						lostErr = fmt.Errorf("解密 %s 失败 (%v)，且无法沿用上一次的数据: %v", rel, err, keepErr)
+					}
+				default:
+					fmt.Printf("Failed to decrypt %s: %v\n", rel, err)
+				}
 				mu.Unlock()
+				return
+			}
+			if state != nil {
+				// 指纹写入失败只影响下一次的增量解密
+				if err := state.save(statePath(outDir, rel)); err != nil {
+					fmt.Printf("Failed to save page state for %s: %v\n", rel, err)
+				}
 			}
+
+			mu.Lock()
+			stats.Files++
+			stats.Pages += fstats.pages
+			stats.DecryptedPages += fstats.decrypted
+			stats.ReusedPages += fstats.reused
+			mu.Unlock()
 		}(src)
 	}
 
 	wg.Wait()
 
-	if successCount == 0 && len(dbFiles) > 0 {
-		return 0, outDir, fmt.Errorf("failed to decrypt any files (found %d). First error: %v", len(dbFiles), firstErr)
+	stats.BytesDecrypted = stats.DecryptedPages * PageSize
+	stats.Duration = time.Since(start)
+	if lostErr != nil {
+		return stats, lostErr
+	}
+	if stats.Files == 0 && len(dbFiles) > 0 {
+		return stats, fmt.Errorf("failed to decrypt any files (found %d). First error: %v", len(dbFiles), firstErr)
 	}
 
-	return successCount, outDir, nil
+	return stats, nil
+}
+
+// keepPrevious links (or copies) the previous output of rel and its page state from prevDir into outDir.
+// It returns false without error when prevDir has no copy of the file.
+func keepPrevious(prevDir, outDir, rel string) (bool, error) {
+	if prevDir == "" {
+		return false, nil
+	}
+	prev := filepath.Join(prevDir, rel)
+	if _, err := os.Stat(prev); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if err := linkOrCopy(prev, filepath.Join(outDir, rel)); err != nil {
+		return false, err
+	}
+	if state := statePath(prevDir, rel); fileExists(state) {
+		dst := statePath(outDir, rel)
+		if err := EnsureDir(filepath.Dir(dst)); err != nil {
+			return false, err
+		}
+		if err := linkOrCopy(state, dst); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// linkOrCopy replaces dst with a hard link to src, copying when linking is not possible
+// (e.g. across volumes). The decrypted databases are never modified in place, so sharing is safe.
+func linkOrCopy(src, dst string) error {
+	if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+	return copyFile(src, dst)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
 }
 
 func loadEnvFile(path string) error {
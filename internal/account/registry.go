@@ -0,0 +1,313 @@
+package account
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+// DefaultID 是由 .env 配置构成的默认账号，始终存在且不写入账号文件
+const DefaultID = "default"
+
+var (
+	ErrNotFound      = errors.New("账号不存在")
+	ErrExists        = errors.New("账号已存在")
+	ErrDefault       = errors.New("默认账号由 .env 配置，不能修改或删除")
+	ErrActive        = errors.New("不能删除当前使用中的账号")
+	ErrInvalidID     = errors.New("账号 ID 只能包含字母、数字、下划线和连字符")
+	ErrWorkDirInUse  = errors.New("工作目录已被其他账号使用")
+	ErrWorkDirNeeded = errors.New("工作目录不能为空")
+)
+
+// idPattern 限制账号 ID 的字符，ID 会用于拼接备份历史等文件名
+var idPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// scopedKeys 是按账号保存的配置项；其余配置 (AI、密码等) 为全局配置，仍写入 .env
+var scopedKeys = map[string]bool{
+	"WECHAT_DB_SRC_PATH":    true,
+	"WECHAT_DB_KEY":         true,
+	"IMAGE_KEY":             true,
+	"XOR_KEY":               true,
+	"SYNC_ENABLED":          true,
+	"SYNC_INTERVAL_MINUTES": true,
+	"BACKUP_ENABLED":        true,
+	"BACKUP_INTERVAL_HOURS": true,
+	"BACKUP_PATH":           true,
+	"BACKUP_FORMAT":         true,
+	"BACKUP_LABEL":          true,
+	"HIDDEN_SESSIONS":       true,
+}
+
+// Scoped 判断配置项是否按账号保存
+func Scoped(key string) bool {
+	return scopedKeys[key]
+}
+
+// Account 是一个微信账号 (wxid) 的数据目录、密钥和源数据路径
+type Account struct {
+	ID              string            `json:"id"` // 通常为 wxid
+	Name            string            `json:"name"`
+	WorkDir         string            `json:"work_dir"`
+	WechatDbSrcPath string            `json:"wechat_db_src_path"`
+	WechatDbKey     string            `json:"wechat_db_key"`
+	ImageKey        string            `json:"image_key"`
+	XorKey          string            `json:"xor_key"`
+	Settings        map[string]string `json:"settings,omitempty"` // 同步、备份等按账号保存的其他配置
+}
+
+// Get 返回按账号保存的配置项
+func (a *Account) Get(key string) string {
+	switch key {
+	case "WECHAT_DB_SRC_PATH":
+		return a.WechatDbSrcPath
+	case "WECHAT_DB_KEY":
+		return a.WechatDbKey
+	case "IMAGE_KEY":
+		return a.ImageKey
+	case "XOR_KEY":
+		return a.XorKey
+	}
+	return a.Settings[key]
+}
+
+// Set 修改按账号保存的配置项
+func (a *Account) Set(key, value string) {
+	switch key {
+	case "WECHAT_DB_SRC_PATH":
+		a.WechatDbSrcPath = value
+	case "WECHAT_DB_KEY":
+		a.WechatDbKey = value
+	case "IMAGE_KEY":
+		a.ImageKey = value
+	case "XOR_KEY":
+		a.XorKey = value
+	default:
+		if a.Settings == nil {
+			a.Settings = make(map[string]string)
+		}
+		a.Settings[key] = value
+	}
+}
+
+// registryData 持久化数据结构
+type registryData struct {
+	Active   string    `json:"active"`
+	Accounts []Account `json:"accounts"`
+}
+
+// Registry 账号注册表，保存在 accounts.json 中
+type Registry struct {
+	mu       sync.RWMutex
+	def      Account
+	data     registryData
+	filePath string
+}
+
+// NewRegistry 从 dataDir/accounts.json 加载账号注册表，def 为由 .env 构成的默认账号
+func NewRegistry(dataDir string, def Account) (*Registry, error) {
+	def.ID = DefaultID
+	if def.Name == "" {
+		def.Name = "默认账号"
+	}
+	r := &Registry{
+		def:      def,
+		filePath: filepath.Join(dataDir, "accounts.json"),
+	}
+	if err := r.load(); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if _, ok := r.find(r.data.Active); !ok {
+		r.data.Active = DefaultID
+	}
+	return r, nil
+}
+
+// load 从文件加载数据
+func (r *Registry) load() error {
+	data, err := os.ReadFile(r.filePath)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &r.data)
+}
+
+// save 将数据写入文件 (先写临时文件再重命名)
+func (r *Registry) save() error {
+	if err := os.MkdirAll(filepath.Dir(r.filePath), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(r.data, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := r.filePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, r.filePath)
+}
+
+// find 按 ID 查找账号并返回副本，调用方需持有锁
+func (r *Registry) find(id string) (Account, bool) {
+	if id == DefaultID {
+		return r.def, true
+	}
+	for _, a := range r.data.Accounts {
+		if a.ID == id {
+			a.Settings = cloneSettings(a.Settings)
+			return a, true
+		}
+	}
+	return Account{}, false
+}
+
+// List 返回所有账号，默认账号在最前
+func (r *Registry) List() []Account {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]Account, 0, len(r.data.Accounts)+1)
+	out = append(out, r.def)
+	for _, a := range r.data.Accounts {
+		a.Settings = cloneSettings(a.Settings)
+		out = append(out, a)
+	}
+	sort.SliceStable(out[1:], func(i, j int) bool { return out[i+1].ID < out[j+1].ID })
+	return out
+}
+
+// Get 按 ID 返回账号
+func (r *Registry) Get(id string) (Account, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	a, ok := r.find(id)
+	if !ok {
+		return Account{}, ErrNotFound
+	}
+	return a, nil
+}
+
+// Active 返回当前使用中的账号
+func (r *Registry) Active() Account {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	a, _ := r.find(r.data.Active)
+	return a
+}
+
+// Add 添加账号，ID 和工作目录不能与已有账号重复
+func (r *Registry) Add(a Account) error {
+	if !idPattern.MatchString(a.ID) {
+		return ErrInvalidID
+	}
+	if a.WorkDir == "" {
+		return ErrWorkDirNeeded
+	}
+	if a.Name == "" {
+		a.Name = a.ID
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.find(a.ID); ok {
+		return ErrExists
+	}
+	workDir := filepath.Clean(a.WorkDir)
+	if filepath.Clean(r.def.WorkDir) == workDir {
+		return ErrWorkDirInUse
+	}
+	for _, other := range r.data.Accounts {
+		if filepath.Clean(other.WorkDir) == workDir {
+			return ErrWorkDirInUse
+		}
+	}
+
+	r.data.Accounts = append(r.data.Accounts, a)
+	if err := r.save(); err != nil {
+		r.data.Accounts = r.data.Accounts[:len(r.data.Accounts)-1]
+		return fmt.Errorf("保存账号文件失败: %w", err)
+	}
+	return nil
+}
+
+// Update 修改账号的配置项并保存，默认账号的配置由 .env 管理
+func (r *Registry) Update(id string, fn func(a *Account)) error {
+	if id == DefaultID {
+		return ErrDefault
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.data.Accounts {
+		if r.data.Accounts[i].ID != id {
+			continue
+		}
+		old := r.data.Accounts[i]
+		old.Settings = cloneSettings(old.Settings)
+		fn(&r.data.Accounts[i])
+		r.data.Accounts[i].ID = id
+		if err := r.save(); err != nil {
+			r.data.Accounts[i] = old
+			return fmt.Errorf("保存账号文件失败: %w", err)
+		}
+		return nil
+	}
+	return ErrNotFound
+}
+
+// Remove 删除账号，不删除其工作目录中的数据
+func (r *Registry) Remove(id string) error {
+	if id == DefaultID {
+		return ErrDefault
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if id == r.data.Active {
+		return ErrActive
+	}
+	for i, a := range r.data.Accounts {
+		if a.ID != id {
+			continue
+		}
+		old := r.data.Accounts
+		r.data.Accounts = append(append([]Account{}, old[:i]...), old[i+1:]...)
+		if err := r.save(); err != nil {
+			r.data.Accounts = old
+			return fmt.Errorf("保存账号文件失败: %w", err)
+		}
+		return nil
+	}
+	return ErrNotFound
+}
+
+// SetActive 切换当前使用中的账号并保存，重启后仍使用该账号
+func (r *Registry) SetActive(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.find(id); !ok {
+		return ErrNotFound
+	}
+	old := r.data.Active
+	r.data.Active = id
+	if err := r.save(); err != nil {
+		r.data.Active = old
+		return fmt.Errorf("保存账号文件失败: %w", err)
+	}
+	return nil
+}
+
+func cloneSettings(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,75 @@
+package account
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	dir := t.TempDir()
+	def := Account{WorkDir: "data", WechatDbKey: "envkey"}
+
+	r, err := NewRegistry(dir, def)
+	if err != nil {
+		t.Fatalf("NewRegistry 失败: %v", err)
+	}
+	if got := r.Active().ID; got != DefaultID {
+		t.Fatalf("期望默认使用 %q, 实际得到 %q", DefaultID, got)
+	}
+
+	// 校验 ID 和工作目录
+	if err := r.Add(Account{ID: "../x", WorkDir: "x"}); !errors.Is(err, ErrInvalidID) {
+		t.Errorf("非法 ID 期望 ErrInvalidID, 实际得到 %v", err)
+	}
+	if err := r.Add(Account{ID: "wxid_a", WorkDir: "data/"}); !errors.Is(err, ErrWorkDirInUse) {
+		t.Errorf("与默认账号相同的工作目录期望 ErrWorkDirInUse, 实际得到 %v", err)
+	}
+	if err := r.Add(Account{ID: "wxid_b", WorkDir: filepath.Join(dir, "b"), WechatDbKey: "bkey"}); err != nil {
+		t.Fatalf("Add 失败: %v", err)
+	}
+	if err := r.Add(Account{ID: "wxid_b", WorkDir: filepath.Join(dir, "b2")}); !errors.Is(err, ErrExists) {
+		t.Errorf("重复 ID 期望 ErrExists, 实际得到 %v", err)
+	}
+
+	// 按账号保存的配置
+	if err := r.Update("wxid_b", func(a *Account) {
+		a.Set("IMAGE_KEY", "img")
+		a.Set("SYNC_ENABLED", "true")
+	}); err != nil {
+		t.Fatalf("Update 失败: %v", err)
+	}
+	if err := r.Update(DefaultID, func(a *Account) {}); !errors.Is(err, ErrDefault) {
+		t.Errorf("修改默认账号期望 ErrDefault, 实际得到 %v", err)
+	}
+
+	if err := r.SetActive("wxid_b"); err != nil {
+		t.Fatalf("SetActive 失败: %v", err)
+	}
+	if err := r.Remove("wxid_b"); !errors.Is(err, ErrActive) {
+		t.Errorf("删除使用中的账号期望 ErrActive, 实际得到 %v", err)
+	}
+
+	// 重新加载后保留账号、配置和当前账号，默认账号仍取自传入的配置
+	r, err = NewRegistry(dir, def)
+	if err != nil {
+		t.Fatalf("重新加载失败: %v", err)
+	}
+	b := r.Active()
+	if b.ID != "wxid_b" || b.WechatDbKey != "bkey" || b.Get("IMAGE_KEY") != "img" || b.Get("SYNC_ENABLED") != "true" {
+		t.Errorf("重新加载后账号不一致: %+v", b)
+	}
+	if list := r.List(); len(list) != 2 || list[0].ID != DefaultID || list[0].WechatDbKey != "envkey" {
+		t.Errorf("期望默认账号在最前且共 2 个账号, 实际得到 %+v", list)
+	}
+
+	if err := r.SetActive(DefaultID); err != nil {
+		t.Fatalf("SetActive 失败: %v", err)
+	}
+	if err := r.Remove("wxid_b"); err != nil {
+		t.Fatalf("Remove 失败: %v", err)
+	}
+	if _, err := r.Get("wxid_b"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("删除后期望 ErrNotFound, 实际得到 %v", err)
+	}
+}
@@ -11,9 +11,9 @@ import (
 )
 
 // BackupFunc is the function called to perform a backup operation.
-// It receives the backup path, format, and optional session IDs filter.
-// When sessionIDs is empty, all sessions are backed up.
-type BackupFunc func(backupPath, format string, sessionIDs []string) (string, int, error)
+// It receives the backup path, format, and optional session IDs and contact label filters.
+// When both are empty, all sessions are backed up.
+type BackupFunc func(backupPath, format string, sessionIDs []string, label string) (string, int, error)
 
 // Record represents a single backup history entry.
 type Record struct {
@@ -33,6 +33,7 @@ type Scheduler struct {
 	intervalHours    int
 	backupPath       string
 	format           string
+	label            string // 定时备份只备份带有该标签的联系人的会话，为空时备份全部
 	lastBackupTime   time.Time
 	lastBackupStatus string
 	isRunning        bool
@@ -61,6 +62,7 @@ type Status struct {
 	IntervalHours    int    `json:"interval_hours"`
 	BackupPath       string `json:"backup_path"`
 	Format           string `json:"format"`
+	Label            string `json:"label"`
 	LastBackupTime   string `json:"last_backup_time"`
 	LastBackupStatus string `json:"last_backup_status"`
 }
@@ -79,13 +81,15 @@ func (s *Scheduler) GetStatus() Status {
 		IntervalHours:    s.intervalHours,
 		BackupPath:       s.backupPath,
 		Format:           s.format,
+		Label:            s.label,
 		LastBackupTime:   lastTime,
 		LastBackupStatus: s.lastBackupStatus,
 	}
 }
 
 // Configure updates the backup scheduler settings.
-func (s *Scheduler) Configure(enabled bool, intervalHours int, backupPath, format string) {
+// An empty label makes scheduled backups include all sessions.
+func (s *Scheduler) Configure(enabled bool, intervalHours int, backupPath, format, label string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -99,6 +103,7 @@ func (s *Scheduler) Configure(enabled bool, intervalHours int, backupPath, forma
 	if format != "" {
 		s.format = format
 	}
+	s.label = label
 
 	s.stopTicker()
 	if s.enabled {
@@ -132,15 +137,24 @@ func (s *Scheduler) startTicker() {
 	log.Info().Int("interval_hours", s.intervalHours).Msg("auto backup scheduler started")
 }
 
-// RunBackup executes a backup operation for all sessions. Safe to call concurrently.
-// Used by the automatic scheduler (timer).
+// RunBackup executes a backup operation for all sessions, or the sessions of the configured
+// label. Safe to call concurrently. Used by the automatic scheduler (timer).
 func (s *Scheduler) RunBackup() {
-	s.RunBackupWithSessions(nil)
+	s.mu.Lock()
+	label := s.label
+	s.mu.Unlock()
+	s.RunBackupWithFilter(nil, label)
 }
 
 // RunBackupWithSessions executes a backup operation with optional session filtering.
 // When sessionIDs is nil or empty, all sessions are backed up.
 func (s *Scheduler) RunBackupWithSessions(sessionIDs []string) {
+	s.RunBackupWithFilter(sessionIDs, "")
+}
+
+// RunBackupWithFilter executes a backup operation limited to the given sessions and/or
+// the sessions of contacts with the given label. Empty filters are ignored.
+func (s *Scheduler) RunBackupWithFilter(sessionIDs []string, label string) {
 	s.mu.Lock()
 	if s.isRunning {
 		s.mu.Unlock()
@@ -165,7 +179,7 @@ func (s *Scheduler) RunBackupWithSessions(sessionIDs []string) {
 	now := time.Now()
 	backupID := "backup_" + now.Format("20060102_150405")
 
-	filePath, sessionsCount, err := s.backupFunc(backupPath, format, sessionIDs)
+	filePath, sessionsCount, err := s.backupFunc(backupPath, format, sessionIDs, label)
 
 	record := Record{
 		ID:            backupID,
@@ -1,5 +1,10 @@
 package model
 
+import (
+	"strconv"
+	"strings"
+)
+
 type Contact struct {
 	UserName        string `json:"userName"`
 	Alias           string `json:"alias"`
@@ -8,6 +13,34 @@ type Contact struct {
 	IsFriend        bool   `json:"isFriend"`
 	SmallHeadImgUrl string `json:"smallHeadImgUrl,omitempty"`
 	BigHeadImgUrl   string `json:"bigHeadImgUrl,omitempty"`
+	LabelIDs        []int  `json:"labelIds,omitempty"` // 联系人标签 ID，对应 Label.ID
+}
+
+// Label 是微信的联系人标签 (例如 "客户"、"家人")
+type Label struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// HasLabel 判断联系人是否带有指定标签
+func (c *Contact) HasLabel(id int) bool {
+	for _, v := range c.LabelIDs {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseLabelIDList 解析逗号分隔的标签 ID 列表 (例如 "1,3,")，忽略无法解析的项
+func ParseLabelIDList(s string) []int {
+	var ids []int
+	for _, part := range strings.Split(s, ",") {
+		if id, err := strconv.Atoi(strings.TrimSpace(part)); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
 }
 
 // CREATE TABLE Contact(
@@ -51,6 +84,13 @@ type ContactV3 struct {
 	Reserved1       int    `json:"Reserved1"` // 1 自己好友或自己加入的群聊; 0 群聊成员(非好友)
 	SmallHeadImgUrl string `json:"SmallHeadImgUrl"`
 	BigHeadImgUrl   string `json:"BigHeadImgUrl"`
+	LabelIDList     string `json:"LabelIDList"`
+
+	// 拼音列，仅用于搜索
+	PYInitial       string `json:"PYInitial"`
+	QuanPin         string `json:"QuanPin"`
+	RemarkPYInitial string `json:"RemarkPYInitial"`
+	RemarkQuanPin   string `json:"RemarkQuanPin"`
 }
 
 func (c *ContactV3) Wrap() *Contact {
@@ -62,6 +102,7 @@ func (c *ContactV3) Wrap() *Contact {
 		IsFriend:        c.Reserved1 == 1,
 		SmallHeadImgUrl: c.SmallHeadImgUrl,
 		BigHeadImgUrl:   c.BigHeadImgUrl,
+		LabelIDs:        ParseLabelIDList(c.LabelIDList),
 	}
 }
 
@@ -32,6 +32,7 @@ type ContactV4 struct {
 	LocalType    int    `json:"local_type"` // 2 群聊; 3 群聊成员(非好友); 5,6 企业微信;
 	SmallHeadURL string `json:"small_head_url"`
 	BigHeadURL   string `json:"big_head_url"`
+	LabelIDList  string `json:"label_id_list"` // 逗号分隔的标签 ID，对应 contact_label.label_id_
 }
 
 func (c *ContactV4) Wrap() *Contact {
@@ -43,5 +44,6 @@ func (c *ContactV4) Wrap() *Contact {
 		IsFriend:        c.LocalType != 3,
 		SmallHeadImgUrl: c.SmallHeadURL,
 		BigHeadImgUrl:   c.BigHeadURL,
+		LabelIDs:        ParseLabelIDList(c.LabelIDList),
 	}
 }
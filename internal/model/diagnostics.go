@@ -0,0 +1,51 @@
+package model
+
+import "time"
+
+// 数据库完整性检查方式
+const (
+	DiagnosticsCheckQuick = "quick" // PRAGMA quick_check
+	DiagnosticsCheckFull  = "full"  // PRAGMA integrity_check，大文件耗时较长
+	DiagnosticsCheckNone  = "none"  // 不检查
+)
+
+// Diagnostics 是数据目录的诊断信息：识别到的版本、时间线索引中的分片和目录中的全部数据库文件
+type Diagnostics struct {
+	Version      string               `json:"version"` // 识别到的数据版本，取值同 WeChatV3 / WeChatV4 / WeChatDarwinV3
+	DataDir      string               `json:"dataDir"`
+	Revision     uint64               `json:"revision"` // 时间线索引版本
+	GeneratedAt  time.Time            `json:"generatedAt"`
+	Check        string               `json:"check"` // 完整性检查方式
+	Shards       []*ShardDiagnostics  `json:"shards"`
+	Files        []*DBFileDiagnostics `json:"files"`
+	Unrecognized []string             `json:"unrecognized"` // 当前版本无法识别的 .db 文件 (相对路径)
+}
+
+// ShardDiagnostics 是时间线索引中的一个消息分片
+type ShardDiagnostics struct {
+	Path      string    `json:"path"` // 相对于数据目录
+	StartTime time.Time `json:"startTime"`
+	EndTime   time.Time `json:"endTime"`
+	Open      bool      `json:"open"`    // 结束时间随新消息增长 (最新的分片或按会话切分的分片)
+	Talkers   int       `json:"talkers"` // 会话映射表的大小
+}
+
+// DBFileDiagnostics 是一个数据库文件的诊断信息
+type DBFileDiagnostics struct {
+	Path          string              `json:"path"` // 相对于数据目录
+	Size          int64               `json:"size"`
+	Type          string              `json:"type,omitempty"` // 识别到的类型 (Message、Contact 等)，无法识别时为空
+	Recognized    bool                `json:"recognized"`
+	Indexed       bool                `json:"indexed"` // 是否为时间线索引中的分片
+	UserVersion   int                 `json:"userVersion"`
+	SchemaVersion int                 `json:"schemaVersion"`
+	Tables        []*TableDiagnostics `json:"tables"`
+	Check         []string            `json:"check,omitempty"` // 完整性检查结果，正常时为 ["ok"]
+	Error         string              `json:"error,omitempty"` // 无法打开或读取时的错误，例如文件未解密
+}
+
+// TableDiagnostics 是数据库中的一张表
+type TableDiagnostics struct {
+	Name string `json:"name"`
+	Rows int64  `json:"rows"` // 无法统计时 (如依赖微信分词器的全文索引表) 为 -1
+}
@@ -0,0 +1,65 @@
+package model
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileAttachment 是一条文件消息中的附件
+type FileAttachment struct {
+	Seq        int64     `json:"seq"`
+	Time       time.Time `json:"time"`
+	Talker     string    `json:"talker"`
+	TalkerName string    `json:"talkerName"`
+	Sender     string    `json:"sender"`
+	SenderName string    `json:"senderName"`
+	IsSelf     bool      `json:"isSelf"`
+	Title      string    `json:"title"`
+	Ext        string    `json:"ext"`  // 扩展名，小写，不含点
+	Size       int64     `json:"size"` // 字节数，消息中没有时取本地文件记录中的大小
+	MD5        string    `json:"md5"`
+	Present    bool      `json:"present"`        // 本地文件记录 (如 file_hardlink_info_v4) 中是否有该文件
+	Path       string    `json:"path,omitempty"` // 相对于微信数据目录的路径
+}
+
+// FileCatalog 是文件附件列表
+type FileCatalog struct {
+	Total     int               `json:"total"`
+	TotalSize int64             `json:"totalSize"`
+	Present   int               `json:"present"` // 本地存在的文件数
+	Items     []*FileAttachment `json:"items"`
+}
+
+// NewFileAttachment 从文件消息生成附件，其他消息返回 nil
+func NewFileAttachment(m *Message) *FileAttachment {
+	if m.Type != MessageTypeShare || m.SubType != MessageSubTypeFile || m.Contents == nil {
+		return nil
+	}
+	str := func(key string) string {
+		s, _ := m.Contents[key].(string)
+		return s
+	}
+	f := &FileAttachment{
+		Seq:        m.Seq,
+		Time:       m.Time,
+		Talker:     m.Talker,
+		TalkerName: m.TalkerName,
+		Sender:     m.Sender,
+		SenderName: m.SenderName,
+		IsSelf:     m.IsSelf,
+		Title:      str("title"),
+		Ext:        NormalizeExt(str("fileext")),
+		MD5:        strings.ToLower(str("md5")),
+	}
+	if f.Ext == "" {
+		f.Ext = NormalizeExt(filepath.Ext(f.Title))
+	}
+	f.Size, _ = m.Contents["size"].(int64)
+	return f
+}
+
+// NormalizeExt 将扩展名转为小写并去掉开头的点，如 ".PDF" 返回 "pdf"
+func NormalizeExt(ext string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), "."))
+}
@@ -0,0 +1,35 @@
+package model
+
+import "testing"
+
+func TestNewFileAttachment(t *testing.T) {
+	m := &Message{Type: MessageTypeShare, Talker: "alice", Sender: "alice"}
+	data := `<msg><appmsg><title>报告.PDF</title><type>6</type><appattach><totallen>2048</totallen><fileext>PDF</fileext></appattach><md5>ABCDEF</md5></appmsg></msg>`
+	if err := m.ParseMediaInfo(data); err != nil {
+		t.Fatalf("ParseMediaInfo 失败: %v", err)
+	}
+	f := NewFileAttachment(m)
+	if f == nil {
+		t.Fatal("未解析出文件")
+	}
+	if f.Title != "报告.PDF" || f.Ext != "pdf" || f.Size != 2048 || f.MD5 != "abcdef" || f.Present {
+		t.Errorf("文件解析错误: %+v", f)
+	}
+
+	// 没有 fileext 时从文件名取扩展名
+	m = &Message{Type: MessageTypeShare}
+	if err := m.ParseMediaInfo(`<msg><appmsg><title>a.tar.GZ</title><type>6</type><md5>x</md5></appmsg></msg>`); err != nil {
+		t.Fatalf("ParseMediaInfo 失败: %v", err)
+	}
+	if f := NewFileAttachment(m); f == nil || f.Ext != "gz" || f.Size != 0 {
+		t.Errorf("文件解析错误: %+v", f)
+	}
+
+	link := &Message{Type: MessageTypeShare}
+	if err := link.ParseMediaInfo(`<msg><appmsg><title>链接</title><type>5</type><url>https://example.com</url></appmsg></msg>`); err != nil {
+		t.Fatalf("ParseMediaInfo 失败: %v", err)
+	}
+	if NewFileAttachment(link) != nil {
+		t.Error("链接消息不应生成文件")
+	}
+}
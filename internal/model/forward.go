@@ -0,0 +1,163 @@
+package model
+
+import (
+	"strconv"
+	"strings"
+)
+
+// 合并转发记录中数据项的种类
+const (
+	ForwardKindText        = "text"
+	ForwardKindImage       = "image"
+	ForwardKindVoice       = "voice"
+	ForwardKindVideo       = "video"
+	ForwardKindLink        = "link"
+	ForwardKindLocation    = "location"
+	ForwardKindFile        = "file"
+	ForwardKindRecord      = "record" // 嵌套的合并转发
+	ForwardKindChannel     = "channel"
+	ForwardKindChannelLive = "channelLive"
+	ForwardKindMusic       = "music"
+	ForwardKindAnimation   = "animation"
+)
+
+// forwardKinds 是 DataItem.DataType 到数据项种类的映射，未列出的类型按文本处理
+var forwardKinds = map[string]string{
+	"1":  ForwardKindText,
+	"2":  ForwardKindImage,
+	"3":  ForwardKindVoice,
+	"4":  ForwardKindVideo,
+	"5":  ForwardKindLink,
+	"6":  ForwardKindLocation,
+	"8":  ForwardKindFile,
+	"17": ForwardKindRecord,
+	"22": ForwardKindChannel,
+	"23": ForwardKindChannelLive,
+	"32": ForwardKindMusic,
+	"37": ForwardKindAnimation,
+}
+
+// ForwardRecord 是合并转发或笔记中的聊天记录，可逐条浏览
+type ForwardRecord struct {
+	Title      string         `json:"title"`
+	Desc       string         `json:"desc,omitempty"`
+	IsChatRoom bool           `json:"isChatRoom"` // 记录是否转发自群聊
+	Items      []*ForwardItem `json:"items"`
+}
+
+// ForwardItem 是合并转发记录中的一条消息
+type ForwardItem struct {
+	ID            string           `json:"id,omitempty"` // 记录内的数据项 ID
+	Kind          string           `json:"kind"`         // ForwardKindText 等
+	SenderName    string           `json:"senderName"`
+	SenderHeadURL string           `json:"senderHeadURL,omitempty"`
+	Time          string           `json:"time"`              // 原消息时间，记录中保存的是格式化后的文本
+	Content       string           `json:"content,omitempty"` // 文本内容或附件描述
+	Title         string           `json:"title,omitempty"`   // 文件名、链接或音乐标题、嵌套记录标题
+	URL           string           `json:"url,omitempty"`     // 链接地址或音乐地址
+	MD5           string           `json:"md5,omitempty"`     // 图片、视频、文件的 MD5，用于查找本地媒体
+	Size          int64            `json:"size,omitempty"`
+	Ext           string           `json:"ext,omitempty"`
+	Location      *ForwardLocation `json:"location,omitempty"`
+	Record        *ForwardRecord   `json:"record,omitempty"` // 嵌套的合并转发
+	Media         *Media           `json:"media,omitempty"`  // 已找到的本地媒体
+	File          string           `json:"file,omitempty"`   // 导出时附件在压缩包中的相对路径
+}
+
+// ForwardLocation 是合并转发记录中的位置
+type ForwardLocation struct {
+	Lat     float64 `json:"lat"`
+	Lng     float64 `json:"lng"`
+	Label   string  `json:"label,omitempty"`
+	PoiName string  `json:"poiName,omitempty"`
+}
+
+// NewForwardRecord 将合并转发的原始记录转换为逐条的聊天记录，嵌套的合并转发递归展开
+func NewForwardRecord(info *RecordInfo) *ForwardRecord {
+	record := &ForwardRecord{
+		Title:      info.Title,
+		Desc:       info.Desc,
+		IsChatRoom: info.IsChatRoom == "1",
+		Items:      make([]*ForwardItem, 0, len(info.DataList.DataItems)),
+	}
+	for _, d := range info.DataList.DataItems {
+		// 笔记的第一条是 htm 格式的正文，与后续数据项重复
+		if d.DataType == "8" && d.DataFmt == ".htm" {
+			continue
+		}
+		item := &ForwardItem{
+			ID:            d.DataID,
+			Kind:          forwardKinds[d.DataType],
+			SenderName:    d.SourceName,
+			SenderHeadURL: d.SourceHeadURL,
+			Time:          d.SourceTime,
+			Content:       d.DataDesc,
+			Title:         d.DataTitle,
+			MD5:           d.FullMD5,
+			Ext:           strings.TrimPrefix(d.DataFmt, "."),
+		}
+		if item.Kind == "" {
+			item.Kind = ForwardKindText
+		}
+		item.Size, _ = strconv.ParseInt(d.DataSize, 10, 64)
+
+		switch item.Kind {
+		case ForwardKindLink:
+			item.URL = d.Link
+		case ForwardKindMusic:
+			item.URL = d.StreamWebURL
+		case ForwardKindLocation:
+			loc := &ForwardLocation{Label: d.Location.Label, PoiName: d.Location.PoiName}
+			loc.Lat, _ = strconv.ParseFloat(d.Location.Lat, 64)
+			loc.Lng, _ = strconv.ParseFloat(d.Location.Lng, 64)
+			item.Location = loc
+		case ForwardKindRecord:
+			if d.RecordXML != nil {
+				item.Record = NewForwardRecord(&d.RecordXML.RecordInfo)
+				if item.Record.Title == "" {
+					item.Record.Title = d.DataTitle
+				}
+			}
+		}
+		record.Items = append(record.Items, item)
+	}
+	return record
+}
+
+// ForwardRecordOf 返回合并转发、笔记或群公告消息中的聊天记录，其他消息返回 nil
+func ForwardRecordOf(m *Message) *ForwardRecord {
+	if m.Type != MessageTypeShare {
+		return nil
+	}
+	info, ok := m.Contents["recordInfo"].(*RecordInfo)
+	if !ok {
+		return nil
+	}
+	record := NewForwardRecord(info)
+	if title, _ := m.Contents["title"].(string); title != "" {
+		record.Title = title
+	}
+	return record
+}
+
+// MediaType 返回数据项附件对应的媒体类型 (用于 GetMedia)，没有本地附件时返回空
+func (i *ForwardItem) MediaType() string {
+	if i.MD5 == "" {
+		return ""
+	}
+	switch i.Kind {
+	case ForwardKindImage, ForwardKindVideo, ForwardKindFile:
+		return i.Kind
+	}
+	return ""
+}
+
+// Walk 按顺序访问记录中的全部数据项，包括嵌套记录中的数据项
+func (r *ForwardRecord) Walk(fn func(*ForwardItem)) {
+	for _, item := range r.Items {
+		fn(item)
+		if item.Record != nil {
+			item.Record.Walk(fn)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package model
+
+import "testing"
+
+func TestForwardRecordOf(t *testing.T) {
+	data := `<msg><appmsg><title>群聊的聊天记录</title><type>19</type><des>张三: 你好</des><recorditem><![CDATA[<recordinfo>
+<title>群聊的聊天记录</title><isChatRoom>1</isChatRoom>
+<datalist count="4">
+<dataitem datatype="1" dataid="a1"><sourcename>张三</sourcename><sourcetime>2023-1-1 10:00</sourcetime><datadesc>你好</datadesc></dataitem>
+<dataitem datatype="2" dataid="a2"><sourcename>李四</sourcename><sourcetime>2023-1-1 10:01</sourcetime><fullmd5>0123abcd</fullmd5><datasize>2048</datasize><datafmt>.jpg</datafmt></dataitem>
+<dataitem datatype="6" dataid="a3"><sourcename>李四</sourcename><sourcetime>2023-1-1 10:02</sourcetime><location lat="39.9" lng="116.4" label="北京市" poiname="天安门"></location></dataitem>
+<dataitem datatype="17" dataid="a4"><sourcename>张三</sourcename><sourcetime>2023-1-1 10:03</sourcetime><datatitle>王五的聊天记录</datatitle><recordxml><recordinfo><datalist count="1">
+<dataitem datatype="8" dataid="b1"><sourcename>王五</sourcename><sourcetime>2022-12-31 09:00</sourcetime><datatitle>报告.pdf</datatitle><fullmd5>ffff0000</fullmd5><datafmt>pdf</datafmt></dataitem>
+</datalist></recordinfo></recordxml></dataitem>
+</datalist></recordinfo>]]></recorditem></appmsg></msg>`
+
+	m := &Message{Type: MessageTypeShare}
+	if err := m.ParseMediaInfo(data); err != nil {
+		t.Fatalf("ParseMediaInfo 失败: %v", err)
+	}
+	record := ForwardRecordOf(m)
+	if record == nil {
+		t.Fatal("未解析出合并转发记录")
+	}
+	if record.Title != "群聊的聊天记录" || !record.IsChatRoom || len(record.Items) != 4 {
+		t.Fatalf("记录解析错误: %+v", record)
+	}
+
+	text, image, loc, nested := record.Items[0], record.Items[1], record.Items[2], record.Items[3]
+	if text.Kind != ForwardKindText || text.Content != "你好" || text.SenderName != "张三" || text.Time != "2023-1-1 10:00" {
+		t.Errorf("文本项解析错误: %+v", text)
+	}
+	if image.Kind != ForwardKindImage || image.MD5 != "0123abcd" || image.Size != 2048 || image.Ext != "jpg" || image.MediaType() != "image" {
+		t.Errorf("图片项解析错误: %+v", image)
+	}
+	if loc.Location == nil || loc.Location.Lat != 39.9 || loc.Location.PoiName != "天安门" || loc.MediaType() != "" {
+		t.Errorf("位置项解析错误: %+v", loc)
+	}
+	if nested.Record == nil || nested.Record.Title != "王五的聊天记录" || len(nested.Record.Items) != 1 {
+		t.Fatalf("嵌套记录解析错误: %+v", nested)
+	}
+	if file := nested.Record.Items[0]; file.Kind != ForwardKindFile || file.Title != "报告.pdf" || file.MediaType() != "file" {
+		t.Errorf("嵌套记录中的文件项解析错误: %+v", file)
+	}
+
+	var ids []string
+	record.Walk(func(item *ForwardItem) { ids = append(ids, item.ID) })
+	if got := len(ids); got != 5 || ids[4] != "b1" {
+		t.Errorf("Walk 应按顺序访问 5 个数据项, 实际 %v", ids)
+	}
+
+	if ForwardRecordOf(&Message{Type: MessageTypeText, Content: "你好"}) != nil {
+		t.Error("普通消息不应返回合并转发记录")
+	}
+}
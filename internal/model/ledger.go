@@ -0,0 +1,161 @@
+package model
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 账目种类
+const (
+	LedgerTransfer    = "transfer"    // 转账
+	LedgerRedEnvelope = "redEnvelope" // 红包
+)
+
+// 账目状态，转账按 PaySubType 区分
+const (
+	LedgerSend    = "send"    // 发起转账或发红包
+	LedgerReceive = "receive" // 确认收款
+	LedgerRefund  = "refund"  // 退还转账
+)
+
+// 资金方向，相对于当前账号
+const (
+	LedgerIn  = "in"
+	LedgerOut = "out"
+)
+
+// LedgerEntry 是一条转账或红包消息
+type LedgerEntry struct {
+	Seq             int64     `json:"seq"`
+	Time            time.Time `json:"time"`
+	Talker          string    `json:"talker"`
+	TalkerName      string    `json:"talkerName"`
+	Kind            string    `json:"kind"`      // LedgerTransfer 或 LedgerRedEnvelope
+	Status          string    `json:"status"`    // LedgerSend、LedgerReceive 或 LedgerRefund
+	Direction       string    `json:"direction"` // LedgerIn 或 LedgerOut
+	Amount          float64   `json:"amount"`    // 金额 (元)，红包消息中没有金额，为 0
+	Memo            string    `json:"memo,omitempty"`
+	Counterpart     string    `json:"counterpart"` // 对方账号
+	CounterpartName string    `json:"counterpartName"`
+	TransferID      string    `json:"transferId,omitempty"` // 转账 ID，同一笔转账的发起、收款和退还消息相同
+}
+
+// Counted 判断账目是否计入合计。
+// 确认收款是对同一笔转账的回执，只统计发起和退还，避免重复计算。
+func (e *LedgerEntry) Counted() bool {
+	return e.Status != LedgerReceive
+}
+
+// LedgerTotal 是一组账目的合计
+type LedgerTotal struct {
+	Key   string  `json:"key"` // 对方账号或月份 (2006-01)
+	Name  string  `json:"name,omitempty"`
+	In    float64 `json:"in"`
+	Out   float64 `json:"out"`
+	Net   float64 `json:"net"` // In - Out
+	Count int     `json:"count"`
+}
+
+// Add 将账目计入合计
+func (t *LedgerTotal) Add(e *LedgerEntry) {
+	if !e.Counted() {
+		return
+	}
+	t.Count++
+	if e.Direction == LedgerIn {
+		t.In = roundCents(t.In + e.Amount)
+	} else {
+		t.Out = roundCents(t.Out + e.Amount)
+	}
+	t.Net = roundCents(t.In - t.Out)
+}
+
+// Ledger 是账目列表及按联系人、按月的合计
+type Ledger struct {
+	Entries   []*LedgerEntry `json:"entries"`
+	Total     *LedgerTotal   `json:"total"`
+	ByContact []*LedgerTotal `json:"byContact"`
+	ByMonth   []*LedgerTotal `json:"byMonth"`
+}
+
+// NewLedgerEntry 从转账或红包消息生成账目，其他消息返回 nil。
+// 对方账号在私聊中为会话对象；群聊中别人发的消息为发送者，自己发的转账为收款方或付款方。
+func NewLedgerEntry(m *Message) *LedgerEntry {
+	if m.Type != MessageTypeShare || m.Contents == nil {
+		return nil
+	}
+	e := &LedgerEntry{
+		Seq:        m.Seq,
+		Time:       m.Time,
+		Talker:     m.Talker,
+		TalkerName: m.TalkerName,
+		Status:     LedgerSend,
+	}
+	str := func(key string) string {
+		s, _ := m.Contents[key].(string)
+		return s
+	}
+
+	switch m.SubType {
+	case MessageSubTypePay:
+		subType, ok := m.Contents["paySubType"].(int)
+		if !ok {
+			return nil
+		}
+		e.Kind = LedgerTransfer
+		e.Amount = ParseAmount(str("feeDesc"))
+		e.Memo = str("payMemo")
+		e.TransferID = str("transferId")
+		switch subType {
+		case 3, 5:
+			e.Status = LedgerReceive
+		case 4:
+			e.Status = LedgerRefund
+		}
+	case MessageSubTypeRedEnvelope:
+		e.Kind = LedgerRedEnvelope
+		e.Memo = str("title")
+	default:
+		return nil
+	}
+
+	// 发起和退还由付出资金的一方发送，收款回执由收款方发送
+	e.Direction = LedgerOut
+	if m.IsSelf == (e.Status == LedgerReceive) {
+		e.Direction = LedgerIn
+	}
+
+	switch {
+	case !m.IsChatRoom:
+		e.Counterpart, e.CounterpartName = m.Talker, m.TalkerName
+	case !m.IsSelf:
+		e.Counterpart, e.CounterpartName = m.Sender, m.SenderName
+	case e.Status == LedgerSend:
+		e.Counterpart = str("receiver")
+	default:
+		e.Counterpart = str("payer")
+	}
+	return e
+}
+
+// ParseAmount 解析金额描述，如 "￥1,200.50" 返回 1200.5，无法解析时返回 0
+func ParseAmount(desc string) float64 {
+	var sb strings.Builder
+	for _, r := range desc {
+		if r >= '0' && r <= '9' || r == '.' {
+			sb.WriteRune(r)
+		}
+	}
+	v, err := strconv.ParseFloat(sb.String(), 64)
+	if err != nil {
+		return 0
+	}
+	return v
+}
+
+// roundCents 将金额保留到分，避免浮点累加误差
+func roundCents(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,79 @@
+package model
+
+import "testing"
+
+func TestParseAmount(t *testing.T) {
+	tests := map[string]float64{
+		"￥100.00":   100,
+		"¥1,200.50": 1200.5,
+		"":          0,
+		"转账":        0,
+	}
+	for desc, want := range tests {
+		if got := ParseAmount(desc); got != want {
+			t.Errorf("ParseAmount(%q) = %v, 期望 %v", desc, got, want)
+		}
+	}
+}
+
+func TestNewLedgerEntry(t *testing.T) {
+	pay := func(subType, fee, memo string) string {
+		return `<msg><appmsg><type>2000</type><wcpayinfo><paysubtype>` + subType + `</paysubtype><feedesc>` + fee +
+			`</feedesc><pay_memo>` + memo + `</pay_memo><transferid>T1</transferid><payer_username>wxid_me</payer_username><receiver_username>wxid_bob</receiver_username></wcpayinfo></appmsg></msg>`
+	}
+	redEnvelope := `<msg><appmsg><type>2001</type><wcpayinfo><sendertitle>恭喜发财</sendertitle><paymsgid>P1</paymsgid></wcpayinfo></appmsg></msg>`
+
+	tests := []struct {
+		name       string
+		data       string
+		isSelf     bool
+		chatRoom   bool
+		want       string // 种类|状态|方向|对方
+		amount     float64
+		memo       string
+		transferID string
+	}{
+		{"自己发起转账", pay("1", "￥100.00", "房租"), true, false, "transfer|send|out|bob", 100, "房租", "T1"},
+		{"对方确认收款", pay("3", "￥100.00", ""), false, false, "transfer|receive|out|bob", 100, "", "T1"},
+		{"对方发起转账", pay("1", "￥20.00", ""), false, false, "transfer|send|in|bob", 20, "", "T1"},
+		{"自己退还转账", pay("4", "￥20.00", ""), true, false, "transfer|refund|out|bob", 20, "", "T1"},
+		{"群聊中自己发起转账", pay("1", "￥5.00", ""), true, true, "transfer|send|out|wxid_bob", 5, "", "T1"},
+		{"群聊中别人发红包", redEnvelope, false, true, "redEnvelope|send|in|wxid_alice", 0, "恭喜发财", ""},
+	}
+	for _, tt := range tests {
+		m := &Message{Type: MessageTypeShare, Talker: "bob", TalkerName: "bob", IsSelf: tt.isSelf, IsChatRoom: tt.chatRoom}
+		if tt.chatRoom {
+			m.Talker = "123@chatroom"
+			if !tt.isSelf {
+				m.Sender = "wxid_alice"
+			}
+		}
+		if err := m.ParseMediaInfo(tt.data); err != nil {
+			t.Fatalf("%s: ParseMediaInfo 失败: %v", tt.name, err)
+		}
+		e := NewLedgerEntry(m)
+		if e == nil {
+			t.Errorf("%s: 未生成账目", tt.name)
+			continue
+		}
+		got := e.Kind + "|" + e.Status + "|" + e.Direction + "|" + e.Counterpart
+		if got != tt.want || e.Amount != tt.amount || e.Memo != tt.memo || e.TransferID != tt.transferID {
+			t.Errorf("%s: 得到 %s %v %q %q, 期望 %s %v %q %q", tt.name, got, e.Amount, e.Memo, e.TransferID, tt.want, tt.amount, tt.memo, tt.transferID)
+		}
+	}
+
+	if NewLedgerEntry(&Message{Type: MessageTypeText, Content: "你好"}) != nil {
+		t.Error("普通消息不应生成账目")
+	}
+}
+
+func TestLedgerTotal(t *testing.T) {
+	var total LedgerTotal
+	total.Add(&LedgerEntry{Status: LedgerSend, Direction: LedgerOut, Amount: 0.1})
+	total.Add(&LedgerEntry{Status: LedgerSend, Direction: LedgerOut, Amount: 0.2})
+	total.Add(&LedgerEntry{Status: LedgerReceive, Direction: LedgerOut, Amount: 0.2})
+	total.Add(&LedgerEntry{Status: LedgerRefund, Direction: LedgerIn, Amount: 0.2})
+	if total.Out != 0.3 || total.In != 0.2 || total.Net != -0.1 || total.Count != 3 {
+		t.Errorf("合计错误: %+v", total)
+	}
+}
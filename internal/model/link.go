@@ -0,0 +1,193 @@
+package model
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 链接种类
+const (
+	LinkKindLink        = "link"        // 网页、公众号文章
+	LinkKindMusic       = "music"       // 音乐
+	LinkKindChannel     = "channel"     // 视频号
+	LinkKindMiniProgram = "miniProgram" // 小程序
+)
+
+// LinkShare 是一次链接分享
+type LinkShare struct {
+	Seq        int64     `json:"seq"`
+	Time       time.Time `json:"time"`
+	Talker     string    `json:"talker"`
+	TalkerName string    `json:"talkerName"`
+	Sender     string    `json:"sender"`
+	SenderName string    `json:"senderName"`
+	IsSelf     bool      `json:"isSelf"`
+}
+
+// SharedLink 是去重后的一个链接及其全部分享记录
+type SharedLink struct {
+	Key       string       `json:"key"` // 去重键：规范化后的 URL，视频号为 channel:<objectId>
+	URL       string       `json:"url"`
+	Title     string       `json:"title"`
+	Desc      string       `json:"desc,omitempty"`
+	Kind      string       `json:"kind"`
+	Domain    string       `json:"domain"`
+	Source    string       `json:"source,omitempty"` // 来源：公众号、视频号或小程序名称
+	FirstTime time.Time    `json:"firstTime"`
+	LastTime  time.Time    `json:"lastTime"`
+	Count     int          `json:"count"`
+	Shares    []*LinkShare `json:"shares"`
+}
+
+// DomainCount 是一个域名下的分享次数
+type DomainCount struct {
+	Domain string `json:"domain"`
+	Count  int    `json:"count"` // 分享次数
+	Links  int    `json:"links"` // 去重后的链接数
+}
+
+// LinkLibrary 是链接库：去重后的链接 (按最近分享时间倒序) 和热门域名
+type LinkLibrary struct {
+	Links      []*SharedLink  `json:"links"`
+	TopDomains []*DomainCount `json:"topDomains"`
+}
+
+// linkKinds 是可收入链接库的分享消息子类型
+var linkKinds = map[int64]string{
+	MessageSubTypeLink:         LinkKindLink,
+	MessageSubTypeLink2:        LinkKindLink,
+	MessageSubTypeMusic:        LinkKindMusic,
+	MessageSubTypeChannel:      LinkKindChannel,
+	MessageSubTypeMiniProgram:  LinkKindMiniProgram,
+	MessageSubTypeMiniProgram2: LinkKindMiniProgram,
+}
+
+// NewSharedLink 从链接类分享消息生成只有一次分享的链接，其他消息或没有 http(s) 地址时返回 nil
+func NewSharedLink(m *Message) *SharedLink {
+	if m.Type != MessageTypeShare || m.Contents == nil {
+		return nil
+	}
+	kind, ok := linkKinds[m.SubType]
+	if !ok {
+		return nil
+	}
+	str := func(key string) string {
+		s, _ := m.Contents[key].(string)
+		return s
+	}
+
+	raw := strings.TrimSpace(str("url"))
+	key, domain, ok := NormalizeURL(raw)
+	if !ok {
+		return nil
+	}
+	if id := str("objectId"); kind == LinkKindChannel && id != "" {
+		// 视频号的地址是带签名的 CDN 地址，每次分享都不同，按视频 ID 去重
+		key = "channel:" + id
+	}
+
+	l := &SharedLink{
+		Key:       key,
+		URL:       raw,
+		Title:     str("title"),
+		Desc:      str("desc"),
+		Kind:      kind,
+		Domain:    domain,
+		Source:    str("displayname"),
+		FirstTime: m.Time,
+		LastTime:  m.Time,
+		Count:     1,
+	}
+	if kind == LinkKindMiniProgram {
+		// 小程序的标题即小程序名称
+		l.Source = l.Title
+	}
+	l.Shares = []*LinkShare{{
+		Seq:        m.Seq,
+		Time:       m.Time,
+		Talker:     m.Talker,
+		TalkerName: m.TalkerName,
+		Sender:     m.Sender,
+		SenderName: m.SenderName,
+		IsSelf:     m.IsSelf,
+	}}
+	return l
+}
+
+// Merge 合并同一链接的另一次分享，标题等信息以最近一次分享为准
+func (l *SharedLink) Merge(o *SharedLink) {
+	if o.LastTime.After(l.LastTime) {
+		l.LastTime = o.LastTime
+		l.URL, l.Title, l.Desc = o.URL, o.Title, o.Desc
+		if o.Source != "" {
+			l.Source = o.Source
+		}
+	}
+	if o.FirstTime.Before(l.FirstTime) {
+		l.FirstTime = o.FirstTime
+	}
+	l.Count += o.Count
+	l.Shares = append(l.Shares, o.Shares...)
+}
+
+// MatchDomain 判断链接是否属于 domain 或其子域名，domain 为空时总是匹配
+func (l *SharedLink) MatchDomain(domain string) bool {
+	domain = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain)), "www.")
+	return domain == "" || l.Domain == domain || strings.HasSuffix(l.Domain, "."+domain)
+}
+
+// trackingParams 是去重时忽略的跟踪参数，这些参数随分享场景变化，不影响指向的内容
+var trackingParams = map[string]bool{
+	"chksm": true, "scene": true, "srcid": true, "from": true, "isappinstalled": true,
+	"clicktime": true, "enterid": true, "sharer_shareinfo": true, "sharer_shareinfo_first": true,
+	"sharer_sharetime": true, "sharer_shareid": true, "share_token": true, "spm": true,
+	"exportkey": true, "pass_ticket": true, "wx_header": true, "ascene": true, "devicetype": true, "nettype": true,
+	"abtest_cookie": true, "session_us": true,
+}
+
+// NormalizeURL 规范化 http(s) 地址用于去重：忽略协议和片段，域名转为小写，去掉默认端口和跟踪参数，其余参数排序。
+// 返回去重键和去掉 www. 的域名，不是 http(s) 地址时 ok 为 false。
+func NormalizeURL(raw string) (key, domain string, ok bool) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", "", false
+	}
+	host := strings.ToLower(u.Hostname())
+	domain = strings.TrimPrefix(host, "www.")
+
+	query := u.Query()
+	names := make([]string, 0, len(query))
+	for name := range query {
+		lower := strings.ToLower(name)
+		if trackingParams[lower] || strings.HasPrefix(lower, "utm_") {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	sb.WriteString(domain)
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		sb.WriteString(":" + port)
+	}
+	sb.WriteString(strings.TrimSuffix(u.EscapedPath(), "/"))
+	for i, name := range names {
+		if i == 0 {
+			sb.WriteByte('?')
+		} else {
+			sb.WriteByte('&')
+		}
+		values := query[name]
+		sort.Strings(values)
+		for j, v := range values {
+			if j > 0 {
+				sb.WriteByte('&')
+			}
+			sb.WriteString(url.QueryEscape(name) + "=" + url.QueryEscape(v))
+		}
+	}
+	return sb.String(), domain, true
+}
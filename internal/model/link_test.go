@@ -0,0 +1,67 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNormalizeURL(t *testing.T) {
+	cases := []struct {
+		a, b string
+	}{
+		{"https://mp.weixin.qq.com/s?__biz=MzA&mid=1&idx=1&sn=abc&chksm=x1&scene=21#wechat_redirect",
+			"http://mp.weixin.qq.com/s?sn=abc&idx=1&mid=1&__biz=MzA&chksm=y2&scene=1"},
+		{"https://WWW.Example.com/a/?utm_source=x&id=1", "https://example.com:443/a?id=1"},
+	}
+	for _, c := range cases {
+		ka, _, ok1 := NormalizeURL(c.a)
+		kb, _, ok2 := NormalizeURL(c.b)
+		if !ok1 || !ok2 || ka != kb {
+			t.Errorf("期望去重键相同: %q / %q", ka, kb)
+		}
+	}
+
+	if _, domain, _ := NormalizeURL("https://www.Example.com/x"); domain != "example.com" {
+		t.Errorf("域名错误: %q", domain)
+	}
+	for _, raw := range []string{"", "weixin://dl/moments", "/pages/index"} {
+		if _, _, ok := NormalizeURL(raw); ok {
+			t.Errorf("%q 不应视为链接", raw)
+		}
+	}
+}
+
+func TestNewLink(t *testing.T) {
+	t1 := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	m := &Message{Type: MessageTypeShare, Time: t1, Talker: "alice", Sender: "alice", SenderName: "Alice"}
+	data := `<msg><appmsg><title>文章</title><des>摘要</des><type>5</type><url>https://mp.weixin.qq.com/s?__biz=MzA&amp;sn=abc</url><sourcedisplayname>某公众号</sourcedisplayname></appmsg></msg>`
+	if err := m.ParseMediaInfo(data); err != nil {
+		t.Fatalf("ParseMediaInfo 失败: %v", err)
+	}
+	l := NewSharedLink(m)
+	if l == nil {
+		t.Fatal("未解析出链接")
+	}
+	if l.Kind != LinkKindLink || l.Domain != "mp.weixin.qq.com" || l.Source != "某公众号" || l.Title != "文章" || len(l.Shares) != 1 {
+		t.Errorf("链接解析错误: %+v", l)
+	}
+	if !l.MatchDomain("qq.com") || !l.MatchDomain("mp.weixin.qq.com") || l.MatchDomain("q.com") {
+		t.Error("域名匹配错误")
+	}
+
+	later := *l
+	later.Title, later.FirstTime, later.LastTime = "新标题", t1.Add(time.Hour), t1.Add(time.Hour)
+	later.Shares = []*LinkShare{{Time: t1.Add(time.Hour), Talker: "bob"}}
+	l.Merge(&later)
+	if l.Count != 2 || l.Title != "新标题" || !l.FirstTime.Equal(t1) || !l.LastTime.Equal(t1.Add(time.Hour)) || len(l.Shares) != 2 {
+		t.Errorf("合并错误: %+v", l)
+	}
+
+	file := &Message{Type: MessageTypeShare}
+	if err := file.ParseMediaInfo(`<msg><appmsg><title>a.pdf</title><type>6</type></appmsg></msg>`); err != nil {
+		t.Fatalf("ParseMediaInfo 失败: %v", err)
+	}
+	if NewSharedLink(file) != nil {
+		t.Error("文件消息不应生成链接")
+	}
+}
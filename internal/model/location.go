@@ -0,0 +1,62 @@
+package model
+
+import (
+	"strconv"
+	"time"
+)
+
+// LocationPoint 是一条位置消息中分享的位置。
+// 坐标为微信使用的 GCJ-02 坐标系，导出为 GIS 格式时需转换为 WGS-84。
+type LocationPoint struct {
+	Seq        int64     `json:"seq"`
+	Time       time.Time `json:"time"`
+	Talker     string    `json:"talker"`
+	TalkerName string    `json:"talkerName"`
+	Sender     string    `json:"sender"`
+	SenderName string    `json:"senderName"`
+	IsSelf     bool      `json:"isSelf"`
+	Lat        float64   `json:"lat"`
+	Lng        float64   `json:"lng"`
+	Label      string    `json:"label,omitempty"` // 详细地址
+	PoiName    string    `json:"poiName,omitempty"`
+	CityName   string    `json:"cityName,omitempty"`
+}
+
+// Name 返回位置的显示名称：优先使用地点名称，其次是地址
+func (p *LocationPoint) Name() string {
+	if p.PoiName != "" {
+		return p.PoiName
+	}
+	return p.Label
+}
+
+// NewLocationPoint 从位置消息生成位置点，其他消息或坐标无效时返回 nil。
+// 位置消息中 x 为纬度，y 为经度。
+func NewLocationPoint(m *Message) *LocationPoint {
+	if m.Type != MessageTypeLocation || m.Contents == nil {
+		return nil
+	}
+	str := func(key string) string {
+		s, _ := m.Contents[key].(string)
+		return s
+	}
+	lat, err1 := strconv.ParseFloat(str("x"), 64)
+	lng, err2 := strconv.ParseFloat(str("y"), 64)
+	if err1 != nil || err2 != nil || lat < -90 || lat > 90 || lng < -180 || lng > 180 || (lat == 0 && lng == 0) {
+		return nil
+	}
+	return &LocationPoint{
+		Seq:        m.Seq,
+		Time:       m.Time,
+		Talker:     m.Talker,
+		TalkerName: m.TalkerName,
+		Sender:     m.Sender,
+		SenderName: m.SenderName,
+		IsSelf:     m.IsSelf,
+		Lat:        lat,
+		Lng:        lng,
+		Label:      str("label"),
+		PoiName:    str("poiname"),
+		CityName:   str("cityname"),
+	}
+}
@@ -0,0 +1,32 @@
+package model
+
+import "testing"
+
+func TestNewLocationPoint(t *testing.T) {
+	m := &Message{Type: MessageTypeLocation, Talker: "alice", Sender: "alice"}
+	data := `<msg><location x="39.909187" y="116.397451" scale="16" label="北京市东城区东长安街" poiname="天安门" cityname="北京市" /></msg>`
+	if err := m.ParseMediaInfo(data); err != nil {
+		t.Fatalf("ParseMediaInfo 失败: %v", err)
+	}
+	p := NewLocationPoint(m)
+	if p == nil {
+		t.Fatal("未解析出位置")
+	}
+	if p.Lat != 39.909187 || p.Lng != 116.397451 || p.Name() != "天安门" || p.CityName != "北京市" || p.Sender != "alice" {
+		t.Errorf("位置解析错误: %+v", p)
+	}
+
+	for _, data := range []string{
+		`<msg><location x="" y="" label="无坐标" /></msg>`,
+		`<msg><location x="0" y="0" label="零点" /></msg>`,
+		`<msg><location x="120" y="30" label="纬度越界" /></msg>`,
+	} {
+		m := &Message{Type: MessageTypeLocation}
+		if err := m.ParseMediaInfo(data); err != nil {
+			t.Fatalf("ParseMediaInfo 失败: %v", err)
+		}
+		if p := NewLocationPoint(m); p != nil {
+			t.Errorf("无效坐标不应生成位置: %+v", p)
+		}
+	}
+}
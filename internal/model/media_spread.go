@@ -0,0 +1,118 @@
+package model
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// 传播分析的媒体种类
+const (
+	SpreadKindImage = "image"
+	SpreadKindVideo = "video"
+	SpreadKindFile  = "file"
+)
+
+// SpreadHop 是媒体传播链中的一次发送
+type SpreadHop struct {
+	Seq        int64     `json:"seq"`
+	Time       time.Time `json:"time"`
+	Talker     string    `json:"talker"`
+	TalkerName string    `json:"talkerName"`
+	Sender     string    `json:"sender"`
+	SenderName string    `json:"senderName"`
+	IsSelf     bool      `json:"isSelf"`
+}
+
+// SpreadSession 是媒体到达过的一个会话
+type SpreadSession struct {
+	Talker     string    `json:"talker"`
+	TalkerName string    `json:"talkerName"`
+	Count      int       `json:"count"`     // 在该会话中出现的次数
+	FirstTime  time.Time `json:"firstTime"` // 首次到达该会话的时间
+}
+
+// MediaSpread 是同一个 md5 的媒体在各会话中的传播情况
+type MediaSpread struct {
+	MD5          string           `json:"md5"`
+	Kind         string           `json:"kind"`            // SpreadKindImage、SpreadKindVideo 或 SpreadKindFile
+	Title        string           `json:"title,omitempty"` // 文件名，仅文件有
+	Count        int              `json:"count"`           // 消息数
+	SessionCount int              `json:"sessionCount"`    // 到达的会话数
+	FirstTime    time.Time        `json:"firstTime"`
+	LastTime     time.Time        `json:"lastTime"`
+	Origin       *SpreadHop       `json:"origin"`   // 最早的一次发送
+	Chain        []*SpreadHop     `json:"chain"`    // 按时间排序的全部发送
+	Sessions     []*SpreadSession `json:"sessions"` // 按首次到达时间排序
+}
+
+// MediaSpreadReport 是跨会话媒体去重报告
+type MediaSpreadReport struct {
+	Total int            `json:"total"`
+	Items []*MediaSpread `json:"items"`
+}
+
+// MediaKeyOf 返回图片、视频和文件消息的媒体种类、md5 (小写) 和文件名，其他消息或没有 md5 时 ok 为 false
+func MediaKeyOf(m *Message) (kind, md5, title string, ok bool) {
+	if m.Contents == nil {
+		return "", "", "", false
+	}
+	switch {
+	case m.Type == MessageTypeImage:
+		md5, _ = m.Contents["md5"].(string)
+		kind = SpreadKindImage
+	case m.Type == MessageTypeVideo:
+		if v := NewVideoItem(m); v != nil {
+			md5 = v.MD5
+		}
+		kind = SpreadKindVideo
+	default:
+		if f := NewFileAttachment(m); f != nil {
+			md5, title = f.MD5, f.Title
+		}
+		kind = SpreadKindFile
+	}
+	md5 = strings.ToLower(md5)
+	if md5 == "" {
+		return "", "", "", false
+	}
+	return kind, md5, title, true
+}
+
+// NewSpreadHop 从消息生成一次发送
+func NewSpreadHop(m *Message) *SpreadHop {
+	return &SpreadHop{
+		Seq:        m.Seq,
+		Time:       m.Time,
+		Talker:     m.Talker,
+		TalkerName: m.TalkerName,
+		Sender:     m.Sender,
+		SenderName: m.SenderName,
+		IsSelf:     m.IsSelf,
+	}
+}
+
+// Finish 将传播链按时间排序，并计算起点、时间范围和到达的会话
+func (s *MediaSpread) Finish() {
+	sort.SliceStable(s.Chain, func(i, j int) bool { return s.Chain[i].Time.Before(s.Chain[j].Time) })
+	s.Count = len(s.Chain)
+	s.Sessions = []*SpreadSession{}
+	if s.Count == 0 {
+		return
+	}
+	s.Origin = s.Chain[0]
+	s.FirstTime = s.Chain[0].Time
+	s.LastTime = s.Chain[s.Count-1].Time
+
+	byTalker := make(map[string]*SpreadSession)
+	for _, hop := range s.Chain {
+		sess, ok := byTalker[hop.Talker]
+		if !ok {
+			sess = &SpreadSession{Talker: hop.Talker, TalkerName: hop.TalkerName, FirstTime: hop.Time}
+			byTalker[hop.Talker] = sess
+			s.Sessions = append(s.Sessions, sess)
+		}
+		sess.Count++
+	}
+	s.SessionCount = len(s.Sessions)
+}
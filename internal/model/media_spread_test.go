@@ -0,0 +1,47 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMediaKeyOf(t *testing.T) {
+	cases := []struct {
+		typ       int64
+		data      string
+		kind, md5 string
+		ok        bool
+	}{
+		{MessageTypeImage, `<msg><img md5="ABC" /></msg>`, SpreadKindImage, "abc", true},
+		{MessageTypeVideo, `<msg><videomsg md5="" rawmd5="RAW" /></msg>`, SpreadKindVideo, "raw", true},
+		{MessageTypeShare, `<msg><appmsg><title>a.pdf</title><type>6</type><md5>F1</md5></appmsg></msg>`, SpreadKindFile, "f1", true},
+		{MessageTypeShare, `<msg><appmsg><title>链接</title><type>5</type><url>https://example.com</url></appmsg></msg>`, "", "", false},
+		{MessageTypeImage, `<msg><img /></msg>`, "", "", false},
+	}
+	for _, c := range cases {
+		m := &Message{Type: c.typ}
+		if err := m.ParseMediaInfo(c.data); err != nil {
+			t.Fatalf("ParseMediaInfo 失败: %v", err)
+		}
+		kind, md5, _, ok := MediaKeyOf(m)
+		if ok != c.ok || kind != c.kind || md5 != c.md5 {
+			t.Errorf("%s: 得到 (%q, %q, %v)", c.data, kind, md5, ok)
+		}
+	}
+}
+
+func TestMediaSpreadFinish(t *testing.T) {
+	t1 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := &MediaSpread{Chain: []*SpreadHop{
+		{Time: t1.Add(2 * time.Hour), Talker: "b"},
+		{Time: t1, Talker: "a", Sender: "origin"},
+		{Time: t1.Add(time.Hour), Talker: "b"},
+	}}
+	s.Finish()
+	if s.Origin.Sender != "origin" || s.Count != 3 || s.SessionCount != 2 || !s.FirstTime.Equal(t1) || !s.LastTime.Equal(t1.Add(2*time.Hour)) {
+		t.Errorf("传播汇总错误: %+v", s)
+	}
+	if s.Sessions[1].Talker != "b" || s.Sessions[1].Count != 2 || !s.Sessions[1].FirstTime.Equal(t1.Add(time.Hour)) {
+		t.Errorf("会话汇总错误: %+v", s.Sessions[1])
+	}
+}
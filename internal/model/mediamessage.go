@@ -46,10 +46,10 @@ type Image struct {
 }
 
 type Video struct {
-	Md5    string `xml:"md5,attr"`
-	RawMd5 string `xml:"rawmd5,attr"`
-	// Length            string `xml:"length,attr"`
-	// PlayLength        string `xml:"playlength,attr"`
+	Md5        string `xml:"md5,attr"`
+	RawMd5     string `xml:"rawmd5,attr"`
+	Length     string `xml:"length,attr"`     // 字节数
+	PlayLength string `xml:"playlength,attr"` // 时长 (秒)
 	// Offset            string `xml:"offset,attr"`
 	// FromUserName      string `xml:"fromusername,attr"`
 	// Status            string `xml:"status,attr"`
@@ -83,7 +83,7 @@ type App struct {
 	PatMsg            *PatMsg     `xml:"patMsg,omitempty"`            // type 62 拍一拍
 	PatInfo           *PatInfo    `xml:"patinfo,omitempty"`           // type 62 拍一拍 v2
 	FinderLive        *FinderLive `xml:"finderLive,omitempty"`        // type 63 视频号直播
-	WCPayInfo         *WCPayInfo  `xml:"wcpayinfo,omitempty"`         // type 2000 微信转账
+	WCPayInfo         *WCPayInfo  `xml:"wcpayinfo,omitempty"`         // type 2000 微信转账, type 2001 红包
 }
 
 type Emoji struct {
@@ -127,7 +127,7 @@ type Location struct {
 	MapType  string `xml:"maptype,attr"`
 	Adcode   string `xml:"adcode,attr"`
 	CityName string `xml:"cityname,attr"`
-	// PoiName         string `xml:"poiname,attr"`
+	PoiName  string `xml:"poiname,attr"`
 	// PoiId           string `xml:"poiid,attr"`
 	// BuildingId      string `xml:"buildingId,attr"`
 	// FloorName       string `xml:"floorName,attr"`
@@ -357,6 +357,9 @@ type WCPayInfo struct {
 	PayMemo           string `xml:"pay_memo"`          // 支付备注
 	ReceiverUsername  string `xml:"receiver_username"` // 接收方用户名
 	PayerUsername     string `xml:"payer_username"`    // 支付方用户名
+	SenderTitle       string `xml:"sendertitle"`       // 红包祝福语
+	SceneText         string `xml:"scenetext"`         // 红包场景，如"微信红包"
+	PayMsgID          string `xml:"paymsgid"`          // 红包 ID
 }
 
 // FinderFeed 视频号信息
@@ -463,9 +466,14 @@ type DelChatRoomMember struct {
 	Link  QRLink `xml:"link"`
 }
 
+// 第三种消息类型：撤回通知，newmsgid 是被撤回消息的 server id
 type RevokeMsg struct {
 	Content    string `xml:"content"`
 	RevokeTime int    `xml:"revoketime"`
+	Session    string `xml:"session"`
+	MsgID      string `xml:"msgid"`
+	NewMsgID   string `xml:"newmsgid"`
+	ReplaceMsg string `xml:"replacemsg"`
 }
 
 type QRLink struct {
@@ -521,7 +529,13 @@ func (s *SysMsg) String() string {
 	case "delchatroommember":
 		return s.DelChatRoomMemberString()
 	case "revokemsg":
-		return s.RevokeMsg.Content
+		if s.RevokeMsg == nil {
+			return ""
+		}
+		if s.RevokeMsg.Content != "" {
+			return s.RevokeMsg.Content
+		}
+		return s.RevokeMsg.ReplaceMsg
 	}
 	return s.SysMsgTemplateString()
 }
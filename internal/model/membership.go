@@ -0,0 +1,193 @@
+package model
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// 群成员变动事件类型
+const (
+	MembershipJoin   = "join"   // 成员通过二维码或链接加入
+	MembershipInvite = "invite" // 成员被邀请加入
+	MembershipLeave  = "leave"  // 成员退出
+	MembershipKick   = "kick"   // 成员被移出
+	MembershipRename = "rename" // 修改群名
+)
+
+// SelfName 是系统消息中对自己的称呼
+const SelfName = "你"
+
+// MemberRef 表示系统消息中提到的成员。
+// 模板消息中带有账号，纯文本消息中只有昵称，此时 UserName 为空。
+type MemberRef struct {
+	UserName string `json:"userName,omitempty"`
+	NickName string `json:"nickName"`
+}
+
+// MembershipEvent 是从群聊系统消息中解析出的一次成员变动
+type MembershipEvent struct {
+	Seq     int64       `json:"seq"`
+	Time    time.Time   `json:"time"`
+	Type    string      `json:"type"`            // MembershipJoin 等
+	Actor   *MemberRef  `json:"actor,omitempty"` // 操作人：邀请人、二维码分享人、移出成员的人或改名的人
+	Targets []MemberRef `json:"targets,omitempty"`
+	Name    string      `json:"name,omitempty"` // 修改后的群名，仅 MembershipRename
+	Content string      `json:"content"`
+}
+
+// Adds 判断事件是否使成员加入群聊
+func (e *MembershipEvent) Adds() bool {
+	return e.Type == MembershipJoin || e.Type == MembershipInvite
+}
+
+// Removes 判断事件是否使成员离开群聊
+func (e *MembershipEvent) Removes() bool {
+	return e.Type == MembershipLeave || e.Type == MembershipKick
+}
+
+// RosterMember 是某一时刻的群成员
+type RosterMember struct {
+	UserName    string `json:"userName,omitempty"`
+	DisplayName string `json:"displayName"`
+}
+
+// 名称可能被英文或中文引号包裹；"你" 不带引号
+const quotedName = `(?:["“][^"”]*["”]|你)`
+
+var membershipPatterns = []struct {
+	typ string
+	re  *regexp.Regexp
+	// actor/targets/name 为子匹配的下标，0 表示没有
+	actor, targets, name int
+}{
+	{MembershipInvite, regexp.MustCompile(`^(` + quotedName + `)邀请(.+?)加入了群聊`), 1, 2, 0},
+	{MembershipJoin, regexp.MustCompile(`^(` + quotedName + `)通过扫描(` + quotedName + `)分享的二维码加入群聊`), 2, 1, 0},
+	{MembershipJoin, regexp.MustCompile(`^(` + quotedName + `)通过(` + quotedName + `)的?(?:邀请|分享).*加入(?:了)?群聊`), 2, 1, 0},
+	{MembershipKick, regexp.MustCompile(`^(` + quotedName + `)将(.+?)移出了群聊`), 1, 2, 0},
+	{MembershipKick, regexp.MustCompile(`^(你)被(` + quotedName + `)移出群聊`), 2, 1, 0},
+	{MembershipLeave, regexp.MustCompile(`^(` + quotedName + `)(?:已)?退出了?群聊`), 0, 1, 0},
+	{MembershipRename, regexp.MustCompile(`^(` + quotedName + `)修改群名为(["“][^"”]*["”])`), 1, 0, 2},
+}
+
+var (
+	quotedNameRe = regexp.MustCompile(`["“]([^"”]*)["”]`)
+	// 模板消息渲染后的成员格式为 nickname(username)
+	nameWithIDRe = regexp.MustCompile(`^(.*)\(([A-Za-z0-9_\-@.]+)\)$`)
+)
+
+// ParseMembershipEvent 解析群聊系统消息的文本 (Message.Content)，不是成员变动时返回 nil。
+// 支持的消息：邀请加入、扫码或链接加入、移出群聊、退出群聊、修改群名。
+func ParseMembershipEvent(content string) *MembershipEvent {
+	text := strings.TrimSpace(content)
+	for _, p := range membershipPatterns {
+		m := p.re.FindStringSubmatch(text)
+		if m == nil {
+			continue
+		}
+		e := &MembershipEvent{Type: p.typ, Content: content}
+		if p.actor > 0 {
+			if refs := parseMemberRefs(m[p.actor]); len(refs) > 0 {
+				e.Actor = &refs[0]
+			}
+		}
+		if p.targets > 0 {
+			e.Targets = parseMemberRefs(m[p.targets])
+		}
+		if p.name > 0 {
+			e.Name = strings.Trim(m[p.name], `"“”`)
+		}
+		return e
+	}
+	return nil
+}
+
+// ParseSysMsgMembershipEvent 解析 XML 系统消息中的成员变动，content 为渲染后的文本，不是成员变动时返回 nil。
+// 模板消息按模板文本识别事件类型，成员取自占位符对应的成员列表；
+// delchatroommember 消息按 plain 文本识别，成员账号按顺序取自 memberlist。
+func ParseSysMsgMembershipEvent(s *SysMsg, content string) *MembershipEvent {
+	switch {
+	case s.SysMsgTemplate != nil:
+		tpl := s.SysMsgTemplate.ContentTemplate
+		e := ParseMembershipEvent(tpl.Template)
+		if e == nil {
+			return nil
+		}
+		members := make(map[string][]MemberRef)
+		for _, link := range tpl.LinkList.Links {
+			for _, m := range link.MemberList.Members {
+				members["$"+link.Name+"$"] = append(members["$"+link.Name+"$"], MemberRef{UserName: m.Username, NickName: m.Nickname})
+			}
+		}
+		expand := func(refs []MemberRef) []MemberRef {
+			var out []MemberRef
+			for _, ref := range refs {
+				if list, ok := members[ref.NickName]; ok {
+					out = append(out, list...)
+				} else if !strings.HasPrefix(ref.NickName, "$") {
+					out = append(out, ref)
+				}
+			}
+			return out
+		}
+		if e.Actor != nil {
+			refs := expand([]MemberRef{*e.Actor})
+			e.Actor = nil
+			if len(refs) > 0 {
+				e.Actor = &refs[0]
+			}
+		}
+		e.Targets = expand(e.Targets)
+		if strings.HasPrefix(e.Name, "$") {
+			// 群名不在成员列表中，取渲染后文本中的群名
+			e.Name = ""
+			if r := ParseMembershipEvent(content); r != nil {
+				e.Name = r.Name
+			}
+		}
+		e.Content = content
+		return e
+	case s.DelChatRoomMember != nil:
+		e := ParseMembershipEvent(s.DelChatRoomMember.Plain)
+		if e == nil {
+			return nil
+		}
+		usernames := s.DelChatRoomMember.Link.MemberList.Usernames
+		var named []int
+		for i, ref := range e.Targets {
+			if ref.NickName != SelfName && ref.UserName == "" {
+				named = append(named, i)
+			}
+		}
+		if len(named) == len(usernames) {
+			for i, idx := range named {
+				e.Targets[idx].UserName = usernames[i].Value
+			}
+		}
+		e.Content = content
+		return e
+	}
+	return ParseMembershipEvent(content)
+}
+
+// parseMemberRefs 从 `"张三"、"李四"和你` 这样的片段中提取成员
+func parseMemberRefs(s string) []MemberRef {
+	var refs []MemberRef
+	for _, m := range quotedNameRe.FindAllStringSubmatch(s, -1) {
+		for _, name := range strings.Split(m[1], "、") {
+			if name = strings.TrimSpace(name); name == "" {
+				continue
+			}
+			ref := MemberRef{NickName: name}
+			if sub := nameWithIDRe.FindStringSubmatch(name); sub != nil {
+				ref = MemberRef{UserName: sub[2], NickName: sub[1]}
+			}
+			refs = append(refs, ref)
+		}
+	}
+	// 去掉引号中的内容后仍有 "你"，说明自己也在其中
+	if strings.Contains(quotedNameRe.ReplaceAllString(s, ""), SelfName) {
+		refs = append(refs, MemberRef{NickName: SelfName})
+	}
+	return refs
+}
@@ -0,0 +1,83 @@
+package model
+
+import (
+	"encoding/xml"
+	"fmt"
+	"testing"
+)
+
+func TestParseMembershipEvent(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string // 类型|操作人|目标|群名
+	}{
+		{`"张三"邀请"李四、王五"加入了群聊`, "invite|张三|李四,王五|"},
+		{`"张三(wxid_zs)"邀请你和"李四(wxid_ls)"加入了群聊`, "invite|张三|wxid_ls:李四,你|"},
+		{`你邀请"李四"加入了群聊  撤销`, "invite|你|李四|"},
+		{`"李四"通过扫描"张三"分享的二维码加入群聊`, "join|张三|李四|"},
+		{`"李四"通过"张三"的邀请链接加入了群聊`, "join|张三|李四|"},
+		{`你将"李四"移出了群聊`, "kick|你|李四|"},
+		{`你被"张三"移出群聊`, "kick|张三|你|"},
+		{`"李四"退出了群聊`, "leave||李四|"},
+		{`"张三"修改群名为“周末爬山”`, "rename|张三||周末爬山"},
+		{`"李四"与群里其他人都不是朋友关系，请注意隐私安全`, ""},
+		{`你好`, ""},
+	}
+	for _, tt := range tests {
+		e := ParseMembershipEvent(tt.content)
+		got := ""
+		if e != nil {
+			actor := ""
+			if e.Actor != nil {
+				actor = e.Actor.NickName
+			}
+			targets := ""
+			for i, ref := range e.Targets {
+				if i > 0 {
+					targets += ","
+				}
+				if ref.UserName != "" {
+					targets += ref.UserName + ":"
+				}
+				targets += ref.NickName
+			}
+			got = fmt.Sprintf("%s|%s|%s|%s", e.Type, actor, targets, e.Name)
+		}
+		if got != tt.want {
+			t.Errorf("ParseMembershipEvent(%q) = %q, 期望 %q", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestParseSysMsgMembershipEvent(t *testing.T) {
+	tpl := `<sysmsg type="sysmsgtemplate"><sysmsgtemplate><content_template type="tmpl_type_profile"><plain><![CDATA[]]></plain>` +
+		`<template><![CDATA["$username$"邀请"$names$"加入了群聊]]></template><link_list>` +
+		`<link name="username" type="link_profile"><memberlist><member><username><![CDATA[wxid_zs]]></username><nickname><![CDATA[张(三)]]></nickname></member></memberlist></link>` +
+		`<link name="names" type="link_profile"><memberlist><member><username><![CDATA[wxid_ls]]></username><nickname><![CDATA[李四、"小李"]]></nickname></member>` +
+		`<member><username><![CDATA[wxid_ww]]></username><nickname><![CDATA[王五]]></nickname></member></memberlist><separator><![CDATA[、]]></separator></link>` +
+		`</link_list></content_template></sysmsgtemplate></sysmsg>`
+	var s SysMsg
+	if err := xml.Unmarshal([]byte(tpl), &s); err != nil {
+		t.Fatal(err)
+	}
+	// 昵称中含有分隔符和引号，按渲染后的文本无法正确拆分
+	e := ParseSysMsgMembershipEvent(&s, s.String())
+	if e == nil || e.Type != MembershipInvite || e.Actor == nil || *e.Actor != (MemberRef{UserName: "wxid_zs", NickName: "张(三)"}) {
+		t.Fatalf("模板消息解析错误: %+v", e)
+	}
+	want := []MemberRef{{UserName: "wxid_ls", NickName: `李四、"小李"`}, {UserName: "wxid_ww", NickName: "王五"}}
+	if fmt.Sprint(e.Targets) != fmt.Sprint(want) {
+		t.Errorf("模板消息成员错误: %+v", e.Targets)
+	}
+
+	del := `<sysmsg type="delchatroommember"><delchatroommember><plain><![CDATA[你邀请"李四"加入了群聊  撤销]]></plain>` +
+		`<link><memberlist><username><![CDATA[wxid_ls]]></username></memberlist></link></delchatroommember></sysmsg>`
+	s = SysMsg{}
+	if err := xml.Unmarshal([]byte(del), &s); err != nil {
+		t.Fatal(err)
+	}
+	e = ParseSysMsgMembershipEvent(&s, s.String())
+	if e == nil || e.Type != MembershipInvite || len(e.Targets) != 1 || e.Targets[0] != (MemberRef{UserName: "wxid_ls", NickName: "李四"}) {
+		t.Errorf("delchatroommember 消息解析错误: %+v", e)
+	}
+}
@@ -48,6 +48,9 @@ const (
 
 	// MessageTypeSystem 系统
 	MessageTypeSystem = 10000
+
+	// MessageTypeSysMsg XML 格式的系统消息 (撤回等)
+	MessageTypeSysMsg = 10002
 )
 
 const (
@@ -107,25 +110,66 @@ const (
 )
 
 type Message struct {
-	Version      string                 `json:"-"`                  // 消息版本，内部判断
-	Seq          int64                  `json:"seq"`                // 消息序号，10位时间戳 + 3位序号
-	Time         time.Time              `json:"time"`               // 消息创建时间，10位时间戳
-	Talker       string                 `json:"talker"`             // 聊天对象，微信 ID or 群 ID
-	TalkerName   string                 `json:"talkerName"`         // 聊天对象名称
-	IsChatRoom   bool                   `json:"isChatRoom"`         // 是否为群聊消息
-	Sender       string                 `json:"sender"`             // 发送人，微信 ID
-	SenderName   string                 `json:"senderName"`         // 发送人名称
-	IsSelf       bool                   `json:"isSelf"`             // 是否为自己发送的消息
-	Type         int64                  `json:"type"`               // 消息类型
-	SubType      int64                  `json:"subType"`            // 消息子类型
-	Content      string                 `json:"content"`            // 消息内容，文字聊天内容
-	Contents     map[string]interface{} `json:"contents,omitempty"` // 消息内容，多媒体消息，采用更灵活的记录方式
-	BigHeadURL   string                 `json:"bigHeadURL"`         // 用户大头像
-	SmallHeadURL string                 `json:"smallHeadURL"`       // 用户小头像
+	Version      string                 `json:"-"`                         // 消息版本，内部判断
+	Seq          int64                  `json:"seq"`                       // 消息序号，10位时间戳 + 3位序号 (macOS 为 6 位本地序号)
+	ServerID     int64                  `json:"serverId,string,omitempty"` // 服务端消息 ID，撤回通知中以 newmsgid 引用
+	Time         time.Time              `json:"time"`                      // 消息创建时间，10位时间戳
+	Talker       string                 `json:"talker"`                    // 聊天对象，微信 ID or 群 ID
+	TalkerName   string                 `json:"talkerName"`                // 聊天对象名称
+	IsChatRoom   bool                   `json:"isChatRoom"`                // 是否为群聊消息
+	Sender       string                 `json:"sender"`                    // 发送人，微信 ID
+	SenderName   string                 `json:"senderName"`                // 发送人名称
+	IsSelf       bool                   `json:"isSelf"`                    // 是否为自己发送的消息
+	Type         int64                  `json:"type"`                      // 消息类型
+	SubType      int64                  `json:"subType"`                   // 消息子类型
+	Content      string                 `json:"content"`                   // 消息内容，文字聊天内容
+	Contents     map[string]interface{} `json:"contents,omitempty"`        // 消息内容，多媒体消息，采用更灵活的记录方式
+	BigHeadURL   string                 `json:"bigHeadURL"`                // 用户大头像
+	SmallHeadURL string                 `json:"smallHeadURL"`              // 用户小头像
+	Revoked      bool                   `json:"revoked,omitempty"`         // 消息已被撤回 (数据库中仍保留原消息)
+	ReplyTo      int64                  `json:"replyTo,omitempty"`         // 引用消息所回复的消息序号，原消息不在数据库中时为 0
 
 	// Debug Info
 	MediaMsg *MediaMsg `json:"mediaMsg,omitempty"` // 原始多媒体消息，XML 格式
 	SysMsg   *SysMsg   `json:"sysMsg,omitempty"`   // 原始系统消息，XML 格式
+
+	sysMsg *SysMsg // 解析后的 XML 系统消息，用于读取其中的结构化成员列表
+}
+
+// Revocation 是一次消息撤回：撤回通知及仍保留在数据库中的原消息
+type Revocation struct {
+	Seq      int64     `json:"seq"`  // 撤回通知的序号
+	Time     time.Time `json:"time"` // 撤回时间
+	Talker   string    `json:"talker"`
+	Content  string    `json:"content"`                   // 撤回提示，例如 "张三" 撤回了一条消息
+	ServerID int64     `json:"serverId,string,omitempty"` // 被撤回消息的服务端 ID，纯文本通知中没有
+	Original *Message  `json:"original,omitempty"`        // 原消息，已被删除或覆盖时为空
+}
+
+// ThreadNode 是回复树中的一条消息及直接引用它的回复
+type ThreadNode struct {
+	Message *Message      `json:"message"`
+	Replies []*ThreadNode `json:"replies,omitempty"`
+}
+
+// ReferServerID 返回引用消息所引用原消息的服务端 ID，不是引用消息时返回 0
+func (m *Message) ReferServerID() int64 {
+	if m.Type != MessageTypeShare || m.SubType != MessageSubTypeQuote {
+		return 0
+	}
+	if refer, ok := m.Contents["refer"].(*Message); ok {
+		return refer.ServerID
+	}
+	return 0
+}
+
+// MembershipEvent 解析群成员变动的系统消息，不是成员变动时返回 nil。
+// XML 系统消息使用其中带账号的成员列表，纯文本系统消息按文本解析。
+func (m *Message) MembershipEvent() *MembershipEvent {
+	if m.sysMsg != nil {
+		return ParseSysMsgMembershipEvent(m.sysMsg, m.Content)
+	}
+	return ParseMembershipEvent(m.Content)
 }
 
 func (m *Message) ParseMediaInfo(data string) error {
@@ -137,7 +181,7 @@ func (m *Message) ParseMediaInfo(data string) error {
 		return nil
 	}
 
-	if m.Type == MessageTypeSystem {
+	if m.Type == MessageTypeSystem || m.Type == MessageTypeSysMsg {
 		m.Sender = "系统消息"
 		m.SenderName = ""
 		var sysMsg SysMsg
@@ -145,10 +189,18 @@ func (m *Message) ParseMediaInfo(data string) error {
 			m.Content = data
 			return nil
 		}
+		m.sysMsg = &sysMsg
 		if Debug {
 			m.SysMsg = &sysMsg
 		}
 		m.Content = sysMsg.String()
+		// 撤回通知记录被撤回消息的 server id
+		if sysMsg.RevokeMsg != nil && sysMsg.RevokeMsg.NewMsgID != "" {
+			if m.Contents == nil {
+				m.Contents = make(map[string]interface{})
+			}
+			m.Contents["revokedMsgId"] = sysMsg.RevokeMsg.NewMsgID
+		}
 		return nil
 	}
 
@@ -182,6 +234,12 @@ func (m *Message) ParseMediaInfo(data string) error {
 		if msg.Video.RawMd5 != "" {
 			m.Contents["rawmd5"] = msg.Video.RawMd5
 		}
+		if v, err := strconv.Atoi(msg.Video.PlayLength); err == nil {
+			m.Contents["playlength"] = v
+		}
+		if v, err := strconv.ParseInt(msg.Video.Length, 10, 64); err == nil {
+			m.Contents["length"] = v
+		}
 	case MessageTypeAnimation:
 		m.Contents["cdnurl"] = msg.Emoji.CdnURL
 		m.Contents["aeskey"] = msg.Emoji.AesKey
@@ -190,6 +248,7 @@ func (m *Message) ParseMediaInfo(data string) error {
 		m.Contents["y"] = msg.Location.Y
 		m.Contents["label"] = msg.Location.Label
 		m.Contents["cityname"] = msg.Location.CityName
+		m.Contents["poiname"] = msg.Location.PoiName
 	case MessageTypeShare:
 		m.SubType = int64(msg.App.Type)
 		switch m.SubType {
@@ -203,6 +262,12 @@ func (m *Message) ParseMediaInfo(data string) error {
 			// 文件
 			m.Contents["title"] = msg.App.Title
 			m.Contents["md5"] = msg.App.MD5
+			if msg.App.AppAttach != nil {
+				m.Contents["fileext"] = msg.App.AppAttach.FileExt
+				if size, err := strconv.ParseInt(msg.App.AppAttach.TotalLen, 10, 64); err == nil {
+					m.Contents["size"] = size
+				}
+			}
 		case MessageSubTypeMergeForward, MessageSubTypeNote, MessageSubTypeChatRoomNotice:
 			// 合并转发 & 笔记
 			m.Contents["title"] = msg.App.Title
@@ -226,6 +291,8 @@ func (m *Message) ParseMediaInfo(data string) error {
 				break
 			}
 			m.Contents["title"] = strings.TrimSpace(strings.ReplaceAll(msg.App.FinderFeed.Desc, "\n", " "))
+			m.Contents["displayname"] = msg.App.FinderFeed.Nickname
+			m.Contents["objectId"] = msg.App.FinderFeed.ObjectID
 			if len(msg.App.FinderFeed.MediaList.Media) > 0 {
 				m.Contents["url"] = msg.App.FinderFeed.MediaList.Media[0].URL
 			}
@@ -241,6 +308,7 @@ func (m *Message) ParseMediaInfo(data string) error {
 				Sender:     msg.App.ReferMsg.ChatUsr,
 				SenderName: msg.App.ReferMsg.DisplayName,
 			}
+			subMsg.ServerID, _ = strconv.ParseInt(msg.App.ReferMsg.SvrID, 10, 64)
 			if subMsg.Sender == "" {
 				subMsg.Sender = msg.App.ReferMsg.FromUsr
 			}
@@ -294,6 +362,19 @@ func (m *Message) ParseMediaInfo(data string) error {
 				payMemo = "(" + msg.App.WCPayInfo.PayMemo + ")"
 			}
 			m.Content = fmt.Sprintf("[转账|%s%s]%s", _type, msg.App.WCPayInfo.FeeDesc, payMemo)
+			m.Contents["paySubType"] = msg.App.WCPayInfo.PaySubType
+			m.Contents["feeDesc"] = msg.App.WCPayInfo.FeeDesc
+			m.Contents["payMemo"] = msg.App.WCPayInfo.PayMemo
+			m.Contents["transferId"] = msg.App.WCPayInfo.TransferID
+			m.Contents["payer"] = msg.App.WCPayInfo.PayerUsername
+			m.Contents["receiver"] = msg.App.WCPayInfo.ReceiverUsername
+		case MessageSubTypeRedEnvelope:
+			// 红包，消息中没有金额
+			if msg.App.WCPayInfo == nil {
+				break
+			}
+			m.Contents["title"] = msg.App.WCPayInfo.SenderTitle
+			m.Contents["payMsgId"] = msg.App.WCPayInfo.PayMsgID
 		}
 	}
 
@@ -573,10 +654,24 @@ type SearchResult struct {
 	Items []*SearchItem `json:"items"`
 }
 
+// MessagePage 游标分页结果，Items 始终按 seq 升序排列
+type MessagePage struct {
+	Items      []*Message `json:"items"`
+	NextCursor int64      `json:"next_cursor"` // 继续同方向翻页时使用的游标，0 表示没有更多消息
+	HasMore    bool       `json:"has_more"`
+}
+
 // SearchItem 搜索结果项
 type SearchItem struct {
 	*Message
-	Highlight string `json:"highlight"`
+	Highlight string       `json:"highlight"`
+	Matches   []MatchRange `json:"matches,omitempty"` // content 中的匹配位置
+}
+
+// MatchRange 是一处匹配在消息内容中的位置 [start, end)，按字符 (Unicode 码点) 计
+type MatchRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
 }
 
 func (m *Message) CSV(host string) []string {
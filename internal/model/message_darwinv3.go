@@ -23,6 +23,8 @@ import (
 // ConBlob BLOB
 // )
 type MessageDarwinV3 struct {
+	MesLocalID    int64  `json:"mesLocalID"`
+	MesSvrID      int64  `json:"mesSvrID"`
 	MsgCreateTime int64  `json:"msgCreateTime"`
 	MsgContent    string `json:"msgContent"`
 	MessageType   int64  `json:"messageType"`
@@ -32,6 +34,8 @@ type MessageDarwinV3 struct {
 func (m *MessageDarwinV3) Wrap(talker string) *Message {
 
 	_m := &Message{
+		Seq:        m.Seq(),
+		ServerID:   m.MesSvrID,
 		Time:       time.Unix(m.MsgCreateTime, 0),
 		Type:       m.MessageType,
 		Talker:     talker,
@@ -55,3 +59,13 @@ func (m *MessageDarwinV3) Wrap(talker string) *Message {
 
 	return _m
 }
+
+// DarwinSeqScale 是 macOS 消息序号中本地序号部分的取值范围
+const DarwinSeqScale = 1000000
+
+// Seq 返回消息序号。macOS 的消息表没有全局序号，这里按 "10位时间戳 + 6位本地序号" 构造：
+// 同一秒内的消息按 mesLocalID 排序，同一会话中只有同一秒内相隔 100 万条的消息才会重复，
+// 且序号仍在 JavaScript 的安全整数范围内。对应的分页条件见 store/repo 中的 darwinKeysetClause。
+func (m *MessageDarwinV3) Seq() int64 {
+	return m.MsgCreateTime*DarwinSeqScale + m.MesLocalID%DarwinSeqScale
+}
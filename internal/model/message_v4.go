@@ -49,6 +49,7 @@ func (m *MessageV4) Wrap(talker string) *Message {
 
 	_m := &Message{
 		Seq:        m.SortSeq,
+		ServerID:   m.ServerID,
 		Time:       time.Unix(m.CreateTime, 0),
 		Talker:     talker,
 		IsChatRoom: strings.HasSuffix(talker, "@chatroom"),
@@ -0,0 +1,57 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// VideoItem 是一条视频消息
+type VideoItem struct {
+	Seq        int64     `json:"seq"`
+	Time       time.Time `json:"time"`
+	Talker     string    `json:"talker"`
+	TalkerName string    `json:"talkerName"`
+	Sender     string    `json:"sender"`
+	SenderName string    `json:"senderName"`
+	IsSelf     bool      `json:"isSelf"`
+	MD5        string    `json:"md5"`
+	Duration   int       `json:"duration"` // 时长 (秒)
+	Size       int64     `json:"size"`     // 字节数，优先取本地文件记录中的大小
+	Present    bool      `json:"present"`  // 本地文件记录 (如 video_hardlink_info_v4) 中是否有该视频
+	Path       string    `json:"path,omitempty"`
+}
+
+// VideoCatalog 是视频列表
+type VideoCatalog struct {
+	Total         int          `json:"total"`
+	TotalSize     int64        `json:"totalSize"`
+	TotalDuration int          `json:"totalDuration"` // 总时长 (秒)
+	Items         []*VideoItem `json:"items"`
+}
+
+// NewVideoItem 从视频消息生成视频，其他消息或没有 md5 时返回 nil
+func NewVideoItem(m *Message) *VideoItem {
+	if m.Type != MessageTypeVideo || m.Contents == nil {
+		return nil
+	}
+	key, _ := m.Contents["md5"].(string)
+	if key == "" {
+		key, _ = m.Contents["rawmd5"].(string)
+	}
+	if key == "" {
+		return nil
+	}
+	v := &VideoItem{
+		Seq:        m.Seq,
+		Time:       m.Time,
+		Talker:     m.Talker,
+		TalkerName: m.TalkerName,
+		Sender:     m.Sender,
+		SenderName: m.SenderName,
+		IsSelf:     m.IsSelf,
+		MD5:        strings.ToLower(key),
+	}
+	v.Duration, _ = m.Contents["playlength"].(int)
+	v.Size, _ = m.Contents["length"].(int64)
+	return v
+}
@@ -0,0 +1,25 @@
+package model
+
+import "testing"
+
+func TestNewVideoItem(t *testing.T) {
+	m := &Message{Type: MessageTypeVideo, Talker: "alice"}
+	if err := m.ParseMediaInfo(`<msg><videomsg md5="ABC123" rawmd5="def456" length="1048576" playlength="15" /></msg>`); err != nil {
+		t.Fatalf("ParseMediaInfo 失败: %v", err)
+	}
+	v := NewVideoItem(m)
+	if v == nil {
+		t.Fatal("未解析出视频")
+	}
+	if v.MD5 != "abc123" || v.Duration != 15 || v.Size != 1048576 || v.Present {
+		t.Errorf("视频解析错误: %+v", v)
+	}
+
+	m = &Message{Type: MessageTypeVideo}
+	if err := m.ParseMediaInfo(`<msg><videomsg /></msg>`); err != nil {
+		t.Fatalf("ParseMediaInfo 失败: %v", err)
+	}
+	if NewVideoItem(m) != nil {
+		t.Error("没有 md5 的视频不应生成")
+	}
+}
@@ -4,11 +4,13 @@ import (
 	"sync"
 	"time"
 
+	"github.com/afumu/wetrace/decrypt"
 	"github.com/rs/zerolog/log"
 )
 
 // SyncFunc is the function called to perform a sync operation.
-type SyncFunc func() error
+// It returns the decryption stats of the run, which may be nil.
+type SyncFunc func() (*decrypt.Stats, error)
 
 // Scheduler manages automatic sync scheduling.
 type Scheduler struct {
@@ -17,6 +19,8 @@ type Scheduler struct {
 	intervalMin    int
 	lastSyncTime   time.Time
 	lastSyncStatus string
+	lastDuration   time.Duration
+	lastStats      *decrypt.Stats
 	isSyncing      bool
 	syncFunc       SyncFunc
 	ticker         *time.Ticker
@@ -39,6 +43,13 @@ type Status struct {
 	LastSyncTime   string `json:"last_sync_time"`
 	LastSyncStatus string `json:"last_sync_status"`
 	IsSyncing      bool   `json:"is_syncing"`
+
+	// 上次同步的耗时和增量解密统计
+	LastDurationMs     int64 `json:"last_duration_ms"`
+	LastBytesDecrypted int64 `json:"last_bytes_decrypted"`
+	LastPagesDecrypted int64 `json:"last_pages_decrypted"`
+	LastPagesReused    int64 `json:"last_pages_reused"`
+	LastFilesKept      int   `json:"last_files_kept"` // 解密失败、沿用上一次结果的文件数
 }
 
 // GetStatus returns the current scheduler status.
@@ -50,13 +61,21 @@ func (s *Scheduler) GetStatus() Status {
 	if !s.lastSyncTime.IsZero() {
 		lastTime = s.lastSyncTime.Format(time.RFC3339)
 	}
-	return Status{
+	st := Status{
 		Enabled:        s.enabled,
 		IntervalMin:    s.intervalMin,
 		LastSyncTime:   lastTime,
 		LastSyncStatus: s.lastSyncStatus,
 		IsSyncing:      s.isSyncing,
+		LastDurationMs: s.lastDuration.Milliseconds(),
+	}
+	if s.lastStats != nil {
+		st.LastBytesDecrypted = s.lastStats.BytesDecrypted
+		st.LastPagesDecrypted = s.lastStats.DecryptedPages
+		st.LastPagesReused = s.lastStats.ReusedPages
+		st.LastFilesKept = s.lastStats.KeptFiles
 	}
+	return st
 }
 
 // Configure updates the scheduler settings and restarts if needed.
@@ -127,17 +146,24 @@ func (s *Scheduler) RunSync() {
 	s.mu.Unlock()
 
 	log.Info().Msg("sync started")
-	err := s.syncFunc()
+	start := time.Now()
+	stats, err := s.syncFunc()
 
 	s.mu.Lock()
 	s.isSyncing = false
 	s.lastSyncTime = time.Now()
+	s.lastDuration = s.lastSyncTime.Sub(start)
+	s.lastStats = stats
 	if err != nil {
 		s.lastSyncStatus = "failed"
 		log.Error().Err(err).Msg("sync failed")
 	} else {
 		s.lastSyncStatus = "success"
-		log.Info().Msg("sync completed")
+		ev := log.Info().Dur("duration", s.lastDuration)
+		if stats != nil {
+			ev = ev.Int64("bytes_decrypted", stats.BytesDecrypted).Int64("pages_reused", stats.ReusedPages)
+		}
+		ev.Msg("sync completed")
 	}
 	s.mu.Unlock()
 }
@@ -7,9 +7,11 @@ import (
 	"os"
 	"os/exec"
 	"os/signal"
+	"path/filepath"
 	"runtime"
 	"syscall"
 
+	"github.com/afumu/wetrace/internal/account"
 	"github.com/afumu/wetrace/store"
 	"github.com/afumu/wetrace/web"
 	"github.com/spf13/viper"
@@ -80,8 +82,32 @@ func main() {
 		log.Fatalf("初始化 store 失败: %v", err)
 	}
 	defer newStore.Close()
+	// 跨分片查询 (全局搜索、年度报告等) 的并发数，未配置时按 CPU 核数取默认值
+	shardConcurrency := viper.GetInt("SHARD_CONCURRENCY")
+	if shardConcurrency > 0 {
+		newStore.SetShardConcurrency(shardConcurrency)
+	}
 	log.Println("Store 初始化成功。")
 
+	// --- 账号注册表 ---
+	// .env 中的配置构成默认账号，其他账号保存在 accounts.json 中
+	accountsDir := viper.GetString("config_path")
+	if accountsDir == "" {
+		home, _ := os.UserHomeDir()
+		accountsDir = filepath.Join(home, ".wetrace")
+	}
+	accounts, err := account.NewRegistry(accountsDir, account.Account{
+		Name:            viper.GetString("ACCOUNT_NAME"),
+		WorkDir:         workDir,
+		WechatDbSrcPath: viper.GetString("WECHAT_DB_SRC_PATH"),
+		WechatDbKey:     viper.GetString("WECHAT_DB_KEY"),
+		ImageKey:        imageKey,
+		XorKey:          xorKey,
+	})
+	if err != nil {
+		log.Fatalf("加载账号注册表失败: %v", err)
+	}
+
 	// --- 准备静态文件系统 ---
 	staticFS, err := fs.Sub(uiDist, "ui/dist")
 	if err != nil {
@@ -104,8 +130,10 @@ func main() {
 		AIAPIKey:        viper.GetString("AI_API_KEY"),
 		AIBaseURL:       viper.GetString("AI_BASE_URL"),
 		AIModel:         viper.GetString("AI_MODEL"),
+
+		ShardConcurrency: shardConcurrency,
 	}
-	webService := web.NewService(newStore, &webConf, staticFS)
+	webService := web.NewService(newStore, &webConf, staticFS, accounts)
 
 	// --- 启动服务 ---
 	if err := webService.Start(); err != nil {
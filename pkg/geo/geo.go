@@ -0,0 +1,65 @@
+// Package geo 提供国内地图坐标系 (GCJ-02，火星坐标) 与 GPS 坐标系 (WGS-84) 之间的转换。
+// 微信位置消息中的坐标为 GCJ-02，GIS 工具和 GeoJSON/KML/GPX 格式使用 WGS-84。
+package geo
+
+import "math"
+
+const (
+	earthA  = 6378245.0              // 克拉索夫斯基椭球长半轴
+	earthEE = 0.00669342162296594323 // 椭球第一偏心率的平方
+)
+
+// OutOfChina 判断坐标是否在国内范围之外，国外的坐标不做偏移
+func OutOfChina(lat, lng float64) bool {
+	return lng < 72.004 || lng > 137.8347 || lat < 0.8293 || lat > 55.8271
+}
+
+// WGS84ToGCJ02 将 WGS-84 坐标转换为 GCJ-02 坐标
+func WGS84ToGCJ02(lat, lng float64) (float64, float64) {
+	if OutOfChina(lat, lng) {
+		return lat, lng
+	}
+	dLat := transformLat(lng-105.0, lat-35.0)
+	dLng := transformLng(lng-105.0, lat-35.0)
+	radLat := lat / 180.0 * math.Pi
+	magic := math.Sin(radLat)
+	magic = 1 - earthEE*magic*magic
+	sqrtMagic := math.Sqrt(magic)
+	dLat = (dLat * 180.0) / ((earthA * (1 - earthEE)) / (magic * sqrtMagic) * math.Pi)
+	dLng = (dLng * 180.0) / (earthA / sqrtMagic * math.Cos(radLat) * math.Pi)
+	return lat + dLat, lng + dLng
+}
+
+// GCJ02ToWGS84 将 GCJ-02 坐标转换为 WGS-84 坐标。
+// 偏移没有解析逆变换，按正向偏移迭代逼近，误差小于 1 厘米。
+func GCJ02ToWGS84(lat, lng float64) (float64, float64) {
+	if OutOfChina(lat, lng) {
+		return lat, lng
+	}
+	wLat, wLng := lat, lng
+	for i := 0; i < 10; i++ {
+		gLat, gLng := WGS84ToGCJ02(wLat, wLng)
+		dLat, dLng := gLat-lat, gLng-lng
+		wLat, wLng = wLat-dLat, wLng-dLng
+		if math.Abs(dLat) < 1e-9 && math.Abs(dLng) < 1e-9 {
+			break
+		}
+	}
+	return wLat, wLng
+}
+
+func transformLat(x, y float64) float64 {
+	ret := -100.0 + 2.0*x + 3.0*y + 0.2*y*y + 0.1*x*y + 0.2*math.Sqrt(math.Abs(x))
+	ret += (20.0*math.Sin(6.0*x*math.Pi) + 20.0*math.Sin(2.0*x*math.Pi)) * 2.0 / 3.0
+	ret += (20.0*math.Sin(y*math.Pi) + 40.0*math.Sin(y/3.0*math.Pi)) * 2.0 / 3.0
+	ret += (160.0*math.Sin(y/12.0*math.Pi) + 320.0*math.Sin(y*math.Pi/30.0)) * 2.0 / 3.0
+	return ret
+}
+
+func transformLng(x, y float64) float64 {
+	ret := 300.0 + x + 2.0*y + 0.1*x*x + 0.1*x*y + 0.1*math.Sqrt(math.Abs(x))
+	ret += (20.0*math.Sin(6.0*x*math.Pi) + 20.0*math.Sin(2.0*x*math.Pi)) * 2.0 / 3.0
+	ret += (20.0*math.Sin(x*math.Pi) + 40.0*math.Sin(x/3.0*math.Pi)) * 2.0 / 3.0
+	ret += (150.0*math.Sin(x/12.0*math.Pi) + 300.0*math.Sin(x/30.0*math.Pi)) * 2.0 / 3.0
+	return ret
+}
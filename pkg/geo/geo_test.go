@@ -0,0 +1,27 @@
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+func TestGCJ02RoundTrip(t *testing.T) {
+	// 天安门的 WGS-84 坐标
+	lat, lng := 39.90872, 116.39748
+	gLat, gLng := WGS84ToGCJ02(lat, lng)
+	// 国内的偏移约为几百米
+	if d := math.Abs(gLat-lat) + math.Abs(gLng-lng); d < 0.001 || d > 0.02 {
+		t.Errorf("偏移量异常: %v, %v", gLat-lat, gLng-lng)
+	}
+	wLat, wLng := GCJ02ToWGS84(gLat, gLng)
+	if math.Abs(wLat-lat) > 1e-7 || math.Abs(wLng-lng) > 1e-7 {
+		t.Errorf("逆转换误差过大: (%v, %v), 期望 (%v, %v)", wLat, wLng, lat, lng)
+	}
+}
+
+func TestOutOfChina(t *testing.T) {
+	// 伦敦的坐标不做偏移
+	if lat, lng := GCJ02ToWGS84(51.5, -0.12); lat != 51.5 || lng != -0.12 {
+		t.Errorf("国外坐标不应偏移: %v, %v", lat, lng)
+	}
+}
@@ -0,0 +1,152 @@
+// Package pinyin 提供内置的汉字转拼音和拼音搜索，
+// 用于数据库中没有拼音列时按全拼或首字母 (例如 zs、zhangsan) 搜索联系人、群聊和会话。
+package pinyin
+
+import (
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// polyphones 是常用多音字的其他读音，主要是姓氏和人名中的读音
+var polyphones = map[rune][]string{
+	'长': {"zhang"}, '重': {"chong"}, '行': {"hang"}, '乐': {"yue"}, '单': {"shan", "chan"},
+	'解': {"xie"}, '查': {"zha"}, '区': {"ou"}, '仇': {"qiu"}, '朴': {"piao", "po"},
+	'覃': {"qin"}, '盖': {"ge"}, '翟': {"zhai"}, '缪': {"miao", "miu"}, '尉': {"yu"},
+	'沈': {"shen"}, '曾': {"zeng"}, '都': {"du"}, '薄': {"bo"}, '还': {"huan"},
+	'会': {"kuai"}, '了': {"liao"}, '地': {"di"}, '着': {"zhao", "zhuo"}, '朝': {"zhao"},
+	'省': {"xing"}, '什': {"shi"}, '厦': {"xia"}, '弄': {"long"}, '传': {"zhuan"},
+	'率': {"shuai"}, '差': {"chai", "ci"}, '参': {"shen", "cen"}, '调': {"tiao", "diao"},
+	'便': {"pian", "bian"}, '觉': {"jiao", "jue"}, '藏': {"zang", "cang"}, '降': {"xiang", "jiang"},
+	'角': {"jue", "jiao"}, '给': {"ji", "gei"}, '系': {"ji", "xi"}, '阿': {"e", "a"},
+	'略': {"lve"}, '掠': {"lve"}, '肋': {"lei"}, '大': {"dai"}, '柏': {"bo", "bai"},
+	'秘': {"bi", "mi"}, '万': {"mo", "wan"}, '尾': {"yi", "wei"}, '伽': {"jia", "qie", "ga"},
+}
+
+var (
+	once    sync.Once
+	readers map[rune][]string
+)
+
+func load() {
+	readers = make(map[rune][]string, 21000)
+	for py, chars := range table {
+		for _, r := range chars {
+			readers[r] = append(readers[r], py)
+		}
+	}
+	for r, extra := range polyphones {
+		for _, py := range extra {
+			if !contains(readers[r], py) {
+				readers[r] = append(readers[r], py)
+			}
+		}
+	}
+}
+
+// Readings 返回汉字的所有读音 (不带声调，ü 写作 v)，非汉字或未收录的字返回 nil
+func Readings(r rune) []string {
+	once.Do(load)
+	return readers[r]
+}
+
+// Convert 返回文本中每个字的主要读音连接成的全拼和首字母，非汉字按原样保留 (转为小写)，空白被忽略。
+// 例如 "张三" 返回 "zhangsan" 和 "zs"。
+func Convert(s string) (full, initials string) {
+	var fb, ib strings.Builder
+	for _, r := range s {
+		if unicode.IsSpace(r) {
+			continue
+		}
+		if py := Readings(r); len(py) > 0 {
+			fb.WriteString(py[0])
+			ib.WriteByte(py[0][0])
+			continue
+		}
+		r = unicode.ToLower(r)
+		fb.WriteRune(r)
+		ib.WriteRune(r)
+	}
+	return fb.String(), ib.String()
+}
+
+// Match 判断 query 能否按拼音匹配 text 中连续的若干个字，不区分大小写，query 中的空白被忽略。
+// 每个汉字可以用其任一读音的前缀表示，因此全拼 (zhangsan)、首字母 (zs) 和混合写法 (zhangs) 都能匹配 "张三"；
+// 非汉字需原样匹配。query 中不含字母时返回 false (中文关键词请直接按子串匹配)。
+func Match(text, query string) bool {
+	q := normalize(query)
+	if q == "" || !hasLetter(q) {
+		return false
+	}
+
+	units := make([][]string, 0, len(text))
+	for _, r := range text {
+		if unicode.IsSpace(r) {
+			continue
+		}
+		if py := Readings(r); len(py) > 0 {
+			units = append(units, py)
+		} else {
+			units = append(units, []string{string(unicode.ToLower(r))})
+		}
+	}
+
+	// reach[k] 表示 q[:k] 能由到当前字为止的连续若干个字拼成，reach[0] 表示从下一个字开始匹配
+	reach := make([]bool, len(q)+1)
+	next := make([]bool, len(q)+1)
+	for _, readings := range units {
+		reach[0] = true
+		clear(next)
+		for k, ok := range reach {
+			if !ok || k == len(q) {
+				continue
+			}
+			for _, py := range readings {
+				for n := min(len(py), len(q)-k); n >= 1; n-- {
+					// 非汉字 (原样保留的字符) 只能整体匹配
+					if n < len(py) && py[0] >= utf8.RuneSelf {
+						continue
+					}
+					if py[:n] == q[k:k+n] {
+						next[k+n] = true
+					}
+				}
+			}
+		}
+		if next[len(q)] {
+			return true
+		}
+		reach, next = next, reach
+	}
+	return false
+}
+
+// normalize 去掉空白并转为小写
+func normalize(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		if !unicode.IsSpace(r) {
+			sb.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return sb.String()
+}
+
+func hasLetter(s string) bool {
+	for _, r := range s {
+		if r >= 'a' && r <= 'z' {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
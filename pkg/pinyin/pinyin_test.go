@@ -0,0 +1,41 @@
+package pinyin
+
+import "testing"
+
+func TestConvert(t *testing.T) {
+	full, initials := Convert("张三 Tom")
+	if full != "zhangsantom" || initials != "zstom" {
+		t.Errorf("期望 zhangsantom/zstom, 实际得到 %s/%s", full, initials)
+	}
+	if py := Readings('绿'); len(py) == 0 || py[0] != "lv" {
+		t.Errorf("绿 期望读音 lv, 实际得到 %v", py)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		text  string
+		query string
+		want  bool
+	}{
+		{"张三", "zs", true},
+		{"张三", "zhangsan", true},
+		{"张三", "Zhang San", true},
+		{"张三", "zhangs", true},
+		{"老张三丰", "zsf", true},
+		{"张三", "zsan", true},
+		{"张三", "sz", false},
+		{"张三", "zhangsanf", false},
+		{"曾小贤", "zxx", true}, // 多音字
+		{"曾小贤", "cxx", true},
+		{"Tom张", "tomz", true},
+		{"Tom张", "tz", false},
+		{"张三", "张", false},
+	}
+
+	for _, tt := range tests {
+		if got := Match(tt.text, tt.query); got != tt.want {
+			t.Errorf("Match(%q, %q): 期望 %v, 实际得到 %v", tt.text, tt.query, tt.want, got)
+		}
+	}
+}
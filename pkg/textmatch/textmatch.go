@@ -0,0 +1,217 @@
+// Package textmatch 提供正则和模糊 (编辑距离) 文本匹配，供搜索的 SQL 函数和结果高亮共用。
+package textmatch
+
+import (
+	"regexp"
+	"sort"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Range 是一处匹配在文本中的字节区间 [Start, End)
+type Range struct {
+	Start int
+	End   int
+}
+
+// maxCachedRegexps 是正则缓存的容量，超出后清空重建
+const maxCachedRegexps = 64
+
+var (
+	regexpMu    sync.Mutex
+	regexpCache = make(map[string]*regexp.Regexp)
+)
+
+// CompileRegexp 编译正则表达式 (RE2 语法)，相同的表达式只编译一次。
+// 搜索时每一行都会调用 REGEXP 函数，因此缓存编译结果。
+func CompileRegexp(pattern string) (*regexp.Regexp, error) {
+	regexpMu.Lock()
+	defer regexpMu.Unlock()
+
+	if re, ok := regexpCache[pattern]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(regexpCache) >= maxCachedRegexps {
+		regexpCache = make(map[string]*regexp.Regexp)
+	}
+	regexpCache[pattern] = re
+	return re, nil
+}
+
+// FindRegexp 返回正则在文本中所有不重叠的匹配区间，空匹配会被忽略
+func FindRegexp(re *regexp.Regexp, text string) []Range {
+	var out []Range
+	for _, loc := range re.FindAllStringIndex(text, -1) {
+		if loc[1] > loc[0] {
+			out = append(out, Range{Start: loc[0], End: loc[1]})
+		}
+	}
+	return out
+}
+
+// FindKeywords 返回所有关键词在文本中的匹配区间 (不区分大小写，与 LIKE 和全文索引一致)，
+// 按起点排序，重叠的区间合并为一个
+func FindKeywords(text string, keywords []string) []Range {
+	var all []Range
+	for _, kw := range keywords {
+		if kw == "" {
+			continue
+		}
+		re, err := CompileRegexp("(?i)" + regexp.QuoteMeta(kw))
+		if err != nil {
+			continue
+		}
+		all = append(all, FindRegexp(re, text)...)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Start < all[j].Start })
+
+	var out []Range
+	for _, r := range all {
+		if n := len(out); n > 0 && r.Start <= out[n-1].End {
+			out[n-1].End = max(out[n-1].End, r.End)
+			continue
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// DefaultDistance 返回模糊匹配默认允许的编辑距离：4 个字以内为 1，更长为 2
+func DefaultDistance(pattern string) int {
+	if utf8.RuneCountInString(pattern) <= 4 {
+		return 1
+	}
+	return 2
+}
+
+// FuzzyContains 判断文本中是否有与 pattern 编辑距离不超过 maxDist 的片段 (不区分大小写)
+func FuzzyContains(text, pattern string, maxDist int) bool {
+	p := foldRunes(pattern)
+	if len(p) == 0 {
+		return false
+	}
+	maxDist = clampDistance(maxDist, len(p))
+
+	// 近似子串匹配 (Sellers 算法)：文本中任意位置都可以作为匹配起点，因此第 0 行始终为 0
+	prev := make([]int, len(p)+1)
+	cur := make([]int, len(p)+1)
+	for i := range prev {
+		prev[i] = i
+	}
+	if prev[len(p)] <= maxDist {
+		return true
+	}
+	for _, r := range text {
+		r = unicode.ToLower(r)
+		cur[0] = 0
+		for i := 1; i <= len(p); i++ {
+			cur[i] = min(prev[i-1]+cost(p[i-1], r), cur[i-1]+1, prev[i]+1)
+		}
+		if cur[len(p)] <= maxDist {
+			return true
+		}
+		prev, cur = cur, prev
+	}
+	return false
+}
+
+// FindFuzzy 返回文本中与 pattern 编辑距离不超过 maxDist 的片段区间 (不区分大小写)。
+// 重叠的候选片段中保留编辑距离最小的一个，距离相同时保留靠前的一个。
+func FindFuzzy(text, pattern string, maxDist int) []Range {
+	p := foldRunes(pattern)
+	if len(p) == 0 {
+		return nil
+	}
+	maxDist = clampDistance(maxDist, len(p))
+
+	// offsets[j] 是第 j 个字符在 text 中的字节偏移，最后追加 len(text)
+	offsets := make([]int, 0, len(text)+1)
+	t := make([]rune, 0, len(text))
+	for i, r := range text {
+		offsets = append(offsets, i)
+		t = append(t, unicode.ToLower(r))
+	}
+	offsets = append(offsets, len(text))
+
+	// 除编辑距离外还记录每个单元格对应匹配的起点，便于得到区间
+	prev := make([]int, len(p)+1)
+	cur := make([]int, len(p)+1)
+	prevStart := make([]int, len(p)+1)
+	curStart := make([]int, len(p)+1)
+	for i := range prev {
+		prev[i] = i
+	}
+
+	type candidate struct {
+		Range
+		dist int
+	}
+	var out []Range
+	var best *candidate
+	for j := 1; j <= len(t); j++ {
+		cur[0], curStart[0] = 0, j
+		for i := 1; i <= len(p); i++ {
+			// 对角线 (匹配或替换)、删除 pattern 中的字符、跳过文本中的字符取最小值；
+			// 距离相同时取起点更靠前的，使 "wrld" 高亮整个 "world" 而不是 "orld"
+			cur[i], curStart[i] = prev[i-1]+cost(p[i-1], t[j-1]), prevStart[i-1]
+			if c := cur[i-1] + 1; c < cur[i] || (c == cur[i] && curStart[i-1] < curStart[i]) {
+				cur[i], curStart[i] = c, curStart[i-1]
+			}
+			if c := prev[i] + 1; c < cur[i] || (c == cur[i] && prevStart[i] < curStart[i]) {
+				cur[i], curStart[i] = c, prevStart[i]
+			}
+		}
+
+		if d := cur[len(p)]; d <= maxDist && curStart[len(p)] < j {
+			c := candidate{Range: Range{Start: curStart[len(p)], End: j}, dist: d}
+			switch {
+			case best == nil:
+				best = &c
+			case c.Start < best.End:
+				if c.dist < best.dist {
+					best = &c
+				}
+			default:
+				out = append(out, Range{Start: offsets[best.Start], End: offsets[best.End]})
+				best = &c
+			}
+		}
+		prev, cur = cur, prev
+		prevStart, curStart = curStart, prevStart
+	}
+	if best != nil {
+		out = append(out, Range{Start: offsets[best.Start], End: offsets[best.End]})
+	}
+	return out
+}
+
+// clampDistance 保证至少要匹配 pattern 中的一个字符，否则任何文本都会命中
+func clampDistance(maxDist, n int) int {
+	if maxDist < 0 {
+		return 0
+	}
+	if maxDist >= n {
+		return n - 1
+	}
+	return maxDist
+}
+
+func foldRunes(s string) []rune {
+	rs := []rune(s)
+	for i, r := range rs {
+		rs[i] = unicode.ToLower(r)
+	}
+	return rs
+}
+
+func cost(a, b rune) int {
+	if a == b {
+		return 0
+	}
+	return 1
+}
@@ -0,0 +1,73 @@
+package textmatch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindRegexp(t *testing.T) {
+	re, err := CompileRegexp(`1[3-9]\d{9}`)
+	if err != nil {
+		t.Fatalf("CompileRegexp 失败: %v", err)
+	}
+	if again, _ := CompileRegexp(`1[3-9]\d{9}`); again != re {
+		t.Errorf("相同的表达式应返回缓存的结果")
+	}
+
+	text := "电话13800138000或15912345678"
+	got := FindRegexp(re, text)
+	want := []Range{{6, 17}, {20, 31}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("期望 %v, 实际得到 %v", want, got)
+	}
+	if text[got[0].Start:got[0].End] != "13800138000" {
+		t.Errorf("区间对应的文本错误: %q", text[got[0].Start:got[0].End])
+	}
+
+	if _, err := CompileRegexp(`(`); err == nil {
+		t.Errorf("非法的正则期望返回错误")
+	}
+}
+
+func TestFindKeywords(t *testing.T) {
+	text := "合同合同 contract"
+	got := FindKeywords(text, []string{"合同", "同 con", ""})
+	want := []Range{{0, 16}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("重叠的区间应合并: 期望 %v, 实际得到 %v", want, got)
+	}
+	if got := FindKeywords("a-b-a", []string{"a"}); !reflect.DeepEqual(got, []Range{{0, 1}, {4, 5}}) {
+		t.Errorf("期望两处匹配, 实际得到 %v", got)
+	}
+	if got := FindKeywords("Hello HELLO", []string{"hello"}); !reflect.DeepEqual(got, []Range{{0, 5}, {6, 11}}) {
+		t.Errorf("应不区分大小写匹配, 实际得到 %v", got)
+	}
+}
+
+func TestFuzzy(t *testing.T) {
+	tests := []struct {
+		text    string
+		pattern string
+		dist    int
+		want    []string
+	}{
+		{"Meeting with Jonathon tomorrow", "jonathan", 1, []string{"Jonathon"}},
+		{"张小明和张晓明", "张晓明", 1, []string{"张小明", "张晓明"}},
+		{"hello world", "wrld", 1, []string{"world"}},
+		{"hello world", "xyz", 1, nil},
+		{"abc", "abc", 5, []string{"abc"}}, // 距离不超过 pattern 长度 - 1
+	}
+
+	for _, tt := range tests {
+		var got []string
+		for _, r := range FindFuzzy(tt.text, tt.pattern, tt.dist) {
+			got = append(got, tt.text[r.Start:r.End])
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("FindFuzzy(%q, %q): 期望 %q, 实际得到 %q", tt.text, tt.pattern, tt.want, got)
+		}
+		if contains := FuzzyContains(tt.text, tt.pattern, tt.dist); contains != (len(tt.want) > 0) {
+			t.Errorf("FuzzyContains(%q, %q) = %v, 与 FindFuzzy 不一致", tt.text, tt.pattern, contains)
+		}
+	}
+}
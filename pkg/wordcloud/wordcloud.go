@@ -45,26 +45,50 @@ var stopWords = map[string]bool{
 
 // Analyze 对文本列表进行词频统计，返回词云结果
 func Analyze(texts []string, limit int) *WordCloudResult {
-	if limit <= 0 {
-		limit = 100
+	c := NewCounter()
+	for _, text := range texts {
+		c.Add(text)
 	}
+	return c.Result(limit)
+}
 
-	freq := make(map[string]int)
-	totalWords := 0
+// Counter 以增量方式累计词频，适合逐条处理大量消息而无需一次性持有全部文本
+type Counter struct {
+	freq       map[string]int
+	totalTexts int
+	totalWords int
+}
 
-	for _, text := range texts {
-		words := tokenize(text)
-		for _, w := range words {
-			if !stopWords[w] {
-				freq[w]++
-				totalWords++
-			}
+// NewCounter 创建一个空的词频计数器
+func NewCounter() *Counter {
+	return &Counter{freq: make(map[string]int)}
+}
+
+// Add 对一段文本分词并累计词频
+func (c *Counter) Add(text string) {
+	c.totalTexts++
+	for _, w := range tokenize(text) {
+		if !stopWords[w] {
+			c.freq[w]++
+			c.totalWords++
 		}
 	}
+}
+
+// Len 返回已累计的文本条数
+func (c *Counter) Len() int {
+	return c.totalTexts
+}
+
+// Result 返回按词频降序排列的前 limit 个词
+func (c *Counter) Result(limit int) *WordCloudResult {
+	if limit <= 0 {
+		limit = 100
+	}
 
 	// 转为切片并排序
-	items := make([]*WordItem, 0, len(freq))
-	for text, count := range freq {
+	items := make([]*WordItem, 0, len(c.freq))
+	for text, count := range c.freq {
 		items = append(items, &WordItem{Text: text, Count: count})
 	}
 	sort.Slice(items, func(i, j int) bool {
@@ -76,8 +100,8 @@ func Analyze(texts []string, limit int) *WordCloudResult {
 	}
 
 	return &WordCloudResult{
-		TotalMessages: len(texts),
-		TotalWords:    totalWords,
+		TotalMessages: c.totalTexts,
+		TotalWords:    c.totalWords,
 		Words:         items,
 	}
 }
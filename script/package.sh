@@ -10,6 +10,9 @@ TEMP_DIR="temp_package"
 VERSION=$(git describe --tags --always --dirty="-dev")
 CHECKSUMS_FILE="$PACKAGES_DIR/checksums.txt"
 
+# 启用 SQLite FTS5 全文索引
+export GOFLAGS="${GOFLAGS:-} -tags=sqlite_fts5"
+
 make -f Makefile crossbuild
 
 rm -rf $PACKAGES_DIR $TEMP_DIR
@@ -6,6 +6,9 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/afumu/wetrace/store/core"
@@ -19,11 +22,20 @@ type DatabaseShard struct {
 	StartTime time.Time
 	EndTime   time.Time
 	TalkerMap map[string]int // 用户名 -> 内部ID 的缓存
+
+	open bool // 最新的分片 (或按会话切分的分片)，结束时间随新消息增长
+}
+
+// IsOpen 判断分片的结束时间是否随新消息增长 (最新的分片或按会话切分的分片)
+func (s *DatabaseShard) IsOpen() bool {
+	return s.open
 }
 
 // TimelineRouter 负责基于时间线将查询路由到具体的数据库文件
 type TimelineRouter struct {
+	mu       sync.RWMutex         // 保护 shards；分片只整体替换，不原地修改
 	shards   []*DatabaseShard     // 按时间排序的数据库分片列表
+	revision atomic.Uint64        // 索引版本，每次重建或刷新分片后递增
 	baseDir  string               // 基础目录
 	pool     *core.ConnectionPool // 连接池
 	strategy strategy.Strategy    // 文件识别策略
@@ -65,15 +77,75 @@ func (r *TimelineRouter) RebuildIndex(ctx context.Context) error {
 		shards = append(shards, shard)
 	}
 
-	// 3. 按开始时间排序
+	// 3. 排序并推导结束时间
+	r.mu.Lock()
+	r.shards = r.arrange(shards)
+	r.mu.Unlock()
+	r.revision.Add(1)
+	return nil
+}
+
+// RefreshShard 只重新加载 path 对应的消息分片 (开始时间、会话映射) 并重新计算各分片的结束时间。
+// 文件已不存在或不再是消息数据库时从索引中移除；path 不在索引中时作为新分片加入。
+func (r *TimelineRouter) RefreshShard(ctx context.Context, path string) error {
+	// 池中的只读连接假定文件不会变化，先丢弃旧连接再读取
+	_ = r.pool.CloseConnection(path)
+
+	var shard *DatabaseShard
+	if _, err := os.Stat(path); err == nil && r.IsMessageFile(path) {
+		var loadErr error
+		shard, loadErr = r.loadShardMetadata(ctx, path)
+		if loadErr != nil {
+			// 文件可能仍在写入中，保留旧的分片信息，等待下一次事件
+			return fmt.Errorf("加载分片元数据失败: %w", loadErr)
+		}
+	}
+
+	r.mu.Lock()
+	shards := make([]*DatabaseShard, 0, len(r.shards)+1)
+	found := false
+	for _, old := range r.shards {
+		if old.FilePath != path {
+			// 复制一份，重新计算结束时间时不修改正在被查询使用的分片
+			cp := *old
+			shards = append(shards, &cp)
+			continue
+		}
+		found = true
+	}
+	if shard == nil && !found {
+		r.mu.Unlock()
+		return nil
+	}
+	if shard != nil {
+		shards = append(shards, shard)
+	}
+	r.shards = r.arrange(shards)
+	r.mu.Unlock()
+
+	r.revision.Add(1)
+	log.Debug().Str("path", path).Bool("removed", shard == nil).Msg("分片索引已刷新")
+	return nil
+}
+
+// arrange 按开始时间排序并推导各分片的结束时间
+func (r *TimelineRouter) arrange(shards []*DatabaseShard) []*DatabaseShard {
+	// 按开始时间排序
 	sort.Slice(shards, func(i, j int) bool {
 		return shards[i].StartTime.Before(shards[j].StartTime)
 	})
 
-	// 4. 计算推导结束时间
+	// 计算推导结束时间
+	// 按会话切分的分片 (macOS) 时间范围相互重叠，每个分片都视为覆盖到当前时间
+	talkerSharded := false
+	if ts, ok := r.strategy.(strategy.TalkerSharder); ok {
+		talkerSharded = ts.TalkerSharded()
+	}
+	now := time.Now()
 	for i := range shards {
-		if i == len(shards)-1 {
-			shards[i].EndTime = time.Now()
+		shards[i].open = talkerSharded || i == len(shards)-1
+		if shards[i].open {
+			shards[i].EndTime = now
 		} else {
 			shards[i].EndTime = shards[i+1].StartTime
 		}
@@ -83,9 +155,47 @@ func (r *TimelineRouter) RebuildIndex(ctx context.Context) error {
 			Time("end", shards[i].EndTime).
 			Msg("分片索引已建立")
 	}
+	return shards
+}
 
-	r.shards = shards
-	return nil
+// Revision 返回索引版本，重建或刷新分片后递增，可用于判断缓存的查询结果是否过期
+func (r *TimelineRouter) Revision() uint64 {
+	return r.revision.Load()
+}
+
+// IsMessageFile 判断 path 是否为当前策略识别的消息数据库
+func (r *TimelineRouter) IsMessageFile(path string) bool {
+	_, ok := r.strategy.IdentifyAs(filepath.Base(path), strategy.Message)
+	return ok
+}
+
+// MessageDirs 返回可能存放消息数据库的目录 (根目录及各版本的消息子目录)
+func (r *TimelineRouter) MessageDirs() []string {
+	dirs := []string{r.baseDir}
+	for _, sub := range subDirsOf(strategy.Message) {
+		dirs = append(dirs, filepath.Join(r.baseDir, sub))
+	}
+	return dirs
+}
+
+// DBPathOf 将 SQLite 的日志文件 (-wal / -shm / -journal) 映射到其所属的数据库文件
+func DBPathOf(path string) string {
+	for _, suffix := range []string{"-wal", "-shm", "-journal"} {
+		if strings.HasSuffix(path, suffix) {
+			return strings.TrimSuffix(path, suffix)
+		}
+	}
+	return path
+}
+
+// Version 返回当前策略对应的数据版本
+func (r *TimelineRouter) Version() string {
+	return r.strategy.Version()
+}
+
+// Strategy 返回当前使用的文件识别策略
+func (r *TimelineRouter) Strategy() strategy.Strategy {
+	return r.strategy
 }
 
 // GetBaseDir 返回基础目录
@@ -93,8 +203,10 @@ func (r *TimelineRouter) GetBaseDir() string {
 	return r.baseDir
 }
 
-// GetShards 返回所有已加载的数据库分片
+// GetShards 返回所有已加载的数据库分片 (当前索引的快照，调用方不得修改)
 func (r *TimelineRouter) GetShards() []*DatabaseShard {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	return r.shards
 }
 
@@ -109,10 +221,11 @@ type RouteResult struct {
 func (r *TimelineRouter) Resolve(start, end time.Time, talker string) []RouteResult {
 	var results []RouteResult
 
-	for _, shard := range r.shards {
+	for _, shard := range r.GetShards() {
 		// 判断时间是否有交集
 		// 逻辑：Shard.Start < Query.End AND Shard.End > Query.Start
-		if shard.StartTime.Before(end) && shard.EndTime.After(start) {
+		// 最新的分片持续写入新消息，结束时间不能停留在建立索引的时刻，视为没有上界
+		if shard.StartTime.Before(end) && (shard.open || shard.EndTime.After(start)) {
 
 			result := RouteResult{
 				FilePath: shard.FilePath,
@@ -142,6 +255,16 @@ func (r *TimelineRouter) GetSessionDBPath() (string, error) {
 	return r.findFileByType(strategy.Session)
 }
 
+// GetChatRoomDBPath 获取群聊数据库的路径。
+// macOS 的群聊保存在独立的 group_new.db 中，其它版本与联系人共用同一个数据库。
+func (r *TimelineRouter) GetChatRoomDBPath() (string, error) {
+	log.Debug().Msg("尝试查找群聊数据库路径")
+	if path, err := r.findFileByType(strategy.ChatRoom); err == nil {
+		return path, nil
+	}
+	return r.GetContactDBPath()
+}
+
 // GetMediaDBPath 获取特定类型媒体数据库的路径
 func (r *TimelineRouter) GetMediaDBPath(mediaType strategy.GroupType) (string, error) {
 	log.Debug().Interface("type", mediaType).Msg("尝试查找媒体数据库路径")
@@ -152,22 +275,8 @@ func (r *TimelineRouter) GetMediaDBPath(mediaType strategy.GroupType) (string, e
 func (r *TimelineRouter) GetAllDBPaths(targetType strategy.GroupType) ([]string, error) {
 	log.Debug().Interface("type", targetType).Msg("尝试查找所有符合类型的数据库路径")
 
-	// 1. 尝试在对应的子目录中查找 (V4 结构)
-	var subDirName string
-	switch targetType {
-	case strategy.Contact:
-		subDirName = "contact"
-	case strategy.Image, strategy.Video, strategy.File:
-		subDirName = "hardlink"
-	case strategy.Message:
-		subDirName = "message"
-	case strategy.Voice:
-		subDirName = "message"
-	case strategy.Session:
-		subDirName = "session"
-	}
-
-	if subDirName != "" {
+	// 1. 尝试在对应的子目录中查找 (V4 / macOS 结构)
+	for _, subDirName := range subDirsOf(targetType) {
 		subDirPath := filepath.Join(r.baseDir, subDirName)
 		found, err := r.scanAllFiles(subDirPath, targetType)
 		if err == nil && len(found) > 0 {
@@ -181,12 +290,33 @@ func (r *TimelineRouter) GetAllDBPaths(targetType strategy.GroupType) ([]string,
 
 // --- 内部辅助方法 ---
 
+// subDirsOf 返回某类数据库可能所在的子目录 (V4 为小写目录，macOS 为首字母大写目录，V3 的消息在 Multi 中，
+// 未去掉 Msg 目录的 V3 解密结果在 Msg 与 Msg/Multi 中)
+func subDirsOf(targetType strategy.GroupType) []string {
+	switch targetType {
+	case strategy.Contact:
+		return []string{"contact", "Contact", "Msg"}
+	case strategy.ChatRoom:
+		return []string{"Group", "Msg"}
+	case strategy.Image, strategy.Video, strategy.File:
+		return []string{"hardlink", "hlink", "Msg"}
+	case strategy.Message:
+		return []string{"message", "Message", "Multi", filepath.Join("Msg", "Multi")}
+	case strategy.Voice:
+		return []string{"message", "Multi", filepath.Join("Msg", "Multi")} // 通常 voice 在 media_x.db / MediaMSGx.db
+	case strategy.Session:
+		return []string{"session", "Session", "Msg"}
+	}
+	return nil
+}
+
 func (r *TimelineRouter) discoverMessageFiles() ([]string, error) {
-	// V4: 检查 message 子目录
-	subDir := filepath.Join(r.baseDir, "message")
-	files, err := r.scanDirForMessages(subDir)
-	if err == nil && len(files) > 0 {
-		return files, nil
+	// V4 / macOS: 检查 message 子目录
+	for _, subDirName := range subDirsOf(strategy.Message) {
+		files, err := r.scanDirForMessages(filepath.Join(r.baseDir, subDirName))
+		if err == nil && len(files) > 0 {
+			return files, nil
+		}
 	}
 
 	// Fallback: 检查根目录
@@ -206,8 +336,7 @@ func (r *TimelineRouter) scanDirForMessages(dir string) ([]string, error) {
 			continue
 		}
 
-		meta, match := r.strategy.Identify(entry.Name())
-		if match && meta.Type == strategy.Message {
+		if _, match := r.strategy.IdentifyAs(entry.Name(), strategy.Message); match {
 			fullPath := filepath.Join(dir, entry.Name())
 			files = append(files, fullPath)
 			log.Info().Str("file", entry.Name()).Msg("识别到消息数据库")
@@ -222,9 +351,15 @@ func (r *TimelineRouter) loadShardMetadata(ctx context.Context, path string) (*D
 		return nil, err
 	}
 
-	startTime, err := r.meta.ReadStartTime(ctx, conn)
-	if err != nil {
-		return nil, err
+	var startTime time.Time
+	if ts, ok := r.strategy.(strategy.TalkerSharder); ok && ts.TalkerSharded() {
+		// 按会话切分的分片没有开始时间记录，视为覆盖全部历史
+		startTime = time.Unix(0, 0)
+	} else {
+		startTime, err = r.meta.ReadStartTime(ctx, conn)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	talkerMap, err := r.meta.ReadTalkerMap(ctx, conn)
@@ -240,23 +375,9 @@ func (r *TimelineRouter) loadShardMetadata(ctx context.Context, path string) (*D
 }
 
 func (r *TimelineRouter) findFileByType(targetType strategy.GroupType) (string, error) {
-	// 1. 尝试在对应的子目录中查找 (V4 结构)
+	// 1. 尝试在对应的子目录中查找 (V4 / macOS 结构)
 	// 映射关系: Contact -> contact/, Image -> hardlink/, etc.
-	var subDirName string
-	switch targetType {
-	case strategy.Contact:
-		subDirName = "contact"
-	case strategy.Image, strategy.Video, strategy.File:
-		subDirName = "hardlink"
-	case strategy.Message:
-		subDirName = "message"
-	case strategy.Voice:
-		subDirName = "message" // 或者 voice? 通常 voice 在 media_x.db
-	case strategy.Session:
-		subDirName = "session"
-	}
-
-	if subDirName != "" {
+	for _, subDirName := range subDirsOf(targetType) {
 		subDirPath := filepath.Join(r.baseDir, subDirName)
 		found, err := r.scanOneFile(subDirPath, targetType)
 		if err == nil {
@@ -281,15 +402,15 @@ func (r *TimelineRouter) scanOneFile(dir string, targetType strategy.GroupType)
 			continue
 		}
 
-		meta, match := r.strategy.Identify(entry.Name())
+		_, match := r.strategy.IdentifyAs(entry.Name(), targetType)
 
 		log.Debug().
 			Str("file", entry.Name()).
 			Bool("isMatch", match).
-			Str("identifiedType", meta.Type.String()).
+			Str("target", targetType.String()).
 			Msg("检查文件")
 
-		if match && meta.Type == targetType {
+		if match {
 			fullPath := filepath.Join(dir, entry.Name())
 			log.Info().Str("path", fullPath).Msg("找到目标数据库文件")
 			return fullPath, nil
@@ -313,9 +434,7 @@ func (r *TimelineRouter) scanAllFiles(dir string, targetType strategy.GroupType)
 			continue
 		}
 
-		meta, match := r.strategy.Identify(entry.Name())
-
-		if match && meta.Type == targetType {
+		if _, match := r.strategy.IdentifyAs(entry.Name(), targetType); match {
 			fullPath := filepath.Join(dir, entry.Name())
 			results = append(results, fullPath)
 			log.Debug().Str("path", fullPath).Msg("找到目标数据库文件")
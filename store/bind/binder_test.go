@@ -64,6 +64,41 @@ func TestTimelineRouter_Resolve(t *testing.T) {
 	}
 }
 
+func TestTimelineRouter_V3MsgLayout(t *testing.T) {
+	tmpDir := t.TempDir()
+	pool := core.NewConnectionPool(tmpDir)
+	defer pool.CloseAll()
+
+	// 未去掉 Msg 目录的 V3 解密结果
+	if err := os.MkdirAll(filepath.Join(tmpDir, "Msg", "Multi"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	createMockFile(t, filepath.Join(tmpDir, "Msg", "MicroMsg.db"))
+	createMockFile(t, filepath.Join(tmpDir, "Msg", "Multi", "MSG0.db"))
+	createMockFile(t, filepath.Join(tmpDir, "Msg", "Multi", "MSG1.db"))
+
+	router := NewTimelineRouter(tmpDir, pool, strategy.Detect(tmpDir))
+	if router.Version() != strategy.NewV3().Version() {
+		t.Fatalf("期望识别为 V3, 实际得到 %s", router.Version())
+	}
+
+	contactPath, err := router.GetContactDBPath()
+	if err != nil {
+		t.Fatalf("GetContactDBPath 失败: %v", err)
+	}
+	if contactPath != filepath.Join(tmpDir, "Msg", "MicroMsg.db") {
+		t.Errorf("期望 Msg/MicroMsg.db, 实际得到 %s", contactPath)
+	}
+
+	files, err := router.discoverMessageFiles()
+	if err != nil {
+		t.Fatalf("discoverMessageFiles 失败: %v", err)
+	}
+	if len(files) != 2 {
+		t.Errorf("期望 2 个消息数据库, 实际得到 %v", files)
+	}
+}
+
 func createMockDB(t *testing.T, path string, startTime time.Time, user string, id int) {
 	// 确保文件存在
 	f, err := os.Create(path)
@@ -96,3 +131,53 @@ func createMockFile(t *testing.T, path string) {
 	}
 	f.Close()
 }
+
+func TestTimelineRouter_RefreshShard(t *testing.T) {
+	tmpDir := t.TempDir()
+	pool := core.NewConnectionPool(tmpDir)
+	defer pool.CloseAll()
+
+	t1 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	t2 := time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC)
+	first := filepath.Join(tmpDir, "message_0.db")
+	second := filepath.Join(tmpDir, "message_1.db")
+	createMockDB(t, first, t1, "alice", 1)
+
+	router := NewTimelineRouter(tmpDir, pool, strategy.NewV4())
+	if err := router.RebuildIndex(context.Background()); err != nil {
+		t.Fatalf("RebuildIndex 失败: %v", err)
+	}
+	rev := router.Revision()
+
+	// 最新分片的结束时间不应停留在建立索引的时刻
+	if got := router.Resolve(time.Now().Add(time.Minute), time.Now().Add(time.Hour), "alice"); len(got) != 1 {
+		t.Errorf("最新分片应覆盖之后的时间, 实际得到 %d 个结果", len(got))
+	}
+
+	// 新增分片：只加载该分片，并重新计算前一个分片的结束时间
+	createMockDB(t, second, t2, "alice", 2)
+	if err := router.RefreshShard(context.Background(), second); err != nil {
+		t.Fatalf("RefreshShard 失败: %v", err)
+	}
+	shards := router.GetShards()
+	if len(shards) != 2 || !shards[0].EndTime.Equal(t2) {
+		t.Fatalf("期望 2 个分片且第一个分片结束于 %v, 实际得到 %+v", t2, shards)
+	}
+	if router.Revision() == rev {
+		t.Error("刷新后索引版本应递增")
+	}
+	if got := router.Resolve(t1, t1.Add(time.Hour), "alice"); len(got) != 1 || got[0].FilePath != first {
+		t.Errorf("期望只路由到 message_0.db, 实际得到 %+v", got)
+	}
+
+	// 删除分片：从索引中移除，前一个分片重新成为最新分片
+	if err := os.Remove(second); err != nil {
+		t.Fatal(err)
+	}
+	if err := router.RefreshShard(context.Background(), second); err != nil {
+		t.Fatalf("RefreshShard 失败: %v", err)
+	}
+	if got := router.Resolve(t2, t2.Add(time.Hour), "alice"); len(got) != 1 || got[0].FilePath != first {
+		t.Errorf("删除后期望路由到 message_0.db, 实际得到 %+v", got)
+	}
+}
@@ -4,12 +4,12 @@ import (
 	"database/sql"
 	"fmt"
 	"sync"
-
-	_ "github.com/mattn/go-sqlite3"
 )
 
 // ConnectionPool 负责管理 SQLite 数据库连接的生命周期。
 // 它保证同一个文件只会被打开一次，并且是线程安全的。
+// 池中的连接都是只读的 (immutable + query_only)，解密后的数据库不会被原地修改；
+// 连接上注册了 REGEXP 和 fuzzy_match 函数 (见 DriverName)。
 type ConnectionPool struct {
 	mu      sync.RWMutex
 	connMap map[string]*sql.DB // 路径 -> 连接对象
@@ -50,12 +50,19 @@ func (p *ConnectionPool) GetConnection(path string) (*sql.DB, error) {
 	return conn, nil
 }
 
+// OpenTemporary 以与池中连接相同的只读方式打开数据库，但不放入池中，调用方使用后需关闭。
+// 用于偶尔读取的文件 (例如诊断时遍历的未识别文件)，避免长期占用连接。
+func (p *ConnectionPool) OpenTemporary(path string) (*sql.DB, error) {
+	return p.openNewConnection(path)
+}
+
 // openNewConnection 封装底层的 SQL 打开逻辑 (单一职责：创建)
 func (p *ConnectionPool) openNewConnection(path string) (*sql.DB, error) {
-	// 使用读写模式 (mode=rw) 和共享缓存 (cache=shared)
-	dsn := fmt.Sprintf("file:%s?mode=rw&cache=shared", path)
+	// 解密后的数据库只用于浏览：以只读模式打开，并声明文件不会被修改 (immutable=1)，
+	// SQLite 因此不再加锁、不再检查日志文件，浏览期间也不会持有文件的写句柄
+	dsn := fmt.Sprintf("file:%s?mode=ro&immutable=1&_query_only=1", path)
 
-	db, err := sql.Open("sqlite3", dsn)
+	db, err := sql.Open(DriverName, dsn)
 	if err != nil {
 		return nil, fmt.Errorf("无法打开数据库文件 %s: %w", path, err)
 	}
@@ -61,6 +61,67 @@ func TestConnectionPool(t *testing.T) {
 	}
 }
 
+func TestConnectionPool_ReadOnly(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+	setupDB(t, dbPath)
+
+	pool := NewConnectionPool(tmpDir)
+	defer pool.CloseAll()
+
+	db, err := pool.GetConnection(dbPath)
+	if err != nil {
+		t.Fatalf("GetConnection 失败: %v", err)
+	}
+
+	// 池中的连接不允许写入
+	if _, err := db.Exec("INSERT INTO test_table (content) VALUES ('x')"); err == nil {
+		t.Error("只读连接应该拒绝写入")
+	}
+}
+
+func TestConnectionPool_Functions(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+	setupDB(t, dbPath)
+
+	pool := NewConnectionPool(tmpDir)
+	defer pool.CloseAll()
+
+	db, err := pool.GetConnection(dbPath)
+	if err != nil {
+		t.Fatalf("GetConnection 失败: %v", err)
+	}
+
+	tests := []struct {
+		query string
+		arg   interface{}
+		want  int
+	}{
+		{"SELECT COUNT(*) FROM test_table WHERE content REGEXP ?", `^hel+o\s`, 1},
+		{"SELECT COUNT(*) FROM test_table WHERE content REGEXP ?", `^world`, 0},
+		{"SELECT COUNT(*) FROM test_table WHERE NULL REGEXP ?", `.*`, 0},
+		{"SELECT COUNT(*) FROM test_table WHERE fuzzy_match(content, ?, 1)", "wrold", 0},
+		{"SELECT COUNT(*) FROM test_table WHERE fuzzy_match(content, ?, 2)", "wrold", 1},
+		{"SELECT COUNT(*) FROM test_table WHERE fuzzy_match(content, ?, 1)", "HELO", 1},
+	}
+	for _, tt := range tests {
+		var got int
+		if err := db.QueryRow(tt.query, tt.arg).Scan(&got); err != nil {
+			t.Fatalf("%s (%v) 失败: %v", tt.query, tt.arg, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s (%v): 期望 %d, 实际得到 %d", tt.query, tt.arg, tt.want, got)
+		}
+	}
+
+	// 非法的正则返回错误
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM test_table WHERE content REGEXP ?", "(").Scan(&n); err == nil {
+		t.Error("非法的正则应该返回错误")
+	}
+}
+
 // setupDB 创建一个包含数据的真实 SQLite 文件
 func setupDB(t *testing.T, path string) {
 	db, err := sql.Open("sqlite3", path)
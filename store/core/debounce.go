@@ -0,0 +1,55 @@
+package core
+
+import (
+	"sync"
+	"time"
+)
+
+// Debouncer 合并短时间内针对同一个 key 的多次触发，只在最后一次触发 delay 之后执行一次。
+// 数据库写入时会连续产生大量 Write 事件，用它避免重复刷新。
+type Debouncer struct {
+	delay  time.Duration
+	mu     sync.Mutex
+	timers map[string]*time.Timer
+}
+
+// NewDebouncer 创建一个新的 Debouncer
+func NewDebouncer(delay time.Duration) *Debouncer {
+	return &Debouncer{
+		delay:  delay,
+		timers: make(map[string]*time.Timer),
+	}
+}
+
+// Trigger 安排在 delay 后执行 fn；delay 内再次以同一个 key 触发时重新计时，只执行最后一次的 fn
+func (d *Debouncer) Trigger(key string, fn func()) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if t, ok := d.timers[key]; ok {
+		t.Stop()
+	}
+	var t *time.Timer
+	t = time.AfterFunc(d.delay, func() {
+		d.mu.Lock()
+		if d.timers[key] != t {
+			// 已被新的触发取代
+			d.mu.Unlock()
+			return
+		}
+		delete(d.timers, key)
+		d.mu.Unlock()
+		fn()
+	})
+	d.timers[key] = t
+}
+
+// Stop 取消所有尚未执行的任务
+func (d *Debouncer) Stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for key, t := range d.timers {
+		t.Stop()
+		delete(d.timers, key)
+	}
+}
@@ -0,0 +1,56 @@
+package core
+
+import (
+	"database/sql"
+
+	"github.com/afumu/wetrace/pkg/textmatch"
+	"github.com/mattn/go-sqlite3"
+)
+
+// DriverName 是注册了自定义 SQL 函数的 SQLite 驱动名，连接池中的连接都使用该驱动。
+// 自定义函数：
+//   - X REGEXP P：X 匹配正则 P (RE2 语法) 时为真
+//   - fuzzy_match(X, P, D)：X 中有与 P 编辑距离不超过 D 的片段时为真 (不区分大小写)
+const DriverName = "sqlite3_wetrace"
+
+func init() {
+	sql.Register(DriverName, &sqlite3.SQLiteDriver{
+		ConnectHook: registerFunctions,
+	})
+}
+
+// registerFunctions 在每个新连接上注册自定义函数
+func registerFunctions(conn *sqlite3.SQLiteConn) error {
+	if err := conn.RegisterFunc("regexp", sqlRegexp, true); err != nil {
+		return err
+	}
+	return conn.RegisterFunc("fuzzy_match", sqlFuzzyMatch, true)
+}
+
+// sqlRegexp 实现 REGEXP 运算符，SQLite 以 regexp(P, X) 的参数顺序调用
+func sqlRegexp(pattern string, value interface{}) (bool, error) {
+	re, err := textmatch.CompileRegexp(pattern)
+	if err != nil {
+		return false, err
+	}
+	text, ok := sqlText(value)
+	return ok && re.MatchString(text), nil
+}
+
+func sqlFuzzyMatch(value interface{}, pattern string, maxDist int) bool {
+	text, ok := sqlText(value)
+	return ok && textmatch.FuzzyContains(text, pattern, maxDist)
+}
+
+// sqlText 将 TEXT/BLOB 参数转换为字符串，NULL 和数值返回 false
+func sqlText(v interface{}) (string, bool) {
+	switch s := v.(type) {
+	case string:
+		return s, true
+	case []byte:
+		// NULL 以 nil 切片传入
+		return string(s), s != nil
+	default:
+		return "", false
+	}
+}
@@ -61,6 +61,16 @@ func (w *Watcher) Stop() error {
 	return w.watcher.Close()
 }
 
+// Add 增加一个监控目录 (fsnotify 不递归监控子目录)
+func (w *Watcher) Add(path string) error {
+	return w.watcher.Add(path)
+}
+
+// Remove 取消对目录的监控
+func (w *Watcher) Remove(path string) error {
+	return w.watcher.Remove(path)
+}
+
 func (w *Watcher) AddCallback(cb func(event fsnotify.Event)) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
@@ -0,0 +1,131 @@
+package fts
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/afumu/wetrace/store/types"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"你好世界", "你 好 世 界"},
+		{"Hello, World!", "hello world"},
+		{"明天3点开会meeting", "明 天 3 点 开 会 meeting"},
+		{"电话13800138000", "电 话 13800138000"},
+		{"   ", ""},
+	}
+
+	for _, tt := range tests {
+		if got := Tokenize(tt.input); got != tt.expected {
+			t.Errorf("Tokenize(%q): 期望 %q, 实际得到 %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestBuildMatch(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"张三", `"张 三"`},
+		{"wor", `"wor"*`},
+		{"合同 v2", `"合 同" AND "v2"*`},
+		{"\"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := BuildMatch(tt.input); got != tt.expected {
+			t.Errorf("BuildMatch(%q): 期望 %q, 实际得到 %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestBuildExprMatch(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"合同 v2", `"合 同" AND "v2"*`},
+		{`"hello world" OR 你好 -spam`, `("hello world" OR "你 好") NOT "spam"*`},
+		{"from:alice 报销", `"报 销"`},
+		{"from:alice", ""},
+		{"报销 -!!", ""},
+	}
+
+	for _, tt := range tests {
+		e, err := types.ParseSearch(tt.input)
+		if err != nil {
+			t.Fatalf("ParseSearch(%q) 失败: %v", tt.input, err)
+		}
+		if got := BuildExprMatch(e); got != tt.expected {
+			t.Errorf("BuildExprMatch(%q): 期望 %q, 实际得到 %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestIndex_Search(t *testing.T) {
+	idx, err := Open(filepath.Join(t.TempDir(), FileName))
+	if err == ErrUnavailable {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatalf("Open 失败: %v", err)
+	}
+	defer idx.Close()
+
+	ctx := context.Background()
+	t1 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	docs := []Doc{
+		{RowID: 1, Talker: "alice", Sender: "alice", Seq: 1, Time: t1, Type: 1, Text: "明天签合同"},
+		{RowID: 2, Talker: "alice", Sender: "me", Seq: 2, Time: t1.Add(time.Minute), Type: 1, Text: "合同已经发你邮箱"},
+		{RowID: 3, Talker: "bob", Sender: "bob", Seq: 3, Time: t1.Add(time.Hour), Type: 1, Text: "同事聚餐"},
+	}
+	if err := idx.Write(ctx, "message_0.db", "Msg_a", docs, 3); err != nil {
+		t.Fatalf("Write 失败: %v", err)
+	}
+
+	wm, err := idx.Watermark(ctx, "message_0.db", "Msg_a")
+	if err != nil || wm != 3 {
+		t.Fatalf("期望水位线 3, 实际得到 %d (%v)", wm, err)
+	}
+
+	// "合同" 不应命中只包含 "同" 的文档
+	hits, total, err := idx.Search(ctx, Query{Keyword: "合同"})
+	if err != nil {
+		t.Fatalf("Search 失败: %v", err)
+	}
+	if total != 2 || len(hits) != 2 {
+		t.Fatalf("期望 2 条命中, 实际得到 total=%d hits=%d", total, len(hits))
+	}
+	if hits[0].Seq != 2 {
+		t.Errorf("按时间倒序时第一条应为 seq 2, 实际得到 %d", hits[0].Seq)
+	}
+
+	// 过滤条件和分页
+	hits, total, err = idx.Search(ctx, Query{Keyword: "合同", Senders: []string{"alice"}, Limit: 10})
+	if err != nil {
+		t.Fatalf("Search 失败: %v", err)
+	}
+	if total != 1 || hits[0].RowID != 1 {
+		t.Errorf("期望只命中 rowid 1, 实际得到 total=%d", total)
+	}
+
+	// 清空后不再命中
+	if err := idx.Purge(ctx, "message_0.db", "Msg_a"); err != nil {
+		t.Fatalf("Purge 失败: %v", err)
+	}
+	_, total, err = idx.Search(ctx, Query{Keyword: "合同"})
+	if err != nil {
+		t.Fatalf("Search 失败: %v", err)
+	}
+	if total != 0 {
+		t.Errorf("Purge 后期望 0 条命中, 实际得到 %d", total)
+	}
+}
@@ -0,0 +1,363 @@
+package fts
+
+import (
+	"context"
+	"crypto/md5"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// FileName 是全文索引在工作目录中的文件名
+const FileName = "wetrace_fts.db"
+
+// ErrUnavailable 表示当前编译的 SQLite 不包含 FTS5 模块 (需要 -tags sqlite_fts5 编译)
+var ErrUnavailable = errors.New("当前构建未启用 SQLite FTS5 (请使用 -tags sqlite_fts5 编译)")
+
+const schema = `
+CREATE TABLE IF NOT EXISTS meta (
+	key   TEXT PRIMARY KEY,
+	value TEXT
+);
+CREATE TABLE IF NOT EXISTS doc (
+	id          INTEGER PRIMARY KEY,
+	shard       TEXT NOT NULL,
+	tbl         TEXT NOT NULL,
+	src_rowid   INTEGER NOT NULL,
+	talker      TEXT NOT NULL,
+	sender      TEXT NOT NULL,
+	seq         INTEGER NOT NULL,
+	create_time INTEGER NOT NULL,
+	msg_type    INTEGER NOT NULL,
+	digest      TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS idx_doc_src ON doc(shard, tbl);
+CREATE INDEX IF NOT EXISTS idx_doc_talker ON doc(talker, create_time);
+CREATE TABLE IF NOT EXISTS watermark (
+	shard     TEXT NOT NULL,
+	tbl       TEXT NOT NULL,
+	max_rowid INTEGER NOT NULL,
+	PRIMARY KEY (shard, tbl)
+);
+CREATE VIRTUAL TABLE IF NOT EXISTS doc_fts USING fts5(
+	tokens,
+	content='',
+	contentless_delete=1,
+	tokenize='unicode61 remove_diacritics 2'
+);
+`
+
+// Doc 是一条待写入索引的消息
+type Doc struct {
+	RowID  int64 // 源表中的 rowid，用于回查原始消息
+	Talker string
+	Sender string
+	Seq    int64
+	Time   time.Time
+	Type   int64
+	Text   string
+}
+
+// Hit 是一条命中结果，指向源分片中的原始消息
+type Hit struct {
+	Shard  string // 相对工作目录的分片路径
+	Table  string
+	RowID  int64
+	Talker string
+	Seq    int64
+	Rank   float64
+	Digest string // 建立索引时文本的摘要 (见 Digest)，用于判断源行是否已被修改；旧索引中为空
+}
+
+// Query 封装了全文检索的条件
+type Query struct {
+	Keyword  string
+	Match    string // 已编译的 MATCH 表达式 (见 BuildExprMatch)，非空时忽略 Keyword
+	Talkers  []string
+	Senders  []string
+	MsgType  int
+	MsgTypes []int // 消息类型之一
+	Start    time.Time
+	End      time.Time
+	ByRank   bool // true 按相关度排序，否则按时间倒序
+	Limit    int
+	Offset   int
+}
+
+// Index 是基于 SQLite FTS5 的消息全文索引，以独立文件保存在工作目录中
+type Index struct {
+	db    *sql.DB
+	path  string
+	ready atomic.Bool
+}
+
+// Open 打开 (或创建) 指定路径的索引文件
+func Open(path string) (*Index, error) {
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?_journal_mode=WAL&_busy_timeout=5000", path))
+	if err != nil {
+		return nil, fmt.Errorf("无法打开索引文件 %s: %w", path, err)
+	}
+	// 写入由同步流程串行完成，单连接即可避免 database is locked
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(schema); err != nil {
+		_ = db.Close()
+		if strings.Contains(err.Error(), "no such module: fts5") {
+			return nil, ErrUnavailable
+		}
+		return nil, fmt.Errorf("初始化索引结构失败: %w", err)
+	}
+	// 旧版本的索引文件没有 digest 列，补上后旧文档的 digest 为空
+	if _, err := db.Exec("SELECT digest FROM doc LIMIT 0"); err != nil {
+		if _, err := db.Exec("ALTER TABLE doc ADD COLUMN digest TEXT NOT NULL DEFAULT ''"); err != nil {
+			_ = db.Close()
+			return nil, fmt.Errorf("升级索引结构失败: %w", err)
+		}
+	}
+
+	idx := &Index{db: db, path: path}
+
+	var built string
+	_ = db.QueryRow("SELECT value FROM meta WHERE key = 'built'").Scan(&built)
+	idx.ready.Store(built == "1")
+
+	return idx, nil
+}
+
+// Path 返回索引文件路径
+func (i *Index) Path() string {
+	return i.path
+}
+
+// Ready 表示索引是否已完成过至少一次全量构建，可用于检索
+func (i *Index) Ready() bool {
+	return i.ready.Load()
+}
+
+// MarkReady 记录全量构建已完成
+func (i *Index) MarkReady(ctx context.Context) error {
+	_, err := i.db.ExecContext(ctx, "INSERT OR REPLACE INTO meta (key, value) VALUES ('built', '1')")
+	if err != nil {
+		return err
+	}
+	i.ready.Store(true)
+	return nil
+}
+
+// Watermark 返回某个分片中某张表已索引到的最大 rowid，未索引过返回 0
+func (i *Index) Watermark(ctx context.Context, shard, table string) (int64, error) {
+	var rowid int64
+	err := i.db.QueryRowContext(ctx, "SELECT max_rowid FROM watermark WHERE shard = ? AND tbl = ?", shard, table).Scan(&rowid)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return rowid, err
+}
+
+// Write 在一个事务中写入一批文档并推进水位线
+func (i *Index) Write(ctx context.Context, shard, table string, docs []Doc, watermark int64) error {
+	tx, err := i.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertDocs(ctx, tx, shard, table, docs); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO watermark (shard, tbl, max_rowid) VALUES (?, ?, ?)`, shard, table, watermark); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Replace 重新索引源表中已被修改或删除的行：先删除 rowids 的索引，再写入 docs (其中仍需索引的行的当前内容)。
+// 水位线只记录新增的行，源行在索引之后被改写 (如撤回) 时由检索方发现并调用本方法。
+func (i *Index) Replace(ctx context.Context, shard, table string, rowids []int64, docs []Doc) error {
+	if len(rowids) == 0 {
+		return nil
+	}
+	tx, err := i.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	args := []interface{}{shard, table}
+	for _, id := range rowids {
+		args = append(args, id)
+	}
+	where := "shard = ? AND tbl = ? AND src_rowid IN (" + placeholders(len(rowids)) + ")"
+	if _, err := tx.ExecContext(ctx, `DELETE FROM doc_fts WHERE rowid IN (SELECT id FROM doc WHERE `+where+`)`, args...); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM doc WHERE `+where, args...); err != nil {
+		return err
+	}
+	if err := insertDocs(ctx, tx, shard, table, docs); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// insertDocs 在事务中写入一批文档，分词结果为空的文档不写入
+func insertDocs(ctx context.Context, tx *sql.Tx, shard, table string, docs []Doc) error {
+	docStmt, err := tx.PrepareContext(ctx, `INSERT INTO doc (shard, tbl, src_rowid, talker, sender, seq, create_time, msg_type, digest) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer docStmt.Close()
+
+	ftsStmt, err := tx.PrepareContext(ctx, `INSERT INTO doc_fts (rowid, tokens) VALUES (?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer ftsStmt.Close()
+
+	for _, d := range docs {
+		tokens := Tokenize(d.Text)
+		if tokens == "" {
+			continue
+		}
+		res, err := docStmt.ExecContext(ctx, shard, table, d.RowID, d.Talker, d.Sender, d.Seq, d.Time.Unix(), d.Type, digestOf(tokens))
+		if err != nil {
+			return err
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		if _, err := ftsStmt.ExecContext(ctx, id, tokens); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Purge 删除某个分片中某张表的全部索引数据 (源表被重建时使用)
+func (i *Index) Purge(ctx context.Context, shard, table string) error {
+	tx, err := i.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM doc_fts WHERE rowid IN (SELECT id FROM doc WHERE shard = ? AND tbl = ?)`, shard, table); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM doc WHERE shard = ? AND tbl = ?`, shard, table); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM watermark WHERE shard = ? AND tbl = ?`, shard, table); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Search 执行全文检索，返回当前页的命中结果和命中总数
+func (i *Index) Search(ctx context.Context, q Query) ([]Hit, int, error) {
+	match := q.Match
+	if match == "" {
+		match = BuildMatch(q.Keyword)
+	}
+	if match == "" {
+		return nil, 0, nil
+	}
+
+	var sb strings.Builder
+	args := []interface{}{match}
+	sb.WriteString(" FROM doc_fts JOIN doc d ON d.id = doc_fts.rowid WHERE doc_fts MATCH ?")
+
+	if len(q.Talkers) > 0 {
+		sb.WriteString(" AND d.talker IN (" + placeholders(len(q.Talkers)) + ")")
+		for _, t := range q.Talkers {
+			args = append(args, t)
+		}
+	}
+	if len(q.Senders) > 0 {
+		sb.WriteString(" AND d.sender IN (" + placeholders(len(q.Senders)) + ")")
+		for _, s := range q.Senders {
+			args = append(args, s)
+		}
+	}
+	if q.MsgType > 0 {
+		sb.WriteString(" AND d.msg_type = ?")
+		args = append(args, q.MsgType)
+	}
+	if len(q.MsgTypes) > 0 {
+		sb.WriteString(" AND d.msg_type IN (" + placeholders(len(q.MsgTypes)) + ")")
+		for _, t := range q.MsgTypes {
+			args = append(args, t)
+		}
+	}
+	if !q.Start.IsZero() {
+		sb.WriteString(" AND d.create_time >= ?")
+		args = append(args, q.Start.Unix())
+	}
+	if !q.End.IsZero() {
+		sb.WriteString(" AND d.create_time <= ?")
+		args = append(args, q.End.Unix())
+	}
+	where := sb.String()
+
+	var total int
+	if err := i.db.QueryRowContext(ctx, "SELECT COUNT(*)"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	if total == 0 {
+		return []Hit{}, 0, nil
+	}
+
+	query := "SELECT d.shard, d.tbl, d.src_rowid, d.talker, d.seq, bm25(doc_fts), d.digest" + where
+	if q.ByRank {
+		query += " ORDER BY bm25(doc_fts), d.seq DESC"
+	} else {
+		query += " ORDER BY d.seq DESC"
+	}
+	if q.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d OFFSET %d", q.Limit, q.Offset)
+	}
+
+	rows, err := i.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var hits []Hit
+	for rows.Next() {
+		var h Hit
+		if err := rows.Scan(&h.Shard, &h.Table, &h.RowID, &h.Talker, &h.Seq, &h.Rank, &h.Digest); err != nil {
+			return nil, 0, err
+		}
+		hits = append(hits, h)
+	}
+	return hits, total, rows.Err()
+}
+
+// Close 关闭索引文件
+func (i *Index) Close() error {
+	return i.db.Close()
+}
+
+// Digest 返回文档文本的摘要，与命中结果的 Hit.Digest 比较可判断源行的内容是否已变化
+func Digest(text string) string {
+	return digestOf(Tokenize(text))
+}
+
+func digestOf(tokens string) string {
+	sum := md5.Sum([]byte(tokens))
+	return hex.EncodeToString(sum[:])
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
@@ -0,0 +1,122 @@
+package fts
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/afumu/wetrace/store/types"
+)
+
+// Tokenize 将文本切分为以空格分隔的词元，供 FTS5 的 unicode61 分词器使用。
+// unicode61 会把连续的中日韩文字视为一个词，无法做子串匹配，
+// 因此这里把每个 CJK 字符拆成独立词元，字母和数字则按连续片段保留并转为小写。
+func Tokenize(s string) string {
+	var sb strings.Builder
+	var word []rune
+
+	flush := func() {
+		if len(word) == 0 {
+			return
+		}
+		if sb.Len() > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(strings.ToLower(string(word)))
+		word = word[:0]
+	}
+
+	for _, r := range s {
+		switch {
+		case isCJK(r):
+			flush()
+			if sb.Len() > 0 {
+				sb.WriteByte(' ')
+			}
+			sb.WriteRune(r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word = append(word, r)
+		default:
+			flush()
+		}
+	}
+	flush()
+
+	return sb.String()
+}
+
+// BuildMatch 将用户输入的关键词转换为 FTS5 MATCH 表达式。
+// 以空白分隔的每一段作为一个短语（段内的 CJK 字符需相邻出现），各段之间为 AND 关系；
+// 以字母或数字结尾的短语追加前缀匹配，使 "wor" 能命中 "world"。
+// 如果关键词中不含任何可检索的字符，返回空字符串。
+func BuildMatch(keyword string) string {
+	var phrases []string
+	for _, term := range strings.Fields(keyword) {
+		tokens := Tokenize(term)
+		if tokens == "" {
+			continue
+		}
+		phrase := `"` + tokens + `"`
+		last := []rune(tokens)
+		if r := last[len(last)-1]; !isCJK(r) {
+			phrase += "*"
+		}
+		phrases = append(phrases, phrase)
+	}
+	return strings.Join(phrases, " AND ")
+}
+
+// BuildExprMatch 将搜索表达式转换为 FTS5 MATCH 表达式：子句之间为 AND，子句内为 OR，排除项使用 NOT。
+// 带引号的短语不追加前缀匹配。表达式中有无法被索引表示的关键词 (例如只含标点) 时返回空字符串，
+// 调用方应回退到逐分片扫描。
+func BuildExprMatch(e *types.SearchExpr) string {
+	var clauses []string
+	for _, clause := range e.Clauses {
+		parts := make([]string, 0, len(clause))
+		for _, term := range clause {
+			phrase := matchPhrase(term)
+			if phrase == "" {
+				return ""
+			}
+			parts = append(parts, phrase)
+		}
+		if len(parts) == 1 {
+			clauses = append(clauses, parts[0])
+		} else {
+			clauses = append(clauses, "("+strings.Join(parts, " OR ")+")")
+		}
+	}
+	if len(clauses) == 0 {
+		return ""
+	}
+
+	match := strings.Join(clauses, " AND ")
+	for _, term := range e.Excludes {
+		phrase := matchPhrase(term)
+		if phrase == "" {
+			return ""
+		}
+		match += " NOT " + phrase
+	}
+	return match
+}
+
+// matchPhrase 将一个关键词转换为 FTS5 短语，关键词中没有可检索的字符时返回空字符串
+func matchPhrase(term types.SearchTerm) string {
+	tokens := Tokenize(term.Text)
+	if tokens == "" {
+		return ""
+	}
+	phrase := `"` + tokens + `"`
+	last := []rune(tokens)
+	if r := last[len(last)-1]; !term.Phrase && !isCJK(r) {
+		phrase += "*"
+	}
+	return phrase
+}
+
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) ||
+		unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) ||
+		unicode.Is(unicode.Hangul, r)
+}
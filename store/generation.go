@@ -0,0 +1,148 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/afumu/wetrace/store/bind"
+	"github.com/afumu/wetrace/store/core"
+	"github.com/afumu/wetrace/store/repo"
+	"github.com/afumu/wetrace/store/strategy"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// SnapshotDirName 是工作目录下保存解密快照的子目录，每次同步解密到其中一个新目录
+	SnapshotDirName = ".snapshots"
+	// currentFileName 记录当前生效的快照目录名
+	currentFileName = "CURRENT"
+)
+
+// generation 是一份数据目录及其上的连接池、路由和仓储。
+// 查询期间持有引用；被新的 generation 替换后，等所有引用释放再关闭连接，
+// 因此正在进行的查询 (例如导出) 不会读到另一份数据或被中途关闭。
+type generation struct {
+	dir    string
+	pool   *core.ConnectionPool
+	router *bind.TimelineRouter
+	repo   *repo.Repository
+
+	mu      sync.Mutex
+	refs    int
+	retired bool
+	discard bool // 关闭后删除数据目录 (被替换的旧快照)
+}
+
+// openGeneration 识别目录中的数据版本并构建时间线索引
+func openGeneration(ctx context.Context, dir string) (*generation, error) {
+	pool := core.NewConnectionPool(dir)
+
+	// 策略层：根据目录布局自动识别 Windows V3 / Windows V4 / macOS V3
+	strat := strategy.Detect(dir)
+	log.Info().Str("version", strat.Version()).Str("dir", dir).Msg("识别到微信数据版本")
+
+	router := bind.NewTimelineRouter(dir, pool, strat)
+	if err := router.RebuildIndex(ctx); err != nil {
+		pool.CloseAll()
+		return nil, fmt.Errorf("构建时间线索引失败: %w", err)
+	}
+
+	return &generation{
+		dir:    dir,
+		pool:   pool,
+		router: router,
+		repo:   repo.New(router, pool),
+	}, nil
+}
+
+// acquire 增加引用计数，generation 已被替换时返回 false
+func (g *generation) acquire() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.retired {
+		return false
+	}
+	g.refs++
+	return true
+}
+
+// release 释放引用，最后一个引用释放时关闭已被替换的 generation
+func (g *generation) release() {
+	g.mu.Lock()
+	g.refs--
+	done := g.retired && g.refs == 0
+	g.mu.Unlock()
+	if done {
+		g.close()
+	}
+}
+
+// retire 标记 generation 已被替换，没有引用时立即关闭
+func (g *generation) retire(discard bool) {
+	g.mu.Lock()
+	g.retired = true
+	g.discard = discard
+	done := g.refs == 0
+	g.mu.Unlock()
+	if done {
+		g.close()
+	}
+}
+
+func (g *generation) close() {
+	if err := g.pool.CloseAll(); err != nil {
+		log.Warn().Err(err).Str("dir", g.dir).Msg("关闭旧数据连接失败")
+	}
+	if g.discard {
+		if err := os.RemoveAll(g.dir); err != nil {
+			log.Warn().Err(err).Str("dir", g.dir).Msg("删除旧快照失败")
+		}
+	}
+}
+
+// resolveDataDir 返回工作目录当前生效的数据目录：
+// 存在已完成的同步快照时使用快照目录，否则直接使用工作目录
+func resolveDataDir(workDir string) string {
+	root := filepath.Join(workDir, SnapshotDirName)
+	name, err := os.ReadFile(filepath.Join(root, currentFileName))
+	if err != nil {
+		return workDir
+	}
+	dir := filepath.Join(root, strings.TrimSpace(string(name)))
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		log.Warn().Str("dir", dir).Msg("当前快照目录不存在，使用工作目录")
+		return workDir
+	}
+	return dir
+}
+
+// writeCurrent 原子地更新当前快照记录 (先写临时文件再重命名)
+func writeCurrent(root, name string) error {
+	tmp := filepath.Join(root, currentFileName+".tmp")
+	if err := os.WriteFile(tmp, []byte(name), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(root, currentFileName))
+}
+
+// cleanSnapshots 删除除 keep 以外的快照目录 (上次运行中断或未及时清理的残留)
+func cleanSnapshots(workDir, keep string) {
+	root := filepath.Join(workDir, SnapshotDirName)
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		dir := filepath.Join(root, e.Name())
+		if !e.IsDir() || dir == keep {
+			continue
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			log.Warn().Err(err).Str("dir", dir).Msg("清理残留快照失败")
+		}
+	}
+}
@@ -527,10 +527,10 @@ func (r *Repository) GetRepeatAnalysis(ctx context.Context, talker string) ([]*m
 	return result, nil
 }
 
-// GetPersonalTopContacts 统计“我”与谁聊得最多
-func (r *Repository) GetPersonalTopContacts(ctx context.Context, limit int) ([]*model.PersonalTopContact, error) {
+// GetPersonalTopContacts 统计“我”与谁聊得最多，label 不为空时只统计带有该标签的联系人
+func (r *Repository) GetPersonalTopContacts(ctx context.Context, limit int, label string) ([]*model.PersonalTopContact, error) {
 	// 获取所有会话列表作为基础，以确定需要扫描哪些 Talker
-	sessions, err := r.GetSessions(ctx, types.SessionQuery{Limit: 1000})
+	sessions, err := r.GetSessions(ctx, types.SessionQuery{Label: label, Limit: 1000})
 	if err != nil {
 		return nil, err
 	}
@@ -540,15 +540,16 @@ func (r *Repository) GetPersonalTopContacts(ctx context.Context, limit int) ([]*
 		recv     int
 		lastTime int64
 	}
-	aggStats := make(map[string]*stats)
-
+	// 排除群聊：个人社交分析只关注私聊记录
+	var privateTalkers []string
 	for _, session := range sessions {
-		talker := session.UserName
-		// 排除群聊：个人社交分析只关注私聊记录
-		if strings.HasSuffix(talker, "@chatroom") {
-			continue
+		if !strings.HasSuffix(session.UserName, "@chatroom") {
+			privateTalkers = append(privateTalkers, session.UserName)
 		}
+	}
 
+	// 各会话的统计相互独立，并发执行
+	perTalker, err := fanOut(ctx, r.shardConcurrency(), privateTalkers, func(ctx context.Context, talker string) *stats {
 		targets := r.router.Resolve(time.Unix(0, 0), time.Now(), talker)
 
 		s := &stats{}
@@ -626,8 +627,16 @@ func (r *Repository) GetPersonalTopContacts(ctx context.Context, limit int) ([]*
 				rows.Close()
 			}
 		}
+		return s
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	aggStats := make(map[string]*stats)
+	for i, s := range perTalker {
 		if s.sent > 0 || s.recv > 0 {
-			aggStats[talker] = s
+			aggStats[privateTalkers[i]] = s
 		}
 	}
 
@@ -681,77 +690,34 @@ func (r *Repository) GetDashboardData(ctx context.Context) (*model.DashboardData
 	dbSize, _ := r.getDBSize()
 	dirSize, _ := r.getDirSize()
 
-	var totalMsgs, sentMsgs, recvMsgs int
-	var earliest, latest int64
-
-	// 2. 遍历所有消息分片，直接统计全局总量和时间范围
+	// 2. 并发遍历所有消息分片，直接统计全局总量和时间范围
 	// 这样比按会话统计要快得多，且能覆盖所有消息
-	for _, shard := range r.router.GetShards() {
+	version := r.version()
+	parts, err := fanOut(ctx, r.shardConcurrency(), r.router.GetShards(), func(ctx context.Context, shard *bind.DatabaseShard) shardTotals {
 		db, err := r.pool.GetConnection(shard.FilePath)
 		if err != nil {
-			continue
+			return shardTotals{}
 		}
+		if version == model.WeChatV3 {
+			return r.queryV3ShardTotals(ctx, db)
+		}
+		return r.queryV4ShardTotals(ctx, db, myWxid)
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		// 检查是 V3 还是 V4
-		if r.isTableExist(db, "MSG") {
-			// V3 逻辑
-			var count int
-			var minT, maxT sql.NullInt64
-			query := "SELECT COUNT(*), MIN(CreateTime/1000), MAX(CreateTime/1000) FROM MSG"
-			err := db.QueryRowContext(ctx, query).Scan(&count, &minT, &maxT)
-			if err == nil {
-				totalMsgs += count
-				if minT.Valid && (earliest == 0 || minT.Int64 < earliest) {
-					earliest = minT.Int64
-				}
-				if maxT.Valid && maxT.Int64 > latest {
-					latest = maxT.Int64
-				}
-
-				// 粗略估算发送/接收 (IsSender 在 V3 中通常存在)
-				var sCount int
-				_ = db.QueryRowContext(ctx, "SELECT COUNT(*) FROM MSG WHERE IsSender = 1").Scan(&sCount)
-				sentMsgs += sCount
-				recvMsgs += (count - sCount)
-			}
-		} else {
-			// V4 逻辑：查询所有 Msg_ 开头的表
-			rows, err := db.QueryContext(ctx, "SELECT name FROM sqlite_master WHERE type='table' AND name LIKE 'Msg_%%'")
-			if err == nil {
-				for rows.Next() {
-					var tableName string
-					rows.Scan(&tableName)
-
-					var count int
-					var minT, maxT sql.NullInt64
-					query := fmt.Sprintf("SELECT COUNT(*), MIN(create_time), MAX(create_time) FROM %s", tableName)
-					if err := db.QueryRowContext(ctx, query).Scan(&count, &minT, &maxT); err == nil {
-						totalMsgs += count
-						if minT.Valid && (earliest == 0 || minT.Int64 < earliest) {
-							earliest = minT.Int64
-						}
-						if maxT.Valid && maxT.Int64 > latest {
-							latest = maxT.Int64
-						}
-
-						// V4 增强版判定
-						var sCount int
-						if myWxid != "" {
-							// 如果识别到了我的 wxid，那么 (是我的 ID OR status=2 OR sender_id=0) 都算我的
-							q := fmt.Sprintf(`
-								SELECT COUNT(*) FROM %s m 
-								LEFT JOIN Name2Id n ON m.real_sender_id = n.rowid 
-								WHERE (n.user_name = ? OR m.status = 2 OR m.real_sender_id = 0) AND m.local_type != 10000`, tableName)
-							_ = db.QueryRowContext(ctx, q, myWxid).Scan(&sCount)
-						} else {
-							_ = db.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE (status = 2 OR real_sender_id = 0)", tableName)).Scan(&sCount)
-						}
-						sentMsgs += sCount
-						recvMsgs += (count - sCount)
-					}
-				}
-				rows.Close()
-			}
+	var totalMsgs, sentMsgs, recvMsgs int
+	var earliest, latest int64
+	for _, t := range parts {
+		totalMsgs += t.total
+		sentMsgs += t.sent
+		recvMsgs += t.recv
+		if t.earliest > 0 && (earliest == 0 || t.earliest < earliest) {
+			earliest = t.earliest
+		}
+		if t.latest > latest {
+			latest = t.latest
 		}
 	}
 
@@ -780,7 +746,7 @@ func (r *Repository) GetDashboardData(ctx context.Context) (*model.DashboardData
 			tableName := "Msg_" + hex.EncodeToString(hash[:])
 			if r.isTableExist(db, tableName) {
 				_ = db.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s", tableName)).Scan(&count)
-			} else if r.isTableExist(db, "MSG") {
+			} else if r.version() == model.WeChatV3 {
 				_ = db.QueryRowContext(ctx, "SELECT COUNT(*) FROM MSG WHERE StrTalker = ?", groups[i].ChatRoomName).Scan(&count)
 			}
 		}
@@ -817,6 +783,76 @@ func (r *Repository) GetDashboardData(ctx context.Context) (*model.DashboardData
 	}, nil
 }
 
+// shardTotals 是单个分片的消息总量和时间范围统计
+type shardTotals struct {
+	total, sent, recv int
+	earliest, latest  int64
+}
+
+// addRange 合并一张表的消息数量和时间范围
+func (t *shardTotals) addRange(count int, minT, maxT sql.NullInt64) {
+	t.total += count
+	if minT.Valid && (t.earliest == 0 || minT.Int64 < t.earliest) {
+		t.earliest = minT.Int64
+	}
+	if maxT.Valid && maxT.Int64 > t.latest {
+		t.latest = maxT.Int64
+	}
+}
+
+// queryV3ShardTotals 统计 V3 分片中 MSG 表的消息总量
+func (r *Repository) queryV3ShardTotals(ctx context.Context, db *sql.DB) shardTotals {
+	var t shardTotals
+	var count int
+	var minT, maxT sql.NullInt64
+	query := "SELECT COUNT(*), MIN(CreateTime/1000), MAX(CreateTime/1000) FROM MSG"
+	if err := db.QueryRowContext(ctx, query).Scan(&count, &minT, &maxT); err != nil {
+		return t
+	}
+	t.addRange(count, minT, maxT)
+
+	// 粗略估算发送/接收 (IsSender 在 V3 中通常存在)
+	var sCount int
+	_ = db.QueryRowContext(ctx, "SELECT COUNT(*) FROM MSG WHERE IsSender = 1").Scan(&sCount)
+	t.sent += sCount
+	t.recv += count - sCount
+	return t
+}
+
+// queryV4ShardTotals 统计 V4 分片中所有 Msg_ 表的消息总量
+func (r *Repository) queryV4ShardTotals(ctx context.Context, db *sql.DB, myWxid string) shardTotals {
+	var t shardTotals
+	tables, err := r.listMessageTables(ctx, db, "Msg_")
+	if err != nil {
+		return t
+	}
+	for _, tableName := range tables {
+		var count int
+		var minT, maxT sql.NullInt64
+		query := fmt.Sprintf("SELECT COUNT(*), MIN(create_time), MAX(create_time) FROM %s", tableName)
+		if err := db.QueryRowContext(ctx, query).Scan(&count, &minT, &maxT); err != nil {
+			continue
+		}
+		t.addRange(count, minT, maxT)
+
+		// V4 增强版判定
+		var sCount int
+		if myWxid != "" {
+			// 如果识别到了我的 wxid，那么 (是我的 ID OR status=2 OR sender_id=0) 都算我的
+			q := fmt.Sprintf(`
+				SELECT COUNT(*) FROM %s m 
+				LEFT JOIN Name2Id n ON m.real_sender_id = n.rowid 
+				WHERE (n.user_name = ? OR m.status = 2 OR m.real_sender_id = 0) AND m.local_type != 10000`, tableName)
+			_ = db.QueryRowContext(ctx, q, myWxid).Scan(&sCount)
+		} else {
+			_ = db.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE (status = 2 OR real_sender_id = 0)", tableName)).Scan(&sCount)
+		}
+		t.sent += sCount
+		t.recv += count - sCount
+	}
+	return t
+}
+
 func (r *Repository) getDBSize() (int64, error) {
 	var total int64
 	// 简单实现：只统计 message/ 下的 db
@@ -842,30 +878,35 @@ func (r *Repository) getDirSize() (int64, error) {
 
 // SearchGlobalMessages 全局搜索消息（跨所有分片和表）
 func (r *Repository) SearchGlobalMessages(ctx context.Context, q types.MessageQuery) ([]*model.Message, error) {
-	var allMessages []*model.Message
-
 	var talkerMD5Map map[string]string
+	version := r.version()
+	if version != model.WeChatV3 {
+		talkerMD5Map = r.getTalkerMD5Map(ctx)
+	}
 
-	for _, shard := range r.router.GetShards() {
+	parts, err := fanOut(ctx, r.shardConcurrency(), r.router.GetShards(), func(ctx context.Context, shard *bind.DatabaseShard) []*model.Message {
 		db, err := r.pool.GetConnection(shard.FilePath)
 		if err != nil {
-			continue
+			return nil
 		}
 
 		// 区分版本进行搜索
-		if r.isTableExist(db, "MSG") {
+		if version == model.WeChatV3 {
 			// V3 搜索
 			msgs, _ := r.searchV3Global(ctx, db, q)
-			allMessages = append(allMessages, msgs...)
-		} else {
-			// V4 搜索：遍历所有 Msg_xxx 表
-			if talkerMD5Map == nil {
-				talkerMD5Map = r.getTalkerMD5Map(ctx)
-			}
-			msgs, _ := r.searchV4Global(ctx, db, q, talkerMD5Map)
-			allMessages = append(allMessages, msgs...)
+			return msgs
 		}
+		// V4 搜索：遍历所有 Msg_xxx 表
+		msgs, _ := r.searchV4Global(ctx, db, q, talkerMD5Map)
+		return msgs
+	})
+	if err != nil {
+		return nil, err
+	}
 
+	var allMessages []*model.Message
+	for _, msgs := range parts {
+		allMessages = append(allMessages, msgs...)
 		if len(allMessages) > 1000 { // 限制全局搜索返回结果数量
 			break
 		}
@@ -12,7 +12,7 @@ import (
 
 func (r *Repository) GetChatRooms(ctx context.Context, q types.ChatRoomQuery) ([]*model.ChatRoom, error) {
 
-	dbPath, err := r.router.GetContactDBPath()
+	dbPath, err := r.router.GetChatRoomDBPath()
 
 	if err != nil {
 
@@ -28,22 +28,70 @@ func (r *Repository) GetChatRooms(ctx context.Context, q types.ChatRoomQuery) ([
 
 	}
 
-	// 1. 尝试 V4 模式
+	if q.Search != "" {
+		return r.searchChatRooms(ctx, db, q)
+	}
+
+	switch r.version() {
+
+	case model.WeChatDarwinV3:
 
-	var exists bool
+		return r.queryDarwinChatRooms(ctx, db, q)
 
-	_ = db.QueryRowContext(ctx, "SELECT 1 FROM sqlite_master WHERE type='table' AND name='chat_room'").Scan(&exists)
+	case model.WeChatV3:
 
-	if exists {
+		return r.queryV3ChatRooms(ctx, db, q)
+
+	default:
 
 		return r.queryV4ChatRooms(ctx, db, q)
 
 	}
 
-	// 2. 尝试 V3 模式
+}
+
+// searchChatRooms 按群账号、群名称或备注 (支持拼音) 搜索群聊。
+// V3/V4 的群聊表不含名称，需要从联系人表补充后在内存中过滤，再分页。
+func (r *Repository) searchChatRooms(ctx context.Context, db *sql.DB, q types.ChatRoomQuery) ([]*model.ChatRoom, error) {
+	all := q
+	all.Limit, all.Offset = 0, 0
+
+	var rooms []*model.ChatRoom
+	var err error
+	switch r.version() {
+	case model.WeChatDarwinV3:
+		rooms, err = r.queryDarwinChatRooms(ctx, db, all)
+	case model.WeChatV3:
+		rooms, err = r.queryV3ChatRooms(ctx, db, all)
+	default:
+		rooms, err = r.queryV4ChatRooms(ctx, db, all)
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	return r.queryV3ChatRooms(ctx, db, q)
+	var missing []string
+	for _, room := range rooms {
+		if room.NickName == "" && room.Remark == "" {
+			missing = append(missing, room.Name)
+		}
+	}
+	profiles, err := r.getContactProfiles(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
 
+	matched := make([]*model.ChatRoom, 0, len(rooms))
+	for _, room := range rooms {
+		nickName, remark := room.NickName, room.Remark
+		if p, ok := profiles[room.Name]; ok {
+			nickName, remark = p.NickName, p.Remark
+		}
+		if matchSearch(q.Search, remark, nickName, room.Name) {
+			matched = append(matched, room)
+		}
+	}
+	return paginate(matched, q.Limit, q.Offset), nil
 }
 
 func (r *Repository) queryV4ChatRooms(ctx context.Context, db *sql.DB, q types.ChatRoomQuery) ([]*model.ChatRoom, error) {
@@ -259,3 +307,38 @@ func (r *Repository) queryV3ChatRooms(ctx context.Context, db *sql.DB, q types.C
 	return rooms, nil
 
 }
+
+func (r *Repository) queryDarwinChatRooms(ctx context.Context, db *sql.DB, q types.ChatRoomQuery) ([]*model.ChatRoom, error) {
+	query := `SELECT m_nsUsrName, IFNULL(nickname,''), IFNULL(m_nsRemark,''), IFNULL(m_nsChatRoomMemList,''), IFNULL(m_nsChatRoomAdminList,'') FROM GroupContact`
+	var args []interface{}
+
+	if q.Keyword != "" {
+		query += ` WHERE m_nsUsrName = ? OR nickname = ? OR m_nsRemark = ?`
+		args = append(args, q.Keyword, q.Keyword, q.Keyword)
+	}
+
+	query += ` ORDER BY m_nsUsrName`
+
+	if q.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", q.Limit)
+		if q.Offset > 0 {
+			query += fmt.Sprintf(" OFFSET %d", q.Offset)
+		}
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rooms []*model.ChatRoom
+	for rows.Next() {
+		var c model.ChatRoomDarwinV3
+		if err := rows.Scan(&c.M_nsUsrName, &c.Nickname, &c.M_nsRemark, &c.M_nsChatRoomMemList, &c.M_nsChatRoomAdminList); err != nil {
+			return nil, err
+		}
+		rooms = append(rooms, c.Wrap(nil))
+	}
+	return rooms, nil
+}
@@ -20,19 +20,57 @@ func (r *Repository) GetContacts(ctx context.Context, q types.ContactQuery) ([]*
 		return nil, err
 	}
 
-	// 1. 尝试 V4 模式
-	var exists bool
-	_ = db.QueryRowContext(ctx, "SELECT 1 FROM sqlite_master WHERE type='table' AND name='contact'").Scan(&exists)
-	if exists {
-		return r.queryV4Contacts(ctx, db, q)
+	// 拼音搜索和标签筛选无法在 SQL 中完成，先取出全部联系人，在内存中过滤后再分页
+	limit, offset := q.Limit, q.Offset
+	filtered := q.Search != "" || q.Label != ""
+	if filtered {
+		q.Limit, q.Offset = 0, 0
 	}
 
-	// 2. 尝试 V3 模式
-	return r.queryV3Contacts(ctx, db, q)
+	var contacts []*model.Contact
+	switch r.version() {
+	case model.WeChatDarwinV3:
+		contacts, err = r.queryDarwinContacts(ctx, db, q)
+	case model.WeChatV3:
+		contacts, err = r.queryV3Contacts(ctx, db, q)
+	default:
+		contacts, err = r.queryV4Contacts(ctx, db, q)
+	}
+	if err != nil || !filtered {
+		return contacts, err
+	}
+
+	if q.Label != "" {
+		label, err := r.resolveLabel(ctx, q.Label)
+		if err != nil {
+			return nil, err
+		}
+		if label == nil {
+			return nil, nil
+		}
+		matched := contacts[:0]
+		for _, c := range contacts {
+			if c.HasLabel(label.ID) {
+				matched = append(matched, c)
+			}
+		}
+		contacts = matched
+	}
+	return paginate(contacts, limit, offset), nil
+}
+
+// matchContact 判断联系人的账号、微信号、备注或昵称是否匹配搜索词
+func matchContact(search string, c *model.Contact) bool {
+	return matchSearch(search, c.Remark, c.NickName, c.Alias, c.UserName)
 }
 
 func (r *Repository) queryV4Contacts(ctx context.Context, db *sql.DB, q types.ContactQuery) ([]*model.Contact, error) {
-	query := `SELECT username, local_type, alias, remark, nick_name, COALESCE(small_head_url,''), COALESCE(big_head_url,'') FROM contact`
+	// 标签成员列只在设置过标签的账号的数据库中出现，没有时返回空字符串
+	labelCol := "''"
+	if r.isColumnExist(db, "contact", "label_id_list") {
+		labelCol = "COALESCE(label_id_list,'')"
+	}
+	query := `SELECT username, local_type, alias, remark, nick_name, COALESCE(small_head_url,''), COALESCE(big_head_url,''), ` + labelCol + ` FROM contact`
 	var args []interface{}
 
 	if q.Keyword != "" {
@@ -58,17 +96,22 @@ func (r *Repository) queryV4Contacts(ctx context.Context, db *sql.DB, q types.Co
 	var contacts []*model.Contact
 	for rows.Next() {
 		var c model.ContactV4
-		err := rows.Scan(&c.UserName, &c.LocalType, &c.Alias, &c.Remark, &c.NickName, &c.SmallHeadURL, &c.BigHeadURL)
+		err := rows.Scan(&c.UserName, &c.LocalType, &c.Alias, &c.Remark, &c.NickName, &c.SmallHeadURL, &c.BigHeadURL, &c.LabelIDList)
 		if err != nil {
 			return nil, err
 		}
-		contacts = append(contacts, c.Wrap())
+		contact := c.Wrap()
+		if q.Search != "" && !matchContact(q.Search, contact) {
+			continue
+		}
+		contacts = append(contacts, contact)
 	}
 	return contacts, nil
 }
 
 func (r *Repository) queryV3Contacts(ctx context.Context, db *sql.DB, q types.ContactQuery) ([]*model.Contact, error) {
-	query := `SELECT UserName, Alias, Remark, NickName, Reserved1, COALESCE(SmallHeadImgUrl,''), COALESCE(BigHeadImgUrl,'') FROM Contact`
+	query := `SELECT UserName, Alias, Remark, NickName, Reserved1, COALESCE(SmallHeadImgUrl,''), COALESCE(BigHeadImgUrl,''), COALESCE(LabelIDList,''),
+		COALESCE(PYInitial,''), COALESCE(QuanPin,''), COALESCE(RemarkPYInitial,''), COALESCE(RemarkQuanPin,'') FROM Contact`
 	var args []interface{}
 
 	if q.Keyword != "" {
@@ -94,11 +137,58 @@ func (r *Repository) queryV3Contacts(ctx context.Context, db *sql.DB, q types.Co
 	var contacts []*model.Contact
 	for rows.Next() {
 		var c model.ContactV3
-		err := rows.Scan(&c.UserName, &c.Alias, &c.Remark, &c.NickName, &c.Reserved1, &c.SmallHeadImgUrl, &c.BigHeadImgUrl)
+		err := rows.Scan(&c.UserName, &c.Alias, &c.Remark, &c.NickName, &c.Reserved1, &c.SmallHeadImgUrl, &c.BigHeadImgUrl, &c.LabelIDList,
+			&c.PYInitial, &c.QuanPin, &c.RemarkPYInitial, &c.RemarkQuanPin)
 		if err != nil {
 			return nil, err
 		}
-		contacts = append(contacts, c.Wrap())
+		contact := c.Wrap()
+		// 优先使用数据库中保存的拼音，再回退到内置的拼音转换
+		if q.Search != "" && !matchPinyinColumns(q.Search, c.PYInitial, c.QuanPin, c.RemarkPYInitial, c.RemarkQuanPin) &&
+			!matchContact(q.Search, contact) {
+			continue
+		}
+		contacts = append(contacts, contact)
+	}
+	return contacts, nil
+}
+
+func (r *Repository) queryDarwinContacts(ctx context.Context, db *sql.DB, q types.ContactQuery) ([]*model.Contact, error) {
+	query := `SELECT m_nsUsrName, IFNULL(nickname,''), IFNULL(m_nsRemark,''), IFNULL(m_uiSex,0), IFNULL(m_nsAliasName,''), IFNULL(m_nsHeadImgUrl,''), IFNULL(m_nsHeadHDImgUrl,'') FROM WCContact`
+	var args []interface{}
+
+	if q.Keyword != "" {
+		query += ` WHERE m_nsUsrName = ? OR m_nsAliasName = ? OR m_nsRemark = ? OR nickname = ?`
+		args = append(args, q.Keyword, q.Keyword, q.Keyword, q.Keyword)
+	}
+
+	query += ` ORDER BY m_nsUsrName`
+
+	if q.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", q.Limit)
+		if q.Offset > 0 {
+			query += fmt.Sprintf(" OFFSET %d", q.Offset)
+		}
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query contacts failed: %w", err)
+	}
+	defer rows.Close()
+
+	var contacts []*model.Contact
+	for rows.Next() {
+		var c model.ContactDarwinV3
+		err := rows.Scan(&c.M_nsUsrName, &c.Nickname, &c.M_nsRemark, &c.M_uiSex, &c.M_nsAliasName, &c.M_nsHeadImgUrl, &c.M_nsHeadHDImgUrl)
+		if err != nil {
+			return nil, err
+		}
+		contact := c.Wrap()
+		if q.Search != "" && !matchContact(q.Search, contact) {
+			continue
+		}
+		contacts = append(contacts, contact)
 	}
 	return contacts, nil
 }
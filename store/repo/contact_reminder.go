@@ -121,7 +121,7 @@ func (r *Repository) queryLastTimeFromShard(ctx context.Context, db *sql.DB, sha
 	}
 
 	// V3 模式
-	if r.isTableExist(db, "MSG") {
+	if r.version() == model.WeChatV3 {
 		var maxTime sql.NullInt64
 		query := "SELECT MAX(CreateTime/1000) FROM MSG WHERE StrTalker = ?"
 		// 优先使用 TalkerID
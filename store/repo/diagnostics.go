@@ -0,0 +1,207 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/afumu/wetrace/internal/model"
+	"github.com/afumu/wetrace/store/fts"
+)
+
+// diagnosticsCheckLimit 是完整性检查最多返回的问题条数
+const diagnosticsCheckLimit = 100
+
+// GetDiagnostics 汇总数据目录的诊断信息：时间线索引中的分片、目录下每个数据库文件的表和行数、
+// schema 版本以及完整性检查结果。check 为 model.DiagnosticsCheckQuick (默认)、Full 或 None。
+// 单个文件无法读取时记录在该文件的 Error 中，不影响其他文件。
+func (r *Repository) GetDiagnostics(ctx context.Context, check string) (*model.Diagnostics, error) {
+	switch check {
+	case "":
+		check = model.DiagnosticsCheckQuick
+	case model.DiagnosticsCheckQuick, model.DiagnosticsCheckFull, model.DiagnosticsCheckNone:
+	default:
+		return nil, fmt.Errorf("不支持的完整性检查方式: %s", check)
+	}
+
+	baseDir := r.router.GetBaseDir()
+	d := &model.Diagnostics{
+		Version:      r.version(),
+		DataDir:      baseDir,
+		Revision:     r.router.Revision(),
+		GeneratedAt:  time.Now(),
+		Check:        check,
+		Shards:       []*model.ShardDiagnostics{},
+		Files:        []*model.DBFileDiagnostics{},
+		Unrecognized: []string{},
+	}
+
+	indexed := make(map[string]bool)
+	for _, shard := range r.router.GetShards() {
+		rel := relPath(baseDir, shard.FilePath)
+		indexed[rel] = true
+		d.Shards = append(d.Shards, &model.ShardDiagnostics{
+			Path:      rel,
+			StartTime: shard.StartTime,
+			EndTime:   shard.EndTime,
+			Open:      shard.IsOpen(),
+			Talkers:   len(shard.TalkerMap),
+		})
+	}
+
+	paths, err := findDBFiles(baseDir)
+	if err != nil {
+		return nil, err
+	}
+	strat := r.router.Strategy()
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		rel := relPath(baseDir, path)
+		f := &model.DBFileDiagnostics{Path: rel, Indexed: indexed[rel], Tables: []*model.TableDiagnostics{}}
+		if info, err := os.Stat(path); err == nil {
+			f.Size = info.Size()
+		}
+		if strat != nil {
+			if meta, ok := strat.Identify(filepath.Base(path)); ok {
+				f.Type, f.Recognized = meta.Type.String(), true
+			}
+		}
+		if !f.Recognized {
+			d.Unrecognized = append(d.Unrecognized, rel)
+		}
+		if err := r.inspectDBFile(ctx, path, f, check); err != nil {
+			f.Error = err.Error()
+		}
+		d.Files = append(d.Files, f)
+	}
+	return d, nil
+}
+
+// inspectDBFile 读取数据库文件的 schema 版本、表和行数，并按 check 执行完整性检查。
+// 已识别的文件使用连接池中的连接，未识别的文件临时打开，读取后关闭。
+func (r *Repository) inspectDBFile(ctx context.Context, path string, f *model.DBFileDiagnostics, check string) error {
+	var db *sql.DB
+	var err error
+	if f.Recognized {
+		db, err = r.pool.GetConnection(path)
+	} else {
+		db, err = r.pool.OpenTemporary(path)
+		if db != nil {
+			defer db.Close()
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&f.UserVersion); err != nil {
+		// 未解密的文件在第一次读取时即报错 (file is not a database)
+		return err
+	}
+	if err := db.QueryRowContext(ctx, "PRAGMA schema_version").Scan(&f.SchemaVersion); err != nil {
+		return err
+	}
+
+	rows, err := db.QueryContext(ctx, "SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name")
+	if err != nil {
+		return err
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		names = append(names, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		t := &model.TableDiagnostics{Name: name}
+		query := fmt.Sprintf(`SELECT COUNT(*) FROM "%s"`, strings.ReplaceAll(name, `"`, `""`))
+		if err := db.QueryRowContext(ctx, query).Scan(&t.Rows); err != nil {
+			// 依赖微信自带分词器等扩展的虚拟表无法读取
+			t.Rows = -1
+		}
+		f.Tables = append(f.Tables, t)
+	}
+
+	if check == model.DiagnosticsCheckNone {
+		return nil
+	}
+	pragma := "quick_check"
+	if check == model.DiagnosticsCheckFull {
+		pragma = "integrity_check"
+	}
+	f.Check, err = queryStrings(ctx, db, fmt.Sprintf("PRAGMA %s(%d)", pragma, diagnosticsCheckLimit))
+	return err
+}
+
+// queryStrings 执行只返回一列文本的查询
+func queryStrings(ctx context.Context, db *sql.DB, query string) ([]string, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []string
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		result = append(result, s)
+	}
+	return result, rows.Err()
+}
+
+// findDBFiles 递归查找数据目录下的 .db 文件，跳过隐藏目录 (如解密快照 .snapshots) 和全文索引文件
+func findDBFiles(baseDir string) ([]string, error) {
+	var paths []string
+	err := filepath.WalkDir(baseDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if path == baseDir {
+				return err
+			}
+			// 无权限等无法进入的子目录不影响其他文件
+			return nil
+		}
+		if entry.IsDir() {
+			if path != baseDir && strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.EqualFold(filepath.Ext(entry.Name()), ".db") && entry.Name() != fts.FileName {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("遍历数据目录失败: %w", err)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// relPath 返回相对于数据目录的路径 (使用 / 分隔)，无法计算时返回原路径
+func relPath(baseDir, path string) string {
+	rel, err := filepath.Rel(baseDir, path)
+	if err != nil {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
@@ -0,0 +1,77 @@
+package repo
+
+import (
+	"context"
+	"runtime"
+	"sync"
+)
+
+// DefaultShardConcurrency 是未配置时同时查询的分片数上限
+var DefaultShardConcurrency = min(runtime.NumCPU(), 8)
+
+// SetShardConcurrency 设置同时查询的分片数上限，n <= 0 时恢复默认值
+func (r *Repository) SetShardConcurrency(n int) {
+	r.concurrency = n
+}
+
+// shardConcurrency 返回当前生效的并发上限
+func (r *Repository) shardConcurrency() int {
+	if r.concurrency > 0 {
+		return r.concurrency
+	}
+	return max(DefaultShardConcurrency, 1)
+}
+
+// fanOut 以不超过 limit 的并发度对 items 中的每个元素执行 fn，结果按输入顺序返回。
+// fn 内部自行处理 (记录并跳过) 单个分片的错误；ctx 被取消后不再启动新的任务，
+// 等待已启动的任务结束后返回 ctx.Err()。
+func fanOut[T, R any](ctx context.Context, limit int, items []T, fn func(ctx context.Context, item T) R) ([]R, error) {
+	results := make([]R, len(items))
+	if len(items) == 0 {
+		return results, ctx.Err()
+	}
+	if limit <= 0 || limit > len(items) {
+		limit = len(items)
+	}
+
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+
+loop:
+	for i, item := range items {
+		select {
+		case <-ctx.Done():
+			break loop
+		case sem <- struct{}{}:
+		}
+
+		wg.Add(1)
+		go func(i int, item T) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if ctx.Err() != nil {
+				return
+			}
+			results[i] = fn(ctx, item)
+		}(i, item)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// fanOutShards 并发查询所有分片，并按分片顺序拼接各分片返回的列表
+func fanOutShards[T, R any](ctx context.Context, r *Repository, items []T, fn func(ctx context.Context, item T) []R) ([]R, error) {
+	parts, err := fanOut(ctx, r.shardConcurrency(), items, fn)
+	if err != nil {
+		return nil, err
+	}
+	var out []R
+	for _, part := range parts {
+		out = append(out, part...)
+	}
+	return out, nil
+}
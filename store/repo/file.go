@@ -0,0 +1,76 @@
+package repo
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/afumu/wetrace/internal/model"
+	"github.com/afumu/wetrace/store/types"
+)
+
+// GetFiles 列出文件消息中的附件，按时间倒序，并根据本地文件记录标记文件是否存在。
+// 扩展名和大小在补全本地信息后筛选，Total 等合计为筛选后、分页前的结果。
+func (r *Repository) GetFiles(ctx context.Context, q types.FileQuery) (*model.FileCatalog, error) {
+	var files []*model.FileAttachment
+	mq := types.MessageQuery{Talker: q.Talker, StartTime: q.StartTime, EndTime: q.EndTime}
+	err := r.iterateSessionMessages(ctx, mq, model.MessageTypeShare, func(m *model.Message) error {
+		if f := model.NewFileAttachment(m); f != nil {
+			files = append(files, f)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	md5s := make([]string, 0, len(files))
+	for _, f := range files {
+		if f.MD5 != "" {
+			md5s = append(md5s, f.MD5)
+		}
+	}
+	medias, err := r.getMediasByMD5(ctx, "file", md5s)
+	if err != nil {
+		return nil, err
+	}
+
+	exts := make(map[string]bool)
+	for _, ext := range splitList(q.Ext) {
+		exts[model.NormalizeExt(ext)] = true
+	}
+	keyword := strings.ToLower(q.Keyword)
+
+	catalog := &model.FileCatalog{Items: []*model.FileAttachment{}}
+	for _, f := range files {
+		if m, ok := medias[f.MD5]; ok {
+			f.Present = true
+			f.Path = filepath.ToSlash(m.Path)
+			if f.Size == 0 {
+				f.Size = m.Size
+			}
+		}
+		if len(exts) > 0 && !exts[f.Ext] {
+			continue
+		}
+		if keyword != "" && !strings.Contains(strings.ToLower(f.Title), keyword) {
+			continue
+		}
+		if (q.MinSize > 0 && f.Size < q.MinSize) || (q.MaxSize > 0 && f.Size > q.MaxSize) {
+			continue
+		}
+		catalog.Items = append(catalog.Items, f)
+		catalog.TotalSize += f.Size
+		if f.Present {
+			catalog.Present++
+		}
+	}
+	sort.SliceStable(catalog.Items, func(i, j int) bool { return catalog.Items[i].Time.After(catalog.Items[j].Time) })
+
+	catalog.Total = len(catalog.Items)
+	if catalog.Items = paginate(catalog.Items, q.Limit, q.Offset); catalog.Items == nil {
+		catalog.Items = []*model.FileAttachment{}
+	}
+	return catalog, nil
+}
@@ -0,0 +1,36 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/afumu/wetrace/internal/model"
+)
+
+// GetForwardRecord 展开会话中一条合并转发消息，返回逐条的聊天记录。
+// 图片、视频和文件附件按 MD5 查找本地媒体，找到的填入 Media；
+// 消息不存在或不是合并转发消息时返回 nil。
+func (r *Repository) GetForwardRecord(ctx context.Context, talker string, seq int64) (*model.ForwardRecord, error) {
+	msg, err := r.messageAt(ctx, talker, seq)
+	if err != nil || msg == nil {
+		return nil, err
+	}
+	record := model.ForwardRecordOf(msg)
+	if record == nil {
+		return nil, nil
+	}
+	r.resolveForwardMedia(ctx, record)
+	return record, nil
+}
+
+// resolveForwardMedia 为记录中的附件查找本地媒体，找不到的附件保持 Media 为空
+func (r *Repository) resolveForwardMedia(ctx context.Context, record *model.ForwardRecord) {
+	record.Walk(func(item *model.ForwardItem) {
+		mediaType := item.MediaType()
+		if mediaType == "" {
+			return
+		}
+		if media, err := r.GetMedia(ctx, mediaType, item.MD5); err == nil {
+			item.Media = media
+		}
+	})
+}
@@ -0,0 +1,485 @@
+package repo
+
+import (
+	"context"
+	"crypto/md5"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/afumu/wetrace/internal/model"
+	"github.com/afumu/wetrace/store/fts"
+	"github.com/afumu/wetrace/store/types"
+	"github.com/rs/zerolog/log"
+)
+
+// ftsBatchSize 每次从源表读取并写入索引的行数
+const ftsBatchSize = 2000
+
+// ftsIndexedTypes 参与全文索引的消息类型：文本、位置、分享（含引用、转账等）、系统消息
+const ftsIndexedTypes = "1, 48, 49, 10000"
+
+// SetFTSIndex 为仓储挂载全文索引，传入 nil 则退回逐分片 LIKE 扫描
+func (r *Repository) SetFTSIndex(idx *fts.Index) {
+	r.fts = idx
+}
+
+// SyncFTSIndex 将所有分片中尚未索引的消息增量写入全文索引。
+// 每张源表按 rowid 记录水位线，只读取水位线之后的新行；
+// 如果源表的最大 rowid 小于水位线 (数据库被重新解密覆盖)，则清空该表的索引后重建。
+func (r *Repository) SyncFTSIndex(ctx context.Context) error {
+	if r.fts == nil {
+		return nil
+	}
+	r.ftsMu.Lock()
+	defer r.ftsMu.Unlock()
+
+	var talkerMD5Map map[string]string
+	version := r.version()
+
+	for _, shard := range r.router.GetShards() {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		db, err := r.pool.GetConnection(shard.FilePath)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(r.router.GetBaseDir(), shard.FilePath)
+		if err != nil {
+			rel = shard.FilePath
+		}
+
+		if version == model.WeChatV3 {
+			if err := r.syncFTSTable(ctx, db, rel, "MSG", ""); err != nil {
+				log.Warn().Err(err).Str("db", shard.FilePath).Msg("索引 V3 消息表失败")
+			}
+			continue
+		}
+
+		if talkerMD5Map == nil {
+			talkerMD5Map = r.getTalkerMD5Map(ctx)
+		}
+		r.mergeShardTalkers(ctx, db, talkerMD5Map)
+
+		// V4 为 Msg_<md5>，macOS 为 Chat_<md5>
+		prefix := "Msg_"
+		if version == model.WeChatDarwinV3 {
+			prefix = "Chat_"
+		}
+		tables, err := r.listMessageTables(ctx, db, prefix)
+		if err != nil {
+			log.Warn().Err(err).Str("db", shard.FilePath).Msg("读取消息表列表失败")
+			continue
+		}
+		for _, table := range tables {
+			talker, ok := talkerMD5Map[strings.TrimPrefix(table, prefix)]
+			if !ok {
+				continue
+			}
+			if err := r.syncFTSTable(ctx, db, rel, table, talker); err != nil {
+				log.Warn().Err(err).Str("db", shard.FilePath).Str("table", table).Msg("索引消息表失败")
+			}
+		}
+	}
+
+	return r.fts.MarkReady(ctx)
+}
+
+// syncFTSTable 增量索引单张消息表
+func (r *Repository) syncFTSTable(ctx context.Context, db *sql.DB, shard, table, talker string) error {
+	watermark, err := r.fts.Watermark(ctx, shard, table)
+	if err != nil {
+		return err
+	}
+
+	var maxRowID int64
+	if err := db.QueryRowContext(ctx, fmt.Sprintf("SELECT IFNULL(MAX(rowid), 0) FROM %s", table)).Scan(&maxRowID); err != nil {
+		return err
+	}
+	if maxRowID < watermark {
+		if err := r.fts.Purge(ctx, shard, table); err != nil {
+			return err
+		}
+		watermark = 0
+	}
+
+	for watermark < maxRowID {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		var docs []fts.Doc
+		var last int64
+		if table == "MSG" {
+			docs, last, err = r.readV3FTSDocs(ctx, db, watermark, maxRowID)
+		} else if strings.HasPrefix(table, "Chat_") {
+			docs, last, err = r.readDarwinFTSDocs(ctx, db, table, talker, watermark, maxRowID)
+		} else {
+			docs, last, err = r.readV4FTSDocs(ctx, db, table, talker, watermark, maxRowID)
+		}
+		if err != nil {
+			return err
+		}
+		if last == 0 {
+			// 剩余的行都不是可索引类型，直接推进到末尾
+			last = maxRowID
+		}
+		if err := r.fts.Write(ctx, shard, table, docs, last); err != nil {
+			return err
+		}
+		watermark = last
+	}
+	return nil
+}
+
+func (r *Repository) readV3FTSDocs(ctx context.Context, db *sql.DB, after, upTo int64) ([]fts.Doc, int64, error) {
+	query := fmt.Sprintf(`
+		SELECT rowid, MsgSvrID, Sequence, CreateTime, StrTalker, IsSender, Type, SubType, StrContent, CompressContent, BytesExtra
+		FROM MSG
+		WHERE rowid > ? AND rowid <= ? AND Type IN (%s)
+		ORDER BY rowid ASC LIMIT %d`, ftsIndexedTypes, ftsBatchSize)
+
+	rows, err := db.QueryContext(ctx, query, after, upTo)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var docs []fts.Doc
+	var last int64
+	for rows.Next() {
+		var rowid int64
+		var msg model.MessageV3
+		if err := rows.Scan(&rowid, &msg.MsgSvrID, &msg.Sequence, &msg.CreateTime, &msg.StrTalker, &msg.IsSender, &msg.Type, &msg.SubType, &msg.StrContent, &msg.CompressContent, &msg.BytesExtra); err != nil {
+			return nil, 0, err
+		}
+		last = rowid
+		docs = append(docs, ftsDoc(rowid, msg.Wrap()))
+	}
+	return docs, last, rows.Err()
+}
+
+func (r *Repository) readV4FTSDocs(ctx context.Context, db *sql.DB, table, talker string, after, upTo int64) ([]fts.Doc, int64, error) {
+	query := fmt.Sprintf(`
+		SELECT m.rowid, m.sort_seq, m.server_id, m.local_type, n.user_name, m.create_time, m.message_content, m.packed_info_data, m.status
+		FROM %s m
+		LEFT JOIN Name2Id n ON m.real_sender_id = n.rowid
+		WHERE m.rowid > ? AND m.rowid <= ? AND (m.local_type & 4294967295) IN (%s)
+		ORDER BY m.rowid ASC LIMIT %d`, table, ftsIndexedTypes, ftsBatchSize)
+
+	rows, err := db.QueryContext(ctx, query, after, upTo)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var docs []fts.Doc
+	var last int64
+	for rows.Next() {
+		var rowid int64
+		var userName sql.NullString
+		var msg model.MessageV4
+		if err := rows.Scan(&rowid, &msg.SortSeq, &msg.ServerID, &msg.LocalType, &userName, &msg.CreateTime, &msg.MessageContent, &msg.PackedInfoData, &msg.Status); err != nil {
+			return nil, 0, err
+		}
+		msg.UserName = userName.String
+		last = rowid
+		docs = append(docs, ftsDoc(rowid, msg.Wrap(talker)))
+	}
+	return docs, last, rows.Err()
+}
+
+func (r *Repository) readDarwinFTSDocs(ctx context.Context, db *sql.DB, table, talker string, after, upTo int64) ([]fts.Doc, int64, error) {
+	query := fmt.Sprintf(`
+		SELECT rowid, mesLocalID, msgCreateTime, IFNULL(msgContent, ''), messageType, mesDes
+		FROM %s
+		WHERE rowid > ? AND rowid <= ? AND messageType IN (%s)
+		ORDER BY rowid ASC LIMIT %d`, table, ftsIndexedTypes, ftsBatchSize)
+
+	rows, err := db.QueryContext(ctx, query, after, upTo)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var docs []fts.Doc
+	var last int64
+	for rows.Next() {
+		var rowid int64
+		var msg model.MessageDarwinV3
+		if err := rows.Scan(&rowid, &msg.MesLocalID, &msg.MsgCreateTime, &msg.MsgContent, &msg.MessageType, &msg.MesDes); err != nil {
+			return nil, 0, err
+		}
+		last = rowid
+		docs = append(docs, ftsDoc(rowid, msg.Wrap(talker)))
+	}
+	return docs, last, rows.Err()
+}
+
+// ftsDoc 从解析后的消息中提取可检索的文本
+func ftsDoc(rowid int64, m *model.Message) fts.Doc {
+	parts := make([]string, 0, 4)
+	// 分享类消息在 V3 中 Content 为原始 XML，只索引解析出的字段
+	if m.Type == model.MessageTypeText || !strings.HasPrefix(strings.TrimSpace(m.Content), "<") {
+		if m.Content != "" {
+			parts = append(parts, m.Content)
+		}
+	}
+	for _, key := range []string{"title", "desc", "label", "cityname"} {
+		if v, ok := m.Contents[key].(string); ok && v != "" {
+			parts = append(parts, v)
+		}
+	}
+
+	return fts.Doc{
+		RowID:  rowid,
+		Talker: m.Talker,
+		Sender: m.Sender,
+		Seq:    m.Seq,
+		Time:   m.Time,
+		Type:   m.Type,
+		Text:   strings.Join(parts, " "),
+	}
+}
+
+// mergeShardTalkers 用分片内 Name2Id 表补全 md5 -> 用户名 映射，
+// 以覆盖已不在会话列表中的聊天对象
+func (r *Repository) mergeShardTalkers(ctx context.Context, db *sql.DB, talkerMD5Map map[string]string) {
+	rows, err := db.QueryContext(ctx, "SELECT user_name FROM Name2Id")
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name sql.NullString
+		if err := rows.Scan(&name); err != nil || name.String == "" {
+			continue
+		}
+		h := md5.Sum([]byte(name.String))
+		key := hex.EncodeToString(h[:])
+		if _, ok := talkerMD5Map[key]; !ok {
+			talkerMD5Map[key] = name.String
+		}
+	}
+}
+
+// listMessageTables 列出分片中所有以 prefix 开头的消息表 (Msg_<md5> 或 Chat_<md5>)
+func (r *Repository) listMessageTables(ctx context.Context, db *sql.DB, prefix string) ([]string, error) {
+	rows, err := db.QueryContext(ctx, `SELECT name FROM sqlite_master WHERE type='table' AND name LIKE ? ESCAPE '\'`, strings.ReplaceAll(prefix, "_", `\_`)+"%")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		tables = append(tables, name)
+	}
+	return tables, rows.Err()
+}
+
+// searchWithFTS 使用全文索引执行搜索，再回到源分片按 rowid 取回完整消息
+func (r *Repository) searchWithFTS(ctx context.Context, q types.MessageQuery) (*model.SearchResult, error) {
+	fq := fts.Query{
+		Keyword: q.Keyword,
+		Talkers: splitList(q.Talker),
+		Senders: splitList(q.Sender),
+		MsgType: q.MsgType,
+		Start:   q.StartTime,
+		End:     q.EndTime,
+		ByRank:  q.Rank,
+		Limit:   q.Limit,
+		Offset:  q.Offset,
+	}
+	if q.Expr != nil {
+		fq.Match = fts.BuildExprMatch(q.Expr)
+		if fq.Match == "" {
+			return nil, errors.New("搜索表达式无法转换为全文检索")
+		}
+		fq.MsgTypes = q.Expr.Types
+	}
+
+	hits, total, err := r.fts.Search(ctx, fq)
+	if err != nil {
+		return nil, err
+	}
+
+	// 按 (分片, 表) 分组回查
+	type source struct{ shard, table string }
+	groups := make(map[source][]fts.Hit)
+	for _, h := range hits {
+		key := source{h.Shard, h.Table}
+		groups[key] = append(groups[key], h)
+	}
+
+	found := make(map[string]*model.Message, len(hits))
+	fetched := make(map[source]bool, len(groups))
+	for src, group := range groups {
+		path := filepath.Join(r.router.GetBaseDir(), src.shard)
+		db, err := r.pool.GetConnection(path)
+		if err != nil {
+			log.Warn().Err(err).Str("db", path).Msg("回查搜索结果失败")
+			continue
+		}
+		msgs, err := r.fetchMessagesByRowID(ctx, db, src.table, group)
+		if err != nil {
+			log.Warn().Err(err).Str("db", path).Str("table", src.table).Msg("回查搜索结果失败")
+			continue
+		}
+		fetched[src] = true
+		for rowid, m := range msgs {
+			found[hitKey(src.shard, src.table, rowid)] = m
+		}
+	}
+
+	// 水位线只覆盖新增的行，索引之后被删除或改写 (如撤回) 的源行不再返回，并按当前内容重新索引
+	paged := make([]*model.Message, 0, len(hits))
+	staleRows := make(map[source][]int64)
+	staleDocs := make(map[source][]fts.Doc)
+	for _, h := range hits {
+		src := source{h.Shard, h.Table}
+		m, ok := found[hitKey(h.Shard, h.Table, h.RowID)]
+		if ok && m.Seq == h.Seq && ftsIndexedType(m.Type) && (h.Digest == "" || fts.Digest(ftsDoc(h.RowID, m).Text) == h.Digest) {
+			paged = append(paged, m)
+			continue
+		}
+		if !fetched[src] {
+			continue
+		}
+		total--
+		staleRows[src] = append(staleRows[src], h.RowID)
+		if ok && ftsIndexedType(m.Type) {
+			staleDocs[src] = append(staleDocs[src], ftsDoc(h.RowID, m))
+		}
+	}
+	// 同步任务正在写入索引时跳过，之后的检索会再次发现这些行
+	if len(staleRows) > 0 && r.ftsMu.TryLock() {
+		for src, rowids := range staleRows {
+			if err := r.fts.Replace(ctx, src.shard, src.table, rowids, staleDocs[src]); err != nil {
+				log.Warn().Err(err).Str("table", src.table).Msg("重新索引已修改的消息失败")
+			}
+		}
+		r.ftsMu.Unlock()
+	}
+	if len(paged) > 0 {
+		r.enrichMessages(ctx, paged)
+	}
+
+	items := make([]*model.SearchItem, 0, len(paged))
+	for _, msg := range paged {
+		items = append(items, &model.SearchItem{Message: msg})
+	}
+
+	return &model.SearchResult{
+		Total: total,
+		Items: items,
+	}, nil
+}
+
+// fetchMessagesByRowID 按 rowid 批量读取源表中的消息
+func (r *Repository) fetchMessagesByRowID(ctx context.Context, db *sql.DB, table string, hits []fts.Hit) (map[int64]*model.Message, error) {
+	args := make([]interface{}, len(hits))
+	for i, h := range hits {
+		args[i] = h.RowID
+	}
+	in := strings.TrimSuffix(strings.Repeat("?,", len(hits)), ",")
+
+	result := make(map[int64]*model.Message, len(hits))
+
+	if table == "MSG" {
+		query := fmt.Sprintf("SELECT rowid, MsgSvrID, Sequence, CreateTime, StrTalker, IsSender, Type, SubType, StrContent, CompressContent, BytesExtra FROM MSG WHERE rowid IN (%s)", in)
+		rows, err := db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var rowid int64
+			var msg model.MessageV3
+			if err := rows.Scan(&rowid, &msg.MsgSvrID, &msg.Sequence, &msg.CreateTime, &msg.StrTalker, &msg.IsSender, &msg.Type, &msg.SubType, &msg.StrContent, &msg.CompressContent, &msg.BytesExtra); err != nil {
+				return nil, err
+			}
+			result[rowid] = msg.Wrap()
+		}
+		return result, rows.Err()
+	}
+
+	talker := hits[0].Talker
+
+	if strings.HasPrefix(table, "Chat_") {
+		query := fmt.Sprintf("SELECT rowid, mesLocalID, msgCreateTime, IFNULL(msgContent, ''), messageType, mesDes FROM %s WHERE rowid IN (%s)", table, in)
+		rows, err := db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var rowid int64
+			var msg model.MessageDarwinV3
+			if err := rows.Scan(&rowid, &msg.MesLocalID, &msg.MsgCreateTime, &msg.MsgContent, &msg.MessageType, &msg.MesDes); err != nil {
+				return nil, err
+			}
+			result[rowid] = msg.Wrap(talker)
+		}
+		return result, rows.Err()
+	}
+
+	query := fmt.Sprintf(`
+		SELECT m.rowid, m.sort_seq, m.server_id, m.local_type, n.user_name, m.create_time, m.message_content, m.packed_info_data, m.status
+		FROM %s m
+		LEFT JOIN Name2Id n ON m.real_sender_id = n.rowid
+		WHERE m.rowid IN (%s)`, table, in)
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var rowid int64
+		var userName sql.NullString
+		var msg model.MessageV4
+		if err := rows.Scan(&rowid, &msg.SortSeq, &msg.ServerID, &msg.LocalType, &userName, &msg.CreateTime, &msg.MessageContent, &msg.PackedInfoData, &msg.Status); err != nil {
+			return nil, err
+		}
+		msg.UserName = userName.String
+		result[rowid] = msg.Wrap(talker)
+	}
+	return result, rows.Err()
+}
+
+// ftsIndexedType 判断消息类型是否参与全文索引，与 ftsIndexedTypes 保持一致
+func ftsIndexedType(t int64) bool {
+	switch t {
+	case model.MessageTypeText, model.MessageTypeLocation, model.MessageTypeShare, model.MessageTypeSystem:
+		return true
+	}
+	return false
+}
+
+func hitKey(shard, table string, rowid int64) string {
+	return fmt.Sprintf("%s|%s|%d", shard, table, rowid)
+}
+
+// splitList 将逗号分隔的参数拆分为去除空白后的列表
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
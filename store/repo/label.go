@@ -0,0 +1,96 @@
+package repo
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/afumu/wetrace/internal/model"
+	"github.com/afumu/wetrace/store/types"
+)
+
+// GetLabels 获取联系人标签定义，数据库中没有标签表时返回空列表
+func (r *Repository) GetLabels(ctx context.Context) ([]*model.Label, error) {
+	dbPath, err := r.router.GetContactDBPath()
+	if err != nil {
+		return nil, err
+	}
+	db, err := r.pool.GetConnection(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var table, query string
+	switch r.version() {
+	case model.WeChatDarwinV3:
+		// macOS 的标签保存在 _packed_WCContactData 中，暂不支持
+		return nil, nil
+	case model.WeChatV3:
+		table = "ContactLabel"
+		query = `SELECT LabelId, IFNULL(LabelName,'') FROM ContactLabel ORDER BY LabelId`
+	default:
+		table = "contact_label"
+		query = `SELECT label_id_, IFNULL(label_name_,'') FROM contact_label ORDER BY sort_order_, label_id_`
+	}
+	if !r.isTableExist(db, table) {
+		return nil, nil
+	}
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var labels []*model.Label
+	for rows.Next() {
+		var l model.Label
+		if err := rows.Scan(&l.ID, &l.Name); err != nil {
+			return nil, err
+		}
+		labels = append(labels, &l)
+	}
+	return labels, rows.Err()
+}
+
+// resolveLabel 按 ID 或名称查找标签，找不到时返回 nil
+func (r *Repository) resolveLabel(ctx context.Context, label string) (*model.Label, error) {
+	labels, err := r.GetLabels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	id, idErr := strconv.Atoi(label)
+	for _, l := range labels {
+		if l.Name == label || (idErr == nil && l.ID == id) {
+			return l, nil
+		}
+	}
+	return nil, nil
+}
+
+// labelMembers 返回带有指定标签的联系人账号集合
+func (r *Repository) labelMembers(ctx context.Context, label string) (map[string]bool, error) {
+	contacts, err := r.GetContacts(ctx, types.ContactQuery{Label: label})
+	if err != nil {
+		return nil, err
+	}
+	members := make(map[string]bool, len(contacts))
+	for _, c := range contacts {
+		members[c.UserName] = true
+	}
+	return members, nil
+}
+
+// filterSessionsByLabel 只保留与带有指定标签的联系人的会话
+func (r *Repository) filterSessionsByLabel(ctx context.Context, sessions []*model.Session, label string) ([]*model.Session, error) {
+	members, err := r.labelMembers(ctx, label)
+	if err != nil {
+		return nil, err
+	}
+	matched := make([]*model.Session, 0, len(members))
+	for _, s := range sessions {
+		if members[s.UserName] {
+			matched = append(matched, s)
+		}
+	}
+	return matched, nil
+}
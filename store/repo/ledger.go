@@ -0,0 +1,107 @@
+package repo
+
+import (
+	"context"
+	"sort"
+
+	"github.com/afumu/wetrace/internal/model"
+	"github.com/afumu/wetrace/store/types"
+)
+
+// GetLedger 提取转账和红包消息，汇总为账目并按联系人、按月合计。
+// q.Talker 为空时统计全部会话；只使用 Talker、StartTime 和 EndTime。
+func (r *Repository) GetLedger(ctx context.Context, q types.MessageQuery) (*model.Ledger, error) {
+	var entries []*model.LedgerEntry
+	// 转账和红包都是分享类消息
+	err := r.iterateSessionMessages(ctx, q, model.MessageTypeShare, func(m *model.Message) error {
+		if e := model.NewLedgerEntry(m); e != nil {
+			entries = append(entries, e)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time.Before(entries[j].Time) })
+
+	if err := r.fillCounterpartNames(ctx, entries); err != nil {
+		return nil, err
+	}
+	return buildLedger(entries), nil
+}
+
+// fillCounterpartNames 为群聊中自己发起的转账等没有名称的对方账号补充名称
+func (r *Repository) fillCounterpartNames(ctx context.Context, entries []*model.LedgerEntry) error {
+	var usernames []string
+	for _, e := range entries {
+		if e.CounterpartName == "" && e.Counterpart != "" {
+			usernames = append(usernames, e.Counterpart)
+		}
+	}
+	profiles, err := r.getContactProfiles(ctx, usernames)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.CounterpartName != "" {
+			continue
+		}
+		if p, ok := profiles[e.Counterpart]; ok {
+			e.CounterpartName = p.Remark
+			if e.CounterpartName == "" {
+				e.CounterpartName = p.NickName
+			}
+		}
+		if e.CounterpartName == "" {
+			e.CounterpartName = e.Counterpart
+		}
+	}
+	return nil
+}
+
+// buildLedger 计算总计、按联系人和按月的合计。
+// 联系人按往来金额从大到小排序，月份按时间排序。
+func buildLedger(entries []*model.LedgerEntry) *model.Ledger {
+	ledger := &model.Ledger{
+		Entries:   entries,
+		Total:     &model.LedgerTotal{Key: "total"},
+		ByContact: []*model.LedgerTotal{},
+		ByMonth:   []*model.LedgerTotal{},
+	}
+	if ledger.Entries == nil {
+		ledger.Entries = []*model.LedgerEntry{}
+	}
+
+	byContact := make(map[string]*model.LedgerTotal)
+	byMonth := make(map[string]*model.LedgerTotal)
+	for _, e := range entries {
+		if !e.Counted() {
+			continue
+		}
+		ledger.Total.Add(e)
+
+		c, ok := byContact[e.Counterpart]
+		if !ok {
+			c = &model.LedgerTotal{Key: e.Counterpart, Name: e.CounterpartName}
+			byContact[e.Counterpart] = c
+			ledger.ByContact = append(ledger.ByContact, c)
+		}
+		c.Add(e)
+
+		month := e.Time.Format("2006-01")
+		m, ok := byMonth[month]
+		if !ok {
+			m = &model.LedgerTotal{Key: month}
+			byMonth[month] = m
+			ledger.ByMonth = append(ledger.ByMonth, m)
+		}
+		m.Add(e)
+	}
+
+	sort.SliceStable(ledger.ByContact, func(i, j int) bool {
+		a, b := ledger.ByContact[i], ledger.ByContact[j]
+		return a.In+a.Out > b.In+b.Out
+	})
+	sort.Slice(ledger.ByMonth, func(i, j int) bool { return ledger.ByMonth[i].Key < ledger.ByMonth[j].Key })
+	return ledger
+}
@@ -0,0 +1,88 @@
+package repo
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/afumu/wetrace/internal/model"
+	"github.com/afumu/wetrace/store/types"
+)
+
+// defaultTopDomains 是默认返回的热门域名数量
+const defaultTopDomains = 20
+
+// GetLinks 汇总会话中分享的链接，按规范化后的地址去重，按最近分享时间倒序排列。
+// 热门域名按筛选后的分享次数统计。
+func (r *Repository) GetLinks(ctx context.Context, q types.LinkQuery) (*model.LinkLibrary, error) {
+	byKey := make(map[string]*model.SharedLink)
+	var links []*model.SharedLink
+	mq := types.MessageQuery{Talker: q.Talker, StartTime: q.StartTime, EndTime: q.EndTime}
+	err := r.iterateSessionMessages(ctx, mq, model.MessageTypeShare, func(m *model.Message) error {
+		l := model.NewSharedLink(m)
+		if l == nil || !matchLink(l, q) {
+			return nil
+		}
+		if exist, ok := byKey[l.Key]; ok {
+			exist.Merge(l)
+			return nil
+		}
+		byKey[l.Key] = l
+		links = append(links, l)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, l := range links {
+		sort.SliceStable(l.Shares, func(i, j int) bool { return l.Shares[i].Time.Before(l.Shares[j].Time) })
+	}
+	sort.SliceStable(links, func(i, j int) bool { return links[i].LastTime.After(links[j].LastTime) })
+	if links == nil {
+		links = []*model.SharedLink{}
+	}
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultTopDomains
+	}
+	return &model.LinkLibrary{Links: links, TopDomains: topDomains(links, limit)}, nil
+}
+
+// matchLink 判断链接是否满足域名、来源和种类筛选
+func matchLink(l *model.SharedLink, q types.LinkQuery) bool {
+	if !l.MatchDomain(q.Domain) {
+		return false
+	}
+	if q.Source != "" && !strings.Contains(strings.ToLower(l.Source), strings.ToLower(q.Source)) {
+		return false
+	}
+	return q.Kind == "" || l.Kind == q.Kind
+}
+
+// topDomains 按分享次数从多到少返回前 limit 个域名，次数相同时按域名排序
+func topDomains(links []*model.SharedLink, limit int) []*model.DomainCount {
+	byDomain := make(map[string]*model.DomainCount)
+	domains := []*model.DomainCount{}
+	for _, l := range links {
+		d, ok := byDomain[l.Domain]
+		if !ok {
+			d = &model.DomainCount{Domain: l.Domain}
+			byDomain[l.Domain] = d
+			domains = append(domains, d)
+		}
+		d.Count += l.Count
+		d.Links++
+	}
+	sort.Slice(domains, func(i, j int) bool {
+		if domains[i].Count != domains[j].Count {
+			return domains[i].Count > domains[j].Count
+		}
+		return domains[i].Domain < domains[j].Domain
+	})
+	if len(domains) > limit {
+		domains = domains[:limit]
+	}
+	return domains
+}
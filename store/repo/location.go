@@ -0,0 +1,26 @@
+package repo
+
+import (
+	"context"
+	"sort"
+
+	"github.com/afumu/wetrace/internal/model"
+	"github.com/afumu/wetrace/store/types"
+)
+
+// GetLocations 返回位置消息中分享的全部位置，按时间排序。
+// q.Talker 为空时汇总全部会话；只使用 Talker、StartTime 和 EndTime。
+func (r *Repository) GetLocations(ctx context.Context, q types.MessageQuery) ([]*model.LocationPoint, error) {
+	points := []*model.LocationPoint{}
+	err := r.iterateSessionMessages(ctx, q, model.MessageTypeLocation, func(m *model.Message) error {
+		if p := model.NewLocationPoint(m); p != nil {
+			points = append(points, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(points, func(i, j int) bool { return points[i].Time.Before(points[j].Time) })
+	return points, nil
+}
@@ -38,32 +38,141 @@ func (r *Repository) GetMedia(ctx context.Context, mediaType string, key string)
 		return nil, err
 	}
 
-	// 1. 尝试 V4 模式
-	var table string
+	switch r.version() {
+	case model.WeChatDarwinV3:
+		return r.queryDarwinMedia(ctx, db, mediaType, key)
+	case model.WeChatV3:
+		return r.queryV3Media(ctx, db, mediaType, key)
+	}
+
+	table := r.v4HardlinkTable(db, mediaType)
+	if table == "" {
+		return nil, fmt.Errorf("media not found")
+	}
+	return r.queryV4Media(ctx, db, table, mediaType, key)
+}
+
+// v4HardlinkTable 返回 V4 媒体类型对应的 hardlink 表名，表名带有 _v4 / _v3 后缀，取存在的一个；都不存在时返回空
+func (r *Repository) v4HardlinkTable(db *sql.DB, mediaType string) string {
+	var prefix string
 	switch mediaType {
 	case "image", "image_merge":
-		table = "image_hardlink_info_v4"
-		if !r.isTableExist(db, table) {
-			table = "image_hardlink_info_v3"
-		}
+		prefix = "image_hardlink_info"
 	case "video":
-		table = "video_hardlink_info_v4"
-		if !r.isTableExist(db, table) {
-			table = "video_hardlink_info_v3"
-		}
+		prefix = "video_hardlink_info"
 	case "file":
-		table = "file_hardlink_info_v4"
-		if !r.isTableExist(db, table) {
-			table = "file_hardlink_info_v3"
+		prefix = "file_hardlink_info"
+	default:
+		return ""
+	}
+	for _, table := range []string{prefix + "_v4", prefix + "_v3"} {
+		if r.isTableExist(db, table) {
+			return table
 		}
 	}
+	return ""
+}
+
+// getMediasByMD5 按 md5 批量查找视频或文件的本地记录，返回小写 md5 到媒体信息的映射。
+// V4 直接批量查询 hardlink 表，其他版本逐个查询；没有媒体数据库时返回空映射。
+func (r *Repository) getMediasByMD5(ctx context.Context, mediaType string, md5s []string) (map[string]*model.Media, error) {
+	medias := make(map[string]*model.Media)
+	if len(md5s) == 0 {
+		return medias, nil
+	}
 
-	if table != "" && (r.isTableExist(db, table)) {
-		return r.queryV4Media(ctx, db, table, mediaType, key)
+	if v := r.version(); v == model.WeChatV3 || v == model.WeChatDarwinV3 {
+		for _, key := range md5s {
+			if _, ok := medias[key]; ok {
+				continue
+			}
+			if m, err := r.GetMedia(ctx, mediaType, key); err == nil {
+				medias[key] = m
+			}
+		}
+		return medias, nil
+	}
+
+	dbPath, err := r.router.GetMediaDBPath(strategy.Image)
+	if err != nil {
+		return medias, nil
+	}
+	db, err := r.pool.GetConnection(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	table := r.v4HardlinkTable(db, mediaType)
+	if table == "" {
+		return medias, nil
 	}
 
-	// 2. 尝试 V3 模式
-	return r.queryV3Media(ctx, db, mediaType, key)
+	const batch = 500
+	for i := 0; i < len(md5s); i += batch {
+		chunk := md5s[i:min(i+batch, len(md5s))]
+		if err := r.queryV4MediasByMD5(ctx, db, table, mediaType, chunk, medias); err != nil {
+			return nil, err
+		}
+	}
+	return medias, nil
+}
+
+// queryV4MediasByMD5 批量查询 hardlink 表，与 queryV4Media 一样同时按 md5 和以 md5 开头的文件名匹配。
+// 视频目录中的缩略图 (<md5>_thumb.jpg) 也有记录，跳过。
+func (r *Repository) queryV4MediasByMD5(ctx context.Context, db *sql.DB, table, mediaType string, md5s []string, medias map[string]*model.Media) error {
+	args := make([]interface{}, 0, len(md5s)*2)
+	for _, key := range md5s {
+		args = append(args, key)
+	}
+	for _, key := range md5s {
+		args = append(args, key)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(md5s)), ",")
+	query := fmt.Sprintf(`
+	SELECT 
+		f.md5,
+		f.file_name,
+		f.file_size,
+		f.modify_time,
+		f.extra_buffer,
+		IFNULL(d1.username,""),
+		IFNULL(d2.username,"")
+	FROM 
+		%s f
+	LEFT JOIN 
+		dir2id d1 ON d1.rowid = f.dir1
+	LEFT JOIN 
+		dir2id d2 ON d2.rowid = f.dir2
+	WHERE f.md5 IN (%s) OR substr(f.file_name, 1, 32) IN (%s)`, table, placeholders, placeholders)
+
+	wanted := make(map[string]bool, len(md5s))
+	for _, key := range md5s {
+		wanted[key] = true
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var m model.MediaV4
+		if err := rows.Scan(&m.Key, &m.Name, &m.Size, &m.ModifyTime, &m.ExtraBuffer, &m.Dir1, &m.Dir2); err != nil {
+			return err
+		}
+		if strings.Contains(m.Name, "_thumb") {
+			continue
+		}
+		m.Type = mediaType
+		media := m.Wrap()
+		key := strings.ToLower(m.Key)
+		if !wanted[key] && len(m.Name) >= 32 {
+			key = strings.ToLower(m.Name[:32])
+		}
+		if _, ok := medias[key]; !ok {
+			medias[key] = media
+		}
+	}
+	return rows.Err()
 }
 
 func (r *Repository) isTableExist(db *sql.DB, table string) bool {
@@ -72,6 +181,13 @@ func (r *Repository) isTableExist(db *sql.DB, table string) bool {
 	return err == nil
 }
 
+// isColumnExist 判断表中是否有指定的列
+func (r *Repository) isColumnExist(db *sql.DB, table, column string) bool {
+	var n int
+	err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", table, column).Scan(&n)
+	return err == nil && n > 0
+}
+
 func (r *Repository) queryV4Media(ctx context.Context, db *sql.DB, table, mediaType, key string) (*model.Media, error) {
 	query := fmt.Sprintf(`
 	SELECT 
@@ -167,6 +283,34 @@ func (r *Repository) queryV3Media(ctx context.Context, db *sql.DB, mediaType, ke
 	return m.Wrap(), nil
 }
 
+func (r *Repository) queryDarwinMedia(ctx context.Context, db *sql.DB, mediaType, key string) (*model.Media, error) {
+	query := `
+	SELECT 
+		r.mediaMd5,
+		r.mediaSize,
+		r.inodeNumber,
+		r.modifyTime,
+		IFNULL(d.relativePath,""),
+		IFNULL(d.fileName,"")
+	FROM 
+		HlinkMediaRecord r
+	JOIN 
+		HlinkMediaDetail d ON r.inodeNumber = d.inodeNumber
+	WHERE 
+		r.mediaMd5 = ?
+	`
+
+	var m model.MediaDarwinV3
+	err := db.QueryRowContext(ctx, query, key).Scan(&m.MediaMd5, &m.MediaSize, &m.InodeNumber, &m.ModifyTime, &m.RelativePath, &m.FileName)
+	if err != nil {
+		return nil, err
+	}
+
+	media := m.Wrap()
+	media.Type = mediaType
+	return media, nil
+}
+
 func (r *Repository) getVoice(ctx context.Context, key string) (*model.Media, error) {
 	// 获取所有 Voice 类型的数据库路径
 	dbPaths, err := r.router.GetAllDBPaths(strategy.Voice)
@@ -0,0 +1,86 @@
+package repo
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/afumu/wetrace/internal/model"
+	"github.com/afumu/wetrace/store/types"
+)
+
+// spreadMessageTypes 是各媒体种类对应的消息类型，文件是分享类消息
+var spreadMessageTypes = map[string]int{
+	model.SpreadKindImage: model.MessageTypeImage,
+	model.SpreadKindVideo: model.MessageTypeVideo,
+	model.SpreadKindFile:  model.MessageTypeShare,
+}
+
+// GetMediaSpread 在全部会话中按 md5 归并图片、视频和文件消息，分析每个媒体的传播：
+// 最早的发送者、按时间排序的传播链和到达的会话。
+// 结果按到达的会话数、消息数从多到少排序，Total 为分页前的数量。
+func (r *Repository) GetMediaSpread(ctx context.Context, q types.MediaSpreadQuery) (*model.MediaSpreadReport, error) {
+	kinds := []string{model.SpreadKindImage, model.SpreadKindVideo, model.SpreadKindFile}
+	if q.Kind != "" {
+		if _, ok := spreadMessageTypes[q.Kind]; !ok {
+			return &model.MediaSpreadReport{Items: []*model.MediaSpread{}}, nil
+		}
+		kinds = []string{q.Kind}
+	}
+	target := strings.ToLower(strings.TrimSpace(q.MD5))
+
+	byKey := make(map[string]*model.MediaSpread)
+	var spreads []*model.MediaSpread
+	mq := types.MessageQuery{StartTime: q.StartTime, EndTime: q.EndTime}
+	for _, kind := range kinds {
+		err := r.iterateSessionMessages(ctx, mq, spreadMessageTypes[kind], func(m *model.Message) error {
+			k, md5, title, ok := model.MediaKeyOf(m)
+			if !ok || k != kind || (target != "" && md5 != target) {
+				return nil
+			}
+			key := kind + ":" + md5
+			s, ok := byKey[key]
+			if !ok {
+				s = &model.MediaSpread{MD5: md5, Kind: kind}
+				byKey[key] = s
+				spreads = append(spreads, s)
+			}
+			if s.Title == "" {
+				s.Title = title
+			}
+			s.Chain = append(s.Chain, model.NewSpreadHop(m))
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	minSessions := q.MinSessions
+	if minSessions <= 0 && target == "" {
+		minSessions = 2
+	}
+	items := []*model.MediaSpread{}
+	for _, s := range spreads {
+		s.Finish()
+		if s.SessionCount >= minSessions {
+			items = append(items, s)
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := items[i], items[j]
+		if a.SessionCount != b.SessionCount {
+			return a.SessionCount > b.SessionCount
+		}
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.FirstTime.Before(b.FirstTime)
+	})
+
+	report := &model.MediaSpreadReport{Total: len(items)}
+	if report.Items = paginate(items, q.Limit, q.Offset); report.Items == nil {
+		report.Items = []*model.MediaSpread{}
+	}
+	return report, nil
+}
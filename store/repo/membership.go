@@ -0,0 +1,117 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/afumu/wetrace/internal/model"
+	"github.com/afumu/wetrace/store/types"
+)
+
+// GetMembershipHistory 解析群聊中的全部系统消息 (纯文本与 XML)，按时间顺序返回成员变动事件
+func (r *Repository) GetMembershipHistory(ctx context.Context, chatroom string) ([]*model.MembershipEvent, error) {
+	self := r.getCurrentUserWxid(ctx)
+
+	parsed := make(map[*model.Message]*model.MembershipEvent)
+	var events []*model.MembershipEvent
+	err := r.iterateSystemMessages(ctx, types.MessageQuery{
+		StartTime: time.Unix(0, 0),
+		EndTime:   time.Now(),
+		Talker:    chatroom,
+	}, func(m *model.Message) bool {
+		if e := m.MembershipEvent(); e != nil {
+			parsed[m] = e
+			return true
+		}
+		return false
+	}, func(m *model.Message) {
+		e := parsed[m]
+		e.Seq, e.Time = m.Seq, m.Time
+		// 系统消息中的 "你" 替换为当前账号
+		if self != "" {
+			if e.Actor != nil && e.Actor.NickName == model.SelfName && e.Actor.UserName == "" {
+				e.Actor.UserName = self
+			}
+			for i := range e.Targets {
+				if e.Targets[i].NickName == model.SelfName && e.Targets[i].UserName == "" {
+					e.Targets[i].UserName = self
+				}
+			}
+		}
+		events = append(events, e)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// GetRosterAt 以当前群成员为基础，倒序撤销 at 之后的成员变动，得到 at 时刻的群成员。
+// events 为 GetMembershipHistory 返回的成员变动，由调用方传入以免重复扫描系统消息。
+// 纯文本系统消息中只有昵称，按群昵称、微信昵称或备注与当前成员对应；
+// 已离开且无法对应到账号的成员只返回昵称。
+func (r *Repository) GetRosterAt(ctx context.Context, chatroom string, at time.Time, events []*model.MembershipEvent) ([]*model.RosterMember, error) {
+	rooms, err := r.GetChatRooms(ctx, types.ChatRoomQuery{Keyword: chatroom, Limit: 1})
+	if err != nil {
+		return nil, err
+	}
+
+	var roster []*model.RosterMember
+	if len(rooms) > 0 {
+		for _, u := range rooms[0].Users {
+			roster = append(roster, &model.RosterMember{UserName: u.UserName, DisplayName: u.DisplayName})
+		}
+	}
+
+	usernames := make([]string, 0, len(roster))
+	for _, m := range roster {
+		usernames = append(usernames, m.UserName)
+	}
+	profiles, err := r.getContactProfiles(ctx, usernames)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range roster {
+		if m.DisplayName == "" {
+			if p, ok := profiles[m.UserName]; ok {
+				m.DisplayName = p.NickName
+			}
+		}
+	}
+
+	// find 返回与成员引用对应的下标，找不到时返回 -1
+	find := func(ref model.MemberRef) int {
+		if ref.UserName == "" && ref.NickName == "" {
+			return -1
+		}
+		for i, m := range roster {
+			if ref.UserName != "" {
+				if m.UserName == ref.UserName {
+					return i
+				}
+				continue
+			}
+			if ref.NickName == m.DisplayName {
+				return i
+			}
+			if p, ok := profiles[m.UserName]; ok && (ref.NickName == p.NickName || ref.NickName == p.Remark) {
+				return i
+			}
+		}
+		return -1
+	}
+
+	for i := len(events) - 1; i >= 0 && events[i].Time.After(at); i-- {
+		e := events[i]
+		for _, ref := range e.Targets {
+			idx := find(ref)
+			switch {
+			case e.Adds() && idx >= 0:
+				roster = append(roster[:idx], roster[idx+1:]...)
+			case e.Removes() && idx < 0:
+				roster = append(roster, &model.RosterMember{UserName: ref.UserName, DisplayName: ref.NickName})
+			}
+		}
+	}
+	return roster, nil
+}
@@ -0,0 +1,73 @@
+package repo
+
+import (
+	"container/heap"
+
+	"github.com/afumu/wetrace/internal/model"
+)
+
+// mergeMessages 对若干已按 seq 排好序的消息列表做 k 路归并。
+// desc 为 true 时输入与输出均为 seq 降序；limit <= 0 表示不限制条数。
+func mergeMessages(lists [][]*model.Message, desc bool, limit int) []*model.Message {
+	h := &messageHeap{desc: desc}
+	total := 0
+	for _, list := range lists {
+		if len(list) > 0 {
+			h.items = append(h.items, cursorItem{list: list})
+			total += len(list)
+		}
+	}
+	heap.Init(h)
+
+	if limit <= 0 || limit > total {
+		limit = total
+	}
+
+	out := make([]*model.Message, 0, limit)
+	for h.Len() > 0 && len(out) < limit {
+		top := &h.items[0]
+		out = append(out, top.list[top.pos])
+		top.pos++
+		if top.pos == len(top.list) {
+			heap.Pop(h)
+		} else {
+			heap.Fix(h, 0)
+		}
+	}
+	return out
+}
+
+// cursorItem 记录某个列表当前读到的位置
+type cursorItem struct {
+	list []*model.Message
+	pos  int
+}
+
+// messageHeap 按各列表当前元素的 seq 排序的小顶堆 (desc 时为大顶堆)
+type messageHeap struct {
+	items []cursorItem
+	desc  bool
+}
+
+func (h *messageHeap) Len() int { return len(h.items) }
+
+func (h *messageHeap) Less(i, j int) bool {
+	a := h.items[i].list[h.items[i].pos].Seq
+	b := h.items[j].list[h.items[j].pos].Seq
+	if h.desc {
+		return a > b
+	}
+	return a < b
+}
+
+func (h *messageHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+
+func (h *messageHeap) Push(x interface{}) { h.items = append(h.items, x.(cursorItem)) }
+
+func (h *messageHeap) Pop() interface{} {
+	old := h.items
+	n := len(old)
+	item := old[n-1]
+	h.items = old[:n-1]
+	return item
+}
@@ -36,35 +36,335 @@ func (r *Repository) GetMessages(ctx context.Context, q types.MessageQuery) ([]*
 	// 4. 分页：内存切片
 	msgs := r.paginateMessages(allMessages, q.Limit, q.Offset)
 
-	// 5. 丰富：填充头像等信息
+	// 5. 丰富：填充头像，按需标记撤回状态、解析引用回复
 	if len(msgs) > 0 {
 		if err := r.enrichMessages(ctx, msgs); err != nil {
 			log.Warn().Err(err).Msg("填充消息头像失败")
 			// 降级策略：不阻断主流程
 		}
+		if q.Annotate {
+			r.annotateMessages(ctx, q, msgs)
+		}
 	}
 
 	return msgs, nil
 }
 
-// queryAllMessageShards 遍历所有分片执行查询并聚合结果
-func (r *Repository) queryAllMessageShards(ctx context.Context, targets []bind.RouteResult, q types.MessageQuery) ([]*model.Message, error) {
-	var allMessages []*model.Message
+// GetMessagePage 基于游标获取一页消息。
+// 每个分片只读取游标之后的 Limit+1 条，再做 k 路归并，
+// 因此无论翻到第几页，代价都与页大小相关而与会话总消息数无关。
+func (r *Repository) GetMessagePage(ctx context.Context, q types.MessageQuery) (*model.MessagePage, error) {
+	cursor := types.Cursor{Direction: types.CursorNext}
+	if q.Cursor != nil {
+		cursor = *q.Cursor
+	}
+	if cursor.Direction != types.CursorPrev {
+		cursor.Direction = types.CursorNext
+	}
+	desc := cursor.Direction == types.CursorPrev
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+
+	page := &model.MessagePage{Items: []*model.Message{}}
+
+	targets := r.router.Resolve(q.StartTime, q.EndTime, q.Talker)
+	if len(targets) == 0 {
+		return page, nil
+	}
+
+	// 多取一条用于判断是否还有下一页
+	need := limit + 1
+
+	lists, err := fanOut(ctx, r.shardConcurrency(), targets, func(ctx context.Context, target bind.RouteResult) []*model.Message {
+		msgs, err := r.queryShardPage(ctx, target, q, cursor, need)
+		if err != nil {
+			log.Warn().Err(err).Str("db", target.FilePath).Msg("查询数据库分片失败，跳过")
+			return nil
+		}
+		return msgs
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	msgs := mergeMessages(lists, desc, need)
+	if len(msgs) > limit {
+		page.HasMore = true
+		msgs = msgs[:limit]
+	}
+	if page.HasMore {
+		page.NextCursor = msgs[len(msgs)-1].Seq
+	}
+
+	// 向前翻页时归并结果为降序，统一转为升序返回
+	if desc {
+		for i, j := 0, len(msgs)-1; i < j; i, j = i+1, j-1 {
+			msgs[i], msgs[j] = msgs[j], msgs[i]
+		}
+	}
+
+	if len(msgs) > 0 {
+		if err := r.enrichMessages(ctx, msgs); err != nil {
+			log.Warn().Err(err).Msg("填充消息头像失败")
+		}
+		if q.Annotate {
+			r.annotateMessages(ctx, q, msgs)
+		}
+	}
+
+	page.Items = msgs
+	return page, nil
+}
+
+// annotateMessages 标记撤回状态并解析引用回复。
+// 两者都需要额外扫描会话中的消息，只在 q.Annotate 时执行，失败时不阻断主流程。
+func (r *Repository) annotateMessages(ctx context.Context, q types.MessageQuery, msgs []*model.Message) {
+	if err := r.markRevoked(ctx, q, msgs); err != nil {
+		log.Warn().Err(err).Msg("标记撤回消息失败")
+	}
+	if err := r.resolveReplies(ctx, msgs); err != nil {
+		log.Warn().Err(err).Msg("解析引用回复失败")
+	}
+}
+
+// iterateBatchSize 是 IterateMessages 每批读取的消息条数
+const iterateBatchSize = 1000
+
+// IterateMessages 按 seq 顺序逐条回调符合条件的消息。
+// 内部基于游标分页批量读取，内存占用与批大小相关而与消息总数无关；
+// q.Limit > 0 时最多回调 Limit 条，q.Reverse 为 true 时从最新消息开始倒序遍历。
+// fn 返回错误时立即停止遍历并返回该错误。
+func (r *Repository) IterateMessages(ctx context.Context, q types.MessageQuery, fn func(*model.Message) error) error {
+	direction := types.CursorNext
+	if q.Reverse {
+		direction = types.CursorPrev
+	}
+
+	remaining := q.Limit
+	pq := q
+	pq.Offset = 0
+	pq.Cursor = &types.Cursor{Direction: direction}
+
+	for {
+		pq.Limit = iterateBatchSize
+		if remaining > 0 && remaining < iterateBatchSize {
+			pq.Limit = remaining
+		}
+
+		page, err := r.GetMessagePage(ctx, pq)
+		if err != nil {
+			return err
+		}
+
+		// 向前翻页时每页内部为升序，倒序遍历需要从页尾开始
+		for i := range page.Items {
+			m := page.Items[i]
+			if direction == types.CursorPrev {
+				m = page.Items[len(page.Items)-1-i]
+			}
+			if err := fn(m); err != nil {
+				return err
+			}
+		}
+
+		if remaining > 0 {
+			remaining -= len(page.Items)
+			if remaining <= 0 {
+				return nil
+			}
+		}
+		if !page.HasMore {
+			return nil
+		}
+		pq.Cursor = &types.Cursor{Seq: page.NextCursor, Direction: direction}
+	}
+}
+
+// iterateSessionMessages 遍历 msgType 类型的消息。
+// 消息按会话分表存储，q.Talker 为空时遍历分片中所有有消息的会话，多个会话用逗号分隔；只使用 Talker、StartTime 和 EndTime。
+// 各分片并发读取，全部读完后按会话、序号顺序逐条回调 fn。
+func (r *Repository) iterateSessionMessages(ctx context.Context, q types.MessageQuery, msgType int, fn func(*model.Message) error) error {
+	var only map[string]bool
+	if q.Talker != "" {
+		only = make(map[string]bool)
+		for _, talker := range splitList(q.Talker) {
+			only[talker] = true
+		}
+	}
+
+	// 会话表名到用户名的映射在所有分片间共享，需在并发查询前准备好
+	var talkerMD5Map map[string]string
+	version := r.version()
+	if version != model.WeChatV3 {
+		talkerMD5Map = r.getTalkerMD5Map(ctx)
+	}
+
+	shards := make(map[string]*bind.DatabaseShard)
+	for _, shard := range r.router.GetShards() {
+		shards[shard.FilePath] = shard
+	}
+
+	mq := types.MessageQuery{StartTime: q.StartTime, EndTime: q.EndTime, MsgType: msgType}
+	msgs, err := fanOutShards(ctx, r, r.router.Resolve(q.StartTime, q.EndTime, ""), func(ctx context.Context, target bind.RouteResult) []*model.Message {
+		shard := shards[target.FilePath]
+		if shard == nil {
+			return nil
+		}
+		talkers, err := r.shardTalkers(ctx, shard, version, talkerMD5Map)
+		if err != nil {
+			log.Warn().Err(err).Str("db", target.FilePath).Msg("读取分片会话列表失败，跳过")
+			return nil
+		}
+
+		var out []*model.Message
+		for _, talker := range talkers {
+			if only != nil && !only[talker] {
+				continue
+			}
+			t := bind.RouteResult{FilePath: target.FilePath, TalkerID: shard.TalkerMap[talker], Talker: talker}
+			msgs, err := r.readShardMessages(ctx, t, mq)
+			if err != nil {
+				log.Warn().Err(err).Str("db", target.FilePath).Str("talker", talker).Msg("查询数据库分片失败，跳过")
+				continue
+			}
+			out = append(out, msgs...)
+		}
+		return out
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.SliceStable(msgs, func(i, j int) bool {
+		if msgs[i].Talker != msgs[j].Talker {
+			return msgs[i].Talker < msgs[j].Talker
+		}
+		return msgs[i].Seq < msgs[j].Seq
+	})
+	if len(msgs) > 0 {
+		if err := r.enrichMessages(ctx, msgs); err != nil {
+			log.Warn().Err(err).Msg("填充消息头像失败")
+		}
+	}
+	for _, m := range msgs {
+		if err := fn(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shardTalkers 列出分片中有消息的会话。V3 取分片 Name2ID 表中的用户名；
+// V4 和 macOS 按会话分表，将消息表名中的 md5 对应回用户名，会话列表中没有的再查分片内的 Name2Id 表。
+func (r *Repository) shardTalkers(ctx context.Context, shard *bind.DatabaseShard, version string, talkerMD5Map map[string]string) ([]string, error) {
+	if version == model.WeChatV3 {
+		talkers := make([]string, 0, len(shard.TalkerMap))
+		for talker := range shard.TalkerMap {
+			talkers = append(talkers, talker)
+		}
+		sort.Strings(talkers)
+		return talkers, nil
+	}
+
+	db, err := r.pool.GetConnection(shard.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	// V4 为 Msg_<md5>，macOS 为 Chat_<md5>
+	prefix := "Msg_"
+	if version == model.WeChatDarwinV3 {
+		prefix = "Chat_"
+	}
+	tables, err := r.listMessageTables(ctx, db, prefix)
+	if err != nil {
+		return nil, err
+	}
 
-	for _, target := range targets {
-		if ctx.Err() != nil {
-			return nil, ctx.Err()
+	// talkerMD5Map 被多个分片并发读取，分片内的补充映射单独存放
+	local := make(map[string]string)
+	r.mergeShardTalkers(ctx, db, local)
+
+	var talkers []string
+	for _, table := range tables {
+		key := strings.TrimPrefix(table, prefix)
+		talker, ok := talkerMD5Map[key]
+		if !ok {
+			talker, ok = local[key]
+		}
+		if ok {
+			talkers = append(talkers, talker)
 		}
+	}
+	return talkers, nil
+}
 
+// readShardMessages 按序号顺序读取单个分片中某个会话满足条件的全部消息
+func (r *Repository) readShardMessages(ctx context.Context, target bind.RouteResult, q types.MessageQuery) ([]*model.Message, error) {
+	cursor := types.Cursor{Direction: types.CursorNext}
+	var out []*model.Message
+	for {
+		msgs, err := r.queryShardPage(ctx, target, q, cursor, iterateBatchSize)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, msgs...)
+		if len(msgs) < iterateBatchSize {
+			return out, nil
+		}
+		cursor.Seq = msgs[len(msgs)-1].Seq
+	}
+}
+
+// queryShardPage 在单个分片上按游标读取最多 need 条满足条件的消息。
+// 发送者无法下推到 SQL (群聊发送者在消息内容中)，因此按批读取并在内存过滤，直到凑满或分片读完。
+func (r *Repository) queryShardPage(ctx context.Context, target bind.RouteResult, q types.MessageQuery, cursor types.Cursor, need int) ([]*model.Message, error) {
+	batch := need
+	if q.Sender != "" && batch < 200 {
+		batch = 200
+	}
+
+	var out []*model.Message
+	for len(out) < need {
+		pq := q
+		pq.Sender = ""
+		pq.Limit = batch
+		pq.Cursor = &types.Cursor{Seq: cursor.Seq, Direction: cursor.Direction}
+
+		msgs, err := r.querySingleShard(ctx, target, pq)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range msgs {
+			if q.Sender == "" || m.Sender == q.Sender {
+				out = append(out, m)
+			}
+		}
+		if len(msgs) < batch {
+			break
+		}
+		cursor.Seq = msgs[len(msgs)-1].Seq
+	}
+
+	if len(out) > need {
+		out = out[:need]
+	}
+	return out, nil
+}
+
+// queryAllMessageShards 并发查询所有分片并聚合结果
+func (r *Repository) queryAllMessageShards(ctx context.Context, targets []bind.RouteResult, q types.MessageQuery) ([]*model.Message, error) {
+	return fanOutShards(ctx, r, targets, func(ctx context.Context, target bind.RouteResult) []*model.Message {
 		msgs, err := r.querySingleShard(ctx, target, q)
 		if err != nil {
 			// 单个分片查询失败只记录警告，不中断整体流程
 			log.Warn().Err(err).Str("db", target.FilePath).Msg("查询数据库分片失败，跳过")
-			continue
+			return nil
 		}
-		allMessages = append(allMessages, msgs...)
-	}
-	return allMessages, nil
+		return msgs
+	})
 }
 
 // querySingleShard 在单个数据库分片上执行查询
@@ -74,15 +374,25 @@ func (r *Repository) querySingleShard(ctx context.Context, target bind.RouteResu
 		return nil, err
 	}
 
-	// 计算 V4 表名
-	hash := md5.Sum([]byte(target.Talker))
-	tableName := "Msg_" + hex.EncodeToString(hash[:])
-
-	if r.isTableExist(db, tableName) {
+	switch r.version() {
+	case model.WeChatV3:
+		return r.queryV3Messages(ctx, db, target, q)
+	case model.WeChatDarwinV3:
+		// 按会话切分的分片中可能没有该会话的表
+		darwinTable := darwinTableName(target.Talker)
+		if !r.isTableExist(db, darwinTable) {
+			return nil, nil
+		}
+		return r.queryDarwinMessages(ctx, db, darwinTable, target.Talker, q)
+	default:
+		// V4 表名为 Msg_ + md5(talker)，该分片时间段内没有此会话的消息时表不存在
+		hash := md5.Sum([]byte(target.Talker))
+		tableName := "Msg_" + hex.EncodeToString(hash[:])
+		if !r.isTableExist(db, tableName) {
+			return nil, nil
+		}
 		return r.queryV4Messages(ctx, db, tableName, target.Talker, q)
 	}
-
-	return r.queryV3Messages(ctx, db, target, q)
 }
 
 // enrichMessages 填充消息的发送者头像信息
@@ -149,11 +459,15 @@ func (r *Repository) queryV4Messages(ctx context.Context, db *sql.DB, tableName,
 	args := []interface{}{q.StartTime.Unix(), q.EndTime.Unix()}
 
 	if q.MsgType != 0 {
-		query += " AND m.local_type = ?"
+		// local_type 的高 32 位是分享消息的子类型，按低 32 位过滤
+		query += " AND (m.local_type & 4294967295) = ?"
 		args = append(args, q.MsgType)
 	}
+	query, args = appendServerIDs(query, args, "m.server_id", q.ServerIDs)
 
-	query += " ORDER BY m.sort_seq ASC"
+	clause, keysetArgs := keysetClause("m.sort_seq", q)
+	query += clause
+	args = append(args, keysetArgs...)
 
 	rows, err := db.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -188,6 +502,52 @@ func (r *Repository) queryV4Messages(ctx context.Context, db *sql.DB, tableName,
 	return msgs, nil
 }
 
+// darwinTableName 返回 macOS 中某个会话的消息表名
+func darwinTableName(talker string) string {
+	hash := md5.Sum([]byte(talker))
+	return "Chat_" + hex.EncodeToString(hash[:])
+}
+
+func (r *Repository) queryDarwinMessages(ctx context.Context, db *sql.DB, tableName, talker string, q types.MessageQuery) ([]*model.Message, error) {
+	query := fmt.Sprintf(`
+		SELECT mesLocalID, IFNULL(mesSvrID, 0), msgCreateTime, IFNULL(msgContent, ''), messageType, mesDes
+		FROM %s
+		WHERE msgCreateTime >= ? AND msgCreateTime <= ?
+	`, tableName)
+	args := []interface{}{q.StartTime.Unix(), q.EndTime.Unix()}
+
+	if q.MsgType != 0 {
+		query += " AND messageType = ?"
+		args = append(args, q.MsgType)
+	}
+	query, args = appendServerIDs(query, args, "mesSvrID", q.ServerIDs)
+
+	clause, keysetArgs := darwinKeysetClause(q)
+	query += clause
+	args = append(args, keysetArgs...)
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var msgs []*model.Message
+	for rows.Next() {
+		var msg model.MessageDarwinV3
+		if err := rows.Scan(&msg.MesLocalID, &msg.MesSvrID, &msg.MsgCreateTime, &msg.MsgContent, &msg.MessageType, &msg.MesDes); err != nil {
+			return nil, err
+		}
+
+		wrapped := msg.Wrap(talker)
+		if q.Sender != "" && wrapped.Sender != q.Sender {
+			continue
+		}
+		msgs = append(msgs, wrapped)
+	}
+	return msgs, nil
+}
+
 func (r *Repository) queryV3Messages(ctx context.Context, db *sql.DB, target bind.RouteResult, q types.MessageQuery) ([]*model.Message, error) {
 	query, args := r.buildV3MessageQuery(target, q)
 
@@ -249,6 +609,8 @@ func (r *Repository) buildV3MessageQuery(target bind.RouteResult, q types.Messag
 		sb.WriteString(" AND Type = ?")
 		args = append(args, q.MsgType)
 	}
+	serverIDs, args := appendServerIDs("", args, "MsgSvrID", q.ServerIDs)
+	sb.WriteString(serverIDs)
 
 	if target.TalkerID != 0 {
 		sb.WriteString(" AND TalkerId = ?")
@@ -258,8 +620,78 @@ func (r *Repository) buildV3MessageQuery(target bind.RouteResult, q types.Messag
 		args = append(args, target.Talker)
 	}
 
-	sb.WriteString(" ORDER BY Sequence ASC")
+	clause, keysetArgs := keysetClause("Sequence", q)
+	sb.WriteString(clause)
+	args = append(args, keysetArgs...)
+
+	return sb.String(), args
+}
+
+// appendServerIDs 在 ids 不为空时追加按服务端消息 ID 过滤的条件
+func appendServerIDs(query string, args []interface{}, column string, ids []int64) (string, []interface{}) {
+	if len(ids) == 0 {
+		return query, args
+	}
+	query += " AND " + column + " IN (" + strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",") + ")"
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	return query, args
+}
+
+// keysetClause 生成游标条件、排序和 LIMIT 子句。
+// 未设置游标时按 seq 升序返回全部结果；设置游标时把 seq 比较和 LIMIT 下推到分片查询中。
+func keysetClause(column string, q types.MessageQuery) (string, []interface{}) {
+	if q.Cursor == nil {
+		return " ORDER BY " + column + " ASC", nil
+	}
+
+	var sb strings.Builder
+	var args []interface{}
+	order := "ASC"
+	if q.Cursor.Direction == types.CursorPrev {
+		order = "DESC"
+		if q.Cursor.Seq > 0 {
+			sb.WriteString(" AND " + column + " < ?")
+			args = append(args, q.Cursor.Seq)
+		}
+	} else if q.Cursor.Seq > 0 {
+		sb.WriteString(" AND " + column + " > ?")
+		args = append(args, q.Cursor.Seq)
+	}
+
+	sb.WriteString(" ORDER BY " + column + " " + order)
+	if q.Limit > 0 {
+		sb.WriteString(fmt.Sprintf(" LIMIT %d", q.Limit))
+	}
+	return sb.String(), args
+}
+
+// darwinKeysetClause 生成 macOS 消息表的游标条件、排序和 LIMIT 子句。
+// 序号由 msgCreateTime 与 mesLocalID 组成 (见 model.MessageDarwinV3.Seq)，这里按两列的复合键比较，
+// 使 msgCreateTime 上的索引可以定位游标，而不必对每一行计算序号。
+func darwinKeysetClause(q types.MessageQuery) (string, []interface{}) {
+	localID := fmt.Sprintf("mesLocalID %% %d", model.DarwinSeqScale)
+	if q.Cursor == nil {
+		return " ORDER BY msgCreateTime ASC, " + localID + " ASC", nil
+	}
+
+	var sb strings.Builder
+	var args []interface{}
+	order, cmp := "ASC", ">"
+	if q.Cursor.Direction == types.CursorPrev {
+		order, cmp = "DESC", "<"
+	}
+	if q.Cursor.Seq > 0 {
+		createTime, id := q.Cursor.Seq/model.DarwinSeqScale, q.Cursor.Seq%model.DarwinSeqScale
+		sb.WriteString(fmt.Sprintf(" AND msgCreateTime %s= ? AND (msgCreateTime %s ? OR %s %s ?)", cmp, cmp, localID, cmp))
+		args = append(args, createTime, createTime, id)
+	}
 
+	sb.WriteString(fmt.Sprintf(" ORDER BY msgCreateTime %s, %s %s", order, localID, order))
+	if q.Limit > 0 {
+		sb.WriteString(fmt.Sprintf(" LIMIT %d", q.Limit))
+	}
 	return sb.String(), args
 }
 
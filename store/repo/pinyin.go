@@ -0,0 +1,49 @@
+package repo
+
+import (
+	"strings"
+
+	"github.com/afumu/wetrace/pkg/pinyin"
+)
+
+// matchSearch 判断 names 中是否有名称包含 search (不区分大小写)，或能按拼音全拼/首字母匹配 search
+func matchSearch(search string, names ...string) bool {
+	lower := strings.ToLower(search)
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		if strings.Contains(strings.ToLower(name), lower) || pinyin.Match(name, search) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPinyinColumns 判断数据库中保存的拼音 (全拼或首字母列) 是否包含 search
+func matchPinyinColumns(search string, columns ...string) bool {
+	q := strings.ToLower(strings.Join(strings.Fields(search), ""))
+	if q == "" {
+		return false
+	}
+	for _, col := range columns {
+		if col != "" && strings.Contains(strings.ToLower(col), q) {
+			return true
+		}
+	}
+	return false
+}
+
+// paginate 对已在内存中过滤的结果分页，limit <= 0 表示不限
+func paginate[T any](items []T, limit, offset int) []T {
+	if offset > 0 {
+		if offset >= len(items) {
+			return nil
+		}
+		items = items[offset:]
+	}
+	if limit > 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
@@ -5,14 +5,18 @@ import (
 	"crypto/md5"
 	"database/sql"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/afumu/wetrace/internal/model"
 	"github.com/afumu/wetrace/store/bind"
 	"github.com/afumu/wetrace/store/core"
+	"github.com/afumu/wetrace/store/fts"
 	"github.com/afumu/wetrace/store/strategy"
 	"github.com/afumu/wetrace/store/types"
 
@@ -46,6 +50,193 @@ func TestRepo_GetContacts(t *testing.T) {
 	}
 }
 
+func TestRepo_SearchContacts(t *testing.T) {
+	tmpDir := t.TempDir()
+	pool := core.NewConnectionPool(tmpDir)
+	defer pool.CloseAll()
+
+	dbPath := filepath.Join(tmpDir, "contact.db")
+	createContactDB(t, dbPath)
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range [][]interface{}{
+		{"wxid_zs", 0, "", "", "张三", "", ""},
+		{"wxid_ls", 0, "", "老李", "李四", "", ""},
+		{"wxid_zsf", 0, "", "", "张三丰", "", ""},
+	} {
+		if _, err := db.Exec("INSERT INTO contact VALUES (?, ?, ?, ?, ?, ?, ?)", c...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+
+	router := bind.NewTimelineRouter(tmpDir, pool, strategy.NewV4())
+	repo := New(router, pool)
+	ctx := context.Background()
+
+	tests := []struct {
+		search string
+		limit  int
+		offset int
+		want   []string
+	}{
+		{search: "zs", want: []string{"wxid_zs", "wxid_zsf"}},
+		{search: "zhangsan", want: []string{"wxid_zs", "wxid_zsf"}},
+		{search: "zhangsf", want: []string{"wxid_zsf"}},
+		{search: "ll", want: []string{"wxid_ls"}},   // 备注 "老李"
+		{search: "lisi", want: []string{"wxid_ls"}}, // 昵称 "李四"
+		{search: "三", want: []string{"wxid_zs", "wxid_zsf"}},
+		{search: "NICK", want: []string{"user1"}},
+		{search: "zs", limit: 1, offset: 1, want: []string{"wxid_zsf"}},
+		{search: "wangwu", want: nil},
+	}
+	for _, tt := range tests {
+		contacts, err := repo.GetContacts(ctx, types.ContactQuery{Search: tt.search, Limit: tt.limit, Offset: tt.offset})
+		if err != nil {
+			t.Fatalf("GetContacts(%q) 失败: %v", tt.search, err)
+		}
+		var got []string
+		for _, c := range contacts {
+			got = append(got, c.UserName)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("GetContacts(%q, %d, %d) = %v, 期望 %v", tt.search, tt.limit, tt.offset, got, tt.want)
+		}
+	}
+}
+
+func TestRepo_Labels(t *testing.T) {
+	tmpDir := t.TempDir()
+	pool := core.NewConnectionPool(tmpDir)
+	defer pool.CloseAll()
+
+	db, err := sql.Open("sqlite3", filepath.Join(tmpDir, "MicroMsg.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		"CREATE TABLE Contact (UserName TEXT PRIMARY KEY, Alias TEXT, Remark TEXT, NickName TEXT, Reserved1 INTEGER, SmallHeadImgUrl TEXT, BigHeadImgUrl TEXT, LabelIDList TEXT, PYInitial TEXT, QuanPin TEXT, RemarkPYInitial TEXT, RemarkQuanPin TEXT)",
+		"CREATE TABLE ContactLabel (LabelId INTEGER PRIMARY KEY, LabelName TEXT, CreateTime INTEGER)",
+		"CREATE TABLE Session (strUsrName TEXT PRIMARY KEY, nOrder INTEGER, strNickName TEXT, strContent TEXT, nTime INTEGER)",
+		"INSERT INTO ContactLabel VALUES (1, '客户', 0), (2, '家人', 0)",
+		"INSERT INTO Contact VALUES ('wxid_a', '', '', 'A', 1, '', '', '1,', '', '', '', '')",
+		"INSERT INTO Contact VALUES ('wxid_b', '', '', 'B', 1, '', '', '1,2', '', '', '', '')",
+		"INSERT INTO Contact VALUES ('wxid_c', '', '', 'C', 1, '', '', NULL, '', '', '', '')",
+		"INSERT INTO Session VALUES ('wxid_a', 3, 'A', '', 0), ('wxid_b', 2, 'B', '', 0), ('wxid_c', 1, 'C', '', 0)",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	db.Close()
+
+	router := bind.NewTimelineRouter(tmpDir, pool, strategy.NewV3())
+	repo := New(router, pool)
+	ctx := context.Background()
+
+	labels, err := repo.GetLabels(ctx)
+	if err != nil {
+		t.Fatalf("GetLabels 失败: %v", err)
+	}
+	if len(labels) != 2 || labels[0].Name != "客户" || labels[1].ID != 2 {
+		t.Errorf("标签解析错误: %+v", labels)
+	}
+
+	names := func(contacts []*model.Contact) []string {
+		var out []string
+		for _, c := range contacts {
+			out = append(out, c.UserName)
+		}
+		return out
+	}
+	for _, tt := range []struct {
+		q    types.ContactQuery
+		want []string
+	}{
+		{q: types.ContactQuery{Label: "客户"}, want: []string{"wxid_a", "wxid_b"}},
+		{q: types.ContactQuery{Label: "2"}, want: []string{"wxid_b"}},
+		{q: types.ContactQuery{Label: "客户", Limit: 1, Offset: 1}, want: []string{"wxid_b"}},
+		{q: types.ContactQuery{Label: "同事"}, want: nil},
+	} {
+		contacts, err := repo.GetContacts(ctx, tt.q)
+		if err != nil {
+			t.Fatalf("GetContacts(%+v) 失败: %v", tt.q, err)
+		}
+		if got := names(contacts); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("GetContacts(%+v) = %v, 期望 %v", tt.q, got, tt.want)
+		}
+	}
+
+	sessions, err := repo.GetSessions(ctx, types.SessionQuery{Label: "家人"})
+	if err != nil {
+		t.Fatalf("GetSessions 失败: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].UserName != "wxid_b" {
+		t.Errorf("按标签筛选会话错误: %+v", sessions)
+	}
+}
+
+func TestRepo_LabelsV4(t *testing.T) {
+	tmpDir := t.TempDir()
+	pool := core.NewConnectionPool(tmpDir)
+	defer pool.CloseAll()
+
+	exec := func(path string, stmts ...string) {
+		db, err := sql.Open("sqlite3", path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		for _, stmt := range stmts {
+			if _, err := db.Exec(stmt); err != nil {
+				t.Fatalf("%s: %v", stmt, err)
+			}
+		}
+	}
+	exec(filepath.Join(tmpDir, "contact.db"),
+		"CREATE TABLE contact (username TEXT, local_type INTEGER, alias TEXT, remark TEXT, nick_name TEXT, small_head_url TEXT, big_head_url TEXT, label_id_list TEXT)",
+		"CREATE TABLE contact_label (label_id_ INTEGER PRIMARY KEY, label_name_ TEXT, sort_order_ INTEGER)",
+		"INSERT INTO contact_label VALUES (1, '客户', 0), (2, '家人', 1)",
+		"INSERT INTO contact VALUES ('wxid_a', 1, '', '', 'A', '', '', '1'), ('wxid_b', 1, '', '', 'B', '', '', '1,2'), ('wxid_c', 1, '', '', 'C', '', '', NULL)",
+	)
+	exec(filepath.Join(tmpDir, "session.db"),
+		"CREATE TABLE SessionTable (username TEXT, summary TEXT, last_timestamp INTEGER, sort_timestamp INTEGER, last_msg_sender TEXT, last_sender_display_name TEXT)",
+		"INSERT INTO SessionTable VALUES ('wxid_a', '', 3, 3, '', ''), ('wxid_b', '', 2, 2, '', ''), ('wxid_c', '', 1, 1, '', '')",
+	)
+
+	router := bind.NewTimelineRouter(tmpDir, pool, strategy.NewV4())
+	repo := New(router, pool)
+	ctx := context.Background()
+
+	contacts, err := repo.GetContacts(ctx, types.ContactQuery{Label: "客户"})
+	if err != nil {
+		t.Fatalf("GetContacts 失败: %v", err)
+	}
+	if len(contacts) != 2 || contacts[0].UserName != "wxid_a" || contacts[1].UserName != "wxid_b" {
+		t.Errorf("按标签筛选联系人错误: %+v", contacts)
+	}
+
+	sessions, err := repo.GetSessions(ctx, types.SessionQuery{Label: "2"})
+	if err != nil {
+		t.Fatalf("GetSessions 失败: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].UserName != "wxid_b" {
+		t.Errorf("按标签筛选会话错误: %+v", sessions)
+	}
+
+	// 没有标签成员列的数据库仍可正常读取联系人
+	plain := t.TempDir()
+	createContactDB(t, filepath.Join(plain, "contact.db"))
+	plainPool := core.NewConnectionPool(plain)
+	defer plainPool.CloseAll()
+	all, err := New(bind.NewTimelineRouter(plain, plainPool, strategy.NewV4()), plainPool).GetContacts(ctx, types.ContactQuery{})
+	if err != nil || len(all) != 1 || len(all[0].LabelIDs) != 0 {
+		t.Errorf("没有标签列时读取联系人错误: %+v, %v", all, err)
+	}
+}
+
 func TestRepo_GetMessages(t *testing.T) {
 	tmpDir := t.TempDir()
 	pool := core.NewConnectionPool(tmpDir)
@@ -81,71 +272,1360 @@ func TestRepo_GetMessages(t *testing.T) {
 	}
 }
 
-func createContactDB(t *testing.T, path string) {
-	f, err := os.Create(path)
+func TestRepo_SearchMessagesFTS(t *testing.T) {
+	tmpDir := t.TempDir()
+	pool := core.NewConnectionPool(tmpDir)
+	defer pool.CloseAll()
+	strat := strategy.NewV4()
+
+	t1 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	dbPath := filepath.Join(tmpDir, "message_0.db")
+	createMessageDB(t, dbPath, t1, "alice", 1)
+
+	router := bind.NewTimelineRouter(tmpDir, pool, strat)
+	if err := router.RebuildIndex(context.Background()); err != nil {
+		t.Fatalf("RebuildIndex 失败: %v", err)
+	}
+
+	idx, err := fts.Open(filepath.Join(tmpDir, fts.FileName))
+	if err == fts.ErrUnavailable {
+		t.Skip(err)
+	}
 	if err != nil {
-		t.Fatal(err)
+		t.Fatalf("打开全文索引失败: %v", err)
 	}
-	f.Close()
+	defer idx.Close()
 
-	db, err := sql.Open("sqlite3", path)
+	repo := New(router, pool)
+	repo.SetFTSIndex(idx)
+
+	ctx := context.Background()
+	if err := repo.SyncFTSIndex(ctx); err != nil {
+		t.Fatalf("SyncFTSIndex 失败: %v", err)
+	}
+
+	result, err := repo.SearchMessages(ctx, types.MessageQuery{Keyword: "hello", Limit: 10})
 	if err != nil {
-		t.Fatal(err)
+		t.Fatalf("SearchMessages 失败: %v", err)
+	}
+	if result.Total != 1 || len(result.Items) != 1 {
+		t.Fatalf("期望 1 条结果, 实际得到 total=%d items=%d", result.Total, len(result.Items))
+	}
+	if result.Items[0].Talker != "alice" {
+		t.Errorf("期望 talker alice, 实际得到 %s", result.Items[0].Talker)
 	}
-	defer db.Close()
 
-	_, err = db.Exec("CREATE TABLE contact (username TEXT, local_type INTEGER, alias TEXT, remark TEXT, nick_name TEXT)")
+	// 追加新消息后增量同步
+	db, err := sql.Open("sqlite3", dbPath)
 	if err != nil {
 		t.Fatal(err)
 	}
-	_, err = db.Exec("INSERT INTO contact VALUES (?, ?, ?, ?, ?)", "user1", 0, "alias1", "remark1", "nick1")
+	hash := md5.Sum([]byte("alice"))
+	_, err = db.Exec(fmt.Sprintf(`INSERT INTO Msg_%s (sort_seq, server_id, local_type, real_sender_id, create_time, message_content, status) VALUES (?, 2, 1, 1, ?, 'hello again', 0)`, hex.EncodeToString(hash[:])), t1.Unix()*1000+1, t1.Unix()+1)
+	db.Close()
+	if err != nil {
+		t.Fatalf("插入消息失败: %v", err)
+	}
+	// 连接池中的只读连接不会看到文件的修改，与监听到文件变化时一样先刷新分片
+	if err := router.RefreshShard(ctx, dbPath); err != nil {
+		t.Fatalf("RefreshShard 失败: %v", err)
+	}
+
+	if err := repo.SyncFTSIndex(ctx); err != nil {
+		t.Fatalf("SyncFTSIndex 失败: %v", err)
+	}
+	result, err = repo.SearchMessages(ctx, types.MessageQuery{Keyword: "hello", Limit: 10})
+	if err != nil {
+		t.Fatalf("SearchMessages 失败: %v", err)
+	}
+	if result.Total != 2 {
+		t.Errorf("增量同步后期望 2 条结果, 实际得到 %d", result.Total)
+	}
+
+	// 搜索表达式：排除关键词
+	expr, err := types.ParseSearch("hello -again")
+	if err != nil {
+		t.Fatalf("ParseSearch 失败: %v", err)
+	}
+	q := types.MessageQuery{Limit: 10}
+	expr.Apply(&q)
+	result, err = repo.SearchMessages(ctx, q)
+	if err != nil {
+		t.Fatalf("SearchMessages 失败: %v", err)
+	}
+	if result.Total != 1 || len(result.Items) != 1 || result.Items[0].Content != "hello" {
+		t.Errorf("排除关键词后期望 1 条结果, 实际得到 %d", result.Total)
+	}
+
+	// 已索引的行被改写 (撤回) 后不再命中旧内容，并按新内容重新索引
+	db, err = sql.Open("sqlite3", dbPath)
 	if err != nil {
 		t.Fatal(err)
 	}
+	_, err = db.Exec(fmt.Sprintf(`UPDATE Msg_%s SET local_type = 10000, message_content = '"Alice" 撤回了一条消息' WHERE message_content = 'hello again'`, hex.EncodeToString(hash[:])))
+	db.Close()
+	if err != nil {
+		t.Fatalf("改写消息失败: %v", err)
+	}
+	if err := router.RefreshShard(ctx, dbPath); err != nil {
+		t.Fatalf("RefreshShard 失败: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		result, err = repo.SearchMessages(ctx, types.MessageQuery{Keyword: "again", Limit: 10})
+		if err != nil {
+			t.Fatalf("SearchMessages 失败: %v", err)
+		}
+		if result.Total != 0 || len(result.Items) != 0 {
+			t.Errorf("第 %d 次检索: 改写后的消息不应命中旧内容, 实际得到 total=%d items=%d", i+1, result.Total, len(result.Items))
+		}
+	}
+	result, err = repo.SearchMessages(ctx, types.MessageQuery{Keyword: "撤回", Limit: 10})
+	if err != nil {
+		t.Fatalf("SearchMessages 失败: %v", err)
+	}
+	if result.Total != 1 || len(result.Items) != 1 {
+		t.Errorf("期望按新内容命中 1 条结果, 实际得到 total=%d items=%d", result.Total, len(result.Items))
+	}
 }
 
-func createMessageDB(t *testing.T, path string, startTime time.Time, user string, id int) {
-	f, err := os.Create(path)
+func TestRepo_GetMessagePage(t *testing.T) {
+	tmpDir := t.TempDir()
+	pool := core.NewConnectionPool(tmpDir)
+	defer pool.CloseAll()
+	strat := strategy.NewV4()
+
+	// 两个分片各 3 条消息
+	t1 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	t2 := t1.AddDate(0, 0, 1)
+	path0 := filepath.Join(tmpDir, "message_0.db")
+	path1 := filepath.Join(tmpDir, "message_1.db")
+	createMessageDB(t, path0, t1, "alice", 1)
+	createMessageDB(t, path1, t2, "alice", 2)
+	for i := 1; i <= 2; i++ {
+		insertV4Message(t, path0, "alice", t1.Add(time.Duration(i)*time.Minute), fmt.Sprintf("day1-%d", i))
+		insertV4Message(t, path1, "alice", t2.Add(time.Duration(i)*time.Minute), fmt.Sprintf("day2-%d", i))
+	}
+
+	router := bind.NewTimelineRouter(tmpDir, pool, strat)
+	if err := router.RebuildIndex(context.Background()); err != nil {
+		t.Fatalf("RebuildIndex 失败: %v", err)
+	}
+	repo := New(router, pool)
+
+	ctx := context.Background()
+	q := types.MessageQuery{
+		StartTime: t1,
+		EndTime:   t2.Add(time.Hour),
+		Talker:    "alice",
+		Limit:     4,
+		Cursor:    &types.Cursor{Direction: types.CursorNext},
+	}
+
+	// 向后翻页：第一页跨越两个分片
+	page, err := repo.GetMessagePage(ctx, q)
 	if err != nil {
-		t.Fatal(err)
+		t.Fatalf("GetMessagePage 失败: %v", err)
+	}
+	if len(page.Items) != 4 || !page.HasMore {
+		t.Fatalf("期望 4 条且还有更多, 实际得到 %d 条 has_more=%v", len(page.Items), page.HasMore)
+	}
+	if page.Items[3].Content != "hello" || page.NextCursor != page.Items[3].Seq {
+		t.Errorf("第一页末尾应为第二个分片的首条消息, 实际得到 %q", page.Items[3].Content)
 	}
-	f.Close()
 
-	db, err := sql.Open("sqlite3", path)
+	q.Cursor = &types.Cursor{Seq: page.NextCursor, Direction: types.CursorNext}
+	page, err = repo.GetMessagePage(ctx, q)
 	if err != nil {
-		t.Fatal(err)
+		t.Fatalf("GetMessagePage 失败: %v", err)
+	}
+	if len(page.Items) != 2 || page.HasMore {
+		t.Fatalf("期望最后一页 2 条, 实际得到 %d 条 has_more=%v", len(page.Items), page.HasMore)
+	}
+	if page.Items[0].Content != "day2-1" || page.Items[1].Content != "day2-2" {
+		t.Errorf("最后一页顺序错误: %q, %q", page.Items[0].Content, page.Items[1].Content)
 	}
-	defer db.Close()
 
-	// V4 Metadata
-	db.Exec("CREATE TABLE Timestamp (timestamp INTEGER)")
-	db.Exec("INSERT INTO Timestamp VALUES (?)", startTime.Unix())
+	// 向前翻页：从最新消息开始，结果仍按时间升序
+	q.Limit = 2
+	q.Cursor = &types.Cursor{Direction: types.CursorPrev}
+	page, err = repo.GetMessagePage(ctx, q)
+	if err != nil {
+		t.Fatalf("GetMessagePage 失败: %v", err)
+	}
+	if len(page.Items) != 2 || !page.HasMore {
+		t.Fatalf("期望 2 条且还有更多, 实际得到 %d 条 has_more=%v", len(page.Items), page.HasMore)
+	}
+	if page.Items[0].Content != "day2-1" || page.NextCursor != page.Items[0].Seq {
+		t.Errorf("向前翻页结果错误: %q, next_cursor=%d", page.Items[0].Content, page.NextCursor)
+	}
 
-	// Msg_{md5} table
-	hash := md5.Sum([]byte(user))
-	tableName := "Msg_" + hex.EncodeToString(hash[:])
+	q.Cursor = &types.Cursor{Seq: page.NextCursor, Direction: types.CursorPrev}
+	page, err = repo.GetMessagePage(ctx, q)
+	if err != nil {
+		t.Fatalf("GetMessagePage 失败: %v", err)
+	}
+	if len(page.Items) != 2 || page.Items[0].Content != "day1-2" || page.Items[1].Content != "hello" {
+		t.Errorf("跨分片向前翻页结果错误: %+v", page.Items)
+	}
+}
 
-	// sort_seq, server_id, local_type, real_sender_id, create_time, message_content, packed_info_data, status
-	sqlStmt := fmt.Sprintf(`
-	CREATE TABLE %s (
-		sort_seq INTEGER, server_id INTEGER, local_type INTEGER, 
-		real_sender_id INTEGER, create_time INTEGER, 
-		message_content TEXT, packed_info_data BLOB, status INTEGER
-	)`, tableName)
-	_, err = db.Exec(sqlStmt)
+func TestRepo_IterateMessages(t *testing.T) {
+	tmpDir := t.TempDir()
+	pool := core.NewConnectionPool(tmpDir)
+	defer pool.CloseAll()
+	strat := strategy.NewV4()
+
+	t1 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	t2 := t1.AddDate(0, 0, 1)
+	path0 := filepath.Join(tmpDir, "message_0.db")
+	path1 := filepath.Join(tmpDir, "message_1.db")
+	createMessageDB(t, path0, t1, "alice", 1)
+	createMessageDB(t, path1, t2, "alice", 2)
+	insertV4Message(t, path0, "alice", t1.Add(time.Minute), "day1")
+	insertV4Message(t, path1, "alice", t2.Add(time.Minute), "day2")
+
+	router := bind.NewTimelineRouter(tmpDir, pool, strat)
+	if err := router.RebuildIndex(context.Background()); err != nil {
+		t.Fatalf("RebuildIndex 失败: %v", err)
+	}
+	repo := New(router, pool)
+
+	q := types.MessageQuery{
+		StartTime: t1,
+		EndTime:   t2.Add(time.Hour),
+		Talker:    "alice",
+	}
+
+	var seqs []int64
+	err := repo.IterateMessages(context.Background(), q, func(m *model.Message) error {
+		seqs = append(seqs, m.Seq)
+		return nil
+	})
 	if err != nil {
-		t.Fatalf("创建 %s 失败: %v", tableName, err)
+		t.Fatalf("IterateMessages 失败: %v", err)
+	}
+	if len(seqs) != 4 {
+		t.Fatalf("期望遍历 4 条消息, 实际得到 %d", len(seqs))
+	}
+	for i := 1; i < len(seqs); i++ {
+		if seqs[i] <= seqs[i-1] {
+			t.Fatalf("遍历结果未按 seq 升序: %v", seqs)
+		}
 	}
 
-	// Name2Id table for sender resolution
-	db.Exec("CREATE TABLE Name2Id (user_name TEXT)")
-	db.Exec("INSERT INTO Name2Id VALUES (?)", user)
+	// 倒序遍历并限制条数
+	q.Reverse = true
+	q.Limit = 3
+	var contents []string
+	err = repo.IterateMessages(context.Background(), q, func(m *model.Message) error {
+		contents = append(contents, m.Content)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("IterateMessages 失败: %v", err)
+	}
+	if len(contents) != 3 || contents[0] != "day2" || contents[2] != "day1" {
+		t.Errorf("倒序遍历结果错误: %v", contents)
+	}
 
-	// 插入消息
-	_, err = db.Exec(fmt.Sprintf(`INSERT INTO %s VALUES (
-		?, 1, 1, 1, ?, 'hello', NULL, 0
-	)`, tableName), startTime.Unix()*1000, startTime.Unix())
+	// 回调返回错误时立即停止
+	stop := errors.New("stop")
+	calls := 0
+	err = repo.IterateMessages(context.Background(), types.MessageQuery{StartTime: t1, EndTime: t2.Add(time.Hour), Talker: "alice"}, func(m *model.Message) error {
+		calls++
+		return stop
+	})
+	if err != stop || calls != 1 {
+		t.Errorf("期望回调一次后返回 stop, 实际得到 calls=%d err=%v", calls, err)
+	}
+}
+
+func TestRepo_IterateSessionMessages(t *testing.T) {
+	tmpDir := t.TempDir()
+	pool := core.NewConnectionPool(tmpDir)
+	defer pool.CloseAll()
+
+	// 没有会话数据库，会话只能从分片的消息表中得到
+	t1 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	t2 := t1.AddDate(0, 0, 1)
+	path0 := filepath.Join(tmpDir, "message_0.db")
+	path1 := filepath.Join(tmpDir, "message_1.db")
+	createMessageDB(t, path0, t1, "alice", 1)
+	createMessageDB(t, path1, t2, "bob", 2)
+	insertV4Message(t, path0, "alice", t1.Add(time.Minute), "alice-2")
+	insertV4Message(t, path1, "bob", t2.Add(time.Minute), "bob-2")
+
+	router := bind.NewTimelineRouter(tmpDir, pool, strategy.NewV4())
+	if err := router.RebuildIndex(context.Background()); err != nil {
+		t.Fatalf("RebuildIndex 失败: %v", err)
+	}
+	repo := New(router, pool)
+
+	collect := func(talker string) []string {
+		var got []string
+		q := types.MessageQuery{StartTime: t1, EndTime: t2.Add(time.Hour), Talker: talker}
+		err := repo.iterateSessionMessages(context.Background(), q, model.MessageTypeText, func(m *model.Message) error {
+			got = append(got, m.Talker+":"+m.Content)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("iterateSessionMessages 失败: %v", err)
+		}
+		return got
+	}
+
+	want := []string{"alice:hello", "alice:alice-2", "bob:hello", "bob:bob-2"}
+	if got := collect(""); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("期望 %v, 实际得到 %v", want, got)
+	}
+	if got := collect("bob"); fmt.Sprint(got) != fmt.Sprint(want[2:]) {
+		t.Errorf("指定会话时期望 %v, 实际得到 %v", want[2:], got)
+	}
+}
+
+func TestFanOut(t *testing.T) {
+	items := make([]int, 20)
+	for i := range items {
+		items[i] = i
+	}
+
+	// 结果按输入顺序返回，且同时运行的任务数不超过上限
+	var running, peak atomic.Int32
+	out, err := fanOut(context.Background(), 3, items, func(ctx context.Context, n int) int {
+		cur := running.Add(1)
+		for {
+			p := peak.Load()
+			if cur <= p || peak.CompareAndSwap(p, cur) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		running.Add(-1)
+		return n * n
+	})
 	if err != nil {
-		t.Fatalf("插入消息失败: %v", err)
+		t.Fatalf("fanOut 失败: %v", err)
+	}
+	for i, v := range out {
+		if v != i*i {
+			t.Fatalf("结果顺序错误: out[%d] = %d", i, v)
+		}
+	}
+	if peak.Load() > 3 {
+		t.Errorf("并发数超过上限: %d", peak.Load())
+	}
+
+	// ctx 取消后返回错误，且不再启动新的任务
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls atomic.Int32
+	_, err = fanOut(ctx, 1, items, func(ctx context.Context, n int) int {
+		if calls.Add(1) == 2 {
+			cancel()
+		}
+		return n
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("期望 context.Canceled, 实际得到 %v", err)
+	}
+	if calls.Load() >= int32(len(items)) {
+		t.Errorf("取消后仍执行了全部任务")
+	}
+}
+
+func TestRepo_Darwin(t *testing.T) {
+	tmpDir := t.TempDir()
+	pool := core.NewConnectionPool(tmpDir)
+	defer pool.CloseAll()
+
+	t1 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	createDarwinFixture(t, tmpDir, t1)
+
+	strat := strategy.Detect(tmpDir)
+	if _, ok := strat.(*strategy.DarwinV3); !ok {
+		t.Fatalf("期望识别为 macOS 布局, 实际得到 %T", strat)
+	}
+
+	router := bind.NewTimelineRouter(tmpDir, pool, strat)
+	if err := router.RebuildIndex(context.Background()); err != nil {
+		t.Fatalf("RebuildIndex 失败: %v", err)
+	}
+	if len(router.GetShards()) != 2 {
+		t.Fatalf("期望 2 个消息分片, 实际得到 %d", len(router.GetShards()))
+	}
+	repo := New(router, pool)
+	ctx := context.Background()
+
+	// 消息：只有 msg_1.db 中有 alice 的表，另一个分片应被静默跳过
+	msgs, err := repo.GetMessages(ctx, types.MessageQuery{
+		StartTime: t1,
+		EndTime:   t1.Add(time.Hour),
+		Talker:    "alice",
+	})
+	if err != nil {
+		t.Fatalf("GetMessages 失败: %v", err)
+	}
+	if len(msgs) != 2 {
+		t.Fatalf("期望 2 条消息, 实际得到 %d", len(msgs))
+	}
+	if msgs[0].Content != "hi from mac" || msgs[0].IsSelf || msgs[0].Sender != "alice" {
+		t.Errorf("第一条消息解析错误: %+v", msgs[0])
+	}
+	if !msgs[1].IsSelf || msgs[1].Seq <= msgs[0].Seq {
+		t.Errorf("第二条消息应为自己发送且 seq 递增: %+v", msgs[1])
+	}
+	if msgs[0].TalkerName != "Alice" {
+		t.Errorf("期望会话名称 Alice, 实际得到 %q", msgs[0].TalkerName)
+	}
+
+	// 游标分页同样适用
+	page, err := repo.GetMessagePage(ctx, types.MessageQuery{
+		StartTime: t1,
+		EndTime:   t1.Add(time.Hour),
+		Talker:    "alice",
+		Limit:     1,
+		Cursor:    &types.Cursor{Direction: types.CursorPrev},
+	})
+	if err != nil {
+		t.Fatalf("GetMessagePage 失败: %v", err)
 	}
+	if len(page.Items) != 1 || !page.HasMore || page.Items[0].Content != "ok" {
+		t.Errorf("向前翻页结果错误: %+v", page)
+	}
+
+	// 联系人
+	contacts, err := repo.GetContacts(ctx, types.ContactQuery{})
+	if err != nil {
+		t.Fatalf("GetContacts 失败: %v", err)
+	}
+	if len(contacts) != 1 || contacts[0].NickName != "Alice" {
+		t.Errorf("联系人解析错误: %+v", contacts)
+	}
+
+	// 群聊
+	rooms, err := repo.GetChatRooms(ctx, types.ChatRoomQuery{})
+	if err != nil {
+		t.Fatalf("GetChatRooms 失败: %v", err)
+	}
+	if len(rooms) != 1 || len(rooms[0].Users) != 2 {
+		t.Errorf("群聊解析错误: %+v", rooms)
+	}
+
+	// 会话：群聊名称从 group_new.db 补充
+	sessions, err := repo.GetSessions(ctx, types.SessionQuery{})
+	if err != nil {
+		t.Fatalf("GetSessions 失败: %v", err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("期望 2 个会话, 实际得到 %d", len(sessions))
+	}
+	if sessions[0].UserName != "123@chatroom" || sessions[0].NickName != "Team" {
+		t.Errorf("会话排序或群名错误: %+v", sessions[0])
+	}
+
+	// 按名称搜索群聊和会话
+	rooms, err = repo.GetChatRooms(ctx, types.ChatRoomQuery{Search: "tea"})
+	if err != nil {
+		t.Fatalf("GetChatRooms 搜索失败: %v", err)
+	}
+	if len(rooms) != 1 || rooms[0].Name != "123@chatroom" {
+		t.Errorf("群聊搜索结果错误: %+v", rooms)
+	}
+	sessions, err = repo.GetSessions(ctx, types.SessionQuery{Search: "ALI"})
+	if err != nil {
+		t.Fatalf("GetSessions 搜索失败: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].UserName != "alice" {
+		t.Errorf("会话搜索结果错误: %+v", sessions)
+	}
+
+	// 关键词搜索 (逐分片扫描)
+	result, err := repo.SearchMessages(ctx, types.MessageQuery{Keyword: "mac", Limit: 10})
+	if err != nil {
+		t.Fatalf("SearchMessages 失败: %v", err)
+	}
+	if result.Total != 1 || result.Items[0].Talker != "alice" {
+		t.Errorf("搜索结果错误: total=%d", result.Total)
+	}
+
+	// 搜索表达式 (逐分片扫描)：OR、排除和消息类型
+	for input, want := range map[string]int{
+		"mac OR ok":         2,
+		"mac OR ok -hi":     1,
+		"ok type:image":     0,
+		`"from mac" in:bob`: 0,
+	} {
+		expr, err := types.ParseSearch(input)
+		if err != nil {
+			t.Fatalf("ParseSearch(%q) 失败: %v", input, err)
+		}
+		q := types.MessageQuery{Limit: 10}
+		expr.Apply(&q)
+		result, err := repo.SearchMessages(ctx, q)
+		if err != nil {
+			t.Fatalf("SearchMessages(%q) 失败: %v", input, err)
+		}
+		if result.Total != want {
+			t.Errorf("SearchMessages(%q): 期望 %d 条结果, 实际得到 %d", input, want, result.Total)
+		}
+	}
+
+	// 正则和模糊搜索
+	for _, q := range []types.MessageQuery{
+		{Mode: types.SearchModeRegex, Keyword: `^hi\s+from`, Limit: 10},
+		{Mode: types.SearchModeFuzzy, Keyword: "form mac", Distance: 2, Limit: 10},
+	} {
+		result, err := repo.SearchMessages(ctx, q)
+		if err != nil {
+			t.Fatalf("SearchMessages(%s %q) 失败: %v", q.Mode, q.Keyword, err)
+		}
+		if result.Total != 1 || result.Items[0].Content != "hi from mac" {
+			t.Errorf("SearchMessages(%s %q): 期望命中 'hi from mac', 实际得到 %d 条", q.Mode, q.Keyword, result.Total)
+		}
+	}
+}
+
+func createContactDB(t *testing.T, path string) {
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec("CREATE TABLE contact (username TEXT, local_type INTEGER, alias TEXT, remark TEXT, nick_name TEXT, small_head_url TEXT, big_head_url TEXT)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec("INSERT INTO contact VALUES (?, ?, ?, ?, ?, ?, ?)", "user1", 0, "alias1", "remark1", "nick1", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func createMessageDB(t *testing.T, path string, startTime time.Time, user string, id int) {
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// V4 Metadata
+	db.Exec("CREATE TABLE Timestamp (timestamp INTEGER)")
+	db.Exec("INSERT INTO Timestamp VALUES (?)", startTime.Unix())
+
+	// Msg_{md5} table
+	hash := md5.Sum([]byte(user))
+	tableName := "Msg_" + hex.EncodeToString(hash[:])
+
+	// sort_seq, server_id, local_type, real_sender_id, create_time, message_content, compress_content, packed_info_data, status
+	sqlStmt := fmt.Sprintf(`
+	CREATE TABLE %s (
+		local_id INTEGER PRIMARY KEY AUTOINCREMENT,
+		sort_seq INTEGER, server_id INTEGER, local_type INTEGER, 
+		real_sender_id INTEGER, create_time INTEGER, 
+		message_content TEXT, compress_content BLOB, packed_info_data BLOB, status INTEGER
+	)`, tableName)
+	_, err = db.Exec(sqlStmt)
+	if err != nil {
+		t.Fatalf("创建 %s 失败: %v", tableName, err)
+	}
+
+	// Name2Id table for sender resolution
+	db.Exec("CREATE TABLE Name2Id (user_name TEXT)")
+	db.Exec("INSERT INTO Name2Id VALUES (?)", user)
+
+	// 插入消息
+	_, err = db.Exec(fmt.Sprintf(`INSERT INTO %s (sort_seq, server_id, local_type, real_sender_id, create_time, message_content, compress_content, packed_info_data, status) VALUES (
+		?, 1, 1, 1, ?, 'hello', NULL, NULL, 0
+	)`, tableName), startTime.Unix()*1000, startTime.Unix())
+	if err != nil {
+		t.Fatalf("插入消息失败: %v", err)
+	}
+}
+
+// insertV4Message 向已有的消息分片追加一条文本消息
+func insertV4Message(t *testing.T, path, user string, ts time.Time, content string) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	hash := md5.Sum([]byte(user))
+	_, err = db.Exec(fmt.Sprintf(`INSERT INTO Msg_%s (sort_seq, server_id, local_type, real_sender_id, create_time, message_content, status) VALUES (?, 0, 1, 1, ?, ?, 0)`, hex.EncodeToString(hash[:])), ts.Unix()*1000, ts.Unix(), content)
+	if err != nil {
+		t.Fatalf("插入消息失败: %v", err)
+	}
+}
+
+func TestRepo_DarwinPageSameSecond(t *testing.T) {
+	t1 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	var msgs []darwinMsg
+	for i := 1; i <= 4; i++ {
+		msgs = append(msgs, darwinMsg{"alice", 0, t1.Unix() + 10, fmt.Sprintf("m%d", i), 1, 1})
+	}
+	repo := newDarwinRepo(t, t1, msgs...)
+	// 同一秒内 mesLocalID 相差 1000 的消息 (m2 的 mesLocalID 为 4)，"时间戳 + 3位序号" 会与 m2 重复
+	db, err := sql.Open("sqlite3", filepath.Join(repo.router.GetBaseDir(), "Message", "msg_1.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(fmt.Sprintf("INSERT INTO %s (mesLocalID, mesSvrID, msgCreateTime, msgContent, messageType, mesDes) VALUES (1004, 0, ?, 'm1004', 1, 1)", darwinTableName("alice")), t1.Unix()+10); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	ctx := context.Background()
+
+	collect := func(direction string) []string {
+		var got []string
+		cursor := &types.Cursor{Direction: direction}
+		for i := 0; i < 10; i++ {
+			page, err := repo.GetMessagePage(ctx, types.MessageQuery{
+				StartTime: t1,
+				EndTime:   t1.Add(time.Hour),
+				Talker:    "alice",
+				Limit:     2,
+				Cursor:    cursor,
+			})
+			if err != nil {
+				t.Fatalf("GetMessagePage 失败: %v", err)
+			}
+			var contents []string
+			for _, m := range page.Items {
+				contents = append(contents, m.Content)
+			}
+			if direction == types.CursorPrev {
+				got = append(contents, got...)
+			} else {
+				got = append(got, contents...)
+			}
+			if !page.HasMore {
+				break
+			}
+			cursor = &types.Cursor{Seq: page.NextCursor, Direction: direction}
+		}
+		return got
+	}
+	// 夹具中 alice 已有 "hi from mac" 与 "ok" 两条消息
+	want := "[hi from mac m1 m2 m3 m4 m1004 ok]"
+	if got := fmt.Sprint(collect(types.CursorNext)); got != want {
+		t.Errorf("向后翻页结果错误: %s", got)
+	}
+	if got := fmt.Sprint(collect(types.CursorPrev)); got != want {
+		t.Errorf("向前翻页结果错误: %s", got)
+	}
+}
+
+func TestRepo_MembershipHistory(t *testing.T) {
+	t1 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	// 群聊中的系统消息
+	var msgs []darwinMsg
+	for i, content := range []string{
+		`"Alice"邀请"Bob(bob)"加入了群聊`,
+		`"Bob"与群里其他人都不是朋友关系，请注意隐私安全`,
+		`"Carol"退出了群聊`,
+		`你修改群名为“Team”`,
+		`"Alice"将"Dave(dave)"移出了群聊`,
+	} {
+		msgs = append(msgs, darwinMsg{"123@chatroom", 0, t1.Unix() + int64(i+1)*10, content, 10000, 1})
+	}
+	// XML 系统消息中的成员带有账号
+	kick := `<sysmsg type="sysmsgtemplate"><sysmsgtemplate><content_template type="tmpl_type_profile"><plain><![CDATA[]]></plain>` +
+		`<template><![CDATA["$username$"将"$kickoutname$"移出了群聊]]></template><link_list>` +
+		`<link name="username" type="link_profile"><memberlist><member><username><![CDATA[alice]]></username><nickname><![CDATA[Alice]]></nickname></member></memberlist></link>` +
+		`<link name="kickoutname" type="link_profile"><memberlist><member><username><![CDATA[frank]]></username><nickname><![CDATA[Frank]]></nickname></member></memberlist></link>` +
+		`</link_list></content_template></sysmsgtemplate></sysmsg>`
+	msgs = append(msgs, darwinMsg{"123@chatroom", 0, t1.Unix() + 60, kick, 10002, 1})
+	msgs = append(msgs, darwinMsg{"123@chatroom", 0, t1.Unix() + 100, "alice:\n你好", 1, 1})
+	repo := newDarwinRepo(t, t1, msgs...)
+	ctx := context.Background()
+
+	events, err := repo.GetMembershipHistory(ctx, "123@chatroom")
+	if err != nil {
+		t.Fatalf("GetMembershipHistory 失败: %v", err)
+	}
+	var kinds []string
+	for _, e := range events {
+		kinds = append(kinds, e.Type)
+	}
+	if fmt.Sprint(kinds) != "[invite leave rename kick kick]" {
+		t.Fatalf("事件类型错误: %v", kinds)
+	}
+	if e := events[0]; e.Actor == nil || e.Actor.NickName != "Alice" || len(e.Targets) != 1 || e.Targets[0].UserName != "bob" || !e.Time.Equal(t1.Add(10*time.Second)) {
+		t.Errorf("邀请事件解析错误: %+v", e)
+	}
+	if e := events[2]; e.Name != "Team" || e.Actor == nil || e.Actor.NickName != "你" {
+		t.Errorf("改名事件解析错误: %+v", e)
+	}
+	if e := events[4]; e.Actor == nil || e.Actor.UserName != "alice" || len(e.Targets) != 1 || e.Targets[0] != (model.MemberRef{UserName: "frank", NickName: "Frank"}) {
+		t.Errorf("XML 移出事件解析错误: %+v", e)
+	}
+
+	roster := func(at time.Time) []string {
+		members, err := repo.GetRosterAt(ctx, "123@chatroom", at, events)
+		if err != nil {
+			t.Fatalf("GetRosterAt 失败: %v", err)
+		}
+		var out []string
+		for _, m := range members {
+			out = append(out, m.UserName+"/"+m.DisplayName)
+		}
+		return out
+	}
+	if got := fmt.Sprint(roster(t1.Add(time.Hour))); got != "[alice/Alice bob/]" {
+		t.Errorf("当前群成员错误: %s", got)
+	}
+	if got := fmt.Sprint(roster(t1.Add(35 * time.Second))); got != "[alice/Alice bob/ frank/Frank dave/Dave]" {
+		t.Errorf("移出前的群成员错误: %s", got)
+	}
+	if got := fmt.Sprint(roster(t1.Add(5 * time.Second))); got != "[alice/Alice frank/Frank dave/Dave /Carol]" {
+		t.Errorf("最早的群成员错误: %s", got)
+	}
+}
+
+func TestRepo_RevokedMessages(t *testing.T) {
+	t1 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	// alice 的会话中：一条被撤回但仍保留的消息、对应的 XML 撤回通知、一条原消息已不存在的纯文本通知
+	revoke := `<sysmsg type="revokemsg"><revokemsg><session>alice</session><msgid>1</msgid><newmsgid>9001</newmsgid><replacemsg><![CDATA["Alice" 撤回了一条消息]]></replacemsg></revokemsg></sysmsg>`
+	repo := newDarwinRepo(t, t1,
+		darwinMsg{"alice", 9001, t1.Unix() + 120, "secret", 1, 1},
+		darwinMsg{"alice", 9002, t1.Unix() + 130, revoke, 10002, 1},
+		darwinMsg{"alice", 9003, t1.Unix() + 140, "你撤回了一条消息", 10000, 0},
+	)
+	ctx := context.Background()
+
+	msgs, err := repo.GetMessages(ctx, types.MessageQuery{StartTime: t1, EndTime: t1.Add(time.Hour), Talker: "alice", Annotate: true})
+	if err != nil {
+		t.Fatalf("GetMessages 失败: %v", err)
+	}
+	if len(msgs) != 5 {
+		t.Fatalf("期望 5 条消息, 实际得到 %d", len(msgs))
+	}
+	for _, m := range msgs {
+		if m.Revoked != (m.Content == "secret") {
+			t.Errorf("消息 %q 的撤回标记错误: %v", m.Content, m.Revoked)
+		}
+	}
+	if msgs[3].Content != `"Alice" 撤回了一条消息` || msgs[3].Contents["revokedMsgId"] != "9001" {
+		t.Errorf("撤回通知解析错误: %+v", msgs[3])
+	}
+
+	// 批量遍历不设置 Annotate，不扫描撤回通知
+	err = repo.IterateMessages(ctx, types.MessageQuery{StartTime: t1, EndTime: t1.Add(time.Hour), Talker: "alice"}, func(m *model.Message) error {
+		if m.Revoked {
+			t.Errorf("未设置 Annotate 时不应标记撤回: %q", m.Content)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("IterateMessages 失败: %v", err)
+	}
+
+	revocations, err := repo.GetRevokedMessages(ctx, types.MessageQuery{StartTime: t1, EndTime: t1.Add(time.Hour), Talker: "alice"})
+	if err != nil {
+		t.Fatalf("GetRevokedMessages 失败: %v", err)
+	}
+	if len(revocations) != 2 {
+		t.Fatalf("期望 2 条撤回记录, 实际得到 %d", len(revocations))
+	}
+	if r := revocations[0]; r.ServerID != 9001 || r.Original == nil || r.Original.Content != "secret" || !r.Original.Revoked {
+		t.Errorf("撤回记录未关联到原消息: %+v", r)
+	}
+	if r := revocations[1]; r.ServerID != 0 || r.Original != nil || r.Content != "你撤回了一条消息" {
+		t.Errorf("纯文本撤回记录错误: %+v", r)
+	}
+}
+
+func TestRepo_Thread(t *testing.T) {
+	t1 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	// quote 构造引用 svrid 对应消息的引用消息
+	quote := func(title string, svrid int) string {
+		return fmt.Sprintf(`<msg><appmsg><title>%s</title><type>57</type><refermsg><type>1</type><svrid>%d</svrid><fromusr>alice</fromusr><displayname>Alice</displayname><content>...</content></refermsg></appmsg></msg>`, title, svrid)
+	}
+	repo := newDarwinRepo(t, t1,
+		darwinMsg{"alice", 8001, t1.Unix() + 200, "question", 1, 1},
+		darwinMsg{"alice", 8002, t1.Unix() + 210, quote("answer", 8001), 49, 0},
+		darwinMsg{"alice", 8003, t1.Unix() + 220, quote("follow-up", 8002), 49, 1},
+		darwinMsg{"alice", 8004, t1.Unix() + 230, quote("another answer", 8001), 49, 1},
+		darwinMsg{"alice", 8005, t1.Unix() + 240, quote("lost", 7000), 49, 0},
+	)
+	ctx := context.Background()
+
+	msgs, err := repo.GetMessages(ctx, types.MessageQuery{StartTime: t1.Add(200 * time.Second), EndTime: t1.Add(time.Hour), Talker: "alice", Annotate: true})
+	if err != nil {
+		t.Fatalf("GetMessages 失败: %v", err)
+	}
+	if len(msgs) != 5 {
+		t.Fatalf("期望 5 条消息, 实际得到 %d", len(msgs))
+	}
+	seqs := make(map[string]int64)
+	for _, m := range msgs {
+		seqs[m.Content] = m.Seq
+	}
+	// 原消息不存在的引用 (lost) 不关联
+	parents := []string{"", "question", "answer", "question", ""}
+	for i, m := range msgs {
+		if want := seqs[parents[i]]; m.ReplyTo != want {
+			t.Errorf("消息 %q 的 ReplyTo 期望 %d, 实际 %d", m.Content, want, m.ReplyTo)
+		}
+	}
+
+	// 从回复链中间的消息查询，应返回以最初的问题为根的整棵树
+	thread, err := repo.GetThread(ctx, "alice", seqs["follow-up"])
+	if err != nil {
+		t.Fatalf("GetThread 失败: %v", err)
+	}
+	if thread == nil || thread.Message.Content != "question" {
+		t.Fatalf("回复树的根消息错误: %+v", thread)
+	}
+	if len(thread.Replies) != 2 || thread.Replies[0].Message.Content != "answer" || thread.Replies[1].Message.Content != "another answer" {
+		t.Fatalf("根消息的回复错误: %+v", thread.Replies)
+	}
+	if r := thread.Replies[0].Replies; len(r) != 1 || r[0].Message.Content != "follow-up" {
+		t.Errorf("二级回复错误: %+v", r)
+	}
+
+	if thread, err := repo.GetThread(ctx, "alice", 1); err != nil || thread != nil {
+		t.Errorf("不存在的消息应返回 nil, 实际 %+v, %v", thread, err)
+	}
+}
+
+func TestRepo_ForwardRecord(t *testing.T) {
+	t1 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	forward := `<msg><appmsg><title>Alice的聊天记录</title><type>19</type><recorditem><![CDATA[<recordinfo><datalist count="2">` +
+		`<dataitem datatype="1"><sourcename>Alice</sourcename><datadesc>hi</datadesc></dataitem>` +
+		`<dataitem datatype="2"><sourcename>Bob</sourcename><fullmd5>0123abcd</fullmd5></dataitem>` +
+		`</datalist></recordinfo>]]></recorditem></appmsg></msg>`
+	repo := newDarwinRepo(t, t1, darwinMsg{"alice", 7001, t1.Unix() + 300, forward, 49, 1})
+	ctx := context.Background()
+
+	msgs, err := repo.GetMessages(ctx, types.MessageQuery{StartTime: t1, EndTime: t1.Add(time.Hour), Talker: "alice"})
+	if err != nil || len(msgs) == 0 {
+		t.Fatalf("GetMessages 失败: %v", err)
+	}
+
+	record, err := repo.GetForwardRecord(ctx, "alice", msgs[len(msgs)-1].Seq)
+	if err != nil {
+		t.Fatalf("GetForwardRecord 失败: %v", err)
+	}
+	if record == nil || record.Title != "Alice的聊天记录" || len(record.Items) != 2 {
+		t.Fatalf("合并转发记录错误: %+v", record)
+	}
+	// 本地没有对应的图片，Media 保持为空
+	if img := record.Items[1]; img.Kind != model.ForwardKindImage || img.MD5 != "0123abcd" || img.Media != nil {
+		t.Errorf("图片项错误: %+v", img)
+	}
+
+	if record, err := repo.GetForwardRecord(ctx, "alice", msgs[0].Seq); err != nil || record != nil {
+		t.Errorf("普通消息应返回 nil, 实际 %+v, %v", record, err)
+	}
+}
+
+func TestRepo_Ledger(t *testing.T) {
+	t1 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	pay := func(subType int, fee, id string) string {
+		return fmt.Sprintf(`<msg><appmsg><type>2000</type><wcpayinfo><paysubtype>%d</paysubtype><feedesc>%s</feedesc><transferid>%s</transferid></wcpayinfo></appmsg></msg>`, subType, fee, id)
+	}
+	// 取月中的时间，避免本地时区影响按月合计
+	jan, feb := time.Date(2023, 1, 15, 0, 0, 0, 0, time.UTC).Unix(), time.Date(2023, 2, 15, 0, 0, 0, 0, time.UTC).Unix()
+	repo := newDarwinRepo(t, t1,
+		// 自己转给 Alice 100 元，Alice 确认收款
+		darwinMsg{"alice", 6001, jan, pay(1, "￥100.00", "T1"), 49, 0},
+		darwinMsg{"alice", 6002, jan + 10, pay(3, "￥100.00", "T1"), 49, 1},
+		// 2 月 Alice 转给自己 20.5 元，自己退还
+		darwinMsg{"alice", 6003, feb, pay(1, "￥20.50", "T2"), 49, 1},
+		darwinMsg{"alice", 6004, feb + 10, pay(4, "￥20.50", "T2"), 49, 0},
+		darwinMsg{"alice", 6005, feb + 20, `<msg><appmsg><type>5</type><title>link</title><url>https://example.com</url></appmsg></msg>`, 49, 1},
+	)
+
+	ledger, err := repo.GetLedger(context.Background(), types.MessageQuery{StartTime: t1, EndTime: t1.AddDate(0, 2, 0), Talker: "alice"})
+	if err != nil {
+		t.Fatalf("GetLedger 失败: %v", err)
+	}
+	if len(ledger.Entries) != 4 {
+		t.Fatalf("期望 4 条账目, 实际得到 %d", len(ledger.Entries))
+	}
+	if e := ledger.Entries[0]; e.Direction != model.LedgerOut || e.Amount != 100 || e.Counterpart != "alice" || e.CounterpartName != "Alice" {
+		t.Errorf("第一条账目错误: %+v", e)
+	}
+	// 确认收款不重复计入
+	if tot := ledger.Total; tot.Out != 120.5 || tot.In != 20.5 || tot.Net != -100 || tot.Count != 3 {
+		t.Errorf("总计错误: %+v", tot)
+	}
+	if len(ledger.ByContact) != 1 || ledger.ByContact[0].Key != "alice" {
+		t.Errorf("按联系人合计错误: %+v", ledger.ByContact)
+	}
+	if len(ledger.ByMonth) != 2 || ledger.ByMonth[0].Key != "2023-01" || ledger.ByMonth[0].Out != 100 || ledger.ByMonth[1].In != 20.5 {
+		t.Errorf("按月合计错误: %+v %+v", ledger.ByMonth[0], ledger.ByMonth[1])
+	}
+}
+
+func TestRepo_Locations(t *testing.T) {
+	t1 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	repo := newDarwinRepo(t, t1,
+		darwinMsg{"alice", 5001, t1.Unix() + 500, `<msg><location x="31.2304" y="121.4737" label="上海市黄浦区" poiname="外滩" /></msg>`, 48, 1},
+		darwinMsg{"alice", 5002, t1.Unix() + 510, `<msg><location x="39.9087" y="116.3975" label="北京市东城区" /></msg>`, 48, 0},
+		darwinMsg{"alice", 5003, t1.Unix() + 520, `<msg><location x="" y="" /></msg>`, 48, 0},
+	)
+
+	// 不指定会话时汇总全部会话
+	points, err := repo.GetLocations(context.Background(), types.MessageQuery{StartTime: t1, EndTime: t1.Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("GetLocations 失败: %v", err)
+	}
+	if len(points) != 2 {
+		t.Fatalf("期望 2 个位置, 实际得到 %d", len(points))
+	}
+	if p := points[0]; p.Name() != "外滩" || p.Lat != 31.2304 || p.Talker != "alice" || p.IsSelf {
+		t.Errorf("第一个位置错误: %+v", p)
+	}
+	if p := points[1]; p.Name() != "北京市东城区" || !p.IsSelf {
+		t.Errorf("第二个位置错误: %+v", p)
+	}
+}
+
+func TestRepo_Links(t *testing.T) {
+	t1 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	link := func(title, url, source string) string {
+		return fmt.Sprintf(`<msg><appmsg><title>%s</title><type>5</type><url>%s</url><sourcedisplayname>%s</sourcedisplayname></appmsg></msg>`, title, url, source)
+	}
+	repo := newDarwinRepo(t, t1,
+		darwinMsg{"alice", 6001, t1.Unix() + 500, link("文章", "https://mp.weixin.qq.com/s?sn=abc&amp;scene=1", "公众号甲"), 49, 1},
+		darwinMsg{"alice", 6002, t1.Unix() + 510, link("文章", "https://mp.weixin.qq.com/s?sn=abc&amp;scene=2", "公众号甲"), 49, 0},
+		darwinMsg{"alice", 6003, t1.Unix() + 520, link("示例", "https://www.example.com/page", ""), 49, 1},
+		darwinMsg{"alice", 6004, t1.Unix() + 530, `<msg><appmsg><title>a.pdf</title><type>6</type></appmsg></msg>`, 49, 1},
+	)
+
+	q := types.LinkQuery{StartTime: t1, EndTime: t1.Add(time.Hour)}
+	lib, err := repo.GetLinks(context.Background(), q)
+	if err != nil {
+		t.Fatalf("GetLinks 失败: %v", err)
+	}
+	if len(lib.Links) != 2 {
+		t.Fatalf("期望去重后 2 个链接, 实际得到 %d", len(lib.Links))
+	}
+	if l := lib.Links[0]; l.Domain != "example.com" {
+		t.Errorf("链接应按最近分享时间倒序: %+v", l)
+	}
+	if l := lib.Links[1]; l.Count != 2 || l.Source != "公众号甲" || !l.FirstTime.Equal(time.Unix(t1.Unix()+500, 0)) || !l.LastTime.Equal(time.Unix(t1.Unix()+510, 0)) || len(l.Shares) != 2 || l.Shares[1].Talker != "alice" {
+		t.Errorf("去重链接错误: %+v", l)
+	}
+	if len(lib.TopDomains) != 2 || lib.TopDomains[0].Domain != "mp.weixin.qq.com" || lib.TopDomains[0].Count != 2 || lib.TopDomains[0].Links != 1 {
+		t.Errorf("热门域名错误: %+v", lib.TopDomains)
+	}
+
+	// 按域名和来源筛选
+	q.Domain = "qq.com"
+	if lib, err = repo.GetLinks(context.Background(), q); err != nil || len(lib.Links) != 1 {
+		t.Fatalf("按域名筛选错误: %v %+v", err, lib)
+	}
+	q.Domain, q.Source = "", "公众号"
+	if lib, err = repo.GetLinks(context.Background(), q); err != nil || len(lib.Links) != 1 || lib.Links[0].Source != "公众号甲" {
+		t.Fatalf("按来源筛选错误: %v %+v", err, lib)
+	}
+}
+
+func TestRepo_Files(t *testing.T) {
+	t1 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	file := func(title, ext string, size int, md5 string) string {
+		return fmt.Sprintf(`<msg><appmsg><title>%s</title><type>6</type><appattach><totallen>%d</totallen><fileext>%s</fileext></appattach><md5>%s</md5></appmsg></msg>`, title, size, ext, md5)
+	}
+	repo := newDarwinRepo(t, t1,
+		darwinMsg{"alice", 7001, t1.Unix() + 500, file("合同.pdf", "pdf", 1000, "aaa111"), 49, 1},
+		darwinMsg{"alice", 7002, t1.Unix() + 510, file("照片.zip", "zip", 50000, "bbb222"), 49, 0},
+		darwinMsg{"alice", 7003, t1.Unix() + 520, file("说明.PDF", "PDF", 300, "ccc333"), 49, 1},
+	)
+
+	// 只有第一个文件在本地有记录
+	hl, err := sql.Open("sqlite3", filepath.Join(repo.router.GetBaseDir(), "hldata.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		`CREATE TABLE HlinkMediaRecord (mediaMd5 TEXT, mediaSize INTEGER, inodeNumber INTEGER, modifyTime INTEGER)`,
+		`CREATE TABLE HlinkMediaDetail (inodeNumber INTEGER, relativePath TEXT, fileName TEXT)`,
+		`INSERT INTO HlinkMediaRecord VALUES ('aaa111', 1000, 1, 0)`,
+		`INSERT INTO HlinkMediaDetail VALUES (1, 'alice/File', '合同.pdf')`,
+	} {
+		if _, err := hl.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hl.Close()
+
+	q := types.FileQuery{StartTime: t1, EndTime: t1.Add(time.Hour)}
+	catalog, err := repo.GetFiles(context.Background(), q)
+	if err != nil {
+		t.Fatalf("GetFiles 失败: %v", err)
+	}
+	if catalog.Total != 3 || catalog.Present != 1 || catalog.TotalSize != 51300 || len(catalog.Items) != 3 {
+		t.Fatalf("文件列表错误: %+v", catalog)
+	}
+	if f := catalog.Items[2]; f.Title != "合同.pdf" || !f.Present || f.Path != "Message/MessageTemp/alice/File/合同.pdf" {
+		t.Errorf("文件应按时间倒序并标记本地存在: %+v", f)
+	}
+	if f := catalog.Items[1]; f.Present || !f.IsSelf {
+		t.Errorf("文件错误: %+v", f)
+	}
+
+	// 按扩展名和大小筛选，扩展名不区分大小写
+	q.Ext, q.MaxSize = ".pdf", 500
+	if catalog, err = repo.GetFiles(context.Background(), q); err != nil || catalog.Total != 1 || catalog.Items[0].Title != "说明.PDF" {
+		t.Fatalf("按扩展名和大小筛选错误: %v %+v", err, catalog)
+	}
+
+	// 分页
+	q = types.FileQuery{StartTime: t1, EndTime: t1.Add(time.Hour), Limit: 2, Offset: 2}
+	if catalog, err = repo.GetFiles(context.Background(), q); err != nil || catalog.Total != 3 || len(catalog.Items) != 1 {
+		t.Fatalf("分页错误: %v %+v", err, catalog)
+	}
+}
+
+func TestRepo_Videos(t *testing.T) {
+	t1 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	repo := newDarwinRepo(t, t1,
+		darwinMsg{"alice", 8001, t1.Unix() + 500, `<msg><videomsg md5="vvv111" length="500" playlength="12" /></msg>`, 43, 1},
+		darwinMsg{"alice", 8002, t1.Unix() + 510, `<msg><videomsg md5="vvv222" length="800" playlength="30" /></msg>`, 43, 0},
+	)
+
+	hl, err := sql.Open("sqlite3", filepath.Join(repo.router.GetBaseDir(), "hldata.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		`CREATE TABLE HlinkMediaRecord (mediaMd5 TEXT, mediaSize INTEGER, inodeNumber INTEGER, modifyTime INTEGER)`,
+		`CREATE TABLE HlinkMediaDetail (inodeNumber INTEGER, relativePath TEXT, fileName TEXT)`,
+		`INSERT INTO HlinkMediaRecord VALUES ('vvv111', 4096, 1, 0)`,
+		`INSERT INTO HlinkMediaDetail VALUES (1, 'alice/Video', 'vvv111.mp4')`,
+	} {
+		if _, err := hl.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hl.Close()
+
+	catalog, err := repo.GetVideos(context.Background(), types.VideoQuery{StartTime: t1, EndTime: t1.Add(time.Hour), Limit: 1})
+	if err != nil {
+		t.Fatalf("GetVideos 失败: %v", err)
+	}
+	// 本地有记录的视频使用记录中的大小
+	if catalog.Total != 2 || catalog.TotalDuration != 42 || catalog.TotalSize != 4896 || len(catalog.Items) != 1 {
+		t.Fatalf("视频列表错误: %+v", catalog)
+	}
+	if v := catalog.Items[0]; v.MD5 != "vvv222" || v.Present || v.Duration != 30 || !v.IsSelf {
+		t.Errorf("视频应按时间倒序: %+v", v)
+	}
+
+	catalog, err = repo.GetVideos(context.Background(), types.VideoQuery{StartTime: t1, EndTime: t1.Add(time.Hour), Offset: 1})
+	if err != nil || len(catalog.Items) != 1 {
+		t.Fatalf("分页错误: %v %+v", err, catalog)
+	}
+	if v := catalog.Items[0]; !v.Present || v.Size != 4096 || v.Path != "Message/MessageTemp/alice/Video/vvv111.mp4" {
+		t.Errorf("本地视频错误: %+v", v)
+	}
+}
+
+func TestRepo_MediasByMD5V4(t *testing.T) {
+	tmpDir := t.TempDir()
+	pool := core.NewConnectionPool(tmpDir)
+	defer pool.CloseAll()
+
+	db, err := sql.Open("sqlite3", filepath.Join(tmpDir, "hardlink.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	named := "aaaabbbbccccddddeeeeffff00001111"
+	for _, stmt := range []string{
+		`CREATE TABLE dir2id (username TEXT)`,
+		`INSERT INTO dir2id VALUES ('2024-01')`,
+		`CREATE TABLE video_hardlink_info_v4 (md5 TEXT, file_name TEXT, file_size INTEGER, modify_time INTEGER, extra_buffer BLOB, dir1 INTEGER, dir2 INTEGER)`,
+		// 按文件名匹配，缩略图记录应跳过
+		`INSERT INTO video_hardlink_info_v4 VALUES ('thumbmd5', '` + named + `_thumb.jpg', 10, 0, NULL, 1, 0)`,
+		`INSERT INTO video_hardlink_info_v4 VALUES ('othermd5', '` + named + `.mp4', 100, 0, NULL, 1, 0)`,
+		// 按 md5 匹配
+		`INSERT INTO video_hardlink_info_v4 VALUES ('bbb', 'bbb.mp4', 200, 0, NULL, 1, 0)`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+
+	router := bind.NewTimelineRouter(tmpDir, pool, strategy.NewV4())
+	repo := New(router, pool)
+
+	medias, err := repo.getMediasByMD5(context.Background(), "video", []string{named, "bbb", "missing"})
+	if err != nil {
+		t.Fatalf("getMediasByMD5 失败: %v", err)
+	}
+	if len(medias) != 2 {
+		t.Fatalf("期望找到 2 个视频, 实际得到 %d", len(medias))
+	}
+	if m := medias[named]; m == nil || m.Size != 100 || filepath.ToSlash(m.Path) != "msg/video/2024-01/"+named+".mp4" {
+		t.Errorf("按文件名匹配错误: %+v", m)
+	}
+	if m := medias["bbb"]; m == nil || m.Size != 200 {
+		t.Errorf("按 md5 匹配错误: %+v", m)
+	}
+}
+
+func TestRepo_MediaSpread(t *testing.T) {
+	t1 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	file := `<msg><appmsg><title>机密.docx</title><type>6</type><md5>DOC111</md5></appmsg></msg>`
+	image := `<msg><img md5="img222" /></msg>`
+	repo := newDarwinRepo(t, t1,
+		// alice 先发给自己，自己再转发到群里，bob 又在群里转发了一次
+		darwinMsg{"alice", 9001, t1.Unix() + 100, file, 49, 1},
+		darwinMsg{"123@chatroom", 9002, t1.Unix() + 200, file, 49, 0},
+		darwinMsg{"123@chatroom", 9003, t1.Unix() + 300, "bob:\n" + file, 49, 1},
+		// 只在一个会话中出现的图片
+		darwinMsg{"alice", 9004, t1.Unix() + 400, image, 3, 1},
+		darwinMsg{"alice", 9005, t1.Unix() + 500, image, 3, 0},
+	)
+	ctx := context.Background()
+	q := types.MediaSpreadQuery{StartTime: t1, EndTime: t1.Add(time.Hour)}
+
+	// 默认只返回到达 2 个及以上会话的媒体
+	report, err := repo.GetMediaSpread(ctx, q)
+	if err != nil {
+		t.Fatalf("GetMediaSpread 失败: %v", err)
+	}
+	if report.Total != 1 || len(report.Items) != 1 {
+		t.Fatalf("期望 1 个跨会话媒体, 实际得到 %+v", report)
+	}
+	s := report.Items[0]
+	if s.MD5 != "doc111" || s.Kind != model.SpreadKindFile || s.Title != "机密.docx" || s.Count != 3 || s.SessionCount != 2 {
+		t.Errorf("传播信息错误: %+v", s)
+	}
+	if s.Origin == nil || s.Origin.Talker != "alice" || s.Origin.IsSelf {
+		t.Errorf("最早发送错误: %+v", s.Origin)
+	}
+	if len(s.Chain) != 3 || s.Chain[2].Sender != "bob" || !s.Chain[1].IsSelf {
+		t.Errorf("传播链错误: %+v", s.Chain)
+	}
+	if len(s.Sessions) != 2 || s.Sessions[0].Talker != "alice" || s.Sessions[1].Talker != "123@chatroom" || s.Sessions[1].Count != 2 {
+		t.Errorf("到达的会话错误: %+v", s.Sessions)
+	}
+
+	// 指定 md5 时不限会话数
+	q.MD5 = "IMG222"
+	if report, err = repo.GetMediaSpread(ctx, q); err != nil || report.Total != 1 || report.Items[0].Count != 2 || report.Items[0].Kind != model.SpreadKindImage {
+		t.Fatalf("按 md5 追踪错误: %v %+v", err, report)
+	}
+	q.MD5, q.Kind = "", model.SpreadKindImage
+	if report, err = repo.GetMediaSpread(ctx, q); err != nil || report.Total != 0 {
+		t.Fatalf("按种类筛选错误: %v %+v", err, report)
+	}
+}
+
+func TestRepo_Diagnostics(t *testing.T) {
+	t1 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := newDarwinRepo(t, t1)
+	tmpDir := r.router.GetBaseDir()
+
+	// 未识别的数据库、未解密的文件，以及应跳过的全文索引和快照目录
+	notes, err := sql.Open("sqlite3", filepath.Join(tmpDir, "Message", "notes.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := notes.Exec("CREATE TABLE note (id INTEGER PRIMARY KEY, body TEXT); INSERT INTO note (body) VALUES ('a'), ('b'), ('c')"); err != nil {
+		t.Fatal(err)
+	}
+	notes.Close()
+	if err := os.WriteFile(filepath.Join(tmpDir, "Message", "broken.db"), []byte("SQLite format 3\x00 encrypted garbage"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, fts.FileName), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(tmpDir, ".snapshots", "g1"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, ".snapshots", "g1", "msg_0.db"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := r.GetDiagnostics(context.Background(), "deep"); err == nil {
+		t.Error("不支持的检查方式应返回错误")
+	}
+
+	d, err := r.GetDiagnostics(context.Background(), "")
+	if err != nil {
+		t.Fatalf("GetDiagnostics 失败: %v", err)
+	}
+	if d.Version != model.WeChatDarwinV3 || d.Check != model.DiagnosticsCheckQuick {
+		t.Errorf("版本或检查方式不符: %s %s", d.Version, d.Check)
+	}
+	if d.Revision == 0 || d.Revision != r.router.Revision() {
+		t.Errorf("索引版本应为 %d, 实际得到 %d", r.router.Revision(), d.Revision)
+	}
+
+	var shard *model.ShardDiagnostics
+	for _, s := range d.Shards {
+		if s.Path == "Message/msg_1.db" {
+			shard = s
+		}
+	}
+	if len(d.Shards) != 2 || shard == nil || !shard.Open {
+		t.Fatalf("分片不符: %+v", shard)
+	}
+
+	files := make(map[string]*model.DBFileDiagnostics)
+	for _, f := range d.Files {
+		files[f.Path] = f
+	}
+	if len(files) != 7 {
+		t.Errorf("期望 7 个数据库文件 (不含全文索引和快照)，实际 %d: %v", len(files), d.Unrecognized)
+	}
+
+	msg := files["Message/msg_1.db"]
+	if msg == nil || !msg.Recognized || !msg.Indexed || msg.Type != "Message" || msg.Error != "" {
+		t.Fatalf("msg_1.db 诊断信息不符: %+v", msg)
+	}
+	hash := md5.Sum([]byte("alice"))
+	if len(msg.Tables) != 1 || msg.Tables[0].Name != "Chat_"+hex.EncodeToString(hash[:]) || msg.Tables[0].Rows != 2 {
+		t.Errorf("msg_1.db 的表或行数不符: %+v", msg.Tables[0])
+	}
+	if len(msg.Check) != 1 || msg.Check[0] != "ok" {
+		t.Errorf("期望完整性检查通过，实际 %v", msg.Check)
+	}
+
+	note := files["Message/notes.db"]
+	if note == nil || note.Recognized || note.Error != "" || len(note.Tables) != 1 || note.Tables[0].Rows != 3 {
+		t.Errorf("notes.db 诊断信息不符: %+v", note)
+	}
+	if broken := files["Message/broken.db"]; broken == nil || broken.Error == "" {
+		t.Errorf("无法读取的文件应记录错误: %+v", broken)
+	}
+	if len(d.Unrecognized) != 2 || d.Unrecognized[0] != "Message/broken.db" || d.Unrecognized[1] != "Message/notes.db" {
+		t.Errorf("未识别文件不符: %v", d.Unrecognized)
+	}
+
+	none, err := r.GetDiagnostics(context.Background(), model.DiagnosticsCheckNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range none.Files {
+		if f.Check != nil {
+			t.Errorf("check=none 时不应执行完整性检查: %s %v", f.Path, f.Check)
+		}
+	}
+}
+
+func TestRepo_HiddenSessions(t *testing.T) {
+	t1 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	repo := newDarwinRepo(t, t1)
+	ctx := context.Background()
+
+	repo.SetHiddenSessions([]string{"123@chatroom"})
+	sessions, err := repo.GetSessions(ctx, types.SessionQuery{Limit: 1})
+	if err != nil {
+		t.Fatalf("GetSessions 失败: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].UserName != "alice" {
+		t.Fatalf("隐藏的会话不应出现在列表中，分页应在过滤后进行: %+v", sessions)
+	}
+
+	repo.SetHiddenSessions(nil)
+	if sessions, err = repo.GetSessions(ctx, types.SessionQuery{}); err != nil || len(sessions) != 2 {
+		t.Errorf("取消隐藏后期望 2 个会话, 实际得到 %d (%v)", len(sessions), err)
+	}
+}
+
+// darwinMsg 是 newDarwinRepo 写入会话表的一条消息，Des 为 0 表示自己发送
+type darwinMsg struct {
+	Talker  string
+	SvrID   int64
+	Time    int64
+	Content string
+	Type    int
+	Des     int
+}
+
+// newDarwinRepo 在临时目录中构建 createDarwinFixture 的数据目录，写入 msgs 后建立索引并返回仓储。
+// 消息写入 Message/msg_1.db，会话表不存在时先创建；数据目录可通过 repo.router.GetBaseDir() 取得。
+func newDarwinRepo(t *testing.T, t1 time.Time, msgs ...darwinMsg) *Repository {
+	t.Helper()
+	dir := t.TempDir()
+	createDarwinFixture(t, dir, t1)
+
+	db, err := sql.Open("sqlite3", filepath.Join(dir, "Message", "msg_1.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range msgs {
+		table := darwinTableName(m.Talker)
+		if _, err := db.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (mesLocalID INTEGER PRIMARY KEY AUTOINCREMENT, mesSvrID INTEGER, msgCreateTime INTEGER, msgContent TEXT, msgStatus INTEGER, messageType INTEGER, mesDes INTEGER)", table)); err != nil {
+			t.Fatal(err)
+		}
+		insert := fmt.Sprintf("INSERT INTO %s (mesSvrID, msgCreateTime, msgContent, messageType, mesDes) VALUES (?, ?, ?, ?, ?)", table)
+		if _, err := db.Exec(insert, m.SvrID, m.Time, m.Content, m.Type, m.Des); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+
+	pool := core.NewConnectionPool(dir)
+	t.Cleanup(func() { pool.CloseAll() })
+	router := bind.NewTimelineRouter(dir, pool, strategy.Detect(dir))
+	if err := router.RebuildIndex(context.Background()); err != nil {
+		t.Fatalf("RebuildIndex 失败: %v", err)
+	}
+	return New(router, pool)
+}
+
+// createDarwinFixture 构建一个最小的 macOS 数据目录
+func createDarwinFixture(t *testing.T, dir string, startTime time.Time) {
+	exec := func(path string, stmts ...string) {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		db, err := sql.Open("sqlite3", path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		for _, stmt := range stmts {
+			if _, err := db.Exec(stmt); err != nil {
+				t.Fatalf("%s: %v", stmt, err)
+			}
+		}
+	}
+
+	hash := md5.Sum([]byte("alice"))
+	chatTable := "Chat_" + hex.EncodeToString(hash[:])
+	ts := startTime.Unix()
+
+	exec(filepath.Join(dir, "Message", "msg_0.db"),
+		"CREATE TABLE Chat_00000000000000000000000000000000 (mesLocalID INTEGER PRIMARY KEY AUTOINCREMENT, msgCreateTime INTEGER, msgContent TEXT, messageType INTEGER, mesDes INTEGER)",
+	)
+	exec(filepath.Join(dir, "Message", "msg_1.db"),
+		fmt.Sprintf("CREATE TABLE %s (mesLocalID INTEGER PRIMARY KEY AUTOINCREMENT, mesSvrID INTEGER, msgCreateTime INTEGER, msgContent TEXT, msgStatus INTEGER, messageType INTEGER, mesDes INTEGER)", chatTable),
+		fmt.Sprintf("INSERT INTO %s (msgCreateTime, msgContent, messageType, mesDes) VALUES (%d, 'hi from mac', 1, 1)", chatTable, ts),
+		fmt.Sprintf("INSERT INTO %s (msgCreateTime, msgContent, messageType, mesDes) VALUES (%d, 'ok', 1, 0)", chatTable, ts+60),
+	)
+	exec(filepath.Join(dir, "Contact", "wccontact_new2.db"),
+		"CREATE TABLE WCContact (m_nsUsrName TEXT PRIMARY KEY, nickname TEXT, m_nsRemark TEXT, m_uiSex INTEGER, m_nsAliasName TEXT, m_nsHeadImgUrl TEXT, m_nsHeadHDImgUrl TEXT)",
+		"INSERT INTO WCContact VALUES ('alice', 'Alice', '', 2, '', '', '')",
+	)
+	exec(filepath.Join(dir, "Group", "group_new.db"),
+		"CREATE TABLE GroupContact (m_nsUsrName TEXT PRIMARY KEY, nickname TEXT, m_nsRemark TEXT, m_nsChatRoomMemList TEXT, m_nsChatRoomAdminList TEXT, m_nsHeadImgUrl TEXT, m_nsHeadHDImgUrl TEXT)",
+		"INSERT INTO GroupContact VALUES ('123@chatroom', 'Team', '', 'alice;bob', 'alice', '', '')",
+	)
+	exec(filepath.Join(dir, "Session", "session_new.db"),
+		"CREATE TABLE SessionAbstract (m_nsUserName TEXT PRIMARY KEY, m_uUnReadCount INTEGER, m_uLastTime INTEGER)",
+		fmt.Sprintf("INSERT INTO SessionAbstract VALUES ('alice', 0, %d)", ts+60),
+		fmt.Sprintf("INSERT INTO SessionAbstract VALUES ('123@chatroom', 0, %d)", ts+120),
+	)
 }
@@ -11,28 +11,62 @@ import (
 	"time"
 
 	"github.com/afumu/wetrace/internal/model"
+	"github.com/afumu/wetrace/store/bind"
 	"github.com/afumu/wetrace/store/types"
 )
 
 // getAnnualOverview 获取年度概览统计
 func (r *Repository) getAnnualOverview(ctx context.Context, start, end time.Time) (model.AnnualOverview, error) {
 	var overview model.AnnualOverview
-	var totalMsgs, sentMsgs, recvMsgs int
-	contactSet := make(map[string]bool)
-	chatroomSet := make(map[string]bool)
-	daySet := make(map[string]bool)
-	var firstDate, lastDate string
 
-	for _, shard := range r.router.GetShards() {
+	// 每个分片使用独立的累加器并发统计，最后再合并
+	type overviewPart struct {
+		total, sent, recv         int
+		contacts, chatrooms, days map[string]bool
+		firstDate, lastDate       string
+	}
+	version := r.version()
+	parts, err := fanOut(ctx, r.shardConcurrency(), r.router.GetShards(), func(ctx context.Context, shard *bind.DatabaseShard) *overviewPart {
+		p := &overviewPart{
+			contacts:  make(map[string]bool),
+			chatrooms: make(map[string]bool),
+			days:      make(map[string]bool),
+		}
 		db, err := r.pool.GetConnection(shard.FilePath)
 		if err != nil {
-			continue
+			return p
 		}
 
-		if r.isTableExist(db, "MSG") {
-			r.overviewV3(ctx, db, start, end, &totalMsgs, &sentMsgs, &recvMsgs, contactSet, chatroomSet, daySet, &firstDate, &lastDate)
+		if version == model.WeChatV3 {
+			r.overviewV3(ctx, db, start, end, &p.total, &p.sent, &p.recv, p.contacts, p.chatrooms, p.days, &p.firstDate, &p.lastDate)
 		} else {
-			r.overviewV4(ctx, db, start, end, &totalMsgs, &sentMsgs, &recvMsgs, contactSet, chatroomSet, daySet, &firstDate, &lastDate)
+			r.overviewV4(ctx, db, start, end, &p.total, &p.sent, &p.recv, p.contacts, p.chatrooms, p.days, &p.firstDate, &p.lastDate)
+		}
+		return p
+	})
+	if err != nil {
+		return overview, err
+	}
+
+	var totalMsgs, sentMsgs, recvMsgs int
+	contactSet := make(map[string]bool)
+	daySet := make(map[string]bool)
+	var firstDate, lastDate string
+	for _, p := range parts {
+		totalMsgs += p.total
+		sentMsgs += p.sent
+		recvMsgs += p.recv
+		for id := range p.contacts {
+			contactSet[id] = true
+		}
+		for d := range p.days {
+			daySet[d] = true
+		}
+		if p.firstDate != "" && (firstDate == "" || p.firstDate < firstDate) {
+			firstDate = p.firstDate
+		}
+		if p.lastDate > lastDate {
+			lastDate = p.lastDate
 		}
 	}
 
@@ -234,14 +268,15 @@ func (r *Repository) getAnnualTopContacts(ctx context.Context, start, end time.T
 		recv     int
 		lastTime int64
 	}
-	aggStats := make(map[string]*stats)
-
+	var privateTalkers []string
 	for _, session := range sessions {
-		talker := session.UserName
-		if strings.HasSuffix(talker, "@chatroom") {
-			continue
+		if !strings.HasSuffix(session.UserName, "@chatroom") {
+			privateTalkers = append(privateTalkers, session.UserName)
 		}
+	}
 
+	// 各会话的统计相互独立，并发执行
+	perTalker, err := fanOut(ctx, r.shardConcurrency(), privateTalkers, func(ctx context.Context, talker string) *stats {
 		targets := r.router.Resolve(start, end, talker)
 		s := &stats{}
 
@@ -314,8 +349,16 @@ func (r *Repository) getAnnualTopContacts(ctx context.Context, start, end time.T
 				}
 			}
 		}
+		return s
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	aggStats := make(map[string]*stats)
+	for i, s := range perTalker {
 		if s.sent > 0 || s.recv > 0 {
-			aggStats[talker] = s
+			aggStats[privateTalkers[i]] = s
 		}
 	}
 
@@ -360,15 +403,15 @@ func (r *Repository) getAnnualTopContacts(ctx context.Context, start, end time.T
 
 // getAnnualMonthlyTrend 获取年度月度趋势
 func (r *Repository) getAnnualMonthlyTrend(ctx context.Context, start, end time.Time) []*model.MonthlyStat {
-	monthlyStats := make(map[int]int)
-
-	for _, shard := range r.router.GetShards() {
+	version := r.version()
+	parts, _ := fanOut(ctx, r.shardConcurrency(), r.router.GetShards(), func(ctx context.Context, shard *bind.DatabaseShard) map[int]int {
+		monthlyStats := make(map[int]int)
 		db, err := r.pool.GetConnection(shard.FilePath)
 		if err != nil {
-			continue
+			return monthlyStats
 		}
 
-		if r.isTableExist(db, "MSG") {
+		if version == model.WeChatV3 {
 			query := "SELECT CAST(strftime('%m', CreateTime/1000, 'unixepoch', 'localtime') AS INTEGER) as month, COUNT(*) as count FROM MSG WHERE CreateTime >= ? AND CreateTime <= ? GROUP BY month"
 			rows, err := db.QueryContext(ctx, query, start.Unix()*1000, end.Unix()*1000)
 			if err == nil {
@@ -382,7 +425,7 @@ func (r *Repository) getAnnualMonthlyTrend(ctx context.Context, start, end time.
 		} else {
 			tables, err := db.QueryContext(ctx, "SELECT name FROM sqlite_master WHERE type='table' AND name LIKE 'Msg_%%'")
 			if err != nil {
-				continue
+				return monthlyStats
 			}
 			for tables.Next() {
 				var tableName string
@@ -400,7 +443,9 @@ func (r *Repository) getAnnualMonthlyTrend(ctx context.Context, start, end time.
 			}
 			tables.Close()
 		}
-	}
+		return monthlyStats
+	})
+	monthlyStats := sumCounts(parts)
 
 	var result []*model.MonthlyStat
 	for i := 1; i <= 12; i++ {
@@ -411,15 +456,15 @@ func (r *Repository) getAnnualMonthlyTrend(ctx context.Context, start, end time.
 
 // getAnnualWeekdayDist 获取年度星期分布
 func (r *Repository) getAnnualWeekdayDist(ctx context.Context, start, end time.Time) []*model.WeekdayStat {
-	weekdayStats := make(map[int]int)
-
-	for _, shard := range r.router.GetShards() {
+	version := r.version()
+	parts, _ := fanOut(ctx, r.shardConcurrency(), r.router.GetShards(), func(ctx context.Context, shard *bind.DatabaseShard) map[int]int {
+		weekdayStats := make(map[int]int)
 		db, err := r.pool.GetConnection(shard.FilePath)
 		if err != nil {
-			continue
+			return weekdayStats
 		}
 
-		if r.isTableExist(db, "MSG") {
+		if version == model.WeChatV3 {
 			query := "SELECT CASE WHEN CAST(strftime('%w', CreateTime/1000, 'unixepoch', 'localtime') AS INTEGER) = 0 THEN 7 ELSE CAST(strftime('%w', CreateTime/1000, 'unixepoch', 'localtime') AS INTEGER) END as weekday, COUNT(*) as count FROM MSG WHERE CreateTime >= ? AND CreateTime <= ? GROUP BY weekday"
 			rows, err := db.QueryContext(ctx, query, start.Unix()*1000, end.Unix()*1000)
 			if err == nil {
@@ -433,7 +478,9 @@ func (r *Repository) getAnnualWeekdayDist(ctx context.Context, start, end time.T
 		} else {
 			r.weekdayV4Shards(ctx, db, start, end, weekdayStats)
 		}
-	}
+		return weekdayStats
+	})
+	weekdayStats := sumCounts(parts)
 
 	var result []*model.WeekdayStat
 	for i := 1; i <= 7; i++ {
@@ -467,15 +514,15 @@ func (r *Repository) weekdayV4Shards(ctx context.Context, db *sql.DB, start, end
 
 // getAnnualHourlyDist 获取年度小时分布
 func (r *Repository) getAnnualHourlyDist(ctx context.Context, start, end time.Time) []*model.HourlyStat {
-	hourlyStats := make(map[int]int)
-
-	for _, shard := range r.router.GetShards() {
+	version := r.version()
+	parts, _ := fanOut(ctx, r.shardConcurrency(), r.router.GetShards(), func(ctx context.Context, shard *bind.DatabaseShard) map[int]int {
+		hourlyStats := make(map[int]int)
 		db, err := r.pool.GetConnection(shard.FilePath)
 		if err != nil {
-			continue
+			return hourlyStats
 		}
 
-		if r.isTableExist(db, "MSG") {
+		if version == model.WeChatV3 {
 			query := "SELECT CAST(strftime('%H', CreateTime/1000, 'unixepoch', 'localtime') AS INTEGER) as hour, COUNT(*) as count FROM MSG WHERE CreateTime >= ? AND CreateTime <= ? GROUP BY hour"
 			rows, err := db.QueryContext(ctx, query, start.Unix()*1000, end.Unix()*1000)
 			if err == nil {
@@ -489,7 +536,9 @@ func (r *Repository) getAnnualHourlyDist(ctx context.Context, start, end time.Ti
 		} else {
 			r.hourlyV4Shards(ctx, db, start, end, hourlyStats)
 		}
-	}
+		return hourlyStats
+	})
+	hourlyStats := sumCounts(parts)
 
 	var result []*model.HourlyStat
 	for i := 0; i < 24; i++ {
@@ -523,15 +572,15 @@ func (r *Repository) hourlyV4Shards(ctx context.Context, db *sql.DB, start, end
 
 // getAnnualMessageTypes 获取年度消息类型分布
 func (r *Repository) getAnnualMessageTypes(ctx context.Context, start, end time.Time) map[string]int {
-	typeStats := make(map[int]int)
-
-	for _, shard := range r.router.GetShards() {
+	version := r.version()
+	parts, _ := fanOut(ctx, r.shardConcurrency(), r.router.GetShards(), func(ctx context.Context, shard *bind.DatabaseShard) map[int]int {
+		typeStats := make(map[int]int)
 		db, err := r.pool.GetConnection(shard.FilePath)
 		if err != nil {
-			continue
+			return typeStats
 		}
 
-		if r.isTableExist(db, "MSG") {
+		if version == model.WeChatV3 {
 			query := "SELECT Type, COUNT(*) FROM MSG WHERE CreateTime >= ? AND CreateTime <= ? GROUP BY Type"
 			rows, err := db.QueryContext(ctx, query, start.Unix()*1000, end.Unix()*1000)
 			if err == nil {
@@ -545,7 +594,9 @@ func (r *Repository) getAnnualMessageTypes(ctx context.Context, start, end time.
 		} else {
 			r.messageTypesV4Shards(ctx, db, start, end, typeStats)
 		}
-	}
+		return typeStats
+	})
+	typeStats := sumCounts(parts)
 
 	// 转换为可读名称
 	result := make(map[string]int)
@@ -579,6 +630,17 @@ func (r *Repository) messageTypesV4Shards(ctx context.Context, db *sql.DB, start
 	}
 }
 
+// sumCounts 合并各分片按键计数的统计结果
+func sumCounts(parts []map[int]int) map[int]int {
+	total := make(map[int]int)
+	for _, part := range parts {
+		for k, c := range part {
+			total[k] += c
+		}
+	}
+	return total
+}
+
 // messageTypeName 将消息类型数字转换为可读名称
 func messageTypeName(t int) string {
 	switch t {
@@ -610,23 +672,40 @@ func messageTypeName(t int) string {
 // getAnnualHighlights 获取年度亮点数据
 func (r *Repository) getAnnualHighlights(ctx context.Context, start, end time.Time) model.AnnualHighlights {
 	highlights := model.AnnualHighlights{}
-	dailyCounts := make(map[string]int)
-	var lateNightCount int
-	var earliestMinute, latestMinute int
-	earliestMinute = 24 * 60 // 初始化为最大值
-	latestMinute = -1
 
-	for _, shard := range r.router.GetShards() {
+	// 每个分片使用独立的累加器并发统计，最后再合并
+	type highlightsPart struct {
+		dailyCounts                 map[string]int
+		lateNight, earliest, latest int
+	}
+	version := r.version()
+	parts, _ := fanOut(ctx, r.shardConcurrency(), r.router.GetShards(), func(ctx context.Context, shard *bind.DatabaseShard) *highlightsPart {
+		p := &highlightsPart{dailyCounts: make(map[string]int), earliest: 24 * 60, latest: -1}
 		db, err := r.pool.GetConnection(shard.FilePath)
 		if err != nil {
-			continue
+			return p
 		}
 
-		if r.isTableExist(db, "MSG") {
-			r.highlightsV3(ctx, db, start, end, dailyCounts, &lateNightCount, &earliestMinute, &latestMinute)
+		if version == model.WeChatV3 {
+			r.highlightsV3(ctx, db, start, end, p.dailyCounts, &p.lateNight, &p.earliest, &p.latest)
 		} else {
-			r.highlightsV4(ctx, db, start, end, dailyCounts, &lateNightCount, &earliestMinute, &latestMinute)
+			r.highlightsV4(ctx, db, start, end, p.dailyCounts, &p.lateNight, &p.earliest, &p.latest)
+		}
+		return p
+	})
+
+	dailyCounts := make(map[string]int)
+	var lateNightCount int
+	var earliestMinute, latestMinute int
+	earliestMinute = 24 * 60 // 初始化为最大值
+	latestMinute = -1
+	for _, p := range parts {
+		for d, c := range p.dailyCounts {
+			dailyCounts[d] += c
 		}
+		lateNightCount += p.lateNight
+		earliestMinute = min(earliestMinute, p.earliest)
+		latestMinute = max(latestMinute, p.latest)
 	}
 
 	// 找出最忙和最闲的一天
@@ -1,14 +1,28 @@
 package repo
 
 import (
+	"sync"
+
 	"github.com/afumu/wetrace/store/bind"
 	"github.com/afumu/wetrace/store/core"
+	"github.com/afumu/wetrace/store/fts"
 )
 
 // Repository 是数据访问层的入口，聚合了路由和连接池
 type Repository struct {
 	router *bind.TimelineRouter
 	pool   *core.ConnectionPool
+
+	fts   *fts.Index // 可选的全文索引
+	ftsMu sync.Mutex // 保证同一时间只有一个索引同步任务
+
+	concurrency int // 同时查询的分片数上限，<= 0 时使用 DefaultShardConcurrency
+
+	revokeMu sync.Mutex
+	revoked  map[string]revokedSet // 会话 -> 被撤回的消息 ID
+
+	hiddenMu sync.RWMutex
+	hidden   map[string]bool // 用户删除的会话，不出现在会话列表中
 }
 
 // New 创建一个新的 Repository
@@ -18,3 +32,9 @@ func New(router *bind.TimelineRouter, pool *core.ConnectionPool) *Repository {
 		pool:   pool,
 	}
 }
+
+// version 返回当前识别到的数据版本。
+// 每次调用时从路由器读取，Reload 重新识别版本后立即生效。
+func (r *Repository) version() string {
+	return r.router.Version()
+}
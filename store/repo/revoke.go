@@ -0,0 +1,166 @@
+package repo
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/afumu/wetrace/internal/model"
+	"github.com/afumu/wetrace/store/types"
+)
+
+// revokedSet 缓存某个会话中被撤回的服务端消息 ID，索引版本变化后失效
+type revokedSet struct {
+	revision uint64
+	ids      map[int64]bool
+}
+
+// isRevokeNotice 判断系统消息是否是撤回通知
+func isRevokeNotice(m *model.Message) bool {
+	if m.Type != model.MessageTypeSystem && m.Type != model.MessageTypeSysMsg {
+		return false
+	}
+	if _, ok := m.Contents["revokedMsgId"]; ok {
+		return true
+	}
+	return strings.Contains(m.Content, "撤回了一条消息")
+}
+
+// revokedID 返回撤回通知引用的服务端消息 ID，纯文本通知返回 0
+func revokedID(m *model.Message) int64 {
+	s, _ := m.Contents["revokedMsgId"].(string)
+	id, _ := strconv.ParseInt(s, 10, 64)
+	return id
+}
+
+// iterateRevokeNotices 按时间顺序遍历会话中的撤回通知
+func (r *Repository) iterateRevokeNotices(ctx context.Context, q types.MessageQuery, fn func(*model.Message)) error {
+	return r.iterateSystemMessages(ctx, q, isRevokeNotice, fn)
+}
+
+// iterateSystemMessages 按时间顺序遍历会话中 keep 返回 true 的系统消息。
+// 系统消息可能是类型 10000 的纯文本或类型 10002 的 XML，两种类型分别按类型过滤读取。
+func (r *Repository) iterateSystemMessages(ctx context.Context, q types.MessageQuery, keep func(*model.Message) bool, fn func(*model.Message)) error {
+	var notices []*model.Message
+	for _, msgType := range []int{model.MessageTypeSystem, model.MessageTypeSysMsg} {
+		tq := types.MessageQuery{StartTime: q.StartTime, EndTime: q.EndTime, Talker: q.Talker, MsgType: msgType}
+		err := r.IterateMessages(ctx, tq, func(m *model.Message) error {
+			if keep(m) {
+				notices = append(notices, m)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	r.sortMessages(notices)
+	for _, m := range notices {
+		fn(m)
+	}
+	return nil
+}
+
+// revokedIDs 返回会话中被撤回的服务端消息 ID，结果按索引版本缓存
+func (r *Repository) revokedIDs(ctx context.Context, talker string) (map[int64]bool, error) {
+	revision := r.router.Revision()
+	r.revokeMu.Lock()
+	cached, ok := r.revoked[talker]
+	r.revokeMu.Unlock()
+	if ok && cached.revision == revision {
+		return cached.ids, nil
+	}
+
+	ids := make(map[int64]bool)
+	err := r.iterateRevokeNotices(ctx, types.MessageQuery{
+		StartTime: time.Unix(0, 0),
+		EndTime:   time.Now(),
+		Talker:    talker,
+	}, func(m *model.Message) {
+		if id := revokedID(m); id != 0 {
+			ids[id] = true
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	r.revokeMu.Lock()
+	if r.revoked == nil {
+		r.revoked = make(map[string]revokedSet)
+	}
+	r.revoked[talker] = revokedSet{revision: revision, ids: ids}
+	r.revokeMu.Unlock()
+	return ids, nil
+}
+
+// markRevoked 将仍保留在数据库中的被撤回消息标记为 Revoked。
+// 查询系统消息本身时跳过，避免读取撤回通知时再次进入本方法。
+func (r *Repository) markRevoked(ctx context.Context, q types.MessageQuery, msgs []*model.Message) error {
+	if q.MsgType == model.MessageTypeSystem || q.MsgType == model.MessageTypeSysMsg {
+		return nil
+	}
+
+	byTalker := make(map[string]map[int64]bool)
+	for _, m := range msgs {
+		if m.ServerID == 0 || m.Talker == "" {
+			continue
+		}
+		ids, ok := byTalker[m.Talker]
+		if !ok {
+			var err error
+			if ids, err = r.revokedIDs(ctx, m.Talker); err != nil {
+				return err
+			}
+			byTalker[m.Talker] = ids
+		}
+		m.Revoked = ids[m.ServerID]
+	}
+	return nil
+}
+
+// GetRevokedMessages 列出会话中的撤回记录，并关联仍然存在的原消息
+func (r *Repository) GetRevokedMessages(ctx context.Context, q types.MessageQuery) ([]*model.Revocation, error) {
+	var revocations []*model.Revocation
+	var ids []int64
+	err := r.iterateRevokeNotices(ctx, q, func(m *model.Message) {
+		rev := &model.Revocation{
+			Seq:      m.Seq,
+			Time:     m.Time,
+			Talker:   m.Talker,
+			Content:  m.Content,
+			ServerID: revokedID(m),
+		}
+		if rev.ServerID != 0 {
+			ids = append(ids, rev.ServerID)
+		}
+		revocations = append(revocations, rev)
+	})
+	if err != nil || len(ids) == 0 {
+		return revocations, err
+	}
+
+	// 原消息可能早于撤回通知的时间范围，在全部时间内按 ID 查找
+	originals, err := r.GetMessages(ctx, types.MessageQuery{
+		StartTime: time.Unix(0, 0),
+		EndTime:   time.Now(),
+		Talker:    q.Talker,
+		ServerIDs: ids,
+	})
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int64]*model.Message, len(originals))
+	for _, m := range originals {
+		// 撤回后原消息可能被通知覆盖，只关联非撤回通知的消息
+		if !isRevokeNotice(m) {
+			m.Revoked = true
+			byID[m.ServerID] = m
+		}
+	}
+	for _, rev := range revocations {
+		rev.Original = byID[rev.ServerID]
+	}
+	return revocations, nil
+}
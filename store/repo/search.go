@@ -6,6 +6,7 @@ import (
 	"database/sql"
 	"encoding/hex"
 	"fmt"
+	"slices"
 	"sort"
 	"strings"
 	"time"
@@ -18,25 +19,41 @@ import (
 
 // SearchMessages 高级搜索（带总数统计）
 func (r *Repository) SearchMessages(ctx context.Context, q types.MessageQuery) (*model.SearchResult, error) {
-	var allMessages []*model.Message
+	// 优先使用全文索引，索引尚未构建完成或检索出错时回退到逐分片扫描
+	if r.fts != nil && r.fts.Ready() && ftsCovers(q) {
+		result, err := r.searchWithFTS(ctx, q)
+		if err == nil {
+			return result, nil
+		}
+		log.Warn().Err(err).Msg("全文索引检索失败，回退到逐分片扫描")
+	}
+
+	// 会话表名到用户名的映射在所有分片间共享，需在并发查询前准备好
 	var talkerMD5Map map[string]string
+	version := r.version()
+	if version != model.WeChatV3 {
+		talkerMD5Map = r.getTalkerMD5Map(ctx)
+	}
 
-	for _, shard := range r.router.GetShards() {
+	allMessages, err := fanOutShards(ctx, r, r.router.GetShards(), func(ctx context.Context, shard *bind.DatabaseShard) []*model.Message {
 		db, err := r.pool.GetConnection(shard.FilePath)
 		if err != nil {
-			continue
+			return nil
 		}
 
-		if r.isTableExist(db, "MSG") {
-			msgs, _ := r.searchV3Advanced(ctx, db, q)
-			allMessages = append(allMessages, msgs...)
-		} else {
-			if talkerMD5Map == nil {
-				talkerMD5Map = r.getTalkerMD5Map(ctx)
-			}
-			msgs, _ := r.searchV4Advanced(ctx, db, q, talkerMD5Map)
-			allMessages = append(allMessages, msgs...)
+		var msgs []*model.Message
+		switch version {
+		case model.WeChatV3:
+			msgs, _ = r.searchV3Advanced(ctx, db, q)
+		case model.WeChatDarwinV3:
+			msgs, _ = r.searchDarwinAdvanced(ctx, db, q, talkerMD5Map)
+		default:
+			msgs, _ = r.searchV4Advanced(ctx, db, q, talkerMD5Map)
 		}
+		return msgs
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	// 丰富信息
@@ -81,8 +98,10 @@ func (r *Repository) searchV3Advanced(ctx context.Context, db *sql.DB, q types.M
 	var sb strings.Builder
 	var args []interface{}
 
-	sb.WriteString("SELECT MsgSvrID, Sequence, CreateTime, StrTalker, IsSender, Type, SubType, StrContent, CompressContent, BytesExtra FROM MSG WHERE StrContent LIKE ?")
-	args = append(args, "%"+q.Keyword+"%")
+	sb.WriteString("SELECT MsgSvrID, Sequence, CreateTime, StrTalker, IsSender, Type, SubType, StrContent, CompressContent, BytesExtra FROM MSG WHERE 1=1")
+	cond, condArgs := matchConditions(q, "StrContent", "Type")
+	sb.WriteString(cond)
+	args = append(args, condArgs...)
 
 	if q.Talker != "" {
 		talkers := strings.Split(q.Talker, ",")
@@ -155,7 +174,7 @@ func (r *Repository) searchV4Advanced(ctx context.Context, db *sql.DB, q types.M
 	defer tables.Close()
 
 	var msgs []*model.Message
-	keywordParam := "%" + q.Keyword + "%"
+	cond, condArgs := matchConditions(q, "m.message_content", "m.local_type")
 
 	// 如果指定了 talker，只搜索对应的表
 	talkerFilter := make(map[string]bool)
@@ -182,8 +201,9 @@ func (r *Repository) searchV4Advanced(ctx context.Context, db *sql.DB, q types.M
 			SELECT m.sort_seq, m.server_id, m.local_type, n.user_name, m.create_time, m.message_content, m.packed_info_data, m.status
 			FROM %s m
 			LEFT JOIN Name2Id n ON m.real_sender_id = n.rowid
-			WHERE m.message_content LIKE ?`, tableName))
-		args = append(args, keywordParam)
+			WHERE 1=1`, tableName))
+		sb.WriteString(cond)
+		args = append(args, condArgs...)
 
 		if !q.StartTime.IsZero() {
 			sb.WriteString(" AND m.create_time >= ?")
@@ -194,7 +214,7 @@ func (r *Repository) searchV4Advanced(ctx context.Context, db *sql.DB, q types.M
 			args = append(args, q.EndTime.Unix())
 		}
 		if q.MsgType > 0 {
-			sb.WriteString(" AND m.local_type = ?")
+			sb.WriteString(" AND (m.local_type & 4294967295) = ?")
 			args = append(args, q.MsgType)
 		}
 
@@ -245,6 +265,76 @@ func (r *Repository) searchV4Advanced(ctx context.Context, db *sql.DB, q types.M
 	return msgs, nil
 }
 
+// matchConditions 把查询的关键词 (或搜索表达式、正则、模糊模式) 编译为针对内容列 col 和类型列 typeCol 的 SQL 条件，
+// 返回的条件以 " AND " 开头。正则和模糊模式使用连接池注册的 REGEXP 和 fuzzy_match 函数。
+func matchConditions(q types.MessageQuery, col, typeCol string) (string, []interface{}) {
+	var sb strings.Builder
+	var args []interface{}
+
+	switch q.Mode {
+	case types.SearchModeRegex:
+		return " AND " + col + " REGEXP ?", []interface{}{q.Keyword}
+	case types.SearchModeFuzzy:
+		return " AND fuzzy_match(" + col + ", ?, ?)", []interface{}{q.Keyword, q.Distance}
+	}
+
+	e := q.Expr
+	if e == nil {
+		sb.WriteString(" AND " + col + " LIKE ?")
+		args = append(args, "%"+q.Keyword+"%")
+		return sb.String(), args
+	}
+
+	// 子句之间为 AND，子句内的关键词为 OR
+	for _, clause := range e.Clauses {
+		parts := make([]string, len(clause))
+		for i, term := range clause {
+			parts[i] = col + ` LIKE ? ESCAPE '\'`
+			args = append(args, "%"+escapeLike(term.Text)+"%")
+		}
+		sb.WriteString(" AND (" + strings.Join(parts, " OR ") + ")")
+	}
+	for _, term := range e.Excludes {
+		sb.WriteString(" AND IFNULL(" + col + `, '') NOT LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+escapeLike(term.Text)+"%")
+	}
+	if len(e.Types) > 0 {
+		placeholders := make([]string, len(e.Types))
+		for i, t := range e.Types {
+			placeholders[i] = "?"
+			args = append(args, t)
+		}
+		sb.WriteString(" AND " + typeCol + " IN (" + strings.Join(placeholders, ",") + ")")
+	}
+	return sb.String(), args
+}
+
+// ftsCovers 判断查询能否交给全文索引：需要有关键词，且 type: 只包含被索引的消息类型。
+// 正则和模糊搜索只能逐分片扫描。
+func ftsCovers(q types.MessageQuery) bool {
+	if q.Mode == types.SearchModeRegex || q.Mode == types.SearchModeFuzzy {
+		return false
+	}
+	e := q.Expr
+	if e == nil {
+		return q.Keyword != ""
+	}
+	if !e.HasKeywords() {
+		return false
+	}
+	for _, t := range e.Types {
+		if !ftsIndexedType(int64(t)) {
+			return false
+		}
+	}
+	return true
+}
+
+// escapeLike 转义 LIKE 模式中的通配符，配合 ESCAPE '\' 使用
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
 // GetMessageContext 获取某条消息前后的上下文消息
 func (r *Repository) GetMessageContext(ctx context.Context, talker string, seq int64, before, after int) ([]*model.Message, error) {
 	targets := r.router.Resolve(time.Unix(0, 0), time.Now(), talker)
@@ -253,6 +343,7 @@ func (r *Repository) GetMessageContext(ctx context.Context, talker string, seq i
 	}
 
 	var allMessages []*model.Message
+	version := r.version()
 
 	for _, target := range targets {
 		db, err := r.pool.GetConnection(target.FilePath)
@@ -260,24 +351,29 @@ func (r *Repository) GetMessageContext(ctx context.Context, talker string, seq i
 			continue
 		}
 
-		hash := md5.Sum([]byte(talker))
-		tableName := "Msg_" + hex.EncodeToString(hash[:])
-
-		if r.isTableExist(db, tableName) {
-			msgs, err := r.queryV4Context(ctx, db, tableName, talker, seq, before, after)
-			if err != nil {
-				log.Warn().Err(err).Msg("查询V4上下文失败")
+		var msgs []*model.Message
+		switch version {
+		case model.WeChatV3:
+			msgs, err = r.queryV3Context(ctx, db, target, seq, before, after)
+		case model.WeChatDarwinV3:
+			darwinTable := darwinTableName(talker)
+			if !r.isTableExist(db, darwinTable) {
 				continue
 			}
-			allMessages = append(allMessages, msgs...)
-		} else {
-			msgs, err := r.queryV3Context(ctx, db, target, seq, before, after)
-			if err != nil {
-				log.Warn().Err(err).Msg("查询V3上下文失败")
+			msgs, err = r.queryDarwinContext(ctx, db, darwinTable, talker, seq, before, after)
+		default:
+			hash := md5.Sum([]byte(talker))
+			tableName := "Msg_" + hex.EncodeToString(hash[:])
+			if !r.isTableExist(db, tableName) {
 				continue
 			}
-			allMessages = append(allMessages, msgs...)
+			msgs, err = r.queryV4Context(ctx, db, tableName, talker, seq, before, after)
+		}
+		if err != nil {
+			log.Warn().Err(err).Str("version", version).Msg("查询上下文失败")
+			continue
 		}
+		allMessages = append(allMessages, msgs...)
 	}
 
 	// 丰富信息
@@ -293,6 +389,110 @@ func (r *Repository) GetMessageContext(ctx context.Context, talker string, seq i
 	return allMessages, nil
 }
 
+// searchDarwinAdvanced macOS 高级搜索
+func (r *Repository) searchDarwinAdvanced(ctx context.Context, db *sql.DB, q types.MessageQuery, talkerMD5Map map[string]string) ([]*model.Message, error) {
+	tables, err := db.QueryContext(ctx, `SELECT name FROM sqlite_master WHERE type='table' AND name LIKE 'Chat\_%' ESCAPE '\'`)
+	if err != nil {
+		return nil, err
+	}
+	var tableNames []string
+	for tables.Next() {
+		var tableName string
+		if err := tables.Scan(&tableName); err == nil {
+			tableNames = append(tableNames, tableName)
+		}
+	}
+	tables.Close()
+
+	// 如果指定了 talker，只搜索对应的表
+	talkerFilter := make(map[string]bool)
+	if q.Talker != "" {
+		for _, t := range strings.Split(q.Talker, ",") {
+			talkerFilter[darwinTableName(strings.TrimSpace(t))] = true
+		}
+	}
+	senders := splitList(q.Sender)
+	cond, condArgs := matchConditions(q, "msgContent", "messageType")
+
+	var msgs []*model.Message
+	for _, tableName := range tableNames {
+		if len(talkerFilter) > 0 && !talkerFilter[tableName] {
+			continue
+		}
+		talker, ok := talkerMD5Map[strings.TrimPrefix(tableName, "Chat_")]
+		if !ok {
+			continue
+		}
+
+		var sb strings.Builder
+		var args []interface{}
+		sb.WriteString(fmt.Sprintf("SELECT mesLocalID, msgCreateTime, IFNULL(msgContent, ''), messageType, mesDes FROM %s WHERE 1=1", tableName))
+		sb.WriteString(cond)
+		args = append(args, condArgs...)
+
+		if !q.StartTime.IsZero() {
+			sb.WriteString(" AND msgCreateTime >= ?")
+			args = append(args, q.StartTime.Unix())
+		}
+		if !q.EndTime.IsZero() {
+			sb.WriteString(" AND msgCreateTime <= ?")
+			args = append(args, q.EndTime.Unix())
+		}
+		if q.MsgType > 0 {
+			sb.WriteString(" AND messageType = ?")
+			args = append(args, q.MsgType)
+		}
+
+		sb.WriteString(" ORDER BY msgCreateTime DESC LIMIT 1000")
+
+		rows, err := db.QueryContext(ctx, sb.String(), args...)
+		if err != nil {
+			continue
+		}
+		for rows.Next() {
+			var msg model.MessageDarwinV3
+			if err := rows.Scan(&msg.MesLocalID, &msg.MsgCreateTime, &msg.MsgContent, &msg.MessageType, &msg.MesDes); err != nil {
+				continue
+			}
+			wrapped := msg.Wrap(talker)
+			if len(senders) > 0 && !slices.Contains(senders, wrapped.Sender) {
+				continue
+			}
+			msgs = append(msgs, wrapped)
+		}
+		rows.Close()
+	}
+	return msgs, nil
+}
+
+// queryDarwinContext 复用游标查询获取 macOS 消息的上下文
+func (r *Repository) queryDarwinContext(ctx context.Context, db *sql.DB, tableName, talker string, seq int64, before, after int) ([]*model.Message, error) {
+	q := types.MessageQuery{
+		StartTime: time.Unix(0, 0),
+		EndTime:   time.Now(),
+	}
+
+	// 锚点及之前的消息 (seq <= 锚点)
+	q.Limit = before + 1
+	q.Cursor = &types.Cursor{Seq: seq + 1, Direction: types.CursorPrev}
+	msgs, err := r.queryDarwinMessages(ctx, db, tableName, talker, q)
+	if err != nil {
+		return nil, err
+	}
+
+	// 锚点之后的消息
+	if after > 0 {
+		q.Limit = after
+		q.Cursor = &types.Cursor{Seq: seq, Direction: types.CursorNext}
+		next, err := r.queryDarwinMessages(ctx, db, tableName, talker, q)
+		if err != nil {
+			return msgs, nil
+		}
+		msgs = append(msgs, next...)
+	}
+	return msgs, nil
+}
+
 func (r *Repository) queryV4Context(ctx context.Context, db *sql.DB, tableName, talker string, seq int64, before, after int) ([]*model.Message, error) {
 	var msgs []*model.Message
 
@@ -29,12 +29,22 @@ func (r *Repository) GetSessions(ctx context.Context, q types.SessionQuery) ([]*
 		return nil, err
 	}
 
+	// 搜索需要匹配联系人的名称和拼音，标签筛选需要联系人的标签，先取出全部会话，在内存中过滤再分页；
+	// 有隐藏的会话时同样在过滤后分页
+	filtered := q.Search != "" || q.Label != "" || r.hasHiddenSessions()
+	raw := q
+	if filtered {
+		raw.Limit, raw.Offset = 0, 0
+	}
+
 	// 1. 获取基础会话数据
-	sessions, err := r.queryRawSessions(ctx, db, q)
+	sessions, err := r.queryRawSessions(ctx, db, raw)
 	if err != nil {
 		return nil, err
 	}
 
+	sessions = r.visibleSessions(sessions)
+
 	// 2. 丰富会话信息（头像、昵称等）
 	if len(sessions) > 0 {
 		if err := r.enrichSessions(ctx, sessions); err != nil {
@@ -42,39 +52,73 @@ func (r *Repository) GetSessions(ctx context.Context, q types.SessionQuery) ([]*
 		}
 	}
 
+	// 3. 按名称、拼音或标签过滤
+	if q.Search != "" {
+		matched := make([]*model.Session, 0, len(sessions))
+		for _, s := range sessions {
+			if matchSearch(q.Search, s.NickName, s.UserName) {
+				matched = append(matched, s)
+			}
+		}
+		sessions = matched
+	}
+	if q.Label != "" {
+		if sessions, err = r.filterSessionsByLabel(ctx, sessions, q.Label); err != nil {
+			return nil, err
+		}
+	}
+	if filtered {
+		sessions = paginate(sessions, q.Limit, q.Offset)
+	}
+
 	return sessions, nil
 }
 
-// DeleteSession 删除会话
-func (r *Repository) DeleteSession(ctx context.Context, username string) error {
-	dbPath, err := r.router.GetSessionDBPath()
-	if err != nil {
-		return err
-	}
-	db, err := r.pool.GetConnection(dbPath)
-	if err != nil {
-		return err
+// SetHiddenSessions 设置用户删除的会话。删除只在会话列表中隐藏，不修改数据库文件：
+// 解密后的数据库在快照之间共享，并以只读、不可变的方式打开。
+func (r *Repository) SetHiddenSessions(usernames []string) {
+	hidden := make(map[string]bool, len(usernames))
+	for _, name := range usernames {
+		hidden[name] = true
 	}
+	r.hiddenMu.Lock()
+	r.hidden = hidden
+	r.hiddenMu.Unlock()
+}
 
-	var query string
-	if r.isTableExist(db, "SessionTable") {
-		// V4
-		query = "DELETE FROM SessionTable WHERE username = ?"
-	} else {
-		// V3
-		query = "DELETE FROM Session WHERE strUsrName = ?"
+// visibleSessions 去掉被隐藏的会话
+func (r *Repository) visibleSessions(sessions []*model.Session) []*model.Session {
+	r.hiddenMu.RLock()
+	defer r.hiddenMu.RUnlock()
+	if len(r.hidden) == 0 {
+		return sessions
 	}
+	out := sessions[:0]
+	for _, s := range sessions {
+		if !r.hidden[s.UserName] {
+			out = append(out, s)
+		}
+	}
+	return out
+}
 
-	_, err = db.ExecContext(ctx, query, username)
-	return err
+// hasHiddenSessions 判断是否有被隐藏的会话
+func (r *Repository) hasHiddenSessions() bool {
+	r.hiddenMu.RLock()
+	defer r.hiddenMu.RUnlock()
+	return len(r.hidden) > 0
 }
 
 // queryRawSessions 根据数据库版本路由并执行查询
 func (r *Repository) queryRawSessions(ctx context.Context, db *sql.DB, q types.SessionQuery) ([]*model.Session, error) {
-	if r.isTableExist(db, "SessionTable") {
+	switch r.version() {
+	case model.WeChatDarwinV3:
+		return r.queryDarwinSessions(ctx, db, q)
+	case model.WeChatV3:
+		return r.queryV3Sessions(ctx, db, q)
+	default:
 		return r.queryV4Sessions(ctx, db, q)
 	}
-	return r.queryV3Sessions(ctx, db, q)
 }
 
 // enrichSessions 批量填充会话的头像和昵称信息
@@ -132,12 +176,15 @@ func (r *Repository) getContactProfiles(ctx context.Context, usernames []string)
 	}
 
 	var query string
-	if r.isTableExist(db, "contact") {
-		// V4
-		query = fmt.Sprintf("SELECT username, remark, nick_name, small_head_url, big_head_url FROM contact WHERE username IN (%s)", placeholders)
-	} else {
-		// V3
+	darwin := false
+	switch r.version() {
+	case model.WeChatDarwinV3:
+		darwin = true
+		query = fmt.Sprintf("SELECT m_nsUsrName, m_nsRemark, nickname, m_nsHeadImgUrl, m_nsHeadHDImgUrl FROM WCContact WHERE m_nsUsrName IN (%s)", placeholders)
+	case model.WeChatV3:
 		query = fmt.Sprintf("SELECT UserName, Remark, NickName, SmallHeadImgUrl, BigHeadImgUrl FROM Contact WHERE UserName IN (%s)", placeholders)
+	default:
+		query = fmt.Sprintf("SELECT username, remark, nick_name, small_head_url, big_head_url FROM contact WHERE username IN (%s)", placeholders)
 	}
 
 	rows, err := db.QueryContext(ctx, query, args...)
@@ -162,9 +209,47 @@ func (r *Repository) getContactProfiles(ctx context.Context, usernames []string)
 			BigHeadURL:   bigHeadURL.String,
 		}
 	}
+
+	// macOS 的群聊保存在独立的 group_new.db 中，需要单独补充
+	if darwin {
+		r.fillDarwinGroupProfiles(ctx, placeholders, args, profiles)
+	}
 	return profiles, nil
 }
 
+// fillDarwinGroupProfiles 从 macOS 的 GroupContact 表补充群聊的名称和头像
+func (r *Repository) fillDarwinGroupProfiles(ctx context.Context, placeholders string, args []interface{}, profiles map[string]contactProfile) {
+	dbPath, err := r.router.GetChatRoomDBPath()
+	if err != nil {
+		return
+	}
+	db, err := r.pool.GetConnection(dbPath)
+	if err != nil || !r.isTableExist(db, "GroupContact") {
+		return
+	}
+
+	query := fmt.Sprintf("SELECT m_nsUsrName, m_nsRemark, nickname, m_nsHeadImgUrl, m_nsHeadHDImgUrl FROM GroupContact WHERE m_nsUsrName IN (%s)", placeholders)
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var username string
+		var remark, nickName, smallHeadURL, bigHeadURL sql.NullString
+		if err := rows.Scan(&username, &remark, &nickName, &smallHeadURL, &bigHeadURL); err != nil {
+			return
+		}
+		profiles[username] = contactProfile{
+			Remark:       remark.String,
+			NickName:     nickName.String,
+			SmallHeadURL: smallHeadURL.String,
+			BigHeadURL:   bigHeadURL.String,
+		}
+	}
+}
+
 // queryV4Sessions V4 版本查询逻辑
 func (r *Repository) queryV4Sessions(ctx context.Context, db *sql.DB, q types.SessionQuery) ([]*model.Session, error) {
 	baseQuery := `SELECT username, summary, last_timestamp, last_msg_sender, last_sender_display_name FROM SessionTable WHERE username != '@placeholder_foldgroup'`
@@ -233,6 +318,40 @@ func (r *Repository) queryV3Sessions(ctx context.Context, db *sql.DB, q types.Se
 	return sessions, nil
 }
 
+// queryDarwinSessions macOS 版本查询逻辑
+func (r *Repository) queryDarwinSessions(ctx context.Context, db *sql.DB, q types.SessionQuery) ([]*model.Session, error) {
+	baseQuery := `SELECT m_nsUserName, m_uLastTime FROM SessionAbstract WHERE m_nsUserName != '@placeholder_foldgroup'`
+	orderBy := `ORDER BY m_uLastTime DESC`
+
+	var query string
+	var args []interface{}
+
+	if q.Keyword != "" {
+		query = fmt.Sprintf("%s AND m_nsUserName = ? %s", baseQuery, orderBy)
+		args = []interface{}{q.Keyword}
+	} else {
+		query = fmt.Sprintf("%s %s", baseQuery, orderBy)
+	}
+
+	query = r.appendPagination(query, q.Limit, q.Offset)
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*model.Session
+	for rows.Next() {
+		var s model.SessionDarwinV3
+		if err := rows.Scan(&s.M_nsUserName, &s.M_uLastTime); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s.Wrap())
+	}
+	return sessions, nil
+}
+
 // appendPagination 辅助方法：追加分页 SQL
 func (r *Repository) appendPagination(query string, limit, offset int) string {
 	if limit > 0 {
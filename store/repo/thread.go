@@ -0,0 +1,136 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/afumu/wetrace/internal/model"
+	"github.com/afumu/wetrace/store/types"
+)
+
+// maxThreadDepth 限制向上查找回复链的层数，防止异常数据形成环
+const maxThreadDepth = 256
+
+// findByServerIDs 在会话的全部分片中按服务端 ID 查找消息，返回 ID 到消息的映射。
+// 直接查询分片而不经过 GetMessages，避免再次解析回复关系。
+func (r *Repository) findByServerIDs(ctx context.Context, talker string, ids []int64) (map[int64]*model.Message, error) {
+	q := types.MessageQuery{
+		StartTime: time.Unix(0, 0),
+		EndTime:   time.Now(),
+		Talker:    talker,
+		ServerIDs: ids,
+	}
+	targets := r.router.Resolve(q.StartTime, q.EndTime, talker)
+	if len(targets) == 0 {
+		return nil, nil
+	}
+	msgs, err := r.queryAllMessageShards(ctx, targets, q)
+	if err != nil {
+		return nil, err
+	}
+	found := make(map[int64]*model.Message, len(msgs))
+	for _, m := range msgs {
+		// 撤回通知可能与原消息使用相同的 ID，优先保留原消息
+		if prev, ok := found[m.ServerID]; ok && !isRevokeNotice(prev) {
+			continue
+		}
+		found[m.ServerID] = m
+	}
+	return found, nil
+}
+
+// resolveReplies 将引用消息中的 svrid 解析为同一会话中原消息的序号，填入 ReplyTo
+func (r *Repository) resolveReplies(ctx context.Context, msgs []*model.Message) error {
+	byTalker := make(map[string][]int64)
+	for _, m := range msgs {
+		if id := m.ReferServerID(); id != 0 && m.Talker != "" {
+			byTalker[m.Talker] = append(byTalker[m.Talker], id)
+		}
+	}
+
+	for talker, ids := range byTalker {
+		found, err := r.findByServerIDs(ctx, talker, ids)
+		if err != nil {
+			return err
+		}
+		for _, m := range msgs {
+			if m.Talker != talker {
+				continue
+			}
+			if orig, ok := found[m.ReferServerID()]; ok && orig.Seq != m.Seq {
+				m.ReplyTo = orig.Seq
+			}
+		}
+	}
+	return nil
+}
+
+// messageAt 返回会话中指定序号的消息，不存在时返回 nil
+func (r *Repository) messageAt(ctx context.Context, talker string, seq int64) (*model.Message, error) {
+	page, err := r.GetMessagePage(ctx, types.MessageQuery{
+		StartTime: time.Unix(0, 0),
+		EndTime:   time.Now(),
+		Talker:    talker,
+		Limit:     1,
+		Cursor:    &types.Cursor{Seq: seq - 1, Direction: types.CursorNext},
+		Annotate:  true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(page.Items) == 0 || page.Items[0].Seq != seq {
+		return nil, nil
+	}
+	return page.Items[0], nil
+}
+
+// GetThread 返回包含指定消息的整棵回复树，消息不存在时返回 nil。
+// 先沿 ReplyTo 向上找到根消息，再遍历根消息之后的会话消息，按 ReplyTo 挂到对应的父消息下。
+func (r *Repository) GetThread(ctx context.Context, talker string, seq int64) (*model.ThreadNode, error) {
+	root, err := r.messageAt(ctx, talker, seq)
+	if err != nil {
+		return nil, err
+	}
+	if root == nil {
+		return nil, nil
+	}
+	for depth := 0; root.ReplyTo != 0 && depth < maxThreadDepth; depth++ {
+		parent, err := r.messageAt(ctx, talker, root.ReplyTo)
+		if err != nil {
+			return nil, err
+		}
+		if parent == nil {
+			break
+		}
+		root = parent
+	}
+
+	children := make(map[int64][]*model.Message)
+	err = r.IterateMessages(ctx, types.MessageQuery{
+		StartTime: root.Time,
+		EndTime:   time.Now(),
+		Talker:    talker,
+		Annotate:  true,
+	}, func(m *model.Message) error {
+		if m.ReplyTo != 0 && m.Seq > root.Seq {
+			children[m.ReplyTo] = append(children[m.ReplyTo], m)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var build func(m *model.Message) *model.ThreadNode
+	build = func(m *model.Message) *model.ThreadNode {
+		node := &model.ThreadNode{Message: m}
+		replies := children[m.Seq]
+		// 每条消息的回复只展开一次，防止异常数据形成环
+		delete(children, m.Seq)
+		for _, child := range replies {
+			node.Replies = append(node.Replies, build(child))
+		}
+		return node
+	}
+	return build(root), nil
+}
@@ -0,0 +1,56 @@
+package repo
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+
+	"github.com/afumu/wetrace/internal/model"
+	"github.com/afumu/wetrace/store/types"
+)
+
+// GetVideos 列出视频消息，按时间倒序，并根据本地文件记录补全大小和路径。
+// Total 等合计为分页前的结果。
+func (r *Repository) GetVideos(ctx context.Context, q types.VideoQuery) (*model.VideoCatalog, error) {
+	var videos []*model.VideoItem
+	mq := types.MessageQuery{Talker: q.Talker, StartTime: q.StartTime, EndTime: q.EndTime}
+	err := r.iterateSessionMessages(ctx, mq, model.MessageTypeVideo, func(m *model.Message) error {
+		if v := model.NewVideoItem(m); v != nil {
+			videos = append(videos, v)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	md5s := make([]string, 0, len(videos))
+	for _, v := range videos {
+		md5s = append(md5s, v.MD5)
+	}
+	medias, err := r.getMediasByMD5(ctx, "video", md5s)
+	if err != nil {
+		return nil, err
+	}
+
+	catalog := &model.VideoCatalog{Items: []*model.VideoItem{}}
+	for _, v := range videos {
+		if m, ok := medias[v.MD5]; ok {
+			v.Present = true
+			v.Path = filepath.ToSlash(m.Path)
+			if m.Size > 0 {
+				v.Size = m.Size
+			}
+		}
+		catalog.Items = append(catalog.Items, v)
+		catalog.TotalSize += v.Size
+		catalog.TotalDuration += v.Duration
+	}
+	sort.SliceStable(catalog.Items, func(i, j int) bool { return catalog.Items[i].Time.After(catalog.Items[j].Time) })
+
+	catalog.Total = len(catalog.Items)
+	if catalog.Items = paginate(catalog.Items, q.Limit, q.Offset); catalog.Items == nil {
+		catalog.Items = []*model.VideoItem{}
+	}
+	return catalog, nil
+}
@@ -2,6 +2,7 @@ package store
 
 import (
 	"context"
+	"time"
 
 	"github.com/afumu/wetrace/internal/model"
 	"github.com/afumu/wetrace/store/types"
@@ -13,13 +14,21 @@ import (
 type Store interface {
 	// 消息操作
 	GetMessages(ctx context.Context, query types.MessageQuery) ([]*model.Message, error)
+	GetMessagePage(ctx context.Context, query types.MessageQuery) (*model.MessagePage, error)
+	IterateMessages(ctx context.Context, query types.MessageQuery, fn func(*model.Message) error) error
 	SearchGlobalMessages(ctx context.Context, query types.MessageQuery) ([]*model.Message, error)
+	GetRevokedMessages(ctx context.Context, query types.MessageQuery) ([]*model.Revocation, error)
+	GetThread(ctx context.Context, talker string, seq int64) (*model.ThreadNode, error)
+	GetForwardRecord(ctx context.Context, talker string, seq int64) (*model.ForwardRecord, error)
 
 	// 联系人操作
 	GetContacts(ctx context.Context, query types.ContactQuery) ([]*model.Contact, error)
 	GetChatRooms(ctx context.Context, query types.ChatRoomQuery) ([]*model.ChatRoom, error)
 	GetSessions(ctx context.Context, query types.SessionQuery) ([]*model.Session, error)
-	DeleteSession(ctx context.Context, username string) error
+	SetHiddenSessions(usernames []string) // 设置用户删除 (隐藏) 的会话，不修改数据库
+	GetLabels(ctx context.Context) ([]*model.Label, error)
+	GetMembershipHistory(ctx context.Context, chatroom string) ([]*model.MembershipEvent, error)
+	GetRosterAt(ctx context.Context, chatroom string, at time.Time, events []*model.MembershipEvent) ([]*model.RosterMember, error)
 
 	// 媒体操作
 	GetMedia(ctx context.Context, mediaType string, key string) (*model.Media, error)
@@ -32,8 +41,9 @@ type Store interface {
 	GetMessageTypeDistribution(ctx context.Context, sessionID string) ([]*model.MessageTypeStat, error)
 	GetMemberActivity(ctx context.Context, sessionID string) ([]*model.MemberActivity, error)
 	GetRepeatAnalysis(ctx context.Context, sessionID string) ([]*model.RepeatStat, error)
-	GetPersonalTopContacts(ctx context.Context, limit int) ([]*model.PersonalTopContact, error)
+	GetPersonalTopContacts(ctx context.Context, limit int, label string) ([]*model.PersonalTopContact, error)
 	GetDashboardData(ctx context.Context) (*model.DashboardData, error)
+	GetMediaSpread(ctx context.Context, query types.MediaSpreadQuery) (*model.MediaSpreadReport, error)
 
 	// 搜索操作
 	SearchMessages(ctx context.Context, query types.MessageQuery) (*model.SearchResult, error)
@@ -45,12 +55,41 @@ type Store interface {
 	// 客户联系提醒
 	GetNeedContactList(ctx context.Context, days int) ([]*model.NeedContactItem, error)
 
+	// 转账和红包账目
+	GetLedger(ctx context.Context, query types.MessageQuery) (*model.Ledger, error)
+
+	// 位置消息
+	GetLocations(ctx context.Context, query types.MessageQuery) ([]*model.LocationPoint, error)
+
+	// 链接库
+	GetLinks(ctx context.Context, query types.LinkQuery) (*model.LinkLibrary, error)
+
+	// 文件附件
+	GetFiles(ctx context.Context, query types.FileQuery) (*model.FileCatalog, error)
+
+	// 视频
+	GetVideos(ctx context.Context, query types.VideoQuery) (*model.VideoCatalog, error)
+
+	// 数据库诊断
+	GetDiagnostics(ctx context.Context, check string) (*model.Diagnostics, error)
+
 	// Watch 注册文件系统事件的回调函数
 	Watch(group string, callback func(event fsnotify.Event) error) error
 
+	// Version 返回识别到的微信数据版本 (model.WeChatV3 / WeChatV4 / WeChatDarwinV3)
+	Version() string
+
+	// Revision 返回索引版本，数据目录切换或消息分片被刷新后递增
+	Revision() uint64
+
 	// Reload 重新加载存储（重建索引、刷新连接等）
 	Reload() error
 
+	// SwapGeneration 在新的快照目录中调用 fill 写入数据 (例如解密)，成功后原子地切换到该目录。
+	// prev 为当前的数据目录，fill 期间保持不变，可作为增量写入的基础。
+	// 切换前的查询继续使用旧数据，旧数据在所有查询结束后关闭并删除。
+	SwapGeneration(fill func(dir, prev string) error) error
+
 	// 生命周期管理
 	Close() error
 }
@@ -3,150 +3,300 @@ package store
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/afumu/wetrace/internal/model"
 	"github.com/afumu/wetrace/store/bind"
 	"github.com/afumu/wetrace/store/core"
-	"github.com/afumu/wetrace/store/repo"
-	"github.com/afumu/wetrace/store/strategy"
+	"github.com/afumu/wetrace/store/fts"
 	"github.com/afumu/wetrace/store/types"
 	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog/log"
 )
 
 // DefaultStore 是 Store 接口的默认实现
 type DefaultStore struct {
-	pool    *core.ConnectionPool
-	router  *bind.TimelineRouter
+	workDir string
+	gen     atomic.Pointer[generation] // 当前生效的数据目录
+	swapMu  sync.Mutex                 // 串行化 Reload / SwapGeneration
 	watcher *core.Watcher
-	repo    *repo.Repository
+	refresh *core.Debouncer // 合并同一文件的连续事件
+	watched []string        // 当前 generation 中被额外监控的消息目录
+	rev     atomic.Uint64   // 索引版本，切换 generation 或刷新分片后递增
+	fts     *fts.Index
+	ftsMu   sync.Mutex // 新旧 generation 共用同一个全文索引，同步需串行
+
+	concurrency int // 跨分片查询的并发数，切换 generation 时沿用
+
+	hiddenMu sync.Mutex // 保证隐藏会话的设置不会在切换 generation 时丢失
+	hidden   []string   // 用户删除的会话，切换 generation 时沿用
 }
 
 // NewStore 初始化一个新的存储实例
 func NewStore(baseDir string) (*DefaultStore, error) {
-	// 1. 初始化核心组件
-	pool := core.NewConnectionPool(baseDir)
+	// 1. 确定数据目录：存在同步快照时使用快照，否则直接使用工作目录
+	dataDir := resolveDataDir(baseDir)
+	cleanSnapshots(baseDir, dataDir)
+
 	watcher, err := core.NewWatcher(baseDir)
 	if err != nil {
-		pool.CloseAll()
 		return nil, err
 	}
 
-	// 2. 策略层
-	strat := strategy.NewV4()
-	router := bind.NewTimelineRouter(baseDir, pool, strat)
-
-	// 3. 构建索引 (这一步可能比较耗时，但必须在启动时完成)
-	if err := router.RebuildIndex(context.Background()); err != nil {
-		pool.CloseAll()
+	// 2. 识别数据版本并构建索引 (这一步可能比较耗时，但必须在启动时完成)
+	g, err := openGeneration(context.Background(), dataDir)
+	if err != nil {
 		watcher.Stop()
-		return nil, fmt.Errorf("构建时间线索引失败: %w", err)
+		return nil, err
 	}
 
-	// 4. 初始化仓储
-	r := repo.New(router, pool)
+	s := &DefaultStore{
+		workDir: baseDir,
+		watcher: watcher,
+		refresh: core.NewDebouncer(refreshDelay),
+	}
+
+	// 全文索引是可选的：打开失败 (例如未启用 FTS5) 时搜索退回逐分片扫描。
+	// 索引保存在工作目录而非快照目录中，切换快照后只需增量更新
+	idx, err := fts.Open(filepath.Join(baseDir, fts.FileName))
+	if err != nil {
+		log.Warn().Err(err).Msg("全文索引不可用")
+	} else {
+		s.fts = idx
+	}
+	s.install(g)
 
-	// 5. 启动文件监听
+	// 3. 启动文件监听
 	watcher.Start()
 
-	// 注册自动刷新逻辑：当有新文件生成时，重建索引
-	watcher.AddCallback(func(event fsnotify.Event) {
-		if event.Op&fsnotify.Create == fsnotify.Create {
-			// 只有当新文件被策略识别为消息数据库时，才重建索引
-			if meta, ok := strat.Identify(event.Name); ok && meta.Type == strategy.Message {
-				_ = router.RebuildIndex(context.Background())
-			}
-		}
-	})
+	// 注册自动刷新逻辑：消息数据库被创建、修改、重命名或删除时，只刷新对应的分片
+	watcher.AddCallback(s.onFileEvent)
 
-	return &DefaultStore{
-		pool:    pool,
-		router:  router,
-		watcher: watcher,
-		repo:    r,
-	}, nil
+	// 首次构建全文索引可能耗时较长，放到后台进行
+	go s.syncFTSIndex()
+
+	return s, nil
 }
 
 func (s *DefaultStore) Close() error {
 	s.watcher.Stop()
-	return s.pool.CloseAll()
+	s.refresh.Stop()
+	if s.fts != nil {
+		_ = s.fts.Close()
+	}
+	return s.gen.Load().pool.CloseAll()
+}
+
+// acquire 获取当前 generation 的引用，使用完毕后需调用 release
+func (s *DefaultStore) acquire() *generation {
+	for {
+		if g := s.gen.Load(); g.acquire() {
+			return g
+		}
+	}
+}
+
+// install 配置新的 generation 并替换当前的 generation
+func (s *DefaultStore) install(next *generation) {
+	next.repo.SetShardConcurrency(s.concurrency)
+	if s.fts != nil {
+		next.repo.SetFTSIndex(s.fts)
+	}
+
+	s.hiddenMu.Lock()
+	next.repo.SetHiddenSessions(s.hidden)
+	old := s.gen.Swap(next)
+	s.hiddenMu.Unlock()
+	s.rev.Add(1)
+	s.watchMessageDirs(next)
+	if old == nil {
+		return
+	}
+	if old.router.Version() != next.router.Version() {
+		log.Info().Str("from", old.router.Version()).Str("to", next.router.Version()).Msg("微信数据版本发生变化")
+	}
+	// 切换到新的快照后，旧快照目录不再需要
+	old.retire(old.dir != next.dir && old.dir != s.workDir)
 }
 
 // --- 下面是 Store 接口的代理实现 ---
 
 func (s *DefaultStore) GetMessages(ctx context.Context, query types.MessageQuery) ([]*model.Message, error) {
-	return s.repo.GetMessages(ctx, query)
+	g := s.acquire()
+	defer g.release()
+	return g.repo.GetMessages(ctx, query)
+}
+
+func (s *DefaultStore) GetMessagePage(ctx context.Context, query types.MessageQuery) (*model.MessagePage, error) {
+	g := s.acquire()
+	defer g.release()
+	return g.repo.GetMessagePage(ctx, query)
+}
+
+func (s *DefaultStore) IterateMessages(ctx context.Context, query types.MessageQuery, fn func(*model.Message) error) error {
+	g := s.acquire()
+	defer g.release()
+	return g.repo.IterateMessages(ctx, query, fn)
 }
 
 func (s *DefaultStore) SearchGlobalMessages(ctx context.Context, query types.MessageQuery) ([]*model.Message, error) {
-	return s.repo.SearchGlobalMessages(ctx, query)
+	g := s.acquire()
+	defer g.release()
+	return g.repo.SearchGlobalMessages(ctx, query)
+}
+
+func (s *DefaultStore) GetRevokedMessages(ctx context.Context, query types.MessageQuery) ([]*model.Revocation, error) {
+	g := s.acquire()
+	defer g.release()
+	return g.repo.GetRevokedMessages(ctx, query)
+}
+
+func (s *DefaultStore) GetThread(ctx context.Context, talker string, seq int64) (*model.ThreadNode, error) {
+	g := s.acquire()
+	defer g.release()
+	return g.repo.GetThread(ctx, talker, seq)
+}
+
+func (s *DefaultStore) GetForwardRecord(ctx context.Context, talker string, seq int64) (*model.ForwardRecord, error) {
+	g := s.acquire()
+	defer g.release()
+	return g.repo.GetForwardRecord(ctx, talker, seq)
 }
 
 func (s *DefaultStore) GetContacts(ctx context.Context, query types.ContactQuery) ([]*model.Contact, error) {
-	return s.repo.GetContacts(ctx, query)
+	g := s.acquire()
+	defer g.release()
+	return g.repo.GetContacts(ctx, query)
 }
 
 func (s *DefaultStore) GetChatRooms(ctx context.Context, query types.ChatRoomQuery) ([]*model.ChatRoom, error) {
-	return s.repo.GetChatRooms(ctx, query)
+	g := s.acquire()
+	defer g.release()
+	return g.repo.GetChatRooms(ctx, query)
 }
 
 func (s *DefaultStore) GetSessions(ctx context.Context, query types.SessionQuery) ([]*model.Session, error) {
-	return s.repo.GetSessions(ctx, query)
+	g := s.acquire()
+	defer g.release()
+	return g.repo.GetSessions(ctx, query)
+}
+
+// SetHiddenSessions 设置不出现在会话列表中的会话，切换 generation 时沿用
+func (s *DefaultStore) SetHiddenSessions(usernames []string) {
+	s.hiddenMu.Lock()
+	defer s.hiddenMu.Unlock()
+	s.hidden = usernames
+	g := s.acquire()
+	defer g.release()
+	g.repo.SetHiddenSessions(usernames)
 }
 
-func (s *DefaultStore) DeleteSession(ctx context.Context, username string) error {
-	return s.repo.DeleteSession(ctx, username)
+func (s *DefaultStore) GetLabels(ctx context.Context) ([]*model.Label, error) {
+	g := s.acquire()
+	defer g.release()
+	return g.repo.GetLabels(ctx)
+}
+
+func (s *DefaultStore) GetMembershipHistory(ctx context.Context, chatroom string) ([]*model.MembershipEvent, error) {
+	g := s.acquire()
+	defer g.release()
+	return g.repo.GetMembershipHistory(ctx, chatroom)
+}
+
+func (s *DefaultStore) GetRosterAt(ctx context.Context, chatroom string, at time.Time, events []*model.MembershipEvent) ([]*model.RosterMember, error) {
+	g := s.acquire()
+	defer g.release()
+	return g.repo.GetRosterAt(ctx, chatroom, at, events)
 }
 
 func (s *DefaultStore) GetMedia(ctx context.Context, mediaType string, key string) (*model.Media, error) {
-	return s.repo.GetMedia(ctx, mediaType, key)
+	g := s.acquire()
+	defer g.release()
+	return g.repo.GetMedia(ctx, mediaType, key)
 }
 
 func (s *DefaultStore) GetHourlyActivity(ctx context.Context, sessionID string) ([]*model.HourlyStat, error) {
-	return s.repo.GetHourlyActivity(ctx, sessionID)
+	g := s.acquire()
+	defer g.release()
+	return g.repo.GetHourlyActivity(ctx, sessionID)
 }
 
 func (s *DefaultStore) GetDailyActivity(ctx context.Context, sessionID string) ([]*model.DailyStat, error) {
-	return s.repo.GetDailyActivity(ctx, sessionID)
+	g := s.acquire()
+	defer g.release()
+	return g.repo.GetDailyActivity(ctx, sessionID)
 }
 
 func (s *DefaultStore) GetWeekdayActivity(ctx context.Context, sessionID string) ([]*model.WeekdayStat, error) {
-	return s.repo.GetWeekdayActivity(ctx, sessionID)
+	g := s.acquire()
+	defer g.release()
+	return g.repo.GetWeekdayActivity(ctx, sessionID)
 }
 
 func (s *DefaultStore) GetMonthlyActivity(ctx context.Context, sessionID string) ([]*model.MonthlyStat, error) {
-	return s.repo.GetMonthlyActivity(ctx, sessionID)
+	g := s.acquire()
+	defer g.release()
+	return g.repo.GetMonthlyActivity(ctx, sessionID)
 }
 
 func (s *DefaultStore) GetMessageTypeDistribution(ctx context.Context, sessionID string) ([]*model.MessageTypeStat, error) {
-	return s.repo.GetMessageTypeDistribution(ctx, sessionID)
+	g := s.acquire()
+	defer g.release()
+	return g.repo.GetMessageTypeDistribution(ctx, sessionID)
 }
 
 func (s *DefaultStore) GetMemberActivity(ctx context.Context, sessionID string) ([]*model.MemberActivity, error) {
-	return s.repo.GetMemberActivity(ctx, sessionID)
+	g := s.acquire()
+	defer g.release()
+	return g.repo.GetMemberActivity(ctx, sessionID)
 }
 
 func (s *DefaultStore) GetRepeatAnalysis(ctx context.Context, sessionID string) ([]*model.RepeatStat, error) {
-	return s.repo.GetRepeatAnalysis(ctx, sessionID)
+	g := s.acquire()
+	defer g.release()
+	return g.repo.GetRepeatAnalysis(ctx, sessionID)
 }
 
-func (s *DefaultStore) GetPersonalTopContacts(ctx context.Context, limit int) ([]*model.PersonalTopContact, error) {
-	return s.repo.GetPersonalTopContacts(ctx, limit)
+func (s *DefaultStore) GetPersonalTopContacts(ctx context.Context, limit int, label string) ([]*model.PersonalTopContact, error) {
+	g := s.acquire()
+	defer g.release()
+	return g.repo.GetPersonalTopContacts(ctx, limit, label)
 }
 
 func (s *DefaultStore) GetDashboardData(ctx context.Context) (*model.DashboardData, error) {
-	return s.repo.GetDashboardData(ctx)
+	g := s.acquire()
+	defer g.release()
+	return g.repo.GetDashboardData(ctx)
+}
+
+func (s *DefaultStore) GetMediaSpread(ctx context.Context, query types.MediaSpreadQuery) (*model.MediaSpreadReport, error) {
+	g := s.acquire()
+	defer g.release()
+	return g.repo.GetMediaSpread(ctx, query)
 }
 
 func (s *DefaultStore) SearchMessages(ctx context.Context, query types.MessageQuery) (*model.SearchResult, error) {
-	return s.repo.SearchMessages(ctx, query)
+	g := s.acquire()
+	defer g.release()
+	return g.repo.SearchMessages(ctx, query)
 }
 
 func (s *DefaultStore) GetMessageContext(ctx context.Context, talker string, seq int64, before, after int) ([]*model.Message, error) {
-	return s.repo.GetMessageContext(ctx, talker, seq, before, after)
+	g := s.acquire()
+	defer g.release()
+	return g.repo.GetMessageContext(ctx, talker, seq, before, after)
 }
 
 func (s *DefaultStore) GetAnnualReport(ctx context.Context, year int) (*model.AnnualReport, error) {
-	return s.repo.GetAnnualReport(ctx, year)
+	g := s.acquire()
+	defer g.release()
+	return g.repo.GetAnnualReport(ctx, year)
 }
 
 func (s *DefaultStore) Watch(group string, callback func(event fsnotify.Event) error) error {
@@ -157,20 +307,211 @@ func (s *DefaultStore) Watch(group string, callback func(event fsnotify.Event) e
 }
 
 func (s *DefaultStore) GetNeedContactList(ctx context.Context, days int) ([]*model.NeedContactItem, error) {
-	return s.repo.GetNeedContactList(ctx, days)
+	g := s.acquire()
+	defer g.release()
+	return g.repo.GetNeedContactList(ctx, days)
+}
+
+func (s *DefaultStore) GetLedger(ctx context.Context, query types.MessageQuery) (*model.Ledger, error) {
+	g := s.acquire()
+	defer g.release()
+	return g.repo.GetLedger(ctx, query)
+}
+
+func (s *DefaultStore) GetLocations(ctx context.Context, query types.MessageQuery) ([]*model.LocationPoint, error) {
+	g := s.acquire()
+	defer g.release()
+	return g.repo.GetLocations(ctx, query)
+}
+
+func (s *DefaultStore) GetLinks(ctx context.Context, query types.LinkQuery) (*model.LinkLibrary, error) {
+	g := s.acquire()
+	defer g.release()
+	return g.repo.GetLinks(ctx, query)
 }
 
-// Reload 重新加载存储（重建索引、刷新连接等）
+func (s *DefaultStore) GetFiles(ctx context.Context, query types.FileQuery) (*model.FileCatalog, error) {
+	g := s.acquire()
+	defer g.release()
+	return g.repo.GetFiles(ctx, query)
+}
+
+func (s *DefaultStore) GetVideos(ctx context.Context, query types.VideoQuery) (*model.VideoCatalog, error) {
+	g := s.acquire()
+	defer g.release()
+	return g.repo.GetVideos(ctx, query)
+}
+
+func (s *DefaultStore) GetDiagnostics(ctx context.Context, check string) (*model.Diagnostics, error) {
+	g := s.acquire()
+	defer g.release()
+	return g.repo.GetDiagnostics(ctx, check)
+}
+
+// SetShardConcurrency 设置同时查询的分片数上限，n <= 0 时使用默认值
+func (s *DefaultStore) SetShardConcurrency(n int) {
+	s.concurrency = n
+	g := s.acquire()
+	defer g.release()
+	g.repo.SetShardConcurrency(n)
+}
+
+// Version 返回识别到的微信数据版本
+func (s *DefaultStore) Version() string {
+	g := s.acquire()
+	defer g.release()
+	return g.router.Version()
+}
+
+// Reload 重新加载存储（重新识别版本、重建索引、刷新连接等）。
+// 新的 generation 构建完成后才替换当前的，构建期间查询不受影响。
 func (s *DefaultStore) Reload() error {
-	// 1. 关闭所有现有连接（这将强制下次查询时重新打开连接）
-	if err := s.pool.CloseAll(); err != nil {
-		return fmt.Errorf("reload: close all connections failed: %w", err)
+	s.swapMu.Lock()
+	defer s.swapMu.Unlock()
+
+	next, err := openGeneration(context.Background(), s.gen.Load().dir)
+	if err != nil {
+		return fmt.Errorf("reload: %w", err)
+	}
+	s.install(next)
+
+	// 后台增量更新全文索引
+	go s.syncFTSIndex()
+
+	return nil
+}
+
+// SwapGeneration 在工作目录的 .snapshots 下创建新的快照目录，由 fill 写入数据后切换过去。
+// fill 失败或新数据无法加载时删除快照目录，当前数据保持不变。
+// swapMu 保证 fill 期间当前数据目录 (prev) 不会被替换或删除。
+func (s *DefaultStore) SwapGeneration(fill func(dir, prev string) error) error {
+	s.swapMu.Lock()
+	defer s.swapMu.Unlock()
+
+	root := filepath.Join(s.workDir, SnapshotDirName)
+	name := strconv.FormatInt(time.Now().UnixNano(), 10)
+	dir := filepath.Join(root, name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("创建快照目录失败: %w", err)
 	}
 
-	// 2. 重新构建时间线索引（扫描目录，重新发现文件）
-	if err := s.router.RebuildIndex(context.Background()); err != nil {
-		return fmt.Errorf("reload: rebuild index failed: %w", err)
+	if err := fill(dir, s.gen.Load().dir); err != nil {
+		_ = os.RemoveAll(dir)
+		return err
 	}
 
+	next, err := openGeneration(context.Background(), dir)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return err
+	}
+
+	// 先持久化当前快照再切换，保证重启后加载的是同一份数据
+	if err := writeCurrent(root, name); err != nil {
+		_ = next.pool.CloseAll()
+		_ = os.RemoveAll(dir)
+		return fmt.Errorf("记录当前快照失败: %w", err)
+	}
+	s.install(next)
+	log.Info().Str("dir", dir).Msg("已切换到新的数据快照")
+
+	go s.syncFTSIndex()
 	return nil
 }
+
+// syncFTSIndex 增量更新全文索引，失败只记录日志
+func (s *DefaultStore) syncFTSIndex() {
+	if s.fts == nil {
+		return
+	}
+	s.ftsMu.Lock()
+	defer s.ftsMu.Unlock()
+
+	g := s.acquire()
+	defer g.release()
+	if err := g.repo.SyncFTSIndex(context.Background()); err != nil {
+		log.Warn().Err(err).Msg("更新全文索引失败")
+		return
+	}
+	log.Info().Msg("全文索引已更新")
+}
+
+// refreshDelay 是同一文件事件的合并时间，数据库写入期间会连续产生大量 Write 事件
+const refreshDelay = 500 * time.Millisecond
+
+// Revision 返回索引版本，切换数据目录或刷新分片后递增。
+// 长时间打开的页面可以据此判断是否有新消息需要重新加载。
+func (s *DefaultStore) Revision() uint64 {
+	return s.rev.Load()
+}
+
+// watchMessageDirs 监控 generation 的消息目录 (fsnotify 不递归监控子目录)，并取消对旧目录的监控
+func (s *DefaultStore) watchMessageDirs(g *generation) {
+	for _, dir := range s.watched {
+		_ = s.watcher.Remove(dir)
+	}
+	s.watched = s.watched[:0]
+	for _, dir := range g.router.MessageDirs() {
+		if dir == s.workDir {
+			continue // 工作目录本身始终被监控
+		}
+		if err := s.watcher.Add(dir); err == nil {
+			s.watched = append(s.watched, dir)
+		}
+	}
+}
+
+// onFileEvent 处理数据目录中的文件事件，合并连续事件后只刷新受影响的消息分片
+func (s *DefaultStore) onFileEvent(event fsnotify.Event) {
+	if event.Op&(fsnotify.Create|fsnotify.Write|fsnotify.Rename|fsnotify.Remove) == 0 {
+		return
+	}
+	path := bind.DBPathOf(event.Name)
+
+	g := s.acquire()
+	defer g.release()
+
+	// 新建的消息子目录 (例如首次解密出 message/)：加入监控并重建索引
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		if !slices.Contains(g.router.MessageDirs(), path) {
+			return
+		}
+		s.refresh.Trigger(path, func() {
+			s.swapMu.Lock()
+			defer s.swapMu.Unlock()
+			g := s.acquire()
+			defer g.release()
+			if err := g.router.RebuildIndex(context.Background()); err != nil {
+				log.Warn().Err(err).Msg("重建时间线索引失败")
+				return
+			}
+			s.watchMessageDirs(g)
+			s.rev.Add(1)
+		})
+		return
+	}
+
+	if !inMessageDir(g, path) || !g.router.IsMessageFile(path) {
+		return
+	}
+	s.refresh.Trigger(path, func() {
+		g := s.acquire()
+		defer g.release()
+		// 合并期间可能已切换到其他数据目录
+		if !inMessageDir(g, path) {
+			return
+		}
+		if err := g.router.RefreshShard(context.Background(), path); err != nil {
+			log.Warn().Err(err).Str("path", path).Msg("刷新分片索引失败")
+			return
+		}
+		s.rev.Add(1)
+		// 新消息需要进入全文索引
+		go s.syncFTSIndex()
+	})
+}
+
+// inMessageDir 判断 path 是否直接位于 generation 的某个消息目录中
+func inMessageDir(g *generation, path string) bool {
+	return slices.Contains(g.router.MessageDirs(), filepath.Dir(path))
+}
@@ -6,11 +6,14 @@ import (
 	"database/sql"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/afumu/wetrace/internal/model"
 	"github.com/afumu/wetrace/store/types"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -50,6 +53,148 @@ func TestDefaultStore_Integration(t *testing.T) {
 	}
 }
 
+func TestDefaultStore_SwapGeneration(t *testing.T) {
+	workDir := t.TempDir()
+	writeContactDB(t, workDir, "old")
+
+	s, err := NewStore(workDir)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+	nickName := func(contacts []*model.Contact, err error) string {
+		if err != nil {
+			t.Fatalf("GetContacts failed: %v", err)
+		}
+		if len(contacts) != 1 {
+			t.Fatalf("expected 1 contact, got %d", len(contacts))
+		}
+		return contacts[0].NickName
+	}
+
+	// 切换前持有旧 generation 的引用，模拟进行中的查询
+	old := s.acquire()
+
+	if err := s.SwapGeneration(func(dir, _ string) error {
+		writeContactDB(t, dir, "new")
+		return nil
+	}); err != nil {
+		t.Fatalf("SwapGeneration failed: %v", err)
+	}
+
+	if got := nickName(s.GetContacts(ctx, types.ContactQuery{})); got != "new" {
+		t.Errorf("after swap: expected new, got %s", got)
+	}
+	if got := nickName(old.repo.GetContacts(ctx, types.ContactQuery{})); got != "old" {
+		t.Errorf("in-flight query: expected old, got %s", got)
+	}
+	old.release()
+
+	first := s.gen.Load().dir
+	if resolveDataDir(workDir) != first {
+		t.Errorf("CURRENT should point to %s", first)
+	}
+
+	// fill 失败时保持当前数据，并删除未完成的快照
+	var failed string
+	if err := s.SwapGeneration(func(dir, _ string) error {
+		failed = dir
+		return errors.New("decrypt failed")
+	}); err == nil {
+		t.Fatal("expected error from SwapGeneration")
+	}
+	if _, err := os.Stat(failed); !os.IsNotExist(err) {
+		t.Errorf("failed snapshot should be removed")
+	}
+	if s.gen.Load().dir != first {
+		t.Errorf("failed swap should keep current generation")
+	}
+
+	// 再次切换后，没有引用的旧快照立即被删除
+	if err := s.SwapGeneration(func(dir, prev string) error {
+		if prev != first {
+			t.Errorf("fill should receive the current data dir %s, got %s", first, prev)
+		}
+		writeContactDB(t, dir, "newer")
+		return nil
+	}); err != nil {
+		t.Fatalf("SwapGeneration failed: %v", err)
+	}
+	if _, err := os.Stat(first); !os.IsNotExist(err) {
+		t.Errorf("retired snapshot should be removed")
+	}
+	if got := nickName(s.GetContacts(ctx, types.ContactQuery{})); got != "newer" {
+		t.Errorf("expected newer, got %s", got)
+	}
+}
+
+func TestDefaultStore_WatchRefresh(t *testing.T) {
+	workDir := t.TempDir()
+	setupMockData(t, workDir)
+
+	s, err := NewStore(workDir)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	defer s.Close()
+	rev := s.Revision()
+
+	shardCount := func() int {
+		g := s.acquire()
+		defer g.release()
+		return len(g.router.GetShards())
+	}
+	if n := shardCount(); n != 1 {
+		t.Fatalf("expected 1 shard, got %d", n)
+	}
+
+	// 新的消息分片写入后，经过合并延迟只刷新该分片
+	db, err := sql.Open("sqlite3", filepath.Join(workDir, "message_1.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		"CREATE TABLE Timestamp (timestamp INTEGER)",
+		"INSERT INTO Timestamp VALUES (1675209600)", // 2023-02-01
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for shardCount() != 2 && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if n := shardCount(); n != 2 {
+		t.Fatalf("expected 2 shards after refresh, got %d", n)
+	}
+	if s.Revision() <= rev {
+		t.Errorf("revision should increase after refresh")
+	}
+}
+
+// writeContactDB 创建只包含一个联系人的 V4 contact.db
+func writeContactDB(t *testing.T, dir, nickName string) {
+	db, err := sql.Open("sqlite3", filepath.Join(dir, "contact.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for _, stmt := range []string{
+		"CREATE TABLE contact (username TEXT, local_type INTEGER, alias TEXT, remark TEXT, nick_name TEXT, small_head_url TEXT, big_head_url TEXT)",
+		fmt.Sprintf("INSERT INTO contact VALUES ('user1', 1, '', '', '%s', '', '')", nickName),
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
 func setupMockData(t *testing.T, dir string) {
 	// contact.db (Contacts, ChatRooms, Sessions)
 	p := filepath.Join(dir, "contact.db")
@@ -0,0 +1,49 @@
+package strategy
+
+import (
+	"regexp"
+
+	"github.com/afumu/wetrace/internal/model"
+)
+
+// DarwinV3 实现 macOS 微信 (3.x) 的策略接口。
+// 目录结构: Message/msg_N.db, Contact/wccontact_new2.db, Group/group_new.db,
+// Session/session_new.db, hlink/hldata.db
+type DarwinV3 struct {
+	patterns []filePattern
+}
+
+// NewDarwinV3 创建一个新的 macOS 策略实例
+func NewDarwinV3() *DarwinV3 {
+	return &DarwinV3{
+		patterns: []filePattern{
+			{Message, regexp.MustCompile(`(?i)^msg_([0-9]?[0-9])\.db$`)},
+			{Contact, regexp.MustCompile(`(?i)^wccontact_new2\.db$`)},
+			{ChatRoom, regexp.MustCompile(`(?i)^group_new\.db$`)},
+			{Session, regexp.MustCompile(`(?i)^session_new\.db$`)},
+			{Image, regexp.MustCompile(`(?i)^hldata\.db$`)},
+			{Video, regexp.MustCompile(`(?i)^hldata\.db$`)},
+			{File, regexp.MustCompile(`(?i)^hldata\.db$`)},
+		},
+	}
+}
+
+// Identify 检查文件名是否匹配任何已知模式
+func (s *DarwinV3) Identify(filename string) (FileMeta, bool) {
+	return identify(s.patterns, filename)
+}
+
+// IdentifyAs 判断文件是否承载指定类型的数据
+func (s *DarwinV3) IdentifyAs(filename string, target GroupType) (FileMeta, bool) {
+	return identifyAs(s.patterns, filename, target)
+}
+
+// Version 返回 macOS V3 的版本标识
+func (s *DarwinV3) Version() string {
+	return model.WeChatDarwinV3
+}
+
+// TalkerSharded macOS 的消息按会话散列到 msg_0.db ~ msg_9.db，每个分片都覆盖全部时间
+func (s *DarwinV3) TalkerSharded() bool {
+	return true
+}
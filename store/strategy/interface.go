@@ -12,6 +12,7 @@ const (
 	File
 	Voice
 	Session
+	ChatRoom
 )
 
 func (g GroupType) String() string {
@@ -30,6 +31,8 @@ func (g GroupType) String() string {
 		return "Voice"
 	case Session:
 		return "Session"
+	case ChatRoom:
+		return "ChatRoom"
 	default:
 		return "Unknown"
 	}
@@ -47,4 +50,17 @@ type Strategy interface {
 	// Identify 根据文件名对文件进行分类。
 	// 如果文件被识别，返回元数据和 true。
 	Identify(filename string) (FileMeta, bool)
+
+	// IdentifyAs 判断文件是否承载指定类型的数据。
+	// 同一个文件可能对应多种类型 (例如 V3 的 MicroMsg.db 同时包含联系人、群聊和会话)。
+	IdentifyAs(filename string, target GroupType) (FileMeta, bool)
+
+	// Version 返回该策略对应的数据版本，取值同 model.WeChatV3 / WeChatV4 / WeChatDarwinV3
+	Version() string
+}
+
+// TalkerSharder 由按会话 (而非时间) 切分消息库的策略实现。
+// 这类分片的时间范围相互重叠，路由时不能用下一个分片的开始时间推导结束时间。
+type TalkerSharder interface {
+	TalkerSharded() bool
 }
@@ -0,0 +1,83 @@
+package strategy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/afumu/wetrace/internal/model"
+)
+
+// entry 描述一个已注册的策略及其识别依据
+type entry struct {
+	version string
+	new     func() Strategy
+	markers []string // 相对工作目录的特征文件，任意一个存在即认为匹配
+}
+
+// registry 按识别优先级排列的已知策略
+var registry = []entry{
+	{
+		version: model.WeChatDarwinV3,
+		new:     func() Strategy { return NewDarwinV3() },
+		markers: []string{
+			filepath.Join("Message", "msg_0.db"),
+			filepath.Join("Contact", "wccontact_new2.db"),
+			filepath.Join("Session", "session_new.db"),
+		},
+	},
+	{
+		version: model.WeChatV4,
+		new:     func() Strategy { return NewV4() },
+		markers: []string{
+			filepath.Join("message", "message_0.db"),
+			filepath.Join("contact", "contact.db"),
+			filepath.Join("session", "session.db"),
+			"message_0.db",
+			"contact.db",
+		},
+	},
+	{
+		version: model.WeChatV3,
+		new:     func() Strategy { return NewV3() },
+		markers: []string{
+			filepath.Join("Multi", "MSG0.db"),
+			"MicroMsg.db",
+			"MSG0.db",
+			// 未去掉 Msg 目录的解密结果
+			filepath.Join("Msg", "Multi", "MSG0.db"),
+			filepath.Join("Msg", "MicroMsg.db"),
+		},
+	},
+}
+
+// Versions 返回所有已注册的版本标识 (按识别优先级)
+func Versions() []string {
+	versions := make([]string, 0, len(registry))
+	for _, e := range registry {
+		versions = append(versions, e.version)
+	}
+	return versions
+}
+
+// New 按版本标识创建策略
+func New(version string) (Strategy, error) {
+	for _, e := range registry {
+		if e.version == version {
+			return e.new(), nil
+		}
+	}
+	return nil, fmt.Errorf("未知的数据版本: %s", version)
+}
+
+// Detect 根据工作目录中的特征文件选择策略，无法识别时默认为 V4
+func Detect(baseDir string) Strategy {
+	for _, e := range registry {
+		for _, marker := range e.markers {
+			if _, err := os.Stat(filepath.Join(baseDir, marker)); err == nil {
+				return e.new()
+			}
+		}
+	}
+	return NewV4()
+}
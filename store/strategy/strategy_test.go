@@ -1,6 +1,8 @@
 package strategy
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -39,3 +41,162 @@ func TestV4_Identify(t *testing.T) {
 		})
 	}
 }
+
+func TestDarwinV3_Identify(t *testing.T) {
+	s := NewDarwinV3()
+
+	tests := []struct {
+		filename      string
+		expectedType  GroupType
+		expectedIndex string
+		expectMatch   bool
+	}{
+		{"msg_0.db", Message, "0", true},
+		{"msg_9.db", Message, "9", true},
+		{"wccontact_new2.db", Contact, "", true},
+		{"group_new.db", ChatRoom, "", true},
+		{"session_new.db", Session, "", true},
+		{"hldata.db", Image, "", true},
+		{"message_0.db", Unknown, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			meta, match := s.Identify(tt.filename)
+			if match != tt.expectMatch {
+				t.Errorf("match: expected %v, got %v", tt.expectMatch, match)
+			}
+			if match {
+				if meta.Type != tt.expectedType {
+					t.Errorf("type: expected %v, got %v", tt.expectedType, meta.Type)
+				}
+				if meta.Index != tt.expectedIndex {
+					t.Errorf("index: expected '%v', got '%v'", tt.expectedIndex, meta.Index)
+				}
+			}
+		})
+	}
+}
+
+func TestV3_Identify(t *testing.T) {
+	s := NewV3()
+
+	tests := []struct {
+		filename      string
+		expectedType  GroupType
+		expectedIndex string
+		expectMatch   bool
+	}{
+		{"MSG0.db", Message, "0", true},
+		{"MSG12.db", Message, "12", true},
+		{"MSG.db", Message, "", true},
+		{"MicroMsg.db", Contact, "", true},
+		{"HardLinkImage.db", Image, "", true},
+		{"HardLinkVideo.db", Video, "", true},
+		{"MediaMSG3.db", Voice, "3", true},
+		{"message_0.db", Unknown, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			meta, match := s.Identify(tt.filename)
+			if match != tt.expectMatch {
+				t.Errorf("match: expected %v, got %v", tt.expectMatch, match)
+			}
+			if match {
+				if meta.Type != tt.expectedType {
+					t.Errorf("type: expected %v, got %v", tt.expectedType, meta.Type)
+				}
+				if meta.Index != tt.expectedIndex {
+					t.Errorf("index: expected '%v', got '%v'", tt.expectedIndex, meta.Index)
+				}
+			}
+		})
+	}
+}
+
+func TestIdentifyAs(t *testing.T) {
+	// MicroMsg.db 同时承载联系人、群聊和会话
+	v3 := NewV3()
+	for _, target := range []GroupType{Contact, ChatRoom, Session} {
+		if _, ok := v3.IdentifyAs("MicroMsg.db", target); !ok {
+			t.Errorf("MicroMsg.db: expected match for %v", target)
+		}
+	}
+	if _, ok := v3.IdentifyAs("MicroMsg.db", Message); ok {
+		t.Errorf("MicroMsg.db: unexpected match for Message")
+	}
+
+	// V4 的 hardlink.db 同时承载图片、视频和文件
+	v4 := NewV4()
+	for _, target := range []GroupType{Image, Video, File} {
+		if _, ok := v4.IdentifyAs("hardlink.db", target); !ok {
+			t.Errorf("hardlink.db: expected match for %v", target)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, version := range Versions() {
+		s, err := New(version)
+		if err != nil {
+			t.Fatalf("New(%q): %v", version, err)
+		}
+		if s.Version() != version {
+			t.Errorf("New(%q).Version(): got %q", version, s.Version())
+		}
+	}
+	if _, err := New("unknown"); err == nil {
+		t.Errorf("New(unknown): expected error")
+	}
+}
+
+func TestDetect(t *testing.T) {
+	dir := t.TempDir()
+	if _, ok := Detect(dir).(*V4); !ok {
+		t.Errorf("empty dir: expected V4")
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, "Message"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "Message", "msg_0.db"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := Detect(dir).(*DarwinV3); !ok {
+		t.Errorf("darwin layout: expected DarwinV3")
+	}
+
+	v3Dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(v3Dir, "Multi"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(v3Dir, "Multi", "MSG0.db"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := Detect(v3Dir).(*V3); !ok {
+		t.Errorf("v3 layout: expected V3")
+	}
+
+	msgDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(msgDir, "Msg", "Multi"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(msgDir, "Msg", "Multi", "MSG0.db"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := Detect(msgDir).(*V3); !ok {
+		t.Errorf("v3 Msg/ layout: expected V3")
+	}
+
+	microDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(microDir, "Msg"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(microDir, "Msg", "MicroMsg.db"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := Detect(microDir).(*V3); !ok {
+		t.Errorf("v3 Msg/MicroMsg.db layout: expected V3")
+	}
+}
@@ -0,0 +1,45 @@
+package strategy
+
+import (
+	"regexp"
+
+	"github.com/afumu/wetrace/internal/model"
+)
+
+// V3 实现 Windows 微信 3.x 的策略接口。
+// 目录结构: Multi/MSG0.db ~ MSGn.db, MicroMsg.db (联系人、群聊、会话),
+// HardLinkImage.db / HardLinkVideo.db / HardLinkFile.db, Multi/MediaMSG0.db ~ MediaMSGn.db
+type V3 struct {
+	patterns []filePattern
+}
+
+// NewV3 创建一个新的 Windows V3 策略实例
+func NewV3() *V3 {
+	return &V3{
+		patterns: []filePattern{
+			{Message, regexp.MustCompile(`(?i)^MSG([0-9]?[0-9])?\.db$`)},
+			{Contact, regexp.MustCompile(`(?i)^MicroMsg\.db$`)},
+			{ChatRoom, regexp.MustCompile(`(?i)^MicroMsg\.db$`)},
+			{Session, regexp.MustCompile(`(?i)^MicroMsg\.db$`)},
+			{Image, regexp.MustCompile(`(?i)^HardLinkImage\.db$`)},
+			{Video, regexp.MustCompile(`(?i)^HardLinkVideo\.db$`)},
+			{File, regexp.MustCompile(`(?i)^HardLinkFile\.db$`)},
+			{Voice, regexp.MustCompile(`(?i)^MediaMSG([0-9]?[0-9])?\.db$`)},
+		},
+	}
+}
+
+// Identify 检查文件名是否匹配任何已知模式
+func (s *V3) Identify(filename string) (FileMeta, bool) {
+	return identify(s.patterns, filename)
+}
+
+// IdentifyAs 判断文件是否承载指定类型的数据
+func (s *V3) IdentifyAs(filename string, target GroupType) (FileMeta, bool) {
+	return identifyAs(s.patterns, filename, target)
+}
+
+// Version 返回 Windows V3 的版本标识
+func (s *V3) Version() string {
+	return model.WeChatV3
+}
@@ -3,22 +3,24 @@ package strategy
 import (
 	"regexp"
 	"strings"
+
+	"github.com/afumu/wetrace/internal/model"
 )
 
-type v4Pattern struct {
+type filePattern struct {
 	group GroupType
 	re    *regexp.Regexp
 }
 
 // V4 实现 WeChat V4 的策略接口
 type V4 struct {
-	patterns []v4Pattern
+	patterns []filePattern
 }
 
 // NewV4 创建一个新的策略实例
 func NewV4() *V4 {
 	return &V4{
-		patterns: []v4Pattern{
+		patterns: []filePattern{
 			{Message, regexp.MustCompile(`(?i)^message(_[0-9]?[0-9])?\.db$`)},
 			{Contact, regexp.MustCompile(`(?i)^contact\.db$`)},
 			{Image, regexp.MustCompile(`(?i)^hardlink\.db$`)},
@@ -26,17 +28,28 @@ func NewV4() *V4 {
 			{File, regexp.MustCompile(`(?i)^hardlink\.db$`)},
 			{Voice, regexp.MustCompile(`(?i)^media(_[0-9]?[0-9])?\.db$`)},
 			{Session, regexp.MustCompile(`(?i)^session\.db$`)},
-
-			// --- 兼容旧版 Windows 文件名 (可选，但也设为不区分大小写) ---
-			{Message, regexp.MustCompile(`(?i)^MSG([0-9]?[0-9])?\.db$`)},
-			{Contact, regexp.MustCompile(`(?i)^MicroMsg\.db$`)},
 		},
 	}
 }
 
 // Identify 检查文件名是否匹配任何已知模式
 func (s *V4) Identify(filename string) (FileMeta, bool) {
-	for _, p := range s.patterns {
+	return identify(s.patterns, filename)
+}
+
+// IdentifyAs 判断文件是否承载指定类型的数据
+func (s *V4) IdentifyAs(filename string, target GroupType) (FileMeta, bool) {
+	return identifyAs(s.patterns, filename, target)
+}
+
+// Version 返回 V4 的版本标识
+func (s *V4) Version() string {
+	return model.WeChatV4
+}
+
+// identify 按顺序匹配文件名模式，返回第一个命中的分类
+func identify(patterns []filePattern, filename string) (FileMeta, bool) {
+	for _, p := range patterns {
 		matches := p.re.FindStringSubmatch(filename)
 		if matches != nil {
 			meta := FileMeta{
@@ -52,3 +65,20 @@ func (s *V4) Identify(filename string) (FileMeta, bool) {
 	}
 	return FileMeta{Type: Unknown}, false
 }
+
+// identifyAs 只在指定类型的模式中匹配文件名
+func identifyAs(patterns []filePattern, filename string, target GroupType) (FileMeta, bool) {
+	for _, p := range patterns {
+		if p.group != target {
+			continue
+		}
+		if matches := p.re.FindStringSubmatch(filename); matches != nil {
+			meta := FileMeta{Type: target}
+			if len(matches) > 1 && matches[1] != "" {
+				meta.Index = strings.TrimPrefix(matches[1], "_")
+			}
+			return meta, true
+		}
+	}
+	return FileMeta{Type: Unknown}, false
+}
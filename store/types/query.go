@@ -2,6 +2,18 @@ package types
 
 import "time"
 
+// 游标分页方向
+const (
+	CursorNext = "next" // 取 seq 大于游标的消息 (向后翻页)
+	CursorPrev = "prev" // 取 seq 小于游标的消息 (向前翻页)
+)
+
+// Cursor 表示基于消息 seq 的游标分页位置
+type Cursor struct {
+	Seq       int64  // 上一页边界消息的 seq，0 表示从最早 (next) 或最新 (prev) 处开始
+	Direction string // CursorNext 或 CursorPrev
+}
+
 // MessageQuery 封装了查询消息的参数
 type MessageQuery struct {
 	StartTime time.Time
@@ -12,26 +24,82 @@ type MessageQuery struct {
 	MsgType   int // 消息类型筛选，0 表示不限
 	Limit     int
 	Offset    int
-	Reverse   bool // 是否按时间倒序排列
+	Reverse   bool        // 是否按时间倒序排列
+	Rank      bool        // 搜索结果是否按相关度排序 (仅全文索引可用)
+	Cursor    *Cursor     // 游标分页位置，设置后忽略 Offset
+	Expr      *SearchExpr // 解析后的搜索表达式，设置后按表达式匹配关键词 (Keyword 仅用于高亮)
+	Mode      string      // 搜索模式 (SearchModeRegex/SearchModeFuzzy)，为空时按关键词匹配
+	Distance  int         // 模糊搜索允许的最大编辑距离
+	ServerIDs []int64     // 只返回这些服务端消息 ID 的消息
+	Annotate  bool        // 是否标记撤回状态并解析引用回复，仅交互式浏览需要，批量遍历时不设置
+}
+
+// LinkQuery 封装了查询链接库的参数
+type LinkQuery struct {
+	StartTime time.Time
+	EndTime   time.Time
+	Talker    string // 会话，为空时汇总全部会话，多个会话可以用逗号分隔
+	Domain    string // 域名，同时匹配其子域名
+	Source    string // 来源 (公众号、视频号或小程序名称)，包含即匹配
+	Kind      string // 链接种类 (model.LinkKind*)，为空时不限
+	Limit     int    // 热门域名数量，0 表示默认 20 个
+}
+
+// FileQuery 封装了查询文件附件的参数
+type FileQuery struct {
+	StartTime time.Time
+	EndTime   time.Time
+	Talker    string // 会话，为空时汇总全部会话，多个会话可以用逗号分隔
+	Keyword   string // 文件名包含 Keyword
+	Ext       string // 扩展名，多个用逗号分隔，不区分大小写，可带点
+	MinSize   int64  // 最小字节数，0 表示不限
+	MaxSize   int64  // 最大字节数，0 表示不限
+	Limit     int
+	Offset    int
+}
+
+// VideoQuery 封装了查询视频的参数
+type VideoQuery struct {
+	StartTime time.Time
+	EndTime   time.Time
+	Talker    string // 会话，为空时汇总全部会话，多个会话可以用逗号分隔
+	Limit     int
+	Offset    int
+}
+
+// MediaSpreadQuery 封装了跨会话媒体去重分析的参数
+type MediaSpreadQuery struct {
+	StartTime   time.Time
+	EndTime     time.Time
+	Kind        string // 媒体种类 (image、video、file)，为空时不限
+	MD5         string // 只追踪该 md5 的媒体
+	MinSessions int    // 至少到达的会话数，0 表示默认 2 个 (指定 MD5 时不限)
+	Limit       int
+	Offset      int
 }
 
 // ContactQuery 封装了查询联系人的参数
 type ContactQuery struct {
-	Keyword string
+	Keyword string // 按账号或名称精确查找
+	Search  string // 搜索：名称包含 Search，或按拼音全拼/首字母匹配 (例如 zs、zhangsan 匹配 "张三")
+	Label   string // 标签 ID 或名称，只返回带有该标签的联系人
 	Limit   int
 	Offset  int
 }
 
 // ChatRoomQuery 封装了查询群聊的参数
 type ChatRoomQuery struct {
-	Keyword string
+	Keyword string // 按账号或名称精确查找
+	Search  string // 搜索：名称包含 Search，或按拼音全拼/首字母匹配 (例如 zs、zhangsan 匹配 "张三")
 	Limit   int
 	Offset  int
 }
 
 // SessionQuery 封装了查询会话的参数
 type SessionQuery struct {
-	Keyword string
+	Keyword string // 按账号或名称精确查找
+	Search  string // 搜索：名称包含 Search，或按拼音全拼/首字母匹配 (例如 zs、zhangsan 匹配 "张三")
+	Label   string // 标签 ID 或名称，只返回与带有该标签的联系人的会话
 	Limit   int
 	Offset  int
 }
@@ -0,0 +1,324 @@
+package types
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/afumu/wetrace/internal/model"
+)
+
+// 搜索模式
+const (
+	SearchModeKeyword = "keyword" // 关键词 (支持搜索语法，见 ParseSearch)
+	SearchModeRegex   = "regex"   // 正则表达式 (RE2 语法)
+	SearchModeFuzzy   = "fuzzy"   // 模糊匹配 (编辑距离)
+)
+
+// SearchTerm 是搜索表达式中的一个关键词
+type SearchTerm struct {
+	Text   string `json:"text"`
+	Phrase bool   `json:"phrase,omitempty"` // 带引号的精确短语
+}
+
+// SearchExpr 是解析后的搜索表达式。
+// Clauses 之间为 AND，同一个子句中的关键词为 OR；Excludes 中的关键词都不能出现。
+type SearchExpr struct {
+	Clauses  [][]SearchTerm
+	Excludes []SearchTerm
+	From     []string  // from: 发送者
+	In       []string  // in: 会话 (好友或群聊)
+	Types    []int     // type: 消息类型，多个时为 OR
+	After    time.Time // after: 不早于该时间
+	Before   time.Time // before: 早于该时间
+}
+
+// SearchParseError 描述搜索表达式中的语法错误
+type SearchParseError struct {
+	Pos     int    `json:"position"` // 出错位置 (按字符计，从 0 开始)
+	Token   string `json:"token"`
+	Message string `json:"message"`
+}
+
+func (e *SearchParseError) Error() string {
+	return fmt.Sprintf("搜索语法错误 (位置 %d, %q): %s", e.Pos, e.Token, e.Message)
+}
+
+// searchTypeNames 是 type: 支持的消息类型名称
+var searchTypeNames = map[string]int{
+	"text":     model.MessageTypeText,
+	"image":    model.MessageTypeImage,
+	"voice":    model.MessageTypeVoice,
+	"card":     model.MessageTypeCard,
+	"video":    model.MessageTypeVideo,
+	"emoji":    model.MessageTypeAnimation,
+	"location": model.MessageTypeLocation,
+	"file":     model.MessageTypeShare, // 文件、链接等都是分享类消息
+	"link":     model.MessageTypeShare,
+	"share":    model.MessageTypeShare,
+	"call":     model.MessageTypeVOIP,
+	"system":   model.MessageTypeSystem,
+}
+
+// searchDateLayouts 是 after: / before: 支持的日期格式
+var searchDateLayouts = []string{"2006", "2006-01", "2006-01-02", "2006/01", "2006/01/02"}
+
+// searchToken 是词法分析得到的一个词
+type searchToken struct {
+	pos    int
+	text   string // 原始文本
+	value  string // 去掉引号和前缀后的值
+	key    string // 过滤条件名 (from/in/type/after/before)，普通关键词为空
+	phrase bool
+	negate bool
+	or     bool
+}
+
+// ParseSearch 解析搜索表达式，例如
+//
+//	from:alice in:group@chatroom type:file after:2023-01 "exact phrase" -excluded OR other
+//
+// 相邻的关键词之间为 AND，OR 连接其两侧的关键词，以 - 开头的关键词表示排除。
+// 语法错误以 *SearchParseError 返回。
+func ParseSearch(input string) (*SearchExpr, error) {
+	tokens, err := lexSearch(input)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &SearchExpr{}
+	pendingOr := false
+	for i, tok := range tokens {
+		if tok.or {
+			if i == 0 || pendingOr {
+				return nil, &SearchParseError{Pos: tok.pos, Token: tok.text, Message: "OR 前缺少关键词"}
+			}
+			prev := tokens[i-1]
+			if prev.key != "" || prev.negate {
+				return nil, &SearchParseError{Pos: tok.pos, Token: tok.text, Message: "OR 只能连接关键词"}
+			}
+			pendingOr = true
+			continue
+		}
+
+		if tok.key != "" {
+			if pendingOr {
+				return nil, &SearchParseError{Pos: tok.pos, Token: tok.text, Message: "OR 只能连接关键词"}
+			}
+			if err := e.applyFilter(tok); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		term := SearchTerm{Text: tok.value, Phrase: tok.phrase}
+		switch {
+		case tok.negate:
+			if pendingOr {
+				return nil, &SearchParseError{Pos: tok.pos, Token: tok.text, Message: "OR 只能连接关键词"}
+			}
+			e.Excludes = append(e.Excludes, term)
+		case pendingOr:
+			last := len(e.Clauses) - 1
+			e.Clauses[last] = append(e.Clauses[last], term)
+			pendingOr = false
+		default:
+			e.Clauses = append(e.Clauses, []SearchTerm{term})
+		}
+	}
+	if pendingOr {
+		last := tokens[len(tokens)-1]
+		return nil, &SearchParseError{Pos: last.pos, Token: last.text, Message: "OR 后缺少关键词"}
+	}
+	if !e.After.IsZero() && !e.Before.IsZero() && !e.After.Before(e.Before) {
+		return nil, &SearchParseError{Pos: 0, Token: input, Message: "after: 必须早于 before:"}
+	}
+	return e, nil
+}
+
+// applyFilter 处理 from:/in:/type:/after:/before: 过滤条件
+func (e *SearchExpr) applyFilter(tok searchToken) error {
+	fail := func(msg string) error {
+		return &SearchParseError{Pos: tok.pos, Token: tok.text, Message: msg}
+	}
+	if tok.negate {
+		return fail("过滤条件不支持排除")
+	}
+	if tok.value == "" {
+		return fail(tok.key + ": 后缺少值")
+	}
+
+	switch tok.key {
+	case "from":
+		e.From = append(e.From, tok.value)
+	case "in":
+		e.In = append(e.In, tok.value)
+	case "type":
+		t, ok := searchTypeNames[strings.ToLower(tok.value)]
+		if !ok {
+			n, err := strconv.Atoi(tok.value)
+			if err != nil || n <= 0 {
+				names := make([]string, 0, len(searchTypeNames))
+				for name := range searchTypeNames {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+				return fail("未知的消息类型，可用: " + strings.Join(names, ", ") + " 或类型编号")
+			}
+			t = n
+		}
+		e.Types = append(e.Types, t)
+	case "after", "before":
+		t, ok := parseSearchDate(tok.value)
+		if !ok {
+			return fail("无法识别的日期，支持 2023、2023-01、2023-01-02")
+		}
+		// after:2023-01 表示从 2023 年 1 月开始，before:2023-01 表示 2023 年 1 月之前
+		if tok.key == "after" {
+			e.After = t
+		} else {
+			e.Before = t
+		}
+	}
+	return nil
+}
+
+// parseSearchDate 按年、月或日解析日期，返回该时间段的开始时间
+func parseSearchDate(s string) (time.Time, bool) {
+	for _, layout := range searchDateLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// lexSearch 将搜索表达式切分为词，处理引号、排除前缀和过滤条件
+func lexSearch(input string) ([]searchToken, error) {
+	runes := []rune(input)
+	var tokens []searchToken
+
+	for i := 0; i < len(runes); {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+
+		tok := searchToken{pos: i}
+		start := i
+		if runes[i] == '-' {
+			tok.negate = true
+			i++
+			if i == len(runes) || unicode.IsSpace(runes[i]) {
+				return nil, &SearchParseError{Pos: start, Token: "-", Message: "- 后缺少要排除的关键词"}
+			}
+		}
+
+		// 读取 key: 前缀 (仅识别已知的过滤条件，其余冒号按普通字符处理，例如网址)
+		wordStart := i
+		for i < len(runes) && !unicode.IsSpace(runes[i]) && runes[i] != '"' && runes[i] != ':' {
+			i++
+		}
+		if i < len(runes) && runes[i] == ':' {
+			switch key := strings.ToLower(string(runes[wordStart:i])); key {
+			case "from", "in", "type", "after", "before":
+				tok.key = key
+				i++
+				wordStart = i
+			}
+		}
+
+		if i < len(runes) && runes[i] == '"' && i == wordStart {
+			// 带引号的短语或过滤值
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			if end == len(runes) {
+				return nil, &SearchParseError{Pos: i, Token: string(runes[start:]), Message: "引号未闭合"}
+			}
+			tok.value = string(runes[i+1 : end])
+			tok.phrase = tok.key == ""
+			i = end + 1
+			if tok.phrase && strings.TrimSpace(tok.value) == "" {
+				return nil, &SearchParseError{Pos: start, Token: string(runes[start:i]), Message: "引号中缺少内容"}
+			}
+		} else {
+			for i < len(runes) && !unicode.IsSpace(runes[i]) {
+				i++
+			}
+			tok.value = string(runes[wordStart:i])
+		}
+		tok.text = string(runes[start:i])
+
+		if tok.text == "OR" {
+			tok.or = true
+		}
+		tokens = append(tokens, tok)
+	}
+	return tokens, nil
+}
+
+// HasKeywords 判断表达式中是否有需要匹配的关键词 (不含排除项)
+func (e *SearchExpr) HasKeywords() bool {
+	return len(e.Clauses) > 0
+}
+
+// Keywords 返回所有需要匹配的关键词，用于结果高亮
+func (e *SearchExpr) Keywords() []string {
+	var out []string
+	for _, clause := range e.Clauses {
+		for _, t := range clause {
+			out = append(out, t.Text)
+		}
+	}
+	return out
+}
+
+// Apply 将表达式中的过滤条件合并到消息查询中：
+// from:/in: 与已有的 Sender/Talker 取交集，after:/before: 收窄时间范围，关键词与 type: 由 Expr 在查询时编译。
+// 交集为空时不可能有匹配的消息，返回 false。
+func (e *SearchExpr) Apply(q *MessageQuery) bool {
+	q.Expr = e
+	q.Keyword = strings.Join(e.Keywords(), " ")
+	var ok bool
+	if len(e.From) > 0 {
+		if q.Sender, ok = intersectList(q.Sender, e.From); !ok {
+			return false
+		}
+	}
+	if len(e.In) > 0 {
+		if q.Talker, ok = intersectList(q.Talker, e.In); !ok {
+			return false
+		}
+	}
+	if !e.After.IsZero() && (q.StartTime.IsZero() || e.After.After(q.StartTime)) {
+		q.StartTime = e.After
+	}
+	if !e.Before.IsZero() {
+		// 查询的结束时间是闭区间，按秒比较
+		end := e.Before.Add(-time.Second)
+		if q.EndTime.IsZero() || end.Before(q.EndTime) {
+			q.EndTime = end
+		}
+	}
+	return true
+}
+
+// intersectList 返回逗号分隔的 existing 与 values 的交集，existing 为空时不限制；交集为空时返回 false
+func intersectList(existing string, values []string) (string, bool) {
+	if existing == "" {
+		return strings.Join(values, ","), true
+	}
+	var out []string
+	for _, v := range strings.Split(existing, ",") {
+		if slices.Contains(values, v) {
+			out = append(out, v)
+		}
+	}
+	return strings.Join(out, ","), len(out) > 0
+}
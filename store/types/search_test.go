@@ -0,0 +1,79 @@
+package types
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/afumu/wetrace/internal/model"
+)
+
+func TestParseSearch(t *testing.T) {
+	e, err := ParseSearch(`from:alice in:123@chatroom type:file after:2023-01 before:2023-03 "exact phrase" -spam 报销 OR 发票 https://a.com/x`)
+	if err != nil {
+		t.Fatalf("ParseSearch 失败: %v", err)
+	}
+
+	clauses := [][]SearchTerm{
+		{{Text: "exact phrase", Phrase: true}},
+		{{Text: "报销"}, {Text: "发票"}},
+		{{Text: "https://a.com/x"}},
+	}
+	if !reflect.DeepEqual(e.Clauses, clauses) {
+		t.Errorf("Clauses 不一致: %+v", e.Clauses)
+	}
+	if !reflect.DeepEqual(e.Excludes, []SearchTerm{{Text: "spam"}}) {
+		t.Errorf("Excludes 不一致: %+v", e.Excludes)
+	}
+	if !reflect.DeepEqual(e.From, []string{"alice"}) || !reflect.DeepEqual(e.In, []string{"123@chatroom"}) {
+		t.Errorf("From/In 不一致: %v %v", e.From, e.In)
+	}
+	if !reflect.DeepEqual(e.Types, []int{model.MessageTypeShare}) {
+		t.Errorf("Types 不一致: %v", e.Types)
+	}
+
+	q := MessageQuery{Talker: "bob,123@chatroom"}
+	if !e.Apply(&q) {
+		t.Fatal("Apply: 交集不为空时应返回 true")
+	}
+	if q.Talker != "123@chatroom" || q.Sender != "alice" {
+		t.Errorf("Apply 后 Talker=%q Sender=%q", q.Talker, q.Sender)
+	}
+	if want := time.Date(2023, 1, 1, 0, 0, 0, 0, time.Local); !q.StartTime.Equal(want) {
+		t.Errorf("StartTime 期望 %v, 实际得到 %v", want, q.StartTime)
+	}
+	if want := time.Date(2023, 2, 28, 23, 59, 59, 0, time.Local); !q.EndTime.Equal(want) {
+		t.Errorf("EndTime 期望 %v, 实际得到 %v", want, q.EndTime)
+	}
+
+	// in: 与已选择的会话没有交集
+	if e.Apply(&MessageQuery{Talker: "bob"}) {
+		t.Error("Apply: 交集为空时应返回 false")
+	}
+
+	// 语法错误返回出错位置
+	errTests := []struct {
+		input string
+		pos   int
+	}{
+		{`hello "world`, 6},
+		{"OR hello", 0},
+		{"hello OR", 6},
+		{"hello type:sticker", 6},
+		{"after:2023-13", 0},
+		{"a - b", 2},
+		{"after:2024 before:2023", 0},
+	}
+	for _, tt := range errTests {
+		_, err := ParseSearch(tt.input)
+		var perr *SearchParseError
+		if !errors.As(err, &perr) {
+			t.Errorf("ParseSearch(%q): 期望 SearchParseError, 实际得到 %v", tt.input, err)
+			continue
+		}
+		if perr.Pos != tt.pos {
+			t.Errorf("ParseSearch(%q): 期望位置 %d, 实际得到 %d (%s)", tt.input, tt.pos, perr.Pos, perr.Message)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/afumu/wetrace/internal/account"
+	"github.com/afumu/wetrace/web/transport"
+	"github.com/gin-gonic/gin"
+)
+
+// accountInfo 是账号列表中返回的账号信息，不包含密钥
+type accountInfo struct {
+	ID              string `json:"id"`
+	Name            string `json:"name"`
+	WorkDir         string `json:"work_dir"`
+	WechatDbSrcPath string `json:"wechat_db_src_path"`
+	Active          bool   `json:"active"`                   // 是否为处理本次请求的账号
+	Current         bool   `json:"current"`                  // 是否为当前账号 (未指定账号的请求使用)
+	Opened          bool   `json:"opened"`                   // Store 是否已打开 (切换过或启动时使用)
+	WechatVersion   string `json:"wechat_version,omitempty"` // 已打开账号识别到的数据版本
+}
+
+// ListAccounts 返回所有账号、处理本次请求的账号 (active) 和当前账号 (current)。
+func (s *Service) ListAccounts(c *gin.Context) {
+	active := requestAccount(c.Request)
+	current := s.active.Load().id
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := s.accounts.List()
+	items := make([]accountInfo, 0, len(list))
+	for _, acc := range list {
+		info := accountInfo{
+			ID:              acc.ID,
+			Name:            acc.Name,
+			WorkDir:         acc.WorkDir,
+			WechatDbSrcPath: acc.WechatDbSrcPath,
+			Active:          acc.ID == active,
+			Current:         acc.ID == current,
+		}
+		if rt, ok := s.runtimes[acc.ID]; ok {
+			info.Opened = true
+			info.WechatVersion = rt.store.Version()
+		}
+		items = append(items, info)
+	}
+
+	transport.SendSuccess(c, gin.H{
+		"active":   active,
+		"current":  current,
+		"accounts": items,
+	})
+}
+
+// AddAccount 添加账号，添加后需调用切换接口才会打开。
+func (s *Service) AddAccount(c *gin.Context) {
+	var req struct {
+		ID              string `json:"id" binding:"required"`
+		Name            string `json:"name"`
+		WorkDir         string `json:"work_dir" binding:"required"`
+		WechatDbSrcPath string `json:"wechat_db_src_path"`
+		WechatDbKey     string `json:"wechat_db_key"`
+		ImageKey        string `json:"image_key"`
+		XorKey          string `json:"xor_key"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		transport.BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	err := s.accounts.Add(account.Account{
+		ID:              req.ID,
+		Name:            req.Name,
+		WorkDir:         req.WorkDir,
+		WechatDbSrcPath: req.WechatDbSrcPath,
+		WechatDbKey:     req.WechatDbKey,
+		ImageKey:        req.ImageKey,
+		XorKey:          req.XorKey,
+	})
+	if err != nil {
+		sendAccountError(c, err)
+		return
+	}
+
+	transport.SendSuccess(c, gin.H{"id": req.ID})
+}
+
+// SwitchAccount 切换账号：本浏览器会话之后的请求由该账号处理，并将其设为未指定账号的请求使用的当前账号。
+// 已固定在其他账号上的会话不受影响。
+func (s *Service) SwitchAccount(c *gin.Context) {
+	var req struct {
+		ID string `json:"id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		transport.BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	rt, err := s.switchAccount(req.ID)
+	if err != nil {
+		sendAccountError(c, err)
+		return
+	}
+	setAccountCookie(c.Writer, rt.id)
+
+	transport.SendSuccess(c, gin.H{
+		"active":         rt.id,
+		"wechat_version": rt.store.Version(),
+	})
+}
+
+// DeleteAccount 删除账号并关闭其数据，不删除工作目录中的文件。
+func (s *Service) DeleteAccount(c *gin.Context) {
+	if err := s.removeAccount(c.Param("id")); err != nil {
+		sendAccountError(c, err)
+		return
+	}
+	transport.SendSuccess(c, gin.H{"status": "ok"})
+}
+
+// sendAccountError 将账号注册表的错误映射为对应的 HTTP 状态码
+func sendAccountError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, account.ErrNotFound):
+		transport.NotFound(c, err.Error())
+	case errors.Is(err, account.ErrExists), errors.Is(err, account.ErrWorkDirInUse),
+		errors.Is(err, account.ErrActive), errors.Is(err, account.ErrDefault):
+		transport.SendError(c, http.StatusConflict, err.Error())
+	case errors.Is(err, account.ErrInvalidID), errors.Is(err, account.ErrWorkDirNeeded):
+		transport.BadRequest(c, err.Error())
+	default:
+		transport.InternalServerError(c, err.Error())
+	}
+}
@@ -0,0 +1,169 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/afumu/wetrace/internal/account"
+	"github.com/afumu/wetrace/store"
+	"github.com/afumu/wetrace/web/media"
+	"github.com/spf13/viper"
+)
+
+// accountGroup 记录同一进程中各账号的 API，用于同步全局配置 (AI、TTS 等) 的修改
+type accountGroup struct {
+	mu   sync.Mutex
+	apis []*API
+}
+
+func (g *accountGroup) add(a *API) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.apis = append(g.apis, a)
+}
+
+func (g *accountGroup) remove(a *API) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for i, p := range g.apis {
+		if p == a {
+			g.apis = append(g.apis[:i], g.apis[i+1:]...)
+			return
+		}
+	}
+}
+
+// others 返回除 a 以外的 API
+func (g *accountGroup) others(a *API) []*API {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	out := make([]*API, 0, len(g.apis))
+	for _, p := range g.apis {
+		if p != a {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// ForAccount 为另一个账号创建 API 处理器。
+// Store、媒体、导出服务和同步/备份调度器按账号独立；AI、TTS、密码和监控等全局服务与 a 共享。
+func (a *API) ForAccount(s store.Store, m *media.Service, conf *Config, id string, accounts *account.Registry) *API {
+	a.mu.Lock()
+	conf.WxKeyDllPath = a.Conf.WxKeyDllPath
+	conf.WechatPath = a.Conf.WechatPath
+	conf.WechatDataPath = a.Conf.WechatDataPath
+	conf.AIEnabled = a.Conf.AIEnabled
+	conf.AIProvider = a.Conf.AIProvider
+	conf.AIAPIKey = a.Conf.AIAPIKey
+	conf.AIBaseURL = a.Conf.AIBaseURL
+	conf.AIModel = a.Conf.AIModel
+	b := &API{
+		Store:          s,
+		Media:          m,
+		Conf:           conf,
+		AI:             a.AI,
+		Password:       a.Password,
+		Monitor:        a.Monitor,
+		MonitorChecker: a.MonitorChecker,
+		TTS:            a.TTS,
+		AccountID:      id,
+		Accounts:       accounts,
+		staticFS:       a.staticFS,
+		group:          a.group,
+	}
+	a.mu.Unlock()
+
+	b.initAccountServices()
+	b.group.add(b)
+	return b
+}
+
+// Close 停止账号的同步和备份调度器，账号被删除时调用
+func (a *API) Close() {
+	a.SyncScheduler.Stop()
+	a.BackupScheduler.Stop()
+	a.group.remove(a)
+}
+
+// isDefaultAccount 判断是否为由 .env 配置的默认账号
+func (a *API) isDefaultAccount() bool {
+	return a.Accounts == nil || a.AccountID == account.DefaultID
+}
+
+// setting 读取配置项：按账号保存的配置项对非默认账号从账号注册表读取，其余从 .env 读取
+func (a *API) setting(key string) string {
+	if a.isDefaultAccount() || !account.Scoped(key) {
+		return viper.GetString(key)
+	}
+	acc, err := a.Accounts.Get(a.AccountID)
+	if err != nil {
+		return ""
+	}
+	return acc.Get(key)
+}
+
+func (a *API) settingBool(key string) bool {
+	v, _ := strconv.ParseBool(a.setting(key))
+	return v
+}
+
+func (a *API) settingInt(key string) int {
+	v, _ := strconv.Atoi(a.setting(key))
+	return v
+}
+
+// saveAccountConfig 保存按账号区分的配置项：默认账号写入 .env，其他账号写入账号注册表
+func (a *API) saveAccountConfig(updates map[string]string) error {
+	if a.isDefaultAccount() {
+		if err := updateEnv(updates); err != nil {
+			return err
+		}
+		for k, v := range updates {
+			viper.Set(k, v)
+		}
+		return nil
+	}
+	return a.Accounts.Update(a.AccountID, func(acc *account.Account) {
+		for k, v := range updates {
+			acc.Set(k, v)
+		}
+	})
+}
+
+// shareGlobals 把 a 的全局配置 (AI、TTS、微信安装路径) 同步给其他账号的 API。
+// 调用时不能持有 a.mu。
+func (a *API) shareGlobals() {
+	a.mu.Lock()
+	conf := *a.Conf
+	aiClient, ttsClient := a.AI, a.TTS
+	a.mu.Unlock()
+
+	for _, p := range a.group.others(a) {
+		p.mu.Lock()
+		p.AI, p.TTS = aiClient, ttsClient
+		p.Conf.WechatPath = conf.WechatPath
+		p.Conf.AIEnabled = conf.AIEnabled
+		p.Conf.AIProvider = conf.AIProvider
+		p.Conf.AIAPIKey = conf.AIAPIKey
+		p.Conf.AIBaseURL = conf.AIBaseURL
+		p.Conf.AIModel = conf.AIModel
+		p.mu.Unlock()
+	}
+}
+
+// backupHistoryFile 返回备份历史文件路径，非默认账号使用各自的历史文件
+func (a *API) backupHistoryFile() string {
+	name := "backup_history.json"
+	if !a.isDefaultAccount() {
+		name = "backup_history_" + a.AccountID + ".json"
+	}
+	historyFile := filepath.Join(viper.GetString("config_path"), name)
+	if historyFile == name {
+		home, _ := os.UserHomeDir()
+		historyFile = filepath.Join(home, ".wetrace", name)
+	}
+	return historyFile
+}
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/afumu/wetrace/decrypt"
+	"github.com/afumu/wetrace/internal/account"
 	"github.com/afumu/wetrace/internal/ai"
 	"github.com/afumu/wetrace/internal/backup"
 	"github.com/afumu/wetrace/internal/monitor"
@@ -19,6 +20,7 @@ import (
 	"github.com/afumu/wetrace/store/types"
 	"github.com/afumu/wetrace/web/export"
 	"github.com/afumu/wetrace/web/media"
+	"github.com/rs/zerolog/log"
 	"github.com/spf13/viper"
 )
 
@@ -35,7 +37,13 @@ type API struct {
 	Monitor         *monitor.Store
 	MonitorChecker  *monitor.Checker
 	TTS             *tts.Client
+	AccountID       string            // 所属账号，默认账号为 account.DefaultID
+	Accounts        *account.Registry // 非默认账号的配置保存在账号注册表中
 	mu              sync.Mutex
+	hiddenMu        sync.Mutex // 串行化删除会话时对 HIDDEN_SESSIONS 的读改写
+
+	staticFS fs.FS
+	group    *accountGroup
 }
 
 type Config struct {
@@ -61,65 +69,22 @@ func NewAPI(s store.Store, m *media.Service, conf *Config, staticFS fs.FS) *API
 		aiClient = ai.NewClient(conf.AIAPIKey, conf.AIBaseURL, conf.AIModel)
 	}
 
-	exportSvc := &export.Service{
-		Media:    m,
-		Store:    s,
-		StaticFS: staticFS,
-	}
-
 	a := &API{
-		Store:    s,
-		Media:    m,
-		Export:   exportSvc,
-		Conf:     conf,
-		AI:       aiClient,
-		Password: NewPasswordManager(),
+		Store:     s,
+		Media:     m,
+		Conf:      conf,
+		AI:        aiClient,
+		Password:  NewPasswordManager(),
+		AccountID: account.DefaultID,
+		staticFS:  staticFS,
+		group:     &accountGroup{},
 	}
+	a.group.add(a)
 
 	// Initialize AI prompts JSON file path
 	initPromptsFilePath(conf.DataDir)
 
-	// Initialize sync scheduler
-	syncFunc := func() error {
-		_, _, err := decrypt.RunTask(conf.WechatDbSrcPath, conf.WechatDbKey)
-		if err != nil {
-			return err
-		}
-		return s.Reload()
-	}
-	a.SyncScheduler = intsync.NewScheduler(syncFunc)
-
-	// Restore sync config from viper
-	if viper.GetBool("SYNC_ENABLED") {
-		interval := viper.GetInt("SYNC_INTERVAL_MINUTES")
-		if interval < 5 {
-			interval = 30
-		}
-		a.SyncScheduler.Configure(true, interval)
-	}
-
-	// Initialize backup scheduler
-	backupFunc := a.createBackupFunc(exportSvc)
-	historyFile := filepath.Join(viper.GetString("config_path"), "backup_history.json")
-	if historyFile == "backup_history.json" {
-		home, _ := os.UserHomeDir()
-		historyFile = filepath.Join(home, ".wetrace", "backup_history.json")
-	}
-	a.BackupScheduler = backup.NewScheduler(backupFunc, historyFile)
-
-	// Restore backup config from viper
-	if viper.GetBool("BACKUP_ENABLED") {
-		hours := viper.GetInt("BACKUP_INTERVAL_HOURS")
-		if hours < 1 {
-			hours = 24
-		}
-		bPath := viper.GetString("BACKUP_PATH")
-		bFormat := viper.GetString("BACKUP_FORMAT")
-		if bFormat == "" {
-			bFormat = "html"
-		}
-		a.BackupScheduler.Configure(true, hours, bPath, bFormat)
-	}
+	a.initAccountServices()
 
 	// Initialize monitor store
 	monitorDir := filepath.Join(viper.GetString("config_path"), "monitor")
@@ -131,6 +96,7 @@ func NewAPI(s store.Store, m *media.Service, conf *Config, staticFS fs.FS) *API
 	if err == nil {
 		a.Monitor = monitorStore
 		// Initialize monitor checker
+		// 监控为全局服务，检查默认账号的数据
 		a.MonitorChecker = monitor.NewChecker(s, monitorStore, aiClient)
 		a.MonitorChecker.Start()
 	}
@@ -148,13 +114,70 @@ func NewAPI(s store.Store, m *media.Service, conf *Config, staticFS fs.FS) *API
 	return a
 }
 
+// initAccountServices 创建按账号区分的导出服务和同步、备份调度器，并恢复账号保存的调度配置
+func (a *API) initAccountServices() {
+	a.Store.SetHiddenSessions(a.hiddenSessions())
+
+	a.Export = &export.Service{
+		Media:    a.Media,
+		Store:    a.Store,
+		StaticFS: a.staticFS,
+	}
+
+	// Initialize sync scheduler
+	// 解密到新的快照目录，完成后再整体切换，浏览中的查询不会读到写了一半的数据库；
+	// 以当前数据为基础增量解密，只解密上次同步后变化的页
+	conf := a.Conf
+	syncFunc := func() (*decrypt.Stats, error) {
+		a.mu.Lock()
+		src, key := conf.WechatDbSrcPath, conf.WechatDbKey
+		a.mu.Unlock()
+		var stats *decrypt.Stats
+		err := a.Store.SwapGeneration(func(dir, prev string) error {
+			var err error
+			stats, err = decrypt.RunTaskIncremental(src, key, prev, dir)
+			return err
+		})
+		return stats, err
+	}
+	a.SyncScheduler = intsync.NewScheduler(syncFunc)
+
+	// Restore sync config
+	if a.settingBool("SYNC_ENABLED") {
+		interval := a.settingInt("SYNC_INTERVAL_MINUTES")
+		if interval < 5 {
+			interval = 30
+		}
+		a.SyncScheduler.Configure(true, interval)
+	}
+
+	// Initialize backup scheduler
+	backupFunc := a.createBackupFunc(a.Export)
+	a.BackupScheduler = backup.NewScheduler(backupFunc, a.backupHistoryFile())
+
+	// Restore backup config
+	if a.settingBool("BACKUP_ENABLED") {
+		hours := a.settingInt("BACKUP_INTERVAL_HOURS")
+		if hours < 1 {
+			hours = 24
+		}
+		bPath := a.setting("BACKUP_PATH")
+		bFormat := a.setting("BACKUP_FORMAT")
+		if bFormat == "" {
+			bFormat = "html"
+		}
+		a.BackupScheduler.Configure(true, hours, bPath, bFormat, a.setting("BACKUP_LABEL"))
+	}
+}
+
 // createBackupFunc creates the backup function that exports sessions.
-// When sessionIDs is empty, all sessions are backed up.
+// When sessionIDs and label are empty, all sessions are backed up.
 func (a *API) createBackupFunc(exportSvc *export.Service) backup.BackupFunc {
-	return func(backupPath, format string, sessionIDs []string) (string, int, error) {
+	return func(backupPath, format string, sessionIDs []string, label string) (string, int, error) {
 		ctx := context.Background()
 
-		sessions, err := a.Store.GetSessions(ctx, types.SessionQuery{Limit: 100000})
+		// 按标签筛选时只备份与带有该标签的联系人的会话
+		sessions, err := a.Store.GetSessions(ctx, types.SessionQuery{Limit: 100000, Label: label})
 		if err != nil {
 			return "", 0, fmt.Errorf("获取会话列表失败: %w", err)
 		}
@@ -194,23 +217,29 @@ func (a *API) createBackupFunc(exportSvc *export.Service) backup.BackupFunc {
 				name = talker
 			}
 
-			var data []byte
-			switch format {
-			case "txt":
-				data, err = exportSvc.ExportChatTxt(ctx, talker, name, allStart, allEnd)
-			default:
-				data, err = exportSvc.ExportChat(ctx, talker, name, allStart, allEnd)
-			}
-			if err != nil {
-				continue
-			}
-
 			ext := ".zip"
 			if format == "txt" {
 				ext = ".txt"
 			}
 			fname := filepath.Join(backupDir, fmt.Sprintf("%s_%s%s", name, timestamp, ext))
-			if writeErr := os.WriteFile(fname, data, 0644); writeErr != nil {
+
+			// 直接流式写入备份文件，避免在内存中持有整个会话
+			f, err := os.Create(fname)
+			if err != nil {
+				continue
+			}
+			switch format {
+			case "txt":
+				err = exportSvc.WriteChatTxt(ctx, f, talker, name, allStart, allEnd)
+			default:
+				err = exportSvc.WriteChat(ctx, f, talker, name, allStart, allEnd)
+			}
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				log.Warn().Err(err).Str("talker", talker).Msg("备份会话失败")
+				_ = os.Remove(fname)
 				continue
 			}
 			count++
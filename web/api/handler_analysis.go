@@ -2,7 +2,9 @@ package api
 
 import (
 	"net/http"
+	"strconv"
 
+	"github.com/afumu/wetrace/store/types"
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog/log"
 )
@@ -135,7 +137,7 @@ func (a *API) GetRepeatAnalysis(c *gin.Context) {
 
 // GetPersonalTopContacts 获取个人社交排行榜
 func (a *API) GetPersonalTopContacts(c *gin.Context) {
-	stats, err := a.Store.GetPersonalTopContacts(c.Request.Context(), 100)
+	stats, err := a.Store.GetPersonalTopContacts(c.Request.Context(), 100, c.Query("label"))
 	if err != nil {
 		log.Error().Err(err).Msg("获取个人社交排行榜失败")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -144,3 +146,28 @@ func (a *API) GetPersonalTopContacts(c *gin.Context) {
 
 	c.JSON(http.StatusOK, stats)
 }
+
+// GetMediaSpread 获取跨会话媒体去重报告：按 md5 归并全部会话中的图片、视频和文件，
+// 返回每个媒体的最早发送者、按时间排序的传播链和到达的会话。
+// 默认只返回到达 2 个及以上会话的媒体，指定 md5 时追踪该媒体。
+func (a *API) GetMediaSpread(c *gin.Context) {
+	rq := talkerRangeQuery(c)
+	q := types.MediaSpreadQuery{
+		StartTime: rq.StartTime,
+		EndTime:   rq.EndTime,
+		Kind:      c.Query("kind"),
+		MD5:       c.Query("md5"),
+	}
+	q.MinSessions, _ = strconv.Atoi(c.Query("min_sessions"))
+	q.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", "50"))
+	q.Offset, _ = strconv.Atoi(c.Query("offset"))
+
+	report, err := a.Store.GetMediaSpread(c.Request.Context(), q)
+	if err != nil {
+		log.Error().Err(err).Msg("获取媒体传播分析失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
@@ -1,9 +1,10 @@
 package api
 
 import (
+	"strconv"
+
 	"github.com/afumu/wetrace/web/transport"
 	"github.com/gin-gonic/gin"
-	"github.com/spf13/viper"
 )
 
 // GetBackupConfig returns the current auto-backup configuration.
@@ -27,6 +28,7 @@ func (a *API) UpdateBackupConfig(c *gin.Context) {
 		IntervalHours int    `json:"interval_hours"`
 		BackupPath    string `json:"backup_path"`
 		Format        string `json:"format"`
+		Label         string `json:"label"` // 定时备份只备份带有该标签的联系人的会话
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		transport.BadRequest(c, "参数错误")
@@ -43,20 +45,22 @@ func (a *API) UpdateBackupConfig(c *gin.Context) {
 		return
 	}
 
-	a.BackupScheduler.Configure(req.Enabled, req.IntervalHours, req.BackupPath, req.Format)
+	a.BackupScheduler.Configure(req.Enabled, req.IntervalHours, req.BackupPath, req.Format, req.Label)
 
-	// Persist to viper config
-	viper.Set("BACKUP_ENABLED", req.Enabled)
-	viper.Set("BACKUP_INTERVAL_HOURS", req.IntervalHours)
-	viper.Set("BACKUP_PATH", req.BackupPath)
-	viper.Set("BACKUP_FORMAT", req.Format)
-	_ = viper.WriteConfig()
+	// Persist to account config
+	_ = a.saveAccountConfig(map[string]string{
+		"BACKUP_ENABLED":        strconv.FormatBool(req.Enabled),
+		"BACKUP_INTERVAL_HOURS": strconv.Itoa(req.IntervalHours),
+		"BACKUP_PATH":           req.BackupPath,
+		"BACKUP_FORMAT":         req.Format,
+		"BACKUP_LABEL":          req.Label,
+	})
 
 	transport.SendSuccess(c, gin.H{"status": "configured"})
 }
 
 // RunBackup manually triggers a backup operation.
-// Accepts optional session_ids in request body to backup specific sessions.
+// Accepts optional session_ids and label in request body to backup specific sessions.
 func (a *API) RunBackup(c *gin.Context) {
 	if a.BackupScheduler == nil {
 		transport.InternalServerError(c, "备份调度器未初始化")
@@ -65,11 +69,12 @@ func (a *API) RunBackup(c *gin.Context) {
 
 	var req struct {
 		SessionIDs []string `json:"session_ids"`
+		Label      string   `json:"label"` // 只备份与带有该标签 (ID 或名称) 的联系人的会话
 	}
 	// Ignore bind error — body may be empty for backward compatibility
 	_ = c.ShouldBindJSON(&req)
 
-	go a.BackupScheduler.RunBackupWithSessions(req.SessionIDs)
+	go a.BackupScheduler.RunBackupWithFilter(req.SessionIDs, req.Label)
 	transport.SendSuccess(c, gin.H{"status": "backup_started"})
 }
 
@@ -1,7 +1,10 @@
 package api
 
 import (
+	"time"
+
 	"github.com/afumu/wetrace/internal/model"
+	"github.com/afumu/wetrace/pkg/util"
 	"github.com/afumu/wetrace/store/types"
 	"github.com/afumu/wetrace/web/transport"
 	"github.com/gin-gonic/gin"
@@ -24,6 +27,7 @@ func (a *API) GetChatRooms(c *gin.Context) {
 
 	query := types.ChatRoomQuery{
 		Keyword: keywordQuery.Keyword,
+		Search:  keywordQuery.Search,
 		Limit:   pageQuery.Limit,
 		Offset:  pageQuery.Offset,
 	}
@@ -69,3 +73,52 @@ func (a *API) GetChatRoomByID(c *gin.Context) {
 
 	transport.SendSuccess(c, chatrooms[0])
 }
+
+// MembershipHistoryResponse 是群成员变动历史的响应
+type MembershipHistoryResponse struct {
+	Events []*model.MembershipEvent `json:"events"`
+	At     *time.Time               `json:"at,omitempty"`
+	Roster []*model.RosterMember    `json:"roster,omitempty"`
+}
+
+// GetMembershipHistory 处理获取群成员变动历史的请求。
+// 可选参数 at 为日期或时间，指定时额外返回该日结束时的群成员。
+func (a *API) GetMembershipHistory(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		transport.BadRequest(c, "群聊 ID 是必需的。")
+		return
+	}
+
+	ctx := c.Request.Context()
+	events, err := a.Store.GetMembershipHistory(ctx, id)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("获取群成员变动历史失败")
+		transport.InternalServerError(c, "获取群成员变动历史失败。")
+		return
+	}
+	if events == nil {
+		events = make([]*model.MembershipEvent, 0)
+	}
+	resp := MembershipHistoryResponse{Events: events}
+
+	if at := c.Query("at"); at != "" {
+		_, end, ok := util.TimeRangeOf(at)
+		if !ok {
+			transport.BadRequest(c, "无效的 at 参数: "+at)
+			return
+		}
+		roster, err := a.Store.GetRosterAt(ctx, id, end, events)
+		if err != nil {
+			log.Error().Err(err).Str("id", id).Str("at", at).Msg("还原历史群成员失败")
+			transport.InternalServerError(c, "获取历史群成员失败。")
+			return
+		}
+		if roster == nil {
+			roster = make([]*model.RosterMember, 0)
+		}
+		resp.At, resp.Roster = &end, roster
+	}
+
+	transport.SendSuccess(c, resp)
+}
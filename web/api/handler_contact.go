@@ -31,6 +31,8 @@ func (a *API) GetContacts(c *gin.Context) {
 
 	query := types.ContactQuery{
 		Keyword: keywordQuery.Keyword,
+		Search:  keywordQuery.Search,
+		Label:   c.Query("label"),
 		Limit:   pageQuery.Limit,
 		Offset:  pageQuery.Offset,
 	}
@@ -49,6 +51,22 @@ func (a *API) GetContacts(c *gin.Context) {
 	transport.SendSuccess(c, contacts)
 }
 
+// GetLabels 处理获取联系人标签列表的请求。
+func (a *API) GetLabels(c *gin.Context) {
+	labels, err := a.Store.GetLabels(c.Request.Context())
+	if err != nil {
+		log.Error().Err(err).Msg("从 store 获取联系人标签失败")
+		transport.InternalServerError(c, "获取标签列表失败。")
+		return
+	}
+
+	if labels == nil {
+		labels = make([]*model.Label, 0)
+	}
+
+	transport.SendSuccess(c, labels)
+}
+
 // GetContactByID 处理通过 ID 获取单个联系人信息的请求。
 func (a *API) GetContactByID(c *gin.Context) {
 	id := c.Param("id")
@@ -84,6 +102,8 @@ func (a *API) ExportContacts(c *gin.Context) {
 
 	query := types.ContactQuery{
 		Keyword: keyword,
+		Search:  c.Query("search"),
+		Label:   c.Query("label"),
 		Limit:   100000,
 		Offset:  0,
 	}
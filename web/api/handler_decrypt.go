@@ -8,20 +8,26 @@ import (
 
 // HandleEnvDecrypt 处理基于环境变量的本地解密请求
 func (a *API) HandleEnvDecrypt(c *gin.Context) {
-	count, outputDir, err := decrypt.RunTask(a.Conf.WechatDbSrcPath, a.Conf.WechatDbKey)
+	// 解密到新的快照目录，成功后 Store 原子地切换过去
+	// 未变化的页直接复用当前数据，只解密变化的页
+	var stats *decrypt.Stats
+	var outputDir string
+	err := a.Store.SwapGeneration(func(dir, prev string) error {
+		var err error
+		stats, err = decrypt.RunTaskIncremental(a.Conf.WechatDbSrcPath, a.Conf.WechatDbKey, prev, dir)
+		outputDir = dir
+		return err
+	})
 	if err != nil {
 		transport.InternalServerError(c, "解密失败: "+err.Error())
 		return
 	}
 
-	// 解密成功后，触发 Store 的重载，以便立即发现新文件
-	if err := a.Store.Reload(); err != nil {
-		transport.InternalServerError(c, "解密成功，但重载数据存储失败: "+err.Error())
-		return
-	}
-
 	transport.SendSuccess(c, gin.H{
-		"processed_files": count,
+		"processed_files": stats.Files,
 		"output_dir":      outputDir,
+		"bytes_decrypted": stats.BytesDecrypted,
+		"pages_reused":    stats.ReusedPages,
+		"duration_ms":     stats.Duration.Milliseconds(),
 	})
 }
@@ -2,12 +2,14 @@ package api
 
 import (
 	"fmt"
+	"io"
 	"net/http"
 	"time"
 
 	"github.com/afumu/wetrace/pkg/util"
 	"github.com/afumu/wetrace/web/transport"
 	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
 )
 
 // ExportChat 处理导出聊天记录的请求
@@ -33,6 +35,31 @@ func (a *API) ExportChat(c *gin.Context) {
 	}
 
 	format := c.Query("format")
+	ctx := c.Request.Context()
+
+	// 文本、CSV 和 HTML 压缩包边读取消息边写入响应，不在内存中缓存整个文件
+	switch format {
+	case "txt":
+		fileName := fmt.Sprintf("chat_export_%s_%s.txt", talkerName, talker)
+		streamExport(c, fileName, "text/plain; charset=utf-8", talker, func(w io.Writer) error {
+			return a.Export.WriteChatTxt(ctx, w, talker, talkerName, start, end)
+		})
+		return
+	case "csv":
+		fileName := fmt.Sprintf("chat_export_%s_%s.csv", talkerName, talker)
+		streamExport(c, fileName, "text/csv; charset=utf-8", talker, func(w io.Writer) error {
+			return a.Export.WriteChatCSV(ctx, w, talker, talkerName, start, end)
+		})
+		return
+	case "xlsx", "docx", "pdf":
+	default:
+		// 默认导出 HTML ZIP
+		fileName := fmt.Sprintf("chat_export_%s_%s.zip", talkerName, talker)
+		streamExport(c, fileName, "application/octet-stream", talker, func(w io.Writer) error {
+			return a.Export.WriteChat(ctx, w, talker, talkerName, start, end)
+		})
+		return
+	}
 
 	var (
 		data        []byte
@@ -41,17 +68,7 @@ func (a *API) ExportChat(c *gin.Context) {
 		contentType string
 	)
 
-	ctx := c.Request.Context()
-
 	switch format {
-	case "txt":
-		data, err = a.Export.ExportChatTxt(ctx, talker, talkerName, start, end)
-		fileName = fmt.Sprintf("chat_export_%s_%s.txt", talkerName, talker)
-		contentType = "text/plain; charset=utf-8"
-	case "csv":
-		data, err = a.Export.ExportChatCSV(ctx, talker, talkerName, start, end)
-		fileName = fmt.Sprintf("chat_export_%s_%s.csv", talkerName, talker)
-		contentType = "text/csv; charset=utf-8"
 	case "xlsx":
 		data, err = a.Export.ExportChatXLSX(ctx, talker, talkerName, start, end)
 		fileName = fmt.Sprintf("chat_export_%s_%s.xlsx", talkerName, talker)
@@ -64,11 +81,6 @@ func (a *API) ExportChat(c *gin.Context) {
 		data, err = a.Export.ExportChatPDF(ctx, talker, talkerName, start, end)
 		fileName = fmt.Sprintf("chat_export_%s_%s.pdf", talkerName, talker)
 		contentType = "application/pdf"
-	default:
-		// 默认导出 HTML ZIP
-		data, err = a.Export.ExportChat(ctx, talker, talkerName, start, end)
-		fileName = fmt.Sprintf("chat_export_%s_%s.zip", talkerName, talker)
-		contentType = "application/octet-stream"
 	}
 
 	if err != nil {
@@ -83,6 +95,19 @@ func (a *API) ExportChat(c *gin.Context) {
 	c.Data(http.StatusOK, contentType, data)
 }
 
+// streamExport 设置下载响应头后将 write 的输出直接写入响应
+func streamExport(c *gin.Context, fileName, contentType, talker string, write func(w io.Writer) error) {
+	c.Header("Content-Description", "File Transfer")
+	c.Header("Content-Transfer-Encoding", "binary")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", fileName))
+	c.Header("Content-Type", contentType)
+	c.Status(http.StatusOK)
+	if err := write(c.Writer); err != nil {
+		// 响应已开始写入，只能记录日志
+		log.Error().Err(err).Str("talker", talker).Msg("导出聊天记录失败")
+	}
+}
+
 // ExportForensic 处理法律取证导出请求，返回包含水印和完整性校验的 standalone HTML 报告
 func (a *API) ExportForensic(c *gin.Context) {
 	talker := c.Query("talker")
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/afumu/wetrace/store/types"
+	"github.com/afumu/wetrace/web/transport"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// GetFiles 处理获取文件附件列表的请求。
+// 支持按会话、时间范围、文件名、扩展名 (ext=pdf,docx) 和大小 (min_size/max_size，字节) 筛选，present 表示本地是否有该文件。
+func (a *API) GetFiles(c *gin.Context) {
+	rq := talkerRangeQuery(c)
+	q := types.FileQuery{
+		StartTime: rq.StartTime,
+		EndTime:   rq.EndTime,
+		Talker:    rq.Talker,
+		Keyword:   c.Query("keyword"),
+		Ext:       c.Query("ext"),
+	}
+	q.MinSize, _ = strconv.ParseInt(c.Query("min_size"), 10, 64)
+	q.MaxSize, _ = strconv.ParseInt(c.Query("max_size"), 10, 64)
+	q.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", "50"))
+	q.Offset, _ = strconv.Atoi(c.Query("offset"))
+
+	catalog, err := a.Store.GetFiles(c.Request.Context(), q)
+	if err != nil {
+		log.Error().Err(err).Str("talker", q.Talker).Msg("获取文件列表失败")
+		transport.InternalServerError(c, "获取文件列表失败")
+		return
+	}
+	transport.SendSuccess(c, catalog)
+}
+
+// DownloadFilesRequest 批量下载文件的请求体
+type DownloadFilesRequest struct {
+	MD5s []string `json:"md5s"`
+}
+
+// DownloadFiles 将选中的文件打包为 ZIP 下载，压缩包中附带 manifest.json 和 SHA256SUMS
+func (a *API) DownloadFiles(c *gin.Context) {
+	var req DownloadFilesRequest
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.MD5s) == 0 {
+		transport.BadRequest(c, "md5s 参数不能为空")
+		return
+	}
+
+	zipName := fmt.Sprintf("files_%s.zip", time.Now().Format("20060102_150405"))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", zipName))
+	c.Header("Content-Type", "application/zip")
+	if err := a.Export.WriteFilesZip(c.Request.Context(), c.Writer, req.MD5s); err != nil {
+		// 响应已开始写入，只能记录日志
+		log.Error().Err(err).Int("count", len(req.MD5s)).Msg("打包文件失败")
+	}
+}
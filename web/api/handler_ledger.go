@@ -0,0 +1,72 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/afumu/wetrace/pkg/util"
+	"github.com/afumu/wetrace/store/types"
+	"github.com/afumu/wetrace/web/transport"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// talkerRangeQuery 从请求参数构造按会话和时间范围的查询：talker_id 为空时统计全部会话，time_range 为空时不限时间
+func talkerRangeQuery(c *gin.Context) types.MessageQuery {
+	start, end, ok := util.TimeRangeOf(c.Query("time_range"))
+	if !ok {
+		start = time.Unix(0, 0)
+		end = time.Now()
+	}
+	return types.MessageQuery{
+		Talker:    c.Query("talker_id"),
+		StartTime: start,
+		EndTime:   end,
+	}
+}
+
+// GetLedger 处理获取转账和红包账目的请求。
+// 返回每笔转账和红包的明细，以及总计、按联系人和按月的收支合计。
+func (a *API) GetLedger(c *gin.Context) {
+	q := talkerRangeQuery(c)
+	ledger, err := a.Store.GetLedger(c.Request.Context(), q)
+	if err != nil {
+		log.Error().Err(err).Str("talker", q.Talker).Msg("获取账目失败")
+		transport.InternalServerError(c, "获取账目失败")
+		return
+	}
+	transport.SendSuccess(c, ledger)
+}
+
+// ExportLedger 处理导出账目的请求，format 为 csv (默认) 或 xlsx
+func (a *API) ExportLedger(c *gin.Context) {
+	q := talkerRangeQuery(c)
+	dateStr := time.Now().Format("20060102")
+	ctx := c.Request.Context()
+
+	var (
+		data        []byte
+		err         error
+		fileName    string
+		contentType string
+	)
+	switch c.DefaultQuery("format", "csv") {
+	case "xlsx":
+		data, err = a.Export.ExportLedgerXLSX(ctx, q)
+		fileName = fmt.Sprintf("ledger_export_%s.xlsx", dateStr)
+		contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	default:
+		data, err = a.Export.ExportLedgerCSV(ctx, q)
+		fileName = fmt.Sprintf("ledger_export_%s.csv", dateStr)
+		contentType = "text/csv; charset=utf-8"
+	}
+	if err != nil {
+		log.Error().Err(err).Str("talker", q.Talker).Msg("导出账目失败")
+		transport.InternalServerError(c, "导出账目失败")
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", fileName))
+	c.Data(http.StatusOK, contentType, data)
+}
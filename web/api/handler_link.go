@@ -0,0 +1,73 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/afumu/wetrace/store/types"
+	"github.com/afumu/wetrace/web/transport"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// linkQuery 从请求参数构造链接库查询，会话和时间范围同 talkerRangeQuery
+func linkQuery(c *gin.Context) types.LinkQuery {
+	q := talkerRangeQuery(c)
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	return types.LinkQuery{
+		StartTime: q.StartTime,
+		EndTime:   q.EndTime,
+		Talker:    q.Talker,
+		Domain:    c.Query("domain"),
+		Source:    c.Query("source"),
+		Kind:      c.Query("kind"),
+		Limit:     limit,
+	}
+}
+
+// GetLinks 处理获取链接库的请求。
+// 返回去重后的链接 (含首次、最近分享时间和每次分享的会话、分享者) 以及热门域名，可按域名、来源和种类筛选。
+func (a *API) GetLinks(c *gin.Context) {
+	q := linkQuery(c)
+	lib, err := a.Store.GetLinks(c.Request.Context(), q)
+	if err != nil {
+		log.Error().Err(err).Str("talker", q.Talker).Msg("获取链接库失败")
+		transport.InternalServerError(c, "获取链接库失败")
+		return
+	}
+	transport.SendSuccess(c, lib)
+}
+
+// ExportLinks 处理导出链接库的请求，format 为 csv (默认) 或 html (浏览器书签)
+func (a *API) ExportLinks(c *gin.Context) {
+	q := linkQuery(c)
+	dateStr := time.Now().Format("20060102")
+	ctx := c.Request.Context()
+
+	var (
+		data        []byte
+		err         error
+		fileName    string
+		contentType string
+	)
+	switch c.DefaultQuery("format", "csv") {
+	case "html":
+		data, err = a.Export.ExportLinksBookmarks(ctx, q)
+		fileName = fmt.Sprintf("links_bookmarks_%s.html", dateStr)
+		contentType = "text/html; charset=utf-8"
+	default:
+		data, err = a.Export.ExportLinksCSV(ctx, q)
+		fileName = fmt.Sprintf("links_export_%s.csv", dateStr)
+		contentType = "text/csv; charset=utf-8"
+	}
+	if err != nil {
+		log.Error().Err(err).Str("talker", q.Talker).Msg("导出链接库失败")
+		transport.InternalServerError(c, "导出链接库失败")
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", fileName))
+	c.Data(http.StatusOK, contentType, data)
+}
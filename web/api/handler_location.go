@@ -0,0 +1,60 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/afumu/wetrace/web/transport"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// GetLocations 处理获取位置消息的请求。
+// talker_id 为空时汇总全部会话的位置，坐标为微信使用的 GCJ-02 坐标系。
+func (a *API) GetLocations(c *gin.Context) {
+	q := talkerRangeQuery(c)
+	points, err := a.Store.GetLocations(c.Request.Context(), q)
+	if err != nil {
+		log.Error().Err(err).Str("talker", q.Talker).Msg("获取位置消息失败")
+		transport.InternalServerError(c, "获取位置消息失败")
+		return
+	}
+	transport.SendSuccess(c, points)
+}
+
+// ExportLocations 处理导出位置的请求，format 为 geojson (默认)、kml 或 gpx，坐标转换为 WGS-84
+func (a *API) ExportLocations(c *gin.Context) {
+	q := talkerRangeQuery(c)
+	dateStr := time.Now().Format("20060102")
+	ctx := c.Request.Context()
+
+	var (
+		data        []byte
+		err         error
+		fileName    string
+		contentType string
+	)
+	switch c.DefaultQuery("format", "geojson") {
+	case "kml":
+		data, err = a.Export.ExportLocationsKML(ctx, q)
+		fileName = fmt.Sprintf("locations_export_%s.kml", dateStr)
+		contentType = "application/vnd.google-earth.kml+xml"
+	case "gpx":
+		data, err = a.Export.ExportLocationsGPX(ctx, q)
+		fileName = fmt.Sprintf("locations_export_%s.gpx", dateStr)
+		contentType = "application/gpx+xml"
+	default:
+		data, err = a.Export.ExportLocationsGeoJSON(ctx, q)
+		fileName = fmt.Sprintf("locations_export_%s.geojson", dateStr)
+		contentType = "application/geo+json"
+	}
+	if err != nil {
+		log.Error().Err(err).Str("talker", q.Talker).Msg("导出位置失败")
+		transport.InternalServerError(c, "导出位置失败")
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", fileName))
+	c.Data(http.StatusOK, contentType, data)
+}
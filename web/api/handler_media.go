@@ -238,6 +238,63 @@ func parseImageTimeRange(timeRange string) (start, end time.Time) {
 	return
 }
 
+// videoListItem 视频列表响应项
+type videoListItem struct {
+	*model.VideoItem
+	ThumbnailURL string `json:"thumbnailUrl"`
+	FullURL      string `json:"fullUrl"`
+}
+
+// videoListResponse 视频列表响应
+type videoListResponse struct {
+	Total         int              `json:"total"`
+	TotalSize     int64            `json:"totalSize"`
+	TotalDuration int              `json:"totalDuration"`
+	Items         []*videoListItem `json:"items"`
+}
+
+// GetVideoList 获取视频列表，参数与图片列表相同，支持按会话筛选、时间范围筛选和分页。
+// 缩略图优先使用微信保存的缩略图，没有时由 ffmpeg 从视频截取。
+func (a *API) GetVideoList(c *gin.Context) {
+	var q imageListQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		transport.BadRequest(c, "无效的请求参数: "+err.Error())
+		return
+	}
+
+	startTime, endTime := parseImageTimeRange(q.TimeRange)
+	catalog, err := a.Store.GetVideos(c.Request.Context(), types.VideoQuery{
+		Talker:    q.Talker,
+		StartTime: startTime,
+		EndTime:   endTime,
+		Limit:     q.Limit,
+		Offset:    q.Offset,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("获取视频列表失败")
+		transport.InternalServerError(c, "获取视频列表失败。")
+		return
+	}
+
+	items := make([]*videoListItem, 0, len(catalog.Items))
+	for _, v := range catalog.Items {
+		thumbnailURL := fmt.Sprintf("/api/v1/media/video/%s?thumb=1", v.MD5)
+		fullURL := fmt.Sprintf("/api/v1/media/video/%s", v.MD5)
+		if v.Path != "" {
+			thumbnailURL += "&path=" + url.QueryEscape(v.Path)
+			fullURL += "?path=" + url.QueryEscape(v.Path)
+		}
+		items = append(items, &videoListItem{VideoItem: v, ThumbnailURL: thumbnailURL, FullURL: fullURL})
+	}
+
+	transport.SendSuccess(c, videoListResponse{
+		Total:         catalog.Total,
+		TotalSize:     catalog.TotalSize,
+		TotalDuration: catalog.TotalDuration,
+		Items:         items,
+	})
+}
+
 // cacheImageExtensions 缓存目录中可能包含的图片文件扩展名。
 // 缓存文件保留原始 .dat 扩展名，也可能是已解码的图片格式。
 var cacheImageExtensions = map[string]bool{
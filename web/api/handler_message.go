@@ -1,6 +1,7 @@
 package api
 
 import (
+	"strconv"
 	"time"
 
 	"github.com/afumu/wetrace/internal/model"
@@ -17,6 +18,8 @@ type MessageRequest struct {
 	Keyword   string `form:"keyword"`
 	TimeRange string `form:"time_range"` // e.g., "2023-01-01~2023-01-31"
 	Reverse   bool   `form:"reverse"`    // 是否倒序
+	Cursor    *int64 `form:"cursor"`     // 游标分页：上一页返回的 next_cursor
+	Direction string `form:"direction"`  // 游标分页方向：next (更新，默认) 或 prev (更早)
 	transport.PaginationQuery
 }
 
@@ -67,9 +70,36 @@ func (a *API) GetMessages(c *gin.Context) {
 		Limit:     req.Limit,
 		Offset:    req.Offset,
 		Reverse:   req.Reverse,
+		Annotate:  true,
 	}
 
-	// 3. 调用 store
+	// 3. 指定了游标或方向时使用游标分页，返回 {items, next_cursor, has_more}
+	if req.Cursor != nil || req.Direction != "" {
+		// 未指定方向时向后翻页
+		cursor := &types.Cursor{Direction: types.CursorNext}
+		if req.Direction != "" {
+			cursor.Direction = req.Direction
+		}
+		if req.Cursor != nil {
+			cursor.Seq = *req.Cursor
+		}
+		if cursor.Direction != types.CursorNext && cursor.Direction != types.CursorPrev {
+			transport.BadRequest(c, "direction 只能为 next 或 prev")
+			return
+		}
+		query.Cursor = cursor
+
+		page, err := a.Store.GetMessagePage(c.Request.Context(), query)
+		if err != nil {
+			log.Error().Err(err).Msg("从 store 获取消息失败")
+			transport.InternalServerError(c, "获取消息失败。")
+			return
+		}
+		transport.SendSuccess(c, page)
+		return
+	}
+
+	// 4. 调用 store
 	messages, err := a.Store.GetMessages(c.Request.Context(), query)
 	if err != nil {
 		log.Error().Err(err).Msg("从 store 获取消息失败")
@@ -81,6 +111,95 @@ func (a *API) GetMessages(c *gin.Context) {
 		messages = make([]*model.Message, 0)
 	}
 
-	// 4. 发送成功响应
+	// 5. 发送成功响应
 	transport.SendSuccess(c, messages)
 }
+
+// GetRevokedMessages 处理获取会话中撤回记录的请求。
+// 每条记录包含撤回通知，以及数据库中仍然存在时的原消息。
+func (a *API) GetRevokedMessages(c *gin.Context) {
+	talker := c.Query("talker_id")
+	if talker == "" {
+		transport.BadRequest(c, "必须指定 talker_id")
+		return
+	}
+
+	start, end, ok := util.TimeRangeOf(c.Query("time_range"))
+	if !ok {
+		end = time.Now()
+		start = time.Unix(0, 0)
+	}
+
+	revocations, err := a.Store.GetRevokedMessages(c.Request.Context(), types.MessageQuery{
+		Talker:    talker,
+		StartTime: start,
+		EndTime:   end,
+	})
+	if err != nil {
+		log.Error().Err(err).Str("talker", talker).Msg("获取撤回记录失败")
+		transport.InternalServerError(c, "获取撤回记录失败")
+		return
+	}
+
+	if revocations == nil {
+		revocations = make([]*model.Revocation, 0)
+	}
+
+	transport.SendSuccess(c, revocations)
+}
+
+// GetThread 处理获取回复树的请求。
+// 返回包含指定消息的整个引用回复链：从最早被引用的根消息开始，每条消息下挂直接引用它的回复。
+func (a *API) GetThread(c *gin.Context) {
+	talker := c.Query("talker_id")
+	if talker == "" {
+		transport.BadRequest(c, "必须指定 talker_id")
+		return
+	}
+	seq, err := strconv.ParseInt(c.Query("seq"), 10, 64)
+	if err != nil {
+		transport.BadRequest(c, "无效的 seq 参数")
+		return
+	}
+
+	thread, err := a.Store.GetThread(c.Request.Context(), talker, seq)
+	if err != nil {
+		log.Error().Err(err).Str("talker", talker).Int64("seq", seq).Msg("获取回复树失败")
+		transport.InternalServerError(c, "获取回复树失败")
+		return
+	}
+	if thread == nil {
+		transport.NotFound(c, "消息不存在")
+		return
+	}
+
+	transport.SendSuccess(c, thread)
+}
+
+// GetForwardRecord 处理展开合并转发消息的请求。
+// 返回转发记录中逐条的消息，嵌套的合并转发递归展开，图片、视频和文件附带已找到的本地媒体。
+func (a *API) GetForwardRecord(c *gin.Context) {
+	talker := c.Query("talker_id")
+	if talker == "" {
+		transport.BadRequest(c, "必须指定 talker_id")
+		return
+	}
+	seq, err := strconv.ParseInt(c.Query("seq"), 10, 64)
+	if err != nil {
+		transport.BadRequest(c, "无效的 seq 参数")
+		return
+	}
+
+	record, err := a.Store.GetForwardRecord(c.Request.Context(), talker, seq)
+	if err != nil {
+		log.Error().Err(err).Str("talker", talker).Int64("seq", seq).Msg("展开合并转发消息失败")
+		transport.InternalServerError(c, "展开合并转发消息失败")
+		return
+	}
+	if record == nil {
+		transport.NotFound(c, "消息不存在或不是合并转发消息")
+		return
+	}
+
+	transport.SendSuccess(c, record)
+}
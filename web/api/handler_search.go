@@ -1,11 +1,16 @@
 package api
 
 import (
+	"errors"
 	"html"
+	"regexp/syntax"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
+	"github.com/afumu/wetrace/internal/model"
+	"github.com/afumu/wetrace/pkg/textmatch"
 	"github.com/afumu/wetrace/pkg/util"
 	"github.com/afumu/wetrace/store/types"
 	"github.com/afumu/wetrace/web/transport"
@@ -15,11 +20,14 @@ import (
 
 // SearchRequest 全文搜索请求参数
 type SearchRequest struct {
-	Keyword   string `form:"keyword" binding:"required"`
+	Keyword   string `form:"keyword" binding:"required"` // 关键词模式下支持搜索语法，见 types.ParseSearch
+	Mode      string `form:"mode"`                       // keyword (默认)、regex 或 fuzzy
+	Distance  int    `form:"distance"`                   // 模糊搜索允许的最大编辑距离，0 表示按关键词长度自动选择
 	Talker    string `form:"talker"`
 	Sender    string `form:"sender"`
 	MsgType   int    `form:"type"`
 	TimeRange string `form:"time_range"`
+	Order     string `form:"order,default=rank"` // rank 按相关度，time 按时间倒序
 	transport.PaginationQuery
 }
 
@@ -46,6 +54,59 @@ func (a *API) Search(c *gin.Context) {
 		EndTime:   end,
 		Limit:     req.Limit,
 		Offset:    req.Offset,
+		Rank:      req.Order != "time",
+	}
+
+	// find 返回内容中的匹配位置，用于高亮
+	var find func(content string) []textmatch.Range
+	switch req.Mode {
+	case "", types.SearchModeKeyword:
+		expr, err := types.ParseSearch(req.Keyword)
+		if err != nil {
+			var perr *types.SearchParseError
+			if errors.As(err, &perr) {
+				transport.BadRequestWithDetails(c, perr.Error(), perr)
+				return
+			}
+			transport.BadRequest(c, "无效的搜索参数: "+err.Error())
+			return
+		}
+		if !expr.Apply(&query) {
+			// from:/in: 与请求中的发送者或会话没有交集
+			transport.SendSuccess(c, &model.SearchResult{Items: []*model.SearchItem{}})
+			return
+		}
+		keywords := expr.Keywords()
+		find = func(content string) []textmatch.Range {
+			return textmatch.FindKeywords(content, keywords)
+		}
+	case types.SearchModeRegex:
+		re, err := textmatch.CompileRegexp(req.Keyword)
+		if err != nil {
+			details := gin.H{"message": err.Error()}
+			var serr *syntax.Error
+			if errors.As(err, &serr) {
+				details = gin.H{"token": serr.Expr, "message": string(serr.Code)}
+			}
+			transport.BadRequestWithDetails(c, "正则表达式错误: "+err.Error(), details)
+			return
+		}
+		query.Mode = types.SearchModeRegex
+		find = func(content string) []textmatch.Range {
+			return textmatch.FindRegexp(re, content)
+		}
+	case types.SearchModeFuzzy:
+		if req.Distance <= 0 {
+			req.Distance = textmatch.DefaultDistance(req.Keyword)
+		}
+		query.Mode = types.SearchModeFuzzy
+		query.Distance = req.Distance
+		find = func(content string) []textmatch.Range {
+			return textmatch.FindFuzzy(content, req.Keyword, req.Distance)
+		}
+	default:
+		transport.BadRequest(c, "未知的搜索模式: "+req.Mode)
+		return
 	}
 
 	result, err := a.Store.SearchMessages(c.Request.Context(), query)
@@ -55,9 +116,11 @@ func (a *API) Search(c *gin.Context) {
 		return
 	}
 
-	// 为搜索结果添加高亮
+	// 为搜索结果添加高亮和匹配位置
 	for _, item := range result.Items {
-		item.Highlight = highlightKeyword(item.Content, req.Keyword)
+		ranges := find(item.Content)
+		item.Highlight = highlightKeyword(item.Content, ranges)
+		item.Matches = matchRanges(item.Content, ranges)
 	}
 
 	transport.SendSuccess(c, result)
@@ -111,12 +174,34 @@ func (a *API) SearchContext(c *gin.Context) {
 	})
 }
 
-// highlightKeyword 对内容中的关键词进行 HTML 高亮标记
-func highlightKeyword(content, keyword string) string {
-	if keyword == "" || content == "" {
-		return content
+// highlightKeyword 按匹配位置 (字节区间) 对内容进行 HTML 高亮标记，内容始终经过 HTML 转义
+func highlightKeyword(content string, ranges []textmatch.Range) string {
+	if len(ranges) == 0 || content == "" {
+		return html.EscapeString(content)
+	}
+	var sb strings.Builder
+	last := 0
+	for _, r := range ranges {
+		sb.WriteString(html.EscapeString(content[last:r.Start]))
+		sb.WriteString("<em>" + html.EscapeString(content[r.Start:r.End]) + "</em>")
+		last = r.End
+	}
+	sb.WriteString(html.EscapeString(content[last:]))
+	return sb.String()
+}
+
+// matchRanges 将字节区间转换为按字符计的匹配位置
+func matchRanges(content string, ranges []textmatch.Range) []model.MatchRange {
+	if len(ranges) == 0 {
+		return nil
+	}
+	out := make([]model.MatchRange, len(ranges))
+	for i, r := range ranges {
+		start := utf8.RuneCountInString(content[:r.Start])
+		out[i] = model.MatchRange{
+			Start: start,
+			End:   start + utf8.RuneCountInString(content[r.Start:r.End]),
+		}
 	}
-	escaped := html.EscapeString(content)
-	escapedKeyword := html.EscapeString(keyword)
-	return strings.ReplaceAll(escaped, escapedKeyword, "<em>"+escapedKeyword+"</em>")
+	return out
 }
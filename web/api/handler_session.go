@@ -1,6 +1,9 @@
 package api
 
 import (
+	"slices"
+	"strings"
+
 	"github.com/afumu/wetrace/internal/model"
 	"github.com/afumu/wetrace/store/types"
 	"github.com/afumu/wetrace/web/transport"
@@ -26,6 +29,8 @@ func (a *API) GetSessions(c *gin.Context) {
 	// 2. 构建 store 查询
 	query := types.SessionQuery{
 		Keyword: keywordQuery.Keyword,
+		Search:  keywordQuery.Search,
+		Label:   c.Query("label"),
 		Limit:   pageQuery.Limit,
 		Offset:  pageQuery.Offset,
 	}
@@ -48,6 +53,7 @@ func (a *API) GetSessions(c *gin.Context) {
 }
 
 // DeleteSession 处理删除会话的请求。
+// 会话只从列表中隐藏：隐藏的会话保存在账号配置 HIDDEN_SESSIONS 中，数据库文件不做修改。
 func (a *API) DeleteSession(c *gin.Context) {
 	username := c.Param("id")
 	if username == "" {
@@ -55,11 +61,29 @@ func (a *API) DeleteSession(c *gin.Context) {
 		return
 	}
 
-	if err := a.Store.DeleteSession(c.Request.Context(), username); err != nil {
-		log.Error().Err(err).Str("username", username).Msg("删除会话失败")
-		transport.InternalServerError(c, "删除会话失败")
-		return
+	a.hiddenMu.Lock()
+	defer a.hiddenMu.Unlock()
+	hidden := a.hiddenSessions()
+	if !slices.Contains(hidden, username) {
+		hidden = append(hidden, username)
+		if err := a.saveAccountConfig(map[string]string{"HIDDEN_SESSIONS": strings.Join(hidden, ",")}); err != nil {
+			log.Error().Err(err).Str("username", username).Msg("删除会话失败")
+			transport.InternalServerError(c, "删除会话失败")
+			return
+		}
 	}
+	a.Store.SetHiddenSessions(hidden)
 
 	transport.SendSuccess(c, nil)
 }
+
+// hiddenSessions 读取账号配置中已删除 (隐藏) 的会话
+func (a *API) hiddenSessions() []string {
+	var names []string
+	for _, name := range strings.Split(a.setting("HIDDEN_SESSIONS"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -1,9 +1,10 @@
 package api
 
 import (
+	"strconv"
+
 	"github.com/afumu/wetrace/web/transport"
 	"github.com/gin-gonic/gin"
-	"github.com/spf13/viper"
 )
 
 // GetSyncConfig returns the current auto-sync configuration.
@@ -38,10 +39,11 @@ func (a *API) UpdateSyncConfig(c *gin.Context) {
 
 	a.SyncScheduler.Configure(req.Enabled, req.IntervalMin)
 
-	// Persist to viper config
-	viper.Set("SYNC_ENABLED", req.Enabled)
-	viper.Set("SYNC_INTERVAL_MINUTES", req.IntervalMin)
-	_ = viper.WriteConfig()
+	// Persist to account config
+	_ = a.saveAccountConfig(map[string]string{
+		"SYNC_ENABLED":          strconv.FormatBool(req.Enabled),
+		"SYNC_INTERVAL_MINUTES": strconv.Itoa(req.IntervalMin),
+	})
 
 	transport.SendSuccess(c, gin.H{"status": "ok"})
 }
@@ -7,7 +7,9 @@ import (
 	"sync"
 	"time"
 
+	"github.com/afumu/wetrace/internal/account"
 	"github.com/afumu/wetrace/internal/ai"
+	"github.com/afumu/wetrace/internal/model"
 	"github.com/afumu/wetrace/internal/tts"
 	"github.com/afumu/wetrace/pkg/util"
 	"github.com/afumu/wetrace/web/transport"
@@ -109,6 +111,9 @@ func (a *API) GetSystemStatus(c *gin.Context) {
 	// 获取当前配置中的密钥，用于前端判断是否存在
 	status := gin.H{
 		"store_initialized": true,
+		"account":           a.AccountID,
+		"wechat_version":    a.Store.Version(),
+		"index_revision":    a.Store.Revision(),
 		"config": gin.H{
 			"wechat_db_key":      a.Conf.WechatDbKey,
 			"image_key":          a.Media.ImageKey,
@@ -120,6 +125,25 @@ func (a *API) GetSystemStatus(c *gin.Context) {
 	transport.SendSuccess(c, status)
 }
 
+// GetDiagnostics 返回数据目录的诊断信息：识别到的消息分片、每个数据库文件的表和行数以及完整性检查结果。
+// check 参数为 quick (默认)、full 或 none，full 会逐页校验，大文件耗时较长。
+func (a *API) GetDiagnostics(c *gin.Context) {
+	check := c.DefaultQuery("check", model.DiagnosticsCheckQuick)
+	switch check {
+	case model.DiagnosticsCheckQuick, model.DiagnosticsCheckFull, model.DiagnosticsCheckNone:
+	default:
+		transport.BadRequest(c, "check 参数必须为 quick、full 或 none")
+		return
+	}
+
+	diag, err := a.Store.GetDiagnostics(c.Request.Context(), check)
+	if err != nil {
+		transport.InternalServerError(c, "生成诊断信息失败: "+err.Error())
+		return
+	}
+	transport.SendSuccess(c, diag)
+}
+
 // DetectWeChatInstallPath 检测微信安装路径
 func (a *API) DetectWeChatInstallPath(c *gin.Context) {
 	paths := util.FindWeChatInstallPaths()
@@ -146,23 +170,38 @@ func (a *API) UpdateConfig(c *gin.Context) {
 		"WECHAT_DB_SRC_PATH": true,
 	}
 
+	// 微信安装路径为全局配置，解锁后同步给其他账号
+	defer a.shareGlobals()
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
 	changed := false
+	scoped := make(map[string]string)
 	for k, v := range req {
 		if allowedKeys[k] {
-			viper.Set(k, v)
 			// 同步更新内存中的配置对象
 			if k == "WXKEY_WECHAT_PATH" {
 				a.Conf.WechatPath = v
 			} else if k == "WECHAT_DB_SRC_PATH" {
 				a.Conf.WechatDbSrcPath = v
 			}
+			// 源数据路径按账号保存
+			if account.Scoped(k) && !a.isDefaultAccount() {
+				scoped[k] = v
+				continue
+			}
+			viper.Set(k, v)
 			changed = true
 		}
 	}
 
+	if len(scoped) > 0 {
+		if err := a.saveAccountConfig(scoped); err != nil {
+			transport.InternalServerError(c, "保存账号配置失败: "+err.Error())
+			return
+		}
+	}
+
 	if changed {
 		if err := viper.WriteConfig(); err != nil {
 			// 如果文件不存在，尝试创建
@@ -224,6 +263,8 @@ func (a *API) UpdateAIConfig(c *gin.Context) {
 		}
 	}
 
+	// AI 和 TTS 为全局配置，解锁后同步给其他账号
+	defer a.shareGlobals()
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
@@ -576,6 +617,8 @@ func (a *API) UpdateTTSConfig(c *gin.Context) {
 		return
 	}
 
+	// AI 和 TTS 为全局配置，解锁后同步给其他账号
+	defer a.shareGlobals()
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
@@ -5,6 +5,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/afumu/wetrace/internal/model"
 	"github.com/afumu/wetrace/pkg/util"
 	"github.com/afumu/wetrace/pkg/wordcloud"
 	"github.com/afumu/wetrace/store/types"
@@ -45,7 +46,6 @@ func (a *API) GetWordCloud(c *gin.Context) {
 		Talker:    talker,
 		StartTime: start,
 		EndTime:   end,
-		Limit:     5000,
 	}
 
 	// 限定发送人
@@ -53,29 +53,27 @@ func (a *API) GetWordCloud(c *gin.Context) {
 		query.Sender = sender
 	}
 
-	msgs, err := a.Store.GetMessages(context.Background(), query)
+	// 流式统计文本消息的词频，不限制消息条数
+	counter := wordcloud.NewCounter()
+	err := a.Store.IterateMessages(c.Request.Context(), query, func(m *model.Message) error {
+		if m.Type == 1 {
+			counter.Add(m.Content)
+		}
+		return nil
+	})
 	if err != nil {
 		transport.InternalServerError(c, err.Error())
 		return
 	}
 
-	// 提取文本消息内容
-	texts := make([]string, 0, len(msgs))
-	for _, m := range msgs {
-		if m.Type == 1 {
-			texts = append(texts, m.Content)
-		}
-	}
-
-	if len(texts) == 0 {
+	if counter.Len() == 0 {
 		transport.SendSuccess(c, &wordcloud.WordCloudResult{
 			Words: []*wordcloud.WordItem{},
 		})
 		return
 	}
 
-	result := wordcloud.Analyze(texts, limit)
-	transport.SendSuccess(c, result)
+	transport.SendSuccess(c, counter.Result(limit))
 }
 
 // GetWordCloudGlobal 获取全局词云数据（不限定会话）
@@ -100,6 +98,12 @@ func (a *API) GetWordCloudGlobal(c *gin.Context) {
 		}
 	}
 
+	// 按标签筛选时只统计带有该标签的联系人的会话
+	if label := c.Query("label"); label != "" {
+		a.wordCloudByLabel(c, label, start, end, limit)
+		return
+	}
+
 	msgs, err := a.Store.SearchGlobalMessages(context.Background(), types.MessageQuery{
 		StartTime: start,
 		EndTime:   end,
@@ -128,3 +132,40 @@ func (a *API) GetWordCloudGlobal(c *gin.Context) {
 	result := wordcloud.Analyze(texts, limit)
 	transport.SendSuccess(c, result)
 }
+
+// wordCloudByLabel 流式统计带有指定标签的联系人会话中的文本消息词频
+func (a *API) wordCloudByLabel(c *gin.Context, label string, start, end time.Time, limit int) {
+	ctx := c.Request.Context()
+	sessions, err := a.Store.GetSessions(ctx, types.SessionQuery{Label: label})
+	if err != nil {
+		transport.InternalServerError(c, err.Error())
+		return
+	}
+
+	// 消息按会话分表存储，逐个会话读取
+	counter := wordcloud.NewCounter()
+	for _, s := range sessions {
+		err = a.Store.IterateMessages(ctx, types.MessageQuery{
+			Talker:    s.UserName,
+			StartTime: start,
+			EndTime:   end,
+			MsgType:   model.MessageTypeText,
+		}, func(m *model.Message) error {
+			counter.Add(m.Content)
+			return nil
+		})
+		if err != nil {
+			transport.InternalServerError(c, err.Error())
+			return
+		}
+	}
+
+	if counter.Len() == 0 {
+		transport.SendSuccess(c, &wordcloud.WordCloudResult{
+			Words: []*wordcloud.WordItem{},
+		})
+		return
+	}
+
+	transport.SendSuccess(c, counter.Result(limit))
+}
@@ -15,7 +15,6 @@ import (
 	"github.com/afumu/wetrace/wxkey"
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog/log"
-	"github.com/spf13/viper"
 )
 
 // updateEnv 更新 .env 文件中的配置项
@@ -228,13 +227,12 @@ func (a *API) GetWeChatDbKey(c *gin.Context) {
 	// 写入 .env 文件并同步更新内存配置
 	updates := map[string]string{"WECHAT_DB_KEY": key}
 
-	if err := updateEnv(updates); err != nil {
-		log.Error().Err(err).Msg("更新 .env 文件失败")
+	if err := a.saveAccountConfig(updates); err != nil {
+		log.Error().Err(err).Msg("保存数据库密钥失败")
 	} else {
-		log.Info().Msg("已自动更新 .env 文件")
-		// 同步更新内存中的配置和 viper 状态
+		log.Info().Str("account", a.AccountID).Msg("已自动保存数据库密钥")
+		// 同步更新内存中的配置
 		a.mu.Lock()
-		viper.Set("WECHAT_DB_KEY", key)
 		a.Conf.WechatDbKey = key
 		a.mu.Unlock()
 	}
@@ -346,14 +344,12 @@ func (a *API) GetWeChatImageKey(c *gin.Context) {
 				"IMAGE_KEY": keyResult.AesKey,
 				"XOR_KEY":   xorStr,
 			}
-			if err := updateEnv(updates); err != nil {
-				log.Error().Err(err).Msg("更新 .env 文件中的图片密钥失败")
+			if err := a.saveAccountConfig(updates); err != nil {
+				log.Error().Err(err).Msg("保存图片密钥失败")
 			} else {
-				log.Info().Msg("已自动更新 .env 文件中的 IMAGE_KEY 和 XOR_KEY")
-				// 同步更新内存中的配置和 viper 状态，确保后续解密和状态获取使用最新密钥
+				log.Info().Str("account", a.AccountID).Msg("已自动保存 IMAGE_KEY 和 XOR_KEY")
+				// 同步更新内存中的配置，确保后续解密和状态获取使用最新密钥
 				a.mu.Lock()
-				viper.Set("IMAGE_KEY", keyResult.AesKey)
-				viper.Set("XOR_KEY", xorStr)
 
 				a.Conf.ImageKey = keyResult.AesKey
 				a.Conf.XorKey = xorStr
@@ -0,0 +1,132 @@
+package export
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileManifestEntry 是文件压缩包清单中的一个文件
+type FileManifestEntry struct {
+	MD5         string `json:"md5"`              // 消息中记录的 md5
+	Name        string `json:"name,omitempty"`   // 原文件名
+	Path        string `json:"path,omitempty"`   // 压缩包内路径
+	Size        int64  `json:"size,omitempty"`   // 字节数
+	SHA256      string `json:"sha256,omitempty"` // 文件内容的 SHA-256
+	MD5Verified bool   `json:"md5Verified"`      // 文件内容的 md5 与消息中记录的一致
+	Error       string `json:"error,omitempty"`  // 未能打包的原因
+}
+
+// FileManifest 是文件压缩包的清单 (manifest.json)
+type FileManifest struct {
+	ExportTime string               `json:"exportTime"`
+	Count      int                  `json:"count"`   // 打包的文件数
+	Missing    int                  `json:"missing"` // 本地找不到的文件数
+	Files      []*FileManifestEntry `json:"files"`
+}
+
+// WriteFilesZip 将按 md5 选中的文件附件打包为 ZIP 写入 w。
+// 文件放在 files/ 下，并附带 manifest.json 和可用 sha256sum -c 校验的 SHA256SUMS；本地找不到的文件记录在清单中。
+func (s *Service) WriteFilesZip(ctx context.Context, w io.Writer, md5s []string) error {
+	zw := zip.NewWriter(w)
+	manifest := &FileManifest{ExportTime: time.Now().Format(time.RFC3339), Files: []*FileManifestEntry{}}
+	var sums strings.Builder
+
+	seenKey := make(map[string]bool)
+	usedName := make(map[string]bool)
+	for _, key := range md5s {
+		key = strings.ToLower(strings.TrimSpace(key))
+		if key == "" || seenKey[key] {
+			continue
+		}
+		seenKey[key] = true
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		entry := &FileManifestEntry{MD5: key}
+		manifest.Files = append(manifest.Files, entry)
+
+		mediaInfo, err := s.Store.GetMedia(ctx, "file", key)
+		if err != nil {
+			entry.Error = "本地没有该文件的记录"
+			manifest.Missing++
+			continue
+		}
+		mediaInfo.Path = filepath.ToSlash(mediaInfo.Path)
+		entry.Name = mediaInfo.Name
+
+		prepared := s.Media.Prepare(mediaInfo, false)
+		if prepared.Error != nil {
+			entry.Error = prepared.Error.Error()
+			manifest.Missing++
+			continue
+		}
+
+		name := safeFileName(mediaInfo.Name)
+		if name == "" {
+			name = key
+		}
+		if usedName[name] {
+			name = key[:min(8, len(key))] + "_" + name
+		}
+		usedName[name] = true
+		entry.Path = path.Join("files", name)
+
+		fw, err := zw.Create(entry.Path)
+		if err != nil {
+			return fmt.Errorf("写入压缩包失败: %w", err)
+		}
+		if _, err := fw.Write(prepared.Content); err != nil {
+			return fmt.Errorf("写入压缩包失败: %w", err)
+		}
+
+		sha := sha256.Sum256(prepared.Content)
+		sum := md5.Sum(prepared.Content)
+		entry.Size = int64(len(prepared.Content))
+		entry.SHA256 = hex.EncodeToString(sha[:])
+		entry.MD5Verified = hex.EncodeToString(sum[:]) == key
+		manifest.Count++
+		fmt.Fprintf(&sums, "%s  %s\n", entry.SHA256, entry.Path)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	fw, err := zw.Create("manifest.json")
+	if err != nil {
+		return fmt.Errorf("写入压缩包失败: %w", err)
+	}
+	if _, err := fw.Write(data); err != nil {
+		return fmt.Errorf("写入压缩包失败: %w", err)
+	}
+	fw, err = zw.Create("SHA256SUMS")
+	if err != nil {
+		return fmt.Errorf("写入压缩包失败: %w", err)
+	}
+	if _, err := io.WriteString(fw, sums.String()); err != nil {
+		return fmt.Errorf("写入压缩包失败: %w", err)
+	}
+	return zw.Close()
+}
+
+// safeFileName 去掉路径部分并替换文件名中的非法字符
+func safeFileName(name string) string {
+	name = path.Base(filepath.ToSlash(strings.TrimSpace(name)))
+	if name == "." || name == "/" || name == ".." {
+		return ""
+	}
+	return strings.NewReplacer(
+		"\\", "_", ":", "_", "*", "_", "?", "_", "\"", "_", "<", "_", ">", "_", "|", "_",
+	).Replace(name)
+}
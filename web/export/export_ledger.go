@@ -0,0 +1,139 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+
+	"github.com/afumu/wetrace/internal/model"
+	"github.com/afumu/wetrace/store/types"
+	"github.com/xuri/excelize/v2"
+)
+
+var ledgerKinds = map[string]string{model.LedgerTransfer: "转账", model.LedgerRedEnvelope: "红包"}
+
+var ledgerStatuses = map[string]string{model.LedgerSend: "发起", model.LedgerReceive: "收款", model.LedgerRefund: "退还"}
+
+var ledgerDirections = map[string]string{model.LedgerIn: "收入", model.LedgerOut: "支出"}
+
+var ledgerHeader = []string{"时间", "会话", "会话ID", "类型", "状态", "方向", "金额(元)", "备注", "对方", "对方ID", "转账ID"}
+
+// ledgerRow 返回账目在明细表中的一行
+func ledgerRow(e *model.LedgerEntry) []string {
+	amount := ""
+	if e.Kind == model.LedgerTransfer {
+		amount = formatAmount(e.Amount)
+	}
+	return []string{
+		e.Time.Format("2006-01-02 15:04:05"),
+		e.TalkerName,
+		e.Talker,
+		ledgerKinds[e.Kind],
+		ledgerStatuses[e.Status],
+		ledgerDirections[e.Direction],
+		amount,
+		e.Memo,
+		e.CounterpartName,
+		e.Counterpart,
+		e.TransferID,
+	}
+}
+
+func formatAmount(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+// ExportLedgerCSV 导出转账和红包明细为 CSV 格式
+func (s *Service) ExportLedgerCSV(ctx context.Context, q types.MessageQuery) ([]byte, error) {
+	ledger, err := s.Store.GetLedger(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	// UTF-8 BOM，确保 Excel 正确识别编码
+	buf.Write([]byte{0xEF, 0xBB, 0xBF})
+
+	w := csv.NewWriter(&buf)
+	if err := w.Write(ledgerHeader); err != nil {
+		return nil, fmt.Errorf("写入CSV表头失败: %w", err)
+	}
+	for _, e := range ledger.Entries {
+		if err := w.Write(ledgerRow(e)); err != nil {
+			return nil, fmt.Errorf("写入CSV数据失败: %w", err)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ExportLedgerXLSX 导出转账和红包为 XLSX 格式，包含明细、按联系人合计和按月合计三个工作表
+func (s *Service) ExportLedgerXLSX(ctx context.Context, q types.MessageQuery) ([]byte, error) {
+	ledger, err := s.Store.GetLedger(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	f := excelize.NewFile()
+	defer f.Close()
+
+	headerStyle, _ := f.NewStyle(&excelize.Style{
+		Font: &excelize.Font{Bold: true},
+	})
+	writeSheet := func(sheet string, header []string, rows [][]string) {
+		for i, h := range header {
+			cell, _ := excelize.CoordinatesToCellName(i+1, 1)
+			f.SetCellValue(sheet, cell, h)
+		}
+		last, _ := excelize.CoordinatesToCellName(len(header), 1)
+		f.SetCellStyle(sheet, "A1", last, headerStyle)
+		for i, row := range rows {
+			for j, val := range row {
+				cell, _ := excelize.CoordinatesToCellName(j+1, i+2)
+				f.SetCellValue(sheet, cell, val)
+			}
+		}
+	}
+	// totalRows 返回合计行，withName 为 true 时第二列为名称；最后追加总计行
+	totalRows := func(totals []*model.LedgerTotal, withName bool) [][]string {
+		rows := make([][]string, 0, len(totals)+1)
+		for _, t := range append(totals[:len(totals):len(totals)], ledger.Total) {
+			key := t.Key
+			if t == ledger.Total {
+				key = "合计"
+			}
+			row := []string{key}
+			if withName {
+				row = append(row, t.Name)
+			}
+			rows = append(rows, append(row, formatAmount(t.In), formatAmount(t.Out), formatAmount(t.Net), strconv.Itoa(t.Count)))
+		}
+		return rows
+	}
+
+	f.SetSheetName("Sheet1", "明细")
+	entries := make([][]string, 0, len(ledger.Entries))
+	for _, e := range ledger.Entries {
+		entries = append(entries, ledgerRow(e))
+	}
+	writeSheet("明细", ledgerHeader, entries)
+	f.SetColWidth("明细", "A", "A", 20)
+	f.SetColWidth("明细", "H", "H", 30)
+
+	f.NewSheet("按联系人")
+	writeSheet("按联系人", []string{"对方ID", "名称", "收入(元)", "支出(元)", "净额(元)", "笔数"}, totalRows(ledger.ByContact, true))
+
+	f.NewSheet("按月")
+	writeSheet("按月", []string{"月份", "收入(元)", "支出(元)", "净额(元)", "笔数"}, totalRows(ledger.ByMonth, false))
+
+	var buf bytes.Buffer
+	if err := f.Write(&buf); err != nil {
+		return nil, fmt.Errorf("写入XLSX失败: %w", err)
+	}
+	return buf.Bytes(), nil
+}
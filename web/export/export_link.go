@@ -0,0 +1,122 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+
+	"github.com/afumu/wetrace/internal/model"
+	"github.com/afumu/wetrace/store/types"
+)
+
+var linkKinds = map[string]string{
+	model.LinkKindLink:        "链接",
+	model.LinkKindMusic:       "音乐",
+	model.LinkKindChannel:     "视频号",
+	model.LinkKindMiniProgram: "小程序",
+}
+
+// ExportLinksCSV 导出链接库为 CSV 格式，每个去重后的链接一行
+func (s *Service) ExportLinksCSV(ctx context.Context, q types.LinkQuery) ([]byte, error) {
+	lib, err := s.Store.GetLinks(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	// UTF-8 BOM，确保 Excel 正确识别编码
+	buf.Write([]byte{0xEF, 0xBB, 0xBF})
+
+	w := csv.NewWriter(&buf)
+	header := []string{"标题", "链接", "类型", "域名", "来源", "分享次数", "首次分享", "最近分享", "分享者", "会话"}
+	if err := w.Write(header); err != nil {
+		return nil, fmt.Errorf("写入CSV表头失败: %w", err)
+	}
+	for _, l := range lib.Links {
+		var sharers, talkers []string
+		seenSharer, seenTalker := make(map[string]bool), make(map[string]bool)
+		for _, sh := range l.Shares {
+			if name := firstNonEmpty(sh.SenderName, sh.Sender); !seenSharer[name] {
+				seenSharer[name] = true
+				sharers = append(sharers, name)
+			}
+			if name := firstNonEmpty(sh.TalkerName, sh.Talker); !seenTalker[name] {
+				seenTalker[name] = true
+				talkers = append(talkers, name)
+			}
+		}
+		row := []string{
+			l.Title,
+			l.URL,
+			linkKinds[l.Kind],
+			l.Domain,
+			l.Source,
+			strconv.Itoa(l.Count),
+			l.FirstTime.Format("2006-01-02 15:04:05"),
+			l.LastTime.Format("2006-01-02 15:04:05"),
+			strings.Join(sharers, "、"),
+			strings.Join(talkers, "、"),
+		}
+		if err := w.Write(row); err != nil {
+			return nil, fmt.Errorf("写入CSV数据失败: %w", err)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ExportLinksBookmarks 导出链接库为 Netscape 书签 HTML，按域名分文件夹，可导入各浏览器
+func (s *Service) ExportLinksBookmarks(ctx context.Context, q types.LinkQuery) ([]byte, error) {
+	lib, err := s.Store.GetLinks(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	// 文件夹按链接首次出现的顺序排列，链接按最近分享时间倒序
+	index := make(map[string]int)
+	var folders [][]*model.SharedLink
+	for _, l := range lib.Links {
+		i, ok := index[l.Domain]
+		if !ok {
+			i = len(folders)
+			index[l.Domain] = i
+			folders = append(folders, nil)
+		}
+		folders[i] = append(folders[i], l)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("<!DOCTYPE NETSCAPE-Bookmark-file-1>\n")
+	buf.WriteString("<META HTTP-EQUIV=\"Content-Type\" CONTENT=\"text/html; charset=UTF-8\">\n")
+	buf.WriteString("<TITLE>Bookmarks</TITLE>\n<H1>WeTrace 链接库</H1>\n<DL><p>\n")
+	for _, links := range folders {
+		fmt.Fprintf(&buf, "    <DT><H3>%s</H3>\n    <DL><p>\n", html.EscapeString(links[0].Domain))
+		for _, l := range links {
+			title := firstNonEmpty(l.Title, l.URL)
+			fmt.Fprintf(&buf, "        <DT><A HREF=\"%s\" ADD_DATE=\"%d\" LAST_MODIFIED=\"%d\">%s</A>\n",
+				html.EscapeString(l.URL), l.FirstTime.Unix(), l.LastTime.Unix(), html.EscapeString(title))
+			if desc := strings.Join(strings.Fields(l.Desc), " "); desc != "" {
+				fmt.Fprintf(&buf, "        <DD>%s\n", html.EscapeString(desc))
+			}
+		}
+		buf.WriteString("    </DL><p>\n")
+	}
+	buf.WriteString("</DL><p>\n")
+	return buf.Bytes(), nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
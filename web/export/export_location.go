@@ -0,0 +1,194 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/afumu/wetrace/internal/model"
+	"github.com/afumu/wetrace/pkg/geo"
+	"github.com/afumu/wetrace/store/types"
+)
+
+// wgs84 返回位置点的 WGS-84 坐标，GeoJSON、KML、GPX 均规定使用该坐标系
+func wgs84(p *model.LocationPoint) (lat, lng float64) {
+	return geo.GCJ02ToWGS84(p.Lat, p.Lng)
+}
+
+// senderGroups 按发送者分组位置点，组按首次出现的顺序排列，组内保持时间顺序
+func senderGroups(points []*model.LocationPoint) [][]*model.LocationPoint {
+	index := make(map[string]int)
+	var groups [][]*model.LocationPoint
+	for _, p := range points {
+		i, ok := index[p.Sender]
+		if !ok {
+			i = len(groups)
+			index[p.Sender] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], p)
+	}
+	return groups
+}
+
+// locationDesc 返回位置点的说明文字：会话、发送者、地址
+func locationDesc(p *model.LocationPoint) string {
+	desc := fmt.Sprintf("%s | %s | %s", p.TalkerName, senderLabel(p), p.Time.Format("2006-01-02 15:04:05"))
+	if p.Label != "" {
+		desc += " | " + p.Label
+	}
+	return desc
+}
+
+func senderLabel(p *model.LocationPoint) string {
+	if p.SenderName != "" {
+		return p.SenderName
+	}
+	return p.Sender
+}
+
+type geoJSONFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   geoJSONPoint           `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type geoJSONPoint struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"` // 经度在前
+}
+
+// ExportLocationsGeoJSON 导出位置为 GeoJSON FeatureCollection，每个位置一个 Point
+func (s *Service) ExportLocationsGeoJSON(ctx context.Context, q types.MessageQuery) ([]byte, error) {
+	points, err := s.Store.GetLocations(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	features := make([]geoJSONFeature, 0, len(points))
+	for _, p := range points {
+		lat, lng := wgs84(p)
+		features = append(features, geoJSONFeature{
+			Type:     "Feature",
+			Geometry: geoJSONPoint{Type: "Point", Coordinates: [2]float64{lng, lat}},
+			Properties: map[string]interface{}{
+				"seq":        strconv.FormatInt(p.Seq, 10),
+				"time":       p.Time.Format(time.RFC3339),
+				"talker":     p.Talker,
+				"talkerName": p.TalkerName,
+				"sender":     p.Sender,
+				"senderName": p.SenderName,
+				"name":       p.Name(),
+				"label":      p.Label,
+				"cityName":   p.CityName,
+			},
+		})
+	}
+	return json.MarshalIndent(map[string]interface{}{
+		"type":     "FeatureCollection",
+		"features": features,
+	}, "", "  ")
+}
+
+type kmlDocument struct {
+	XMLName xml.Name    `xml:"kml"`
+	NS      string      `xml:"xmlns,attr"`
+	Name    string      `xml:"Document>name"`
+	Folders []kmlFolder `xml:"Document>Folder"`
+}
+
+type kmlFolder struct {
+	Name       string         `xml:"name"`
+	Placemarks []kmlPlacemark `xml:"Placemark"`
+}
+
+type kmlPlacemark struct {
+	Name        string `xml:"name"`
+	Description string `xml:"description"`
+	When        string `xml:"TimeStamp>when"`
+	Coordinates string `xml:"Point>coordinates"`
+}
+
+// ExportLocationsKML 导出位置为 KML，每个发送者一个文件夹，位置带有时间戳，可按时间回放
+func (s *Service) ExportLocationsKML(ctx context.Context, q types.MessageQuery) ([]byte, error) {
+	points, err := s.Store.GetLocations(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := kmlDocument{NS: "http://www.opengis.net/kml/2.2", Name: "WeTrace 位置"}
+	for _, group := range senderGroups(points) {
+		folder := kmlFolder{Name: senderLabel(group[0])}
+		for _, p := range group {
+			lat, lng := wgs84(p)
+			folder.Placemarks = append(folder.Placemarks, kmlPlacemark{
+				Name:        p.Name(),
+				Description: locationDesc(p),
+				When:        p.Time.Format(time.RFC3339),
+				Coordinates: strconv.FormatFloat(lng, 'f', 7, 64) + "," + strconv.FormatFloat(lat, 'f', 7, 64),
+			})
+		}
+		doc.Folders = append(doc.Folders, folder)
+	}
+	return marshalXML(doc)
+}
+
+type gpxDocument struct {
+	XMLName   xml.Name   `xml:"gpx"`
+	NS        string     `xml:"xmlns,attr"`
+	Version   string     `xml:"version,attr"`
+	Creator   string     `xml:"creator,attr"`
+	Waypoints []gpxPoint `xml:"wpt"`
+	Tracks    []gpxTrack `xml:"trk"`
+}
+
+type gpxPoint struct {
+	Lat  float64 `xml:"lat,attr"`
+	Lon  float64 `xml:"lon,attr"`
+	Time string  `xml:"time"`
+	Name string  `xml:"name,omitempty"`
+	Desc string  `xml:"desc,omitempty"`
+}
+
+type gpxTrack struct {
+	Name   string     `xml:"name"`
+	Points []gpxPoint `xml:"trkseg>trkpt"`
+}
+
+// ExportLocationsGPX 导出位置为 GPX：每个位置一个航点，每个发送者的位置按时间连成一条轨迹
+func (s *Service) ExportLocationsGPX(ctx context.Context, q types.MessageQuery) ([]byte, error) {
+	points, err := s.Store.GetLocations(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := gpxDocument{NS: "http://www.topografix.com/GPX/1/1", Version: "1.1", Creator: "WeTrace"}
+	for _, group := range senderGroups(points) {
+		track := gpxTrack{Name: senderLabel(group[0])}
+		for _, p := range group {
+			lat, lng := wgs84(p)
+			pt := gpxPoint{Lat: lat, Lon: lng, Time: p.Time.UTC().Format(time.RFC3339)}
+			track.Points = append(track.Points, pt)
+
+			pt.Name, pt.Desc = p.Name(), locationDesc(p)
+			doc.Waypoints = append(doc.Waypoints, pt)
+		}
+		doc.Tracks = append(doc.Tracks, track)
+	}
+	return marshalXML(doc)
+}
+
+func marshalXML(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return nil, fmt.Errorf("生成 XML 失败: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -2,12 +2,14 @@ package export
 
 import (
 	"archive/zip"
+	"bufio"
 	"bytes"
 	"context"
 	"crypto/md5"
 	_ "embed"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
@@ -30,63 +32,101 @@ type Service struct {
 }
 
 func (s *Service) ExportChat(ctx context.Context, talker string, talkerName string, startTime, endTime time.Time) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := s.WriteChat(ctx, buf, talker, talkerName, startTime, endTime); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteChat 将聊天记录以 HTML 压缩包的形式流式写入 w。
+// 消息逐条从 Store 读取，data.js 先写入临时文件，因此内存占用不随消息条数增长。
+func (s *Service) WriteChat(ctx context.Context, w io.Writer, talker string, talkerName string, startTime, endTime time.Time) error {
 	query := types.MessageQuery{
 		Talker:    talker,
 		StartTime: startTime,
 		EndTime:   endTime,
-		Limit:     200000,
-		Offset:    0,
 	}
-	messages, err := s.Store.GetMessages(ctx, query)
+
+	// zip 条目需顺序写入，媒体文件在遍历过程中写入，消息数据先暂存到临时文件
+	spool, err := os.CreateTemp("", "wetrace-export-*.js")
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("创建临时文件失败: %w", err)
 	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
 
-	buf := new(bytes.Buffer)
-	zw := zip.NewWriter(buf)
-
-	fmt.Printf("[Export] Processing %d messages for %s...\n", len(messages), talkerName)
+	zw := zip.NewWriter(w)
+	bw := bufio.NewWriter(spool)
+	bw.WriteString("window.CHAT_DATA = [")
 
-	for _, msg := range messages {
+	count := 0
+	forwarded := make(map[string]string)
+	err = s.Store.IterateMessages(ctx, query, func(msg *model.Message) error {
 		s.processMedia(ctx, zw, msg)
+		s.processForward(ctx, zw, msg, forwarded)
+
+		msgJson, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			bw.WriteByte(',')
+		}
+		bw.Write(msgJson)
+		count++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	bw.WriteString("];")
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("写入临时文件失败: %w", err)
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return err
 	}
 
-	msgJson, _ := json.Marshal(messages)
-	dataJs := fmt.Sprintf("window.CHAT_DATA = %s;", string(msgJson))
+	fmt.Printf("[Export] Processed %d messages for %s\n", count, talkerName)
+
 	fData, _ := zw.Create("data.js")
-	fData.Write([]byte(dataJs))
+	if _, err := io.Copy(fData, spool); err != nil {
+		return fmt.Errorf("写入 data.js 失败: %w", err)
+	}
 
 	html, err := s.buildHtml(talkerName)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	fHtml, _ := zw.Create("index.html")
 	fHtml.Write([]byte(html))
 
 	s.copyAssets(zw)
 
-	zw.Close()
-	return buf.Bytes(), nil
+	return zw.Close()
 }
 
 func (s *Service) ExportChatTxt(ctx context.Context, talker string, talkerName string, startTime, endTime time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := s.WriteChatTxt(ctx, &buf, talker, talkerName, startTime, endTime); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteChatTxt 将聊天记录以纯文本形式逐条写入 w
+func (s *Service) WriteChatTxt(ctx context.Context, w io.Writer, talker string, talkerName string, startTime, endTime time.Time) error {
 	query := types.MessageQuery{
 		Talker:    talker,
 		StartTime: startTime,
 		EndTime:   endTime,
-		Limit:     200000,
-		Offset:    0,
-	}
-	messages, err := s.Store.GetMessages(ctx, query)
-	if err != nil {
-		return nil, err
 	}
 
-	var buf bytes.Buffer
-
-	fmt.Printf("[ExportTXT] Processing %d messages for %s...\n", len(messages), talkerName)
-
-	for _, msg := range messages {
+	bw := bufio.NewWriter(w)
+	count := 0
+	err := s.Store.IterateMessages(ctx, query, func(msg *model.Message) error {
 		// 简单的格式化: [昵称] 时间 \n 内容
 		sender := msg.SenderName
 		if sender == "" {
@@ -97,15 +137,21 @@ func (s *Service) ExportChatTxt(ctx context.Context, talker string, talkerName s
 		msg.SetContent("host", "127.0.0.1:5200/api/v1/media")
 		content := msg.PlainTextContent()
 
-		line := fmt.Sprintf("[%s] %s\n%s\n\n",
+		_, err := fmt.Fprintf(bw, "[%s] %s\n%s\n\n",
 			sender,
 			msg.Time.Format("2006-01-02 15:04:05"),
 			content,
 		)
-		buf.WriteString(line)
+		count++
+		return err
+	})
+	if err != nil {
+		return err
 	}
 
-	return buf.Bytes(), nil
+	fmt.Printf("[ExportTXT] Processed %d messages for %s\n", count, talkerName)
+
+	return bw.Flush()
 }
 
 func (s *Service) buildHtml(talkerName string) (string, error) {
@@ -222,21 +268,10 @@ func (s *Service) processMedia(ctx context.Context, zw *zip.Writer, msg *model.M
 		return
 	}
 
-	ext := ""
-	contentType := strings.ToLower(prepared.ContentType)
-	if strings.Contains(contentType, "jpeg") {
-		ext = ".jpg"
-	} else if strings.Contains(contentType, "png") {
-		ext = ".png"
-	} else if strings.Contains(contentType, "gif") {
-		ext = ".gif"
-	} else if strings.Contains(contentType, "mp4") {
-		ext = ".mp4"
-	} else if strings.Contains(contentType, "mpeg") || strings.Contains(contentType, "mp3") {
-		ext = ".mp3"
-	} else if msg.Type == 43 {
+	ext := contentTypeExt(prepared.ContentType)
+	if ext == "" && msg.Type == 43 {
 		ext = ".mp4"
-	} else if msg.Type == 47 {
+	} else if ext == "" && msg.Type == 47 {
 		// 表情包保底使用 .gif (微信表情大多是 gif 或 png)
 		ext = ".gif"
 	}
@@ -269,6 +304,81 @@ func (s *Service) processMedia(ctx context.Context, zw *zip.Writer, msg *model.M
 	}
 }
 
+// contentTypeExt 返回媒体类型对应的文件扩展名，无法识别时返回空
+func contentTypeExt(contentType string) string {
+	contentType = strings.ToLower(contentType)
+	switch {
+	case strings.Contains(contentType, "jpeg"):
+		return ".jpg"
+	case strings.Contains(contentType, "png"):
+		return ".png"
+	case strings.Contains(contentType, "gif"):
+		return ".gif"
+	case strings.Contains(contentType, "mp4"):
+		return ".mp4"
+	case strings.Contains(contentType, "mpeg"), strings.Contains(contentType, "mp3"):
+		return ".mp3"
+	}
+	return ""
+}
+
+// processForward 展开合并转发消息，将其中的图片、视频和文件写入压缩包，
+// 展开后的记录保存在 Contents["forward"] 中，附件的相对路径记录在数据项的 File 字段。
+// written 记录已写入的附件 (md5 -> 相对路径)，同一附件被多次转发时只写入一次。
+func (s *Service) processForward(ctx context.Context, zw *zip.Writer, msg *model.Message, written map[string]string) {
+	record := model.ForwardRecordOf(msg)
+	if record == nil {
+		return
+	}
+	record.Walk(func(item *model.ForwardItem) {
+		mediaType := item.MediaType()
+		// md5 和标题都来自消息内容，md5 只接受十六进制，避免拼出压缩包外的路径
+		if mediaType == "" || !isHex(item.MD5) {
+			return
+		}
+		if relPath, ok := written[item.MD5]; ok {
+			item.File = relPath
+			return
+		}
+		mediaInfo, err := s.Store.GetMedia(ctx, mediaType, item.MD5)
+		if err != nil || mediaInfo == nil {
+			return
+		}
+		mediaInfo.Path = filepath.ToSlash(mediaInfo.Path)
+		prepared := s.Media.Prepare(mediaInfo, false)
+		if prepared.Error != nil || len(prepared.Content) == 0 {
+			return
+		}
+
+		name := item.MD5 + contentTypeExt(prepared.ContentType)
+		if title := safeFileName(item.Title); item.Kind == model.ForwardKindFile && title != "" {
+			name = item.MD5 + "_" + title
+		}
+		relPath := "media/forward/" + name
+		f, err := zw.Create(relPath)
+		if err != nil {
+			return
+		}
+		f.Write(prepared.Content)
+		written[item.MD5] = relPath
+		item.File = relPath
+	})
+	msg.Contents["forward"] = record
+}
+
+// isHex 判断 s 是否为非空的十六进制字符串
+func isHex(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
 func (s *Service) getStyles() string {
 	if s.StaticFS == nil {
 		return ""
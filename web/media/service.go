@@ -278,6 +278,10 @@ func (s *Service) Prepare(media *model.Media, isThumb bool) PreparedMedia {
 		return s.prepareImageWithFallback(media.Path, isThumb)
 	}
 
+	if media.Type == "video" && isThumb {
+		return s.prepareVideoThumb(media.Path)
+	}
+
 	res := s.prepareFile(media.Path, media.Type == "video")
 
 	// 如果是视频类型，强制设置为 video/mp4，确保前端可以播放
@@ -415,53 +419,173 @@ func (s *Service) doPrepareFile(absolutePath string, isVideo bool) PreparedMedia
 // ensureVideoTranscoded 确保视频被转码为兼容性好的格式（H.264/AAC MP4）。
 // 返回转码后文件的绝对路径。如果已存在缓存，直接返回。
 func (s *Service) ensureVideoTranscoded(srcPath string) (string, error) {
-	// 简单的缓存键生成策略：基于文件名或路径 hash
-	// 这里简单使用文件名加后缀，保存在系统临时目录的 chatlog_video_cache 子目录下
-	fileName := filepath.Base(srcPath)
-	cacheDir := filepath.Join(os.TempDir(), "chatlog_video_cache")
-	if err := os.MkdirAll(cacheDir, 0755); err != nil {
-		return "", fmt.Errorf("创建缓存目录失败: %w", err)
+	dstPath, err := videoCachePath(srcPath, ".mp4")
+	if err != nil {
+		return "", err
 	}
 
-	// 目标文件：文件名 + .transcoded.mp4
-	// 注意：如果不同目录下有同名文件，这里会冲突。
-	// 更严谨的做法是 hash(srcPath)。这里为了演示简单处理。
-	// 改进：使用 srcPath 的 hash
-	hashName := hex.EncodeToString([]byte(srcPath)) // 简单的 path hash，实际可用 md5
-	// 或者是文件名 + hash 的组合以便调试
-	dstPath := filepath.Join(cacheDir, fmt.Sprintf("%s_%s.mp4", fileName, hashName[:8]))
-
-	// 1. 检查缓存是否存在
+	// 1. 检查缓存是否存在；缓存只会由 renderVideoCache 原子地改名生成，存在即完整
 	if _, err := os.Stat(dstPath); err == nil {
-		// 缓存存在，直接返回
-		// log.Debug().Str("cache", dstPath).Msg("命中视频转码缓存")
 		return dstPath, nil
 	}
 
 	// 2. 调用 ffmpeg 转码
-	// 命令：ffmpeg -i <src> -c:v libx264 -c:a aac -strict experimental <dst>
-	// -y 覆盖输出
 	// -preset ultrafast 加速转码（牺牲压缩率）
-	log.Info().Str("src", srcPath).Msg("开始视频转码 (HEVC -> H.264)...")
+	err = renderVideoCache(dstPath, func(tmpPath string) error {
+		log.Info().Str("src", srcPath).Msg("开始视频转码 (HEVC -> H.264)...")
+		cmd := exec.Command(dat2img.FFMpegPath,
+			"-y",
+			"-i", srcPath,
+			"-c:v", "libx264",
+			"-preset", "ultrafast", // 追求速度
+			"-c:a", "aac",
+			tmpPath,
+		)
+		// 捕获输出以便调试
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("ffmpeg 转码失败: %w, output: %s", err, string(output))
+		}
+		log.Info().Str("dst", dstPath).Msg("视频转码成功")
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return dstPath, nil
+}
+
+// prepareVideoThumb 返回视频缩略图：优先使用微信保存在视频旁的缩略图 (V4 为 <md5>_thumb.jpg，V3 为 <md5>.jpg)，
+// 没有时用 ffmpeg 从视频中截取一帧。
+func (s *Service) prepareVideoThumb(relativePath string) PreparedMedia {
+	if strings.Contains(relativePath, "..") {
+		return PreparedMedia{Error: fmt.Errorf("无效的文件路径: %s", relativePath)}
+	}
 
-	cmd := exec.Command(dat2img.FFMpegPath,
-		"-y",
-		"-i", srcPath,
-		"-c:v", "libx264",
-		"-preset", "ultrafast", // 追求速度
-		"-c:a", "aac",
-		dstPath,
-	)
+	absolutePath := filepath.Join(s.WechatDbSrcPath, relativePath)
+	base := strings.TrimSuffix(absolutePath, filepath.Ext(absolutePath))
+	for _, candidate := range []string{base + "_thumb.jpg", base + ".jpg"} {
+		if content, err := os.ReadFile(candidate); err == nil && len(content) > 0 {
+			return PreparedMedia{Content: content, ContentType: detectContentType(content)}
+		}
+	}
 
-	// 捕获输出以便调试
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return "", fmt.Errorf("ffmpeg 转码失败: %w, output: %s", err, string(output))
+	if _, err := os.Stat(absolutePath); os.IsNotExist(err) {
+		return PreparedMedia{Error: fmt.Errorf("文件在磁盘上不存在: %s", absolutePath)}
+	}
+	thumbPath, err := s.ensureVideoThumbnail(absolutePath)
+	if err != nil {
+		return PreparedMedia{Error: err}
+	}
+	content, err := os.ReadFile(thumbPath)
+	if err != nil {
+		return PreparedMedia{Error: fmt.Errorf("读取视频缩略图失败: %w", err)}
+	}
+	return PreparedMedia{Content: content, ContentType: "image/jpeg"}
+}
+
+// ensureVideoThumbnail 确保已用 ffmpeg 为视频生成缩略图，与转码结果缓存在同一目录。
+// 返回缩略图的绝对路径。
+func (s *Service) ensureVideoThumbnail(srcPath string) (string, error) {
+	dstPath, err := videoCachePath(srcPath, "_thumb.jpg")
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(dstPath); err == nil {
+		return dstPath, nil
 	}
 
-	log.Info().Str("dst", dstPath).Msg("视频转码成功")
+	err = renderVideoCache(dstPath, func(tmpPath string) error {
+		// thumbnail 滤镜从开头的若干帧中选取有代表性的一帧，较短的视频也能截到画面
+		cmd := exec.Command(dat2img.FFMpegPath,
+			"-y",
+			"-i", srcPath,
+			"-vf", "thumbnail,scale=320:-2",
+			"-frames:v", "1",
+			tmpPath,
+		)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("ffmpeg 截取缩略图失败: %w, output: %s", err, string(output))
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
 	return dstPath, nil
 }
 
+// videoCacheJob 是某个缓存文件正在进行的一次生成，同一文件的并发请求等待它完成并共享结果。
+type videoCacheJob struct {
+	done chan struct{}
+	err  error
+}
+
+var videoCacheJobs = struct {
+	sync.Mutex
+	m map[string]*videoCacheJob
+}{m: make(map[string]*videoCacheJob)}
+
+// renderVideoCache 调用 render 把派生文件写到同目录下的临时文件，成功后改名为 dstPath，
+// 中途失败或进程退出不会留下被当作缓存命中的半成品。同一 dstPath 同时只生成一次。
+func renderVideoCache(dstPath string, render func(tmpPath string) error) error {
+	videoCacheJobs.Lock()
+	if job, ok := videoCacheJobs.m[dstPath]; ok {
+		videoCacheJobs.Unlock()
+		<-job.done
+		return job.err
+	}
+	job := &videoCacheJob{done: make(chan struct{})}
+	videoCacheJobs.m[dstPath] = job
+	videoCacheJobs.Unlock()
+
+	defer func() {
+		videoCacheJobs.Lock()
+		delete(videoCacheJobs.m, dstPath)
+		videoCacheJobs.Unlock()
+		close(job.done)
+	}()
+
+	// 等待期间可能已由上一轮生成完毕
+	if _, err := os.Stat(dstPath); err == nil {
+		return nil
+	}
+	job.err = renderToTemp(dstPath, render)
+	return job.err
+}
+
+func renderToTemp(dstPath string, render func(tmpPath string) error) error {
+	// 临时文件保留原扩展名，ffmpeg 依据扩展名选择输出格式
+	ext := filepath.Ext(dstPath)
+	tmp, err := os.CreateTemp(filepath.Dir(dstPath), strings.TrimSuffix(filepath.Base(dstPath), ext)+".*.tmp"+ext)
+	if err != nil {
+		return fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+
+	if err := render(tmpPath); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, dstPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("写入视频缓存失败: %w", err)
+	}
+	return nil
+}
+
+// videoCachePath 返回视频派生文件 (转码结果、缩略图) 在系统临时目录 chatlog_video_cache 下的缓存路径。
+// 文件名由源文件名和源路径的 md5 组成，不同目录下的同名视频互不冲突。
+func videoCachePath(srcPath, suffix string) (string, error) {
+	cacheDir := filepath.Join(os.TempDir(), "chatlog_video_cache")
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return "", fmt.Errorf("创建缓存目录失败: %w", err)
+	}
+	sum := md5.Sum([]byte(srcPath))
+	name := fmt.Sprintf("%s_%s%s", filepath.Base(srcPath), hex.EncodeToString(sum[:]), suffix)
+	return filepath.Join(cacheDir, name), nil
+}
+
 func (s *Service) prepareDatFile(path string) PreparedMedia {
 	b, err := os.ReadFile(path)
 	if err != nil {
@@ -7,8 +7,8 @@ import (
 )
 
 // setupMiddleware 配置 Gin 引擎所需的中间件。
-func (s *Service) setupMiddleware() {
-	s.router.Use(
+func (s *Service) setupMiddleware(router *gin.Engine) {
+	router.Use(
 		gin.LoggerWithWriter(log.Logger, "/health"),
 		recoveryMiddleware(),
 		corsMiddleware(),
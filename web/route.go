@@ -5,173 +5,204 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/afumu/wetrace/web/api"
 	"github.com/afumu/wetrace/web/middleware"
 	"github.com/gin-gonic/gin"
 )
 
-// setupRoutes 初始化所有应用程序路由。
-func (s *Service) setupRoutes() {
+// setupRoutes 为一个账号的路由初始化所有应用程序路由。
+func (s *Service) setupRoutes(router *gin.Engine, a *api.API) {
 	// 密码保护中间件
-	s.router.Use(middleware.AuthMiddleware(s.api))
+	router.Use(middleware.AuthMiddleware(a))
 
-	// API v1 路由组, 使用在 service 中初始化的处理器
-	v1 := s.router.Group("/api/v1")
+	// API v1 路由组, 使用账号的处理器
+	v1 := router.Group("/api/v1")
 	{
+		// 账号路由 (多账号)
+		v1.GET("/accounts", s.ListAccounts)
+		v1.POST("/accounts", s.AddAccount)
+		v1.POST("/accounts/switch", s.SwitchAccount)
+		v1.DELETE("/accounts/:id", s.DeleteAccount)
+
 		// 系统路由
 		system := v1.Group("/system")
 		{
-			system.GET("/status", s.api.GetSystemStatus)
-			system.POST("/decrypt", s.api.HandleEnvDecrypt)
-			system.GET("/wxkey/db", s.api.GetWeChatDbKey)
-			system.GET("/wxkey/image", s.api.GetWeChatImageKey)
-			system.GET("/detect/wechat_path", s.api.DetectWeChatInstallPath)
-			system.GET("/detect/db_path", s.api.DetectWeChatDataPath)
-			system.POST("/select_path", s.api.SelectPath)
-			system.POST("/config", s.api.UpdateConfig)
+			system.GET("/status", a.GetSystemStatus)
+			system.GET("/diagnostics", a.GetDiagnostics)
+			system.POST("/decrypt", a.HandleEnvDecrypt)
+			system.GET("/wxkey/db", a.GetWeChatDbKey)
+			system.GET("/wxkey/image", a.GetWeChatImageKey)
+			system.GET("/detect/wechat_path", a.DetectWeChatInstallPath)
+			system.GET("/detect/db_path", a.DetectWeChatDataPath)
+			system.POST("/select_path", a.SelectPath)
+			system.POST("/config", a.UpdateConfig)
 
 			// AI 配置路由 (需求3)
-			system.GET("/ai_config", s.api.GetAIConfig)
-			system.POST("/ai_config", s.api.UpdateAIConfig)
-			system.POST("/ai_config/test", s.api.TestAIConfig)
+			system.GET("/ai_config", a.GetAIConfig)
+			system.POST("/ai_config", a.UpdateAIConfig)
+			system.POST("/ai_config/test", a.TestAIConfig)
 
 			// AI 提示词配置路由
-			system.GET("/ai_prompts", s.api.GetAIPrompts)
-			system.POST("/ai_prompts", s.api.UpdateAIPrompts)
+			system.GET("/ai_prompts", a.GetAIPrompts)
+			system.POST("/ai_prompts", a.UpdateAIPrompts)
 
 			// 密码保护路由 (需求6)
-			system.GET("/password/status", s.api.GetPasswordStatus)
-			system.POST("/password/set", s.api.SetPassword)
-			system.POST("/password/verify", s.api.VerifyPassword)
-			system.POST("/password/disable", s.api.DisablePassword)
+			system.GET("/password/status", a.GetPasswordStatus)
+			system.POST("/password/set", a.SetPassword)
+			system.POST("/password/verify", a.VerifyPassword)
+			system.POST("/password/disable", a.DisablePassword)
 
 			// 合规提示路由 (需求9)
-			system.GET("/compliance", s.api.GetCompliance)
-			system.POST("/compliance/agree", s.api.AgreeCompliance)
+			system.GET("/compliance", a.GetCompliance)
+			system.POST("/compliance/agree", a.AgreeCompliance)
 
 			// 自动同步路由 (需求5)
-			system.GET("/sync_config", s.api.GetSyncConfig)
-			system.POST("/sync_config", s.api.UpdateSyncConfig)
-			system.POST("/sync", s.api.TriggerSync)
-			system.GET("/sync_status", s.api.GetSyncStatus)
+			system.GET("/sync_config", a.GetSyncConfig)
+			system.POST("/sync_config", a.UpdateSyncConfig)
+			system.POST("/sync", a.TriggerSync)
+			system.GET("/sync_status", a.GetSyncStatus)
 
 			// 自动备份路由 (需求8)
-			system.GET("/backup_config", s.api.GetBackupConfig)
-			system.POST("/backup_config", s.api.UpdateBackupConfig)
-			system.POST("/backup/run", s.api.RunBackup)
-			system.GET("/backup/history", s.api.GetBackupHistory)
+			system.GET("/backup_config", a.GetBackupConfig)
+			system.POST("/backup_config", a.UpdateBackupConfig)
+			system.POST("/backup/run", a.RunBackup)
+			system.GET("/backup/history", a.GetBackupHistory)
 
 			// TTS 语音转文字配置路由
-			system.GET("/tts_config", s.api.GetTTSConfig)
-			system.POST("/tts_config", s.api.UpdateTTSConfig)
+			system.GET("/tts_config", a.GetTTSConfig)
+			system.POST("/tts_config", a.UpdateTTSConfig)
 		}
 
 		// 会话路由
-		v1.GET("/sessions", s.api.GetSessions)
-		v1.DELETE("/sessions/:id", s.api.DeleteSession)
+		v1.GET("/sessions", a.GetSessions)
+		v1.DELETE("/sessions/:id", a.DeleteSession)
 
 		// 总览路由
-		v1.GET("/dashboard", s.api.GetDashboard)
+		v1.GET("/dashboard", a.GetDashboard)
 
 		// 消息路由
-		v1.GET("/messages", s.api.GetMessages)
+		v1.GET("/messages", a.GetMessages)
+		v1.GET("/messages/revoked", a.GetRevokedMessages)
+		v1.GET("/messages/thread", a.GetThread)
+		v1.GET("/messages/forward", a.GetForwardRecord)
 
 		// 联系人路由
-		v1.GET("/contacts", s.api.GetContacts)
-		v1.GET("/contacts/need-contact", s.api.GetNeedContactList)
-		v1.GET("/contacts/:id", s.api.GetContactByID)
-		v1.GET("/contacts/export", s.api.ExportContacts)
+		v1.GET("/contacts", a.GetContacts)
+		v1.GET("/contacts/need-contact", a.GetNeedContactList)
+		v1.GET("/contacts/:id", a.GetContactByID)
+		v1.GET("/contacts/export", a.ExportContacts)
+		v1.GET("/labels", a.GetLabels)
 
 		// 群聊路由
-		v1.GET("/chatrooms", s.api.GetChatRooms)
-		v1.GET("/chatrooms/:id", s.api.GetChatRoomByID)
+		v1.GET("/chatrooms", a.GetChatRooms)
+		v1.GET("/chatrooms/:id", a.GetChatRoomByID)
+		v1.GET("/chatrooms/:id/membership_history", a.GetMembershipHistory)
 
 		// 媒体路由
-		v1.GET("/media/images", s.api.GetImageList)
-		v1.GET("/media/:type/:key", s.api.GetMedia)
-		v1.GET("/media/emoji", s.api.GetEmoji)
-		v1.POST("/media/cache/start", s.api.HandleStartCache)
-		v1.GET("/media/cache/status", s.api.GetCacheStatus)
-		v1.POST("/media/voice/transcribe", s.api.TranscribeVoice)
+		v1.GET("/media/images", a.GetImageList)
+		v1.GET("/media/videos", a.GetVideoList)
+		v1.GET("/media/:type/:key", a.GetMedia)
+		v1.GET("/media/emoji", a.GetEmoji)
+		v1.POST("/media/cache/start", a.HandleStartCache)
+		v1.GET("/media/cache/status", a.GetCacheStatus)
+		v1.POST("/media/voice/transcribe", a.TranscribeVoice)
 
 		// 导出路由
-		v1.GET("/export/chat", s.api.ExportChat)
-		v1.GET("/export/forensic", s.api.ExportForensic)
-		v1.GET("/export/voices", s.api.ExportVoices)
-		v1.POST("/export/voices", s.api.ExportVoices)
+		v1.GET("/export/chat", a.ExportChat)
+		v1.GET("/export/forensic", a.ExportForensic)
+		v1.GET("/export/voices", a.ExportVoices)
+		v1.POST("/export/voices", a.ExportVoices)
+
+		// 账目路由
+		v1.GET("/ledger", a.GetLedger)
+		v1.GET("/ledger/export", a.ExportLedger)
+
+		// 位置路由
+		v1.GET("/locations", a.GetLocations)
+		v1.GET("/locations/export", a.ExportLocations)
+
+		// 链接库路由
+		v1.GET("/links", a.GetLinks)
+		v1.GET("/links/export", a.ExportLinks)
+
+		// 文件附件路由
+		v1.GET("/files", a.GetFiles)
+		v1.POST("/files/download", a.DownloadFiles)
 
 		// 搜索路由
 		searchGroup := v1.Group("/search")
 		{
-			searchGroup.GET("", s.api.Search)
-			searchGroup.GET("/context", s.api.SearchContext)
+			searchGroup.GET("", a.Search)
+			searchGroup.GET("/context", a.SearchContext)
 		}
 
 		// 年度报告路由
 		reportGroup := v1.Group("/report")
 		{
-			reportGroup.GET("/annual", s.api.GetAnnualReport)
+			reportGroup.GET("/annual", a.GetAnnualReport)
 		}
 
 		// AI 路由
 		aiGroup := v1.Group("/ai")
 		{
-			aiGroup.POST("/summarize", s.api.AISummarize)
-			aiGroup.POST("/simulate", s.api.AISimulate)
-			aiGroup.POST("/sentiment", s.api.AISentiment)
-			aiGroup.POST("/summary", s.api.AISummary)
-			aiGroup.POST("/todos", s.api.AIExtractTodos)
-			aiGroup.POST("/extract", s.api.AIExtractInfo)
-			aiGroup.POST("/voice2text", s.api.AIVoice2Text)
+			aiGroup.POST("/summarize", a.AISummarize)
+			aiGroup.POST("/simulate", a.AISimulate)
+			aiGroup.POST("/sentiment", a.AISentiment)
+			aiGroup.POST("/summary", a.AISummary)
+			aiGroup.POST("/todos", a.AIExtractTodos)
+			aiGroup.POST("/extract", a.AIExtractInfo)
+			aiGroup.POST("/voice2text", a.AIVoice2Text)
 		}
 
 		// 分析路由
 		analysisGroup := v1.Group("/analysis")
 		{
-			analysisGroup.GET("/personal/top_contacts", s.api.GetPersonalTopContacts)
-			analysisGroup.GET("/hourly/:id", s.api.GetHourlyActivity)
-			analysisGroup.GET("/daily/:id", s.api.GetDailyActivity)
-			analysisGroup.GET("/weekday/:id", s.api.GetWeekdayActivity)
-			analysisGroup.GET("/monthly/:id", s.api.GetMonthlyActivity)
-			analysisGroup.GET("/type_distribution/:id", s.api.GetMessageTypeDistribution)
-			analysisGroup.GET("/member_activity/:id", s.api.GetMemberActivity)
-			analysisGroup.GET("/repeat/:id", s.api.GetRepeatAnalysis)
-			analysisGroup.GET("/wordcloud/global", s.api.GetWordCloudGlobal)
-			analysisGroup.GET("/wordcloud/:id", s.api.GetWordCloud)
+			analysisGroup.GET("/personal/top_contacts", a.GetPersonalTopContacts)
+			analysisGroup.GET("/hourly/:id", a.GetHourlyActivity)
+			analysisGroup.GET("/daily/:id", a.GetDailyActivity)
+			analysisGroup.GET("/weekday/:id", a.GetWeekdayActivity)
+			analysisGroup.GET("/monthly/:id", a.GetMonthlyActivity)
+			analysisGroup.GET("/type_distribution/:id", a.GetMessageTypeDistribution)
+			analysisGroup.GET("/member_activity/:id", a.GetMemberActivity)
+			analysisGroup.GET("/repeat/:id", a.GetRepeatAnalysis)
+			analysisGroup.GET("/media_spread", a.GetMediaSpread)
+			analysisGroup.GET("/wordcloud/global", a.GetWordCloudGlobal)
+			analysisGroup.GET("/wordcloud/:id", a.GetWordCloud)
 		}
 
 		// 回放路由 (需求16)
-		v1.GET("/messages/replay", s.api.GetReplayMessages)
+		v1.GET("/messages/replay", a.GetReplayMessages)
 
 		// 监控配置路由 (需求14+15)
 		monitorGroup := v1.Group("/monitor")
 		{
-			monitorGroup.GET("/configs", s.api.GetMonitorConfigs)
-			monitorGroup.POST("/configs", s.api.CreateMonitorConfig)
-			monitorGroup.PUT("/configs/:id", s.api.UpdateMonitorConfig)
-			monitorGroup.DELETE("/configs/:id", s.api.DeleteMonitorConfig)
-			monitorGroup.POST("/test", s.api.TestMonitorPush)
+			monitorGroup.GET("/configs", a.GetMonitorConfigs)
+			monitorGroup.POST("/configs", a.CreateMonitorConfig)
+			monitorGroup.PUT("/configs/:id", a.UpdateMonitorConfig)
+			monitorGroup.DELETE("/configs/:id", a.DeleteMonitorConfig)
+			monitorGroup.POST("/test", a.TestMonitorPush)
 		}
 
 		// 飞书配置路由 (需求15)
 		feishuGroup := v1.Group("/feishu")
 		{
-			feishuGroup.GET("/config", s.api.GetFeishuConfig)
-			feishuGroup.PUT("/config", s.api.UpdateFeishuConfig)
-			feishuGroup.POST("/test", s.api.TestFeishuBot)
-			feishuGroup.POST("/test_bitable", s.api.TestFeishuBitable)
+			feishuGroup.GET("/config", a.GetFeishuConfig)
+			feishuGroup.PUT("/config", a.UpdateFeishuConfig)
+			feishuGroup.POST("/test", a.TestFeishuBot)
+			feishuGroup.POST("/test_bitable", a.TestFeishuBitable)
 		}
 	}
 
 	// 健康检查
-	s.router.GET("/health", func(c *gin.Context) {
+	router.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"status": "ok"})
 	})
 
 	// 静态文件服务 (UI)
 	if s.staticFS != nil {
-		s.router.StaticFS("/assets", http.FS(s.staticFS))
+		router.StaticFS("/assets", http.FS(s.staticFS))
 		// 处理 SPA 的 fallback，除了 /api 开头的路径外，都返回 index.html
-		s.router.NoRoute(func(c *gin.Context) {
+		router.NoRoute(func(c *gin.Context) {
 			if strings.HasPrefix(c.Request.URL.Path, "/api") {
 				c.JSON(http.StatusNotFound, gin.H{"error": "API route not found"})
 				return
@@ -2,51 +2,88 @@ package web
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io/fs"
 	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/afumu/wetrace/internal/account"
 	"github.com/afumu/wetrace/store"
 	"github.com/afumu/wetrace/web/api"
 	"github.com/afumu/wetrace/web/media"
+	"github.com/afumu/wetrace/web/transport"
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog/log"
 )
 
+// AccountHeader 与 AccountCookie 指定请求使用的账号。
+// 两者都未指定时使用当前账号 (最近一次切换到的账号)，并以 Cookie 把浏览器会话固定在该账号上，
+// 之后其他会话切换账号不会影响这个会话。
+const (
+	AccountHeader = "X-Wetrace-Account"
+	AccountCookie = "wetrace_account"
+)
+
+// accountKey 是请求 context 中保存所用账号 ID 的键
+type accountKey struct{}
+
 // Service 定义了 web 服务。
+// 每个账号有独立的 Store、媒体服务、API 处理器和路由，请求交给其指定的账号处理。
 type Service struct {
-	store    store.Store
-	router   *gin.Engine
 	server   *http.Server
 	conf     *Config
-	api      *api.API
-	media    *media.Service
 	staticFS fs.FS
+	accounts *account.Registry
+
+	mu       sync.Mutex // 保护 runtimes
+	runtimes map[string]*accountRuntime
+	active   atomic.Pointer[accountRuntime] // 当前账号：请求未指定账号时使用
+}
+
+// accountRuntime 是一个已打开账号的 Store、媒体服务、API 处理器和路由
+type accountRuntime struct {
+	id     string
+	store  store.Store
+	media  *media.Service
+	api    *api.API
+	router *gin.Engine
 }
 
 // Config 保存 web 服务的配置。
 type Config struct {
-	ListenAddr      string
-	DataDir         string
-	ImageKey        string
-	XorKey          string
-	WechatDbSrcPath string
-	WechatDbKey     string
-	WxKeyDllPath    string
-	WechatPath      string
-	WechatDataPath  string
-	AIEnabled       bool
-	AIProvider      string
-	AIAPIKey        string
-	AIBaseURL       string
-	AIModel         string
+	ListenAddr       string
+	DataDir          string
+	ImageKey         string
+	XorKey           string
+	WechatDbSrcPath  string
+	WechatDbKey      string
+	WxKeyDllPath     string
+	WechatPath       string
+	WechatDataPath   string
+	AIEnabled        bool
+	AIProvider       string
+	AIAPIKey         string
+	AIBaseURL        string
+	AIModel          string
+	ShardConcurrency int // 打开其他账号的 Store 时使用的分片并发数
 }
 
 // NewService 创建一个新的 web 服务。
-func NewService(store store.Store, conf *Config, staticFS fs.FS) *Service {
+// store 为默认账号 (由 .env 配置) 的 Store；上次使用的是其他账号时启动后切换过去。
+func NewService(store store.Store, conf *Config, staticFS fs.FS, accounts *account.Registry) *Service {
 	gin.SetMode(gin.ReleaseMode)
-	router := gin.New()
+
+	s := &Service{
+		conf:     conf,
+		staticFS: staticFS,
+		accounts: accounts,
+		runtimes: make(map[string]*accountRuntime),
+	}
 
 	mediaService := media.NewService(conf.DataDir, conf.ImageKey, conf.XorKey, conf.WechatDbSrcPath)
 
@@ -68,27 +105,180 @@ func NewService(store store.Store, conf *Config, staticFS fs.FS) *Service {
 	}
 
 	apiHandler := api.NewAPI(store, mediaService, apiConf, staticFS)
+	apiHandler.Accounts = accounts
 
-	s := &Service{
-		store:    store,
-		router:   router,
-		conf:     conf,
-		api:      apiHandler,
-		media:    mediaService,
-		staticFS: staticFS,
-	}
+	def := s.newRuntime(account.DefaultID, store, mediaService, apiHandler)
+	s.runtimes[account.DefaultID] = def
+	s.active.Store(def)
 
-	s.setupMiddleware()
-	s.setupRoutes()
+	if id := accounts.Active().ID; id != account.DefaultID {
+		if _, err := s.switchAccount(id); err != nil {
+			log.Warn().Err(err).Str("account", id).Msg("打开上次使用的账号失败，使用默认账号")
+		}
+	}
 
 	return s
 }
 
+// newRuntime 为账号创建路由并注册中间件和路由
+func (s *Service) newRuntime(id string, st store.Store, m *media.Service, a *api.API) *accountRuntime {
+	rt := &accountRuntime{
+		id:     id,
+		store:  st,
+		media:  m,
+		api:    a,
+		router: gin.New(),
+	}
+	s.setupMiddleware(rt.router)
+	s.setupRoutes(rt.router, rt.api)
+	return rt
+}
+
+// openAccount 打开账号的 Store 并创建其媒体服务和 API 处理器
+func (s *Service) openAccount(acc account.Account) (*accountRuntime, error) {
+	if err := os.MkdirAll(acc.WorkDir, 0755); err != nil {
+		return nil, fmt.Errorf("创建工作目录失败: %w", err)
+	}
+	st, err := store.NewStore(acc.WorkDir)
+	if err != nil {
+		return nil, fmt.Errorf("初始化 store 失败: %w", err)
+	}
+	if s.conf.ShardConcurrency > 0 {
+		st.SetShardConcurrency(s.conf.ShardConcurrency)
+	}
+
+	mediaService := media.NewService(acc.WorkDir, acc.ImageKey, acc.XorKey, acc.WechatDbSrcPath)
+	apiConf := &api.Config{
+		DataDir:         acc.WorkDir,
+		WechatDbSrcPath: acc.WechatDbSrcPath,
+		WechatDbKey:     acc.WechatDbKey,
+		ImageKey:        acc.ImageKey,
+		XorKey:          acc.XorKey,
+	}
+	def := s.runtimes[account.DefaultID]
+	apiHandler := def.api.ForAccount(st, mediaService, apiConf, acc.ID, s.accounts)
+
+	log.Info().Str("account", acc.ID).Str("work_dir", acc.WorkDir).Msg("已打开账号")
+	return s.newRuntime(acc.ID, st, mediaService, apiHandler), nil
+}
+
+// runtimeFor 返回账号的运行时，账号尚未打开时先打开
+func (s *Service) runtimeFor(id string) (*accountRuntime, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.runtimeForLocked(id)
+}
+
+// runtimeForLocked 与 runtimeFor 相同，调用方需持有 s.mu
+func (s *Service) runtimeForLocked(id string) (*accountRuntime, error) {
+	if rt, ok := s.runtimes[id]; ok {
+		return rt, nil
+	}
+	acc, err := s.accounts.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	rt, err := s.openAccount(acc)
+	if err != nil {
+		return nil, err
+	}
+	s.runtimes[id] = rt
+	return rt, nil
+}
+
+// switchAccount 将账号设为当前账号，账号尚未打开时先打开
+func (s *Service) switchAccount(id string) (*accountRuntime, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rt, err := s.runtimeForLocked(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.accounts.SetActive(id); err != nil {
+		return nil, err
+	}
+	s.active.Store(rt)
+	log.Info().Str("account", id).Msg("已切换当前账号")
+	return rt, nil
+}
+
+// removeAccount 删除账号并关闭其 Store 和调度器，不删除工作目录中的数据
+func (s *Service) removeAccount(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.accounts.Remove(id); err != nil {
+		return err
+	}
+	if rt, ok := s.runtimes[id]; ok {
+		delete(s.runtimes, id)
+		rt.close()
+	}
+	return nil
+}
+
+// close 停止账号的调度器并关闭 Store
+func (rt *accountRuntime) close() {
+	rt.api.Close()
+	if err := rt.store.Close(); err != nil {
+		log.Warn().Err(err).Str("account", rt.id).Msg("关闭账号 store 失败")
+	}
+}
+
+// ServeHTTP 将请求交给其指定的账号处理：先看 AccountHeader，再看 AccountCookie，都没有时使用当前账号。
+// Header 指定的账号不存在时返回 404；Cookie 中的账号已被删除时改用当前账号。
+func (s *Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var rt *accountRuntime
+	if id := r.Header.Get(AccountHeader); id != "" {
+		var err error
+		if rt, err = s.runtimeFor(id); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, account.ErrNotFound) {
+				status = http.StatusNotFound
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.WriteHeader(status)
+			_ = json.NewEncoder(w).Encode(transport.ErrorResponse{Error: transport.APIError{Code: status, Message: err.Error()}})
+			return
+		}
+	} else if c, err := r.Cookie(AccountCookie); err == nil && c.Value != "" {
+		if rt, err = s.runtimeFor(c.Value); err != nil {
+			log.Debug().Err(err).Str("account", c.Value).Msg("Cookie 中的账号不可用，使用当前账号")
+			rt = nil
+		}
+	}
+	if rt == nil {
+		rt = s.active.Load()
+		setAccountCookie(w, rt.id)
+	}
+
+	r = r.WithContext(context.WithValue(r.Context(), accountKey{}, rt.id))
+	rt.router.ServeHTTP(w, r)
+}
+
+// setAccountCookie 把浏览器会话固定在账号上
+func setAccountCookie(w http.ResponseWriter, id string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     AccountCookie,
+		Value:    id,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// requestAccount 返回处理请求的账号 ID
+func requestAccount(r *http.Request) string {
+	id, _ := r.Context().Value(accountKey{}).(string)
+	return id
+}
+
 // Start 开始提供 web 应用服务。
 func (s *Service) Start() error {
 	s.server = &http.Server{
 		Addr:    s.conf.ListenAddr,
-		Handler: s.router,
+		Handler: s,
 	}
 
 	log.Info().Msg(fmt.Sprintf("在 %s 上启动 web 服务", s.conf.ListenAddr))
@@ -102,7 +292,7 @@ func (s *Service) Start() error {
 	return nil
 }
 
-// Stop 优雅地关闭 web 服务器。
+// Stop 优雅地关闭 web 服务器，并关闭默认账号以外已打开的账号。
 func (s *Service) Stop() error {
 	if s.server == nil {
 		return nil
@@ -116,10 +306,20 @@ func (s *Service) Stop() error {
 		return err
 	}
 
+	// 默认账号的 Store 由调用方创建和关闭
+	s.mu.Lock()
+	for id, rt := range s.runtimes {
+		if id != account.DefaultID {
+			rt.close()
+		}
+	}
+	s.mu.Unlock()
+
 	log.Info().Msg("Web 服务已停止")
 	return nil
 }
 
+// GetRouter 返回当前账号的路由。
 func (s *Service) GetRouter() *gin.Engine {
-	return s.router
+	return s.active.Load().router
 }
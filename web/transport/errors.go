@@ -14,9 +14,10 @@ type ErrorResponse struct {
 
 // APIError 表示返回给客户端的详细错误信息。
 type APIError struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
-	PayURL  string `json:"pay_url,omitempty"`
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	PayURL  string      `json:"pay_url,omitempty"`
+	Details interface{} `json:"details,omitempty"`
 }
 
 // SendError 使用给定的 HTTP 状态码和标准化的 JSON 错误载荷进行响应。
@@ -47,6 +48,18 @@ func BadRequest(c *gin.Context, message string) {
 	SendError(c, http.StatusBadRequest, message)
 }
 
+// BadRequestWithDetails 发送一个 400 Bad Request 错误，并附带结构化的错误详情。
+func BadRequestWithDetails(c *gin.Context, message string, details interface{}) {
+	c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{
+		Success: false,
+		Error: APIError{
+			Code:    http.StatusBadRequest,
+			Message: message,
+			Details: details,
+		},
+	})
+}
+
 // NotFound 发送一个 404 Not Found 错误。
 func NotFound(c *gin.Context, message string) {
 	SendError(c, http.StatusNotFound, message)
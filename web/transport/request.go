@@ -7,6 +7,8 @@ type PaginationQuery struct {
 }
 
 // KeywordQuery 定义了一个通用的搜索参数。
+// Keyword 按账号或名称精确查找；Search 按名称包含或拼音全拼/首字母模糊搜索。
 type KeywordQuery struct {
 	Keyword string `form:"keyword"`
+	Search  string `form:"search"`
 }